@@ -1,10 +1,23 @@
 package conf
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/freddy33/qsm-go/m3util"
 	_ "github.com/joho/godotenv/autoload"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+/*
+Backend configuration built in layers, each one overriding the values set by the previous:
+defaults, then the JSON or YAML config file, then the environment variables, then the command line flags.
+*/
 type Config struct {
 	DBHost     string
 	DBPort     int
@@ -13,24 +26,310 @@ type Config struct {
 	DBName     string
 
 	ServerPort string
+	// Origins allowed by CORS, empty means the ones of the auth file, or none
+	AllowedOrigins []string
+	// Number of go routines used to grow the path contexts
+	NbParallelProcesses int
+	DefaultEnvId        m3util.QsmEnvID
+	// Max time to wait for the running computations on shutdown
+	ShutdownTimeout time.Duration
+
+	// The config file used, empty if none
+	ConfigFile string
+}
+
+// Same keys as the dbconn files created by the scripts from db-template.json
+type fileConfig struct {
+	Host           string   `json:"host" yaml:"host"`
+	Port           int      `json:"port" yaml:"port"`
+	User           string   `json:"user" yaml:"user"`
+	Password       string   `json:"password" yaml:"password"`
+	DbName         string   `json:"dbName" yaml:"dbName"`
+	ServerPort     string   `json:"serverPort" yaml:"serverPort"`
+	AllowedOrigins []string `json:"allowedOrigins" yaml:"allowedOrigins"`
+	Parallelism    int      `json:"parallelism" yaml:"parallelism"`
+	DefaultEnv     int      `json:"defaultEnv" yaml:"defaultEnv"`
+	// Go duration like 30s or 2m
+	ShutdownTimeout string `json:"shutdownTimeout" yaml:"shutdownTimeout"`
+}
+
+const (
+	ConfigFileKey      = "QSM_CONFIG_FILE"
+	CorsOriginsKey     = "QSM_CORS_ORIGINS"
+	ParallelismKey     = "QSM_PARALLELISM"
+	ShutdownTimeoutKey = "QSM_SHUTDOWN_TIMEOUT"
+)
+
+// The flags with a value read from the command line, see ReadArgs
+var cliFlags = map[string]bool{
+	"-config":           true,
+	"-db-host":          true,
+	"-db-port":          true,
+	"-db-user":          true,
+	"-db-password":      true,
+	"-db-name":          true,
+	"-port":             true,
+	"-cors":             true,
+	"-parallel":         true,
+	"-env":              true,
+	"-shutdown-timeout": true,
+}
+
+var cliValues = make(map[string]string)
+
+/*
+Keep the config flags of the command line for all the later NewConfig calls.
+Returns the args not used, and an error if a flag has no value.
+*/
+func ReadArgs(args []string) ([]string, error) {
+	others := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if !cliFlags[args[i]] {
+			others = append(others, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return others, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "flag %s needs a value", args[i])
+		}
+		cliValues[args[i]] = args[i+1]
+		i++
+	}
+	return others, nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		DBHost:              "localhost",
+		DBPort:              54321,
+		ServerPort:          "8063",
+		NbParallelProcesses: 8,
+		DefaultEnvId:        m3util.MainEnv,
+		ShutdownTimeout:     30 * time.Second,
+	}
+}
+
+/*
+The file from -config, QSM_CONFIG_FILE or the dbconn<env>.json created by the scripts in backend/conf,
+also found when running from the backend dir like the scripts do.
+Empty if none is found.
+*/
+func findConfigFile() string {
+	if p := cliValues["-config"]; p != "" {
+		return p
+	}
+	if p := os.Getenv(ConfigFileKey); p != "" {
+		return p
+	}
+	envNumber := cliValues["-env"]
+	if envNumber == "" {
+		envNumber = os.Getenv(m3util.QsmEnvNumberKey)
+	}
+	if envNumber == "" {
+		envNumber = m3util.MainEnv.String()
+	}
+	confDir, b := m3util.FindConfDir()
+	if !b {
+		return ""
+	}
+	p := filepath.Join(confDir, "dbconn"+envNumber+".json")
+	if _, err := os.Stat(p); err != nil {
+		return ""
+	}
+	return p
 }
 
-func NewDBConfig() Config {
-	config := Config{
-		DBHost:     m3util.GetCompulsoryEnv("DB_HOST"),
-		DBPort:     m3util.GetCompulsoryEnvInt("DB_PORT"),
-		DBUser:     m3util.GetCompulsoryEnv("DB_USER"),
-		DBPassword: m3util.GetCompulsoryEnv("DB_PASSWORD"),
-		DBName:     m3util.GetCompulsoryEnv("DB_NAME"),
+func (c *Config) applyFile(filePath string) error {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return m3util.MakeWrapQsmCodeErrorf(m3util.ErrBadRequest, err, "could not read config file %s due to %v", filePath, err)
+	}
+	fc := fileConfig{}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if ext == ".yaml" || ext == ".yml" {
+		err = yaml.Unmarshal(data, &fc)
+	} else {
+		err = json.Unmarshal(data, &fc)
+	}
+	if err != nil {
+		return m3util.MakeWrapQsmCodeErrorf(m3util.ErrBadRequest, err, "could not parse config file %s due to %v", filePath, err)
+	}
+	c.ConfigFile = filePath
+	setString(&c.DBHost, fc.Host)
+	setInt(&c.DBPort, fc.Port)
+	setString(&c.DBUser, fc.User)
+	setString(&c.DBPassword, fc.Password)
+	setString(&c.DBName, fc.DbName)
+	setString(&c.ServerPort, fc.ServerPort)
+	if len(fc.AllowedOrigins) > 0 {
+		c.AllowedOrigins = fc.AllowedOrigins
+	}
+	setInt(&c.NbParallelProcesses, fc.Parallelism)
+	if fc.DefaultEnv != 0 {
+		c.DefaultEnvId = m3util.QsmEnvID(fc.DefaultEnv)
 	}
+	return setDurationValue(&c.ShutdownTimeout, "config file "+filePath, "shutdownTimeout", fc.ShutdownTimeout, nil)
+}
+
+// Override with the values found by lookup using the env var keys, source is used in errors
+func (c *Config) applyValues(source string, lookup func(key string) string) error {
+	var err error
+	setString(&c.DBHost, lookup("DB_HOST"))
+	err = setIntValue(&c.DBPort, source, "DB_PORT", lookup("DB_PORT"), err)
+	setString(&c.DBUser, lookup("DB_USER"))
+	setString(&c.DBPassword, lookup("DB_PASSWORD"))
+	setString(&c.DBName, lookup("DB_NAME"))
+	setString(&c.ServerPort, lookup("SERVER_PORT"))
+	origins := lookup(CorsOriginsKey)
+	if origins != "" {
+		c.AllowedOrigins = strings.Split(origins, ",")
+	}
+	err = setIntValue(&c.NbParallelProcesses, source, ParallelismKey, lookup(ParallelismKey), err)
+	envId := int(c.DefaultEnvId)
+	err = setIntValue(&envId, source, m3util.QsmEnvNumberKey, lookup(m3util.QsmEnvNumberKey), err)
+	c.DefaultEnvId = m3util.QsmEnvID(envId)
+	err = setDurationValue(&c.ShutdownTimeout, source, ShutdownTimeoutKey, lookup(ShutdownTimeoutKey), err)
+	return err
+}
 
-	return config
+// Map of the env var keys to the command line flags
+var flagsForKeys = map[string]string{
+	"DB_HOST":              "-db-host",
+	"DB_PORT":              "-db-port",
+	"DB_USER":              "-db-user",
+	"DB_PASSWORD":          "-db-password",
+	"DB_NAME":              "-db-name",
+	"SERVER_PORT":          "-port",
+	CorsOriginsKey:         "-cors",
+	ParallelismKey:         "-parallel",
+	m3util.QsmEnvNumberKey: "-env",
+	ShutdownTimeoutKey:     "-shutdown-timeout",
 }
 
-func NewServerConfig() Config {
-	config := Config{
-		ServerPort: m3util.GetCompulsoryEnv("SERVER_PORT"),
+func setString(field *string, value string) {
+	if value != "" {
+		*field = value
 	}
+}
+
+func setInt(field *int, value int) {
+	if value != 0 {
+		*field = value
+	}
+}
 
-	return config
+// Keep the first error found
+func setIntValue(field *int, source string, key string, value string, previousErr error) error {
+	if value == "" {
+		return previousErr
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		if previousErr != nil {
+			return previousErr
+		}
+		return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "%s %s=%q is not an integer", source, key, value)
+	}
+	*field = intValue
+	return previousErr
+}
+
+// Keep the first error found
+func setDurationValue(field *time.Duration, source string, key string, value string, previousErr error) error {
+	if value == "" {
+		return previousErr
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		if previousErr != nil {
+			return previousErr
+		}
+		return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "%s %s=%q is not a duration", source, key, value)
+	}
+	*field = d
+	return previousErr
+}
+
+// Build the configuration from all the layers without validation
+func NewConfig() (Config, error) {
+	c := defaultConfig()
+	filePath := findConfigFile()
+	if filePath != "" {
+		err := c.applyFile(filePath)
+		if err != nil {
+			return c, err
+		}
+	}
+	err := c.applyValues("env var", os.Getenv)
+	if err != nil {
+		return c, err
+	}
+	err = c.applyValues("flag", func(key string) string {
+		return cliValues[flagsForKeys[key]]
+	})
+	return c, err
+}
+
+func (c Config) ValidateDB() error {
+	problems := make([]string, 0)
+	if c.DBHost == "" {
+		problems = append(problems, "missing DB host (DB_HOST)")
+	}
+	if c.DBPort <= 0 || c.DBPort > 65535 {
+		problems = append(problems, fmt.Sprintf("invalid DB port %d (DB_PORT)", c.DBPort))
+	}
+	if c.DBUser == "" {
+		problems = append(problems, "missing DB user (DB_USER)")
+	}
+	if c.DBPassword == "" {
+		problems = append(problems, "missing DB password (DB_PASSWORD)")
+	}
+	if c.DBName == "" {
+		problems = append(problems, "missing DB name (DB_NAME)")
+	}
+	return c.makeValidationError(problems)
+}
+
+func (c Config) ValidateServer() error {
+	problems := make([]string, 0)
+	port, err := strconv.Atoi(c.ServerPort)
+	if err != nil || port <= 0 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("invalid server port %q (SERVER_PORT)", c.ServerPort))
+	}
+	if c.NbParallelProcesses < 1 {
+		problems = append(problems, fmt.Sprintf("parallelism %d should be at least 1 (%s)", c.NbParallelProcesses, ParallelismKey))
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, fmt.Sprintf("shutdown timeout %v should be positive (%s)", c.ShutdownTimeout, ShutdownTimeoutKey))
+	}
+	if c.DefaultEnvId <= m3util.NoEnv {
+		problems = append(problems, fmt.Sprintf("invalid default env %d (%s)", c.DefaultEnvId, m3util.QsmEnvNumberKey))
+	}
+	return c.makeValidationError(problems)
+}
+
+func (c Config) makeValidationError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	from := "defaults and env vars"
+	if c.ConfigFile != "" {
+		from = "config file " + c.ConfigFile + " and env vars"
+	}
+	return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "invalid configuration from %s: %s", from, strings.Join(problems, ", "))
+}
+
+func NewDBConfig() (Config, error) {
+	c, err := NewConfig()
+	if err != nil {
+		return c, err
+	}
+	return c, c.ValidateDB()
+}
+
+func NewServerConfig() (Config, error) {
+	c, err := NewConfig()
+	if err != nil {
+		return c, err
+	}
+	return c, c.ValidateServer()
 }
@@ -0,0 +1,160 @@
+package conf
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var configEnvKeys = []string{ConfigFileKey, "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME",
+	"SERVER_PORT", CorsOriginsKey, ParallelismKey, m3util.QsmEnvNumberKey, ShutdownTimeoutKey}
+
+// Clear the config env vars and flags, and restore them at the end of the test
+func clearConfigEnv(t *testing.T) {
+	saved := make(map[string]string)
+	for _, key := range configEnvKeys {
+		value, ok := os.LookupEnv(key)
+		if ok {
+			saved[key] = value
+		}
+		assert.NoError(t, os.Unsetenv(key))
+	}
+	cliValues = make(map[string]string)
+	t.Cleanup(func() {
+		for _, key := range configEnvKeys {
+			value, ok := saved[key]
+			if ok {
+				_ = os.Setenv(key, value)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		}
+		cliValues = make(map[string]string)
+	})
+}
+
+func TestConfigLayers(t *testing.T) {
+	clearConfigEnv(t)
+
+	c, err := NewConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, defaultConfig(), c)
+	err = c.ValidateDB()
+	assert.Error(t, err)
+	assert.Equal(t, m3util.ErrBadRequest, m3util.GetQsmErrorCode(err))
+	assert.Contains(t, err.Error(), "missing DB user")
+	assert.NoError(t, c.ValidateServer())
+
+	assert.NoError(t, os.Setenv(ConfigFileKey, "db-test.json"))
+	c, err = NewDBConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "hostTest", c.DBHost)
+	assert.Equal(t, 1234, c.DBPort)
+	assert.Equal(t, "userTest", c.DBUser)
+	assert.Equal(t, "dbNameTest", c.DBName)
+	assert.Equal(t, "db-test.json", c.ConfigFile)
+
+	assert.NoError(t, os.Setenv("DB_PORT", "5432"))
+	assert.NoError(t, os.Setenv(CorsOriginsKey, "http://a,http://b"))
+	others, err := ReadArgs([]string{"server", "-db-host", "flagHost", "-parallel", "3", "gentxt"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"server", "gentxt"}, others)
+	c, err = NewServerConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "flagHost", c.DBHost)
+	assert.Equal(t, 5432, c.DBPort)
+	assert.Equal(t, "userTest", c.DBUser)
+	assert.Equal(t, []string{"http://a", "http://b"}, c.AllowedOrigins)
+	assert.Equal(t, 3, c.NbParallelProcesses)
+	assert.Equal(t, 30*time.Second, c.ShutdownTimeout)
+
+	assert.NoError(t, os.Setenv(ShutdownTimeoutKey, "2m"))
+	_, err = ReadArgs([]string{"-shutdown-timeout", "45s"})
+	assert.NoError(t, err)
+	c, err = NewServerConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 45*time.Second, c.ShutdownTimeout)
+	_, err = ReadArgs([]string{"-shutdown-timeout", "0s"})
+	assert.NoError(t, err)
+	_, err = NewServerConfig()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "shutdown timeout")
+	_, err = ReadArgs([]string{"-shutdown-timeout", "soon"})
+	assert.NoError(t, err)
+	_, err = NewConfig()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ShutdownTimeoutKey)
+	delete(cliValues, "-shutdown-timeout")
+
+	_, err = ReadArgs([]string{"-port"})
+	assert.Error(t, err)
+	_, err = ReadArgs([]string{"-port", "http"})
+	assert.NoError(t, err)
+	_, err = NewServerConfig()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid server port")
+
+	assert.NoError(t, os.Setenv("DB_PORT", "abc"))
+	_, err = NewConfig()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_PORT")
+}
+
+func TestYamlConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+
+	dir, err := ioutil.TempDir("", "qsmconf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "qsm.yaml")
+	assert.NoError(t, ioutil.WriteFile(p, []byte("host: yamlHost\nport: 6543\nuser: u\npassword: p\ndbName: d\n"+
+		"serverPort: \"9000\"\nallowedOrigins: [\"http://c\"]\nparallelism: 2\ndefaultEnv: 3\nshutdownTimeout: 1m\n"), 0644))
+	_, err = ReadArgs([]string{"-config", p})
+	assert.NoError(t, err)
+	c, err := NewDBConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "yamlHost", c.DBHost)
+	assert.Equal(t, 6543, c.DBPort)
+	assert.Equal(t, "9000", c.ServerPort)
+	assert.Equal(t, []string{"http://c"}, c.AllowedOrigins)
+	assert.Equal(t, 2, c.NbParallelProcesses)
+	assert.Equal(t, m3util.PerfTestEnv, c.DefaultEnvId)
+	assert.Equal(t, time.Minute, c.ShutdownTimeout)
+
+	assert.NoError(t, ioutil.WriteFile(p, []byte("host: [not a string"), 0644))
+	_, err = NewConfig()
+	assert.Error(t, err)
+}
+
+func TestConfigFileFromBackendDir(t *testing.T) {
+	clearConfigEnv(t)
+
+	wd, err := os.Getwd()
+	if !assert.NoError(t, err) {
+		return
+	}
+	dir, err := ioutil.TempDir("", "qsmroot")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	confDir := filepath.Join(dir, "backend", "conf")
+	assert.NoError(t, os.MkdirAll(confDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(confDir, "dbconn1.json"), []byte(`{"host":"scriptHost","user":"u"}`), 0644))
+	defer os.Chdir(wd)
+
+	// From the root dir and from the backend dir like the scripts
+	for _, runDir := range []string{dir, filepath.Join(dir, "backend")} {
+		assert.NoError(t, os.Chdir(runDir))
+		c, err := NewConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, "scriptHost", c.DBHost, "running from %s", runDir)
+		assert.Equal(t, filepath.Join(confDir, "dbconn1.json"), c.ConfigFile, "running from %s", runDir)
+	}
+}
@@ -3,7 +3,7 @@ module github.com/freddy33/qsm-go/backend
 require (
 	github.com/c2h5oh/datasize v0.0.0-20200825124411-48ed595a09d2
 	github.com/freddy33/qsm-go/m3util v0.0.0-latest
-	github.com/freddy33/qsm-go/model v0.0.0-20200626140801-6f9a15bc7381
+	github.com/freddy33/qsm-go/model v0.0.0-latest
 	github.com/freddy33/urlquery v1.2.4
 	github.com/golang/protobuf v1.4.2
 	github.com/gorilla/mux v1.7.4
@@ -11,6 +11,8 @@ require (
 	github.com/lib/pq v1.7.1
 	github.com/rs/cors v1.7.0
 	github.com/stretchr/testify v1.3.0
+	google.golang.org/protobuf v1.23.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 replace github.com/freddy33/qsm-go/m3util => ../m3util
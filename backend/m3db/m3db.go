@@ -65,28 +65,56 @@ func (env *QsmDbEnvironment) GetSchemaName() string {
 	return env.schemaName
 }
 
-func createNewDbEnv(envId m3util.QsmEnvID) m3util.QsmEnvironment {
-	dbConf := config.NewDBConfig()
+func createNewDbEnv(envId m3util.QsmEnvID) (m3util.QsmEnvironment, error) {
+	dbConf, err := config.NewDBConfig()
+	if err != nil {
+		return nil, m3util.MakeWrapQsmCodeErrorf(m3util.ErrInternal, err, "env %d has no valid DB configuration: %v", envId, err)
+	}
 	env := NewQsmDbEnvironment(dbConf)
 
 	env.Id = envId
 	env.schemaName = "qsm" + envId.String()
 	env.tableExecs = make(map[string]*TableExec)
 
-	err := env.OpenDb()
+	err = env.OpenDb()
 	if err != nil {
-		Log.Fatalf("Env %d failed to open DB: %v", envId, err)
+		return nil, m3util.MakeWrapQsmCodeErrorf(m3util.ErrUnavailable, err, "env %d failed to open DB: %v", envId, err)
 	}
 
 	if !env.Ping() {
-		Log.Fatalf("Could not ping DB %d", envId)
+		env.CloseDb()
+		return nil, m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "could not ping DB %s of env %d", env.dbDetails.DbName, envId)
 	}
 
-	return env
+	return env, nil
+}
+
+// The DB environment created and opened on first use, an error is returned if it cannot be opened
+func OpenEnvironment(envId m3util.QsmEnvID) (*QsmDbEnvironment, error) {
+	env, err := m3util.GetOrCreateEnvironment(envId, createNewDbEnv)
+	if err != nil {
+		return nil, err
+	}
+	return env.(*QsmDbEnvironment), nil
 }
 
+// Same as OpenEnvironment for the commands and tests that cannot run without their DB environment
 func GetEnvironment(envId m3util.QsmEnvID) *QsmDbEnvironment {
-	return m3util.GetEnvironmentWithCreator(envId, createNewDbEnv).(*QsmDbEnvironment)
+	env, err := OpenEnvironment(envId)
+	if err != nil {
+		Log.Fatal(err)
+		return nil
+	}
+	return env
+}
+
+// The DB environment if it was already created and opened, nil otherwise
+func GetExistingEnvironment(envId m3util.QsmEnvID) *QsmDbEnvironment {
+	env, ok := m3util.GetExistingEnvironment(envId)
+	if !ok {
+		return nil
+	}
+	return env.(*QsmDbEnvironment)
 }
 
 func (env *QsmDbEnvironment) OpenDb() error {
@@ -229,6 +257,23 @@ func (env *QsmDbEnvironment) Ping() bool {
 
 }
 
+// Single ping without retries, used by readiness checks
+func (env *QsmDbEnvironment) PingOnce() error {
+	db := env.GetConnection()
+	if db == nil {
+		return m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "no DB connection for environment %d", env.GetId())
+	}
+	err := db.Ping()
+	if err != nil {
+		return m3util.MakeWrapQsmCodeErrorf(m3util.ErrUnavailable, err, "failed to ping env %d on DB %s: %v", env.GetId(), env.dbDetails.DbName, err)
+	}
+	return nil
+}
+
+func (env *QsmDbEnvironment) IsSchemaChecked() bool {
+	return env.schemaChecked
+}
+
 func (env *QsmDbEnvironment) DataChecked(dataIdx int) bool {
 	return env.dataChecked[dataIdx]
 }
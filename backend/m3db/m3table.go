@@ -1,9 +1,15 @@
 package m3db
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"github.com/freddy33/qsm-go/m3util"
+	"sort"
+	"strconv"
+	"time"
 )
 
 type TableDefinition struct {
@@ -11,11 +17,15 @@ type TableDefinition struct {
 	DdlColumns     string
 	DdlColumnsRefs []string
 	Indexes        []string
+	// Alter table clauses bringing a table created by an older version to the current DDL, need to be idempotent
+	Migrations     []string
 	Insert         string
 	SelectAll      string
 	Queries        []string
 	QueryTableRefs map[int][]string
 	ExpectedCount  int
+	// Optional where clause restricting the rows counted against ExpectedCount
+	ExpectedCountFilter string
 
 	ErrorFilter func(err error) bool
 }
@@ -42,6 +52,42 @@ func AddTableDef(tDef *TableDefinition) {
 	tableDefinitions[tDef.Name] = tDef
 }
 
+// Hash of the DDL of all the table definitions added, changes when the schema changes
+func GetSchemaFingerprint() string {
+	names := make([]string, 0, len(tableDefinitions))
+	for name := range tableDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		tDef := tableDefinitions[name]
+		fmt.Fprintf(h, "%s %s\n", tDef.Name, tDef.DdlColumns)
+		for _, ddl := range [][]string{tDef.DdlColumnsRefs, tDef.Indexes, tDef.Migrations} {
+			fmt.Fprintf(h, "%q\n", ddl)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var dbQueryDuration = m3util.QsmMetrics.Histogram("qsm_db_query_duration_seconds",
+	"Duration of the DB statements per table and query id", m3util.DefaultDurationBuckets, "table", "query")
+
+const (
+	insertQueryName    = "insert"
+	selectAllQueryName = "select_all"
+)
+
+func (te *TableExec) observeQuery(start time.Time, queryName string) {
+	dbQueryDuration.ObserveSince(start, te.tableName, queryName)
+}
+
+// Metric and span of the request trace, one span per table summing all its queries
+func (te *TableExec) observeQueryContext(ctx context.Context, start time.Time, queryName string) {
+	te.observeQuery(start, queryName)
+	m3util.GetTrace(ctx).AddSpan("db_"+te.tableName, time.Since(start))
+}
+
 /***************************************************************/
 // Global QsmDbEnvironment functions
 /***************************************************************/
@@ -93,6 +139,27 @@ func (env *QsmDbEnvironment) GetOrCreateTableExec(tableName string) (*TableExec,
 	return tableExec, nil
 }
 
+// Run fn in one transaction of the env DB, committed only if fn returns no error
+func (env *QsmDbEnvironment) InTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := env.GetConnection().Begin()
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not start a transaction in env %d due to %v", env.GetId(), err)
+	}
+	err = fn(tx)
+	if err != nil {
+		rbErr := tx.Rollback()
+		if rbErr != nil {
+			Log.Errorf("rollback of transaction in env %d failed with %v after %v", env.GetId(), rbErr, err)
+		}
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not commit transaction in env %d due to %v", env.GetId(), err)
+	}
+	return nil
+}
+
 /***************************************************************/
 // QsmWrongCount functions
 /***************************************************************/
@@ -141,6 +208,19 @@ func (te *TableExec) Close() error {
 	return nil
 }
 
+// A copy of this table exec executing its insert and prepared queries in the transaction
+func (te *TableExec) InTx(tx *sql.Tx) *TableExec {
+	res := *te
+	res.InsertStmt = tx.Stmt(te.InsertStmt)
+	if te.queriesPrepared {
+		res.QueriesStmt = make([]*sql.Stmt, len(te.QueriesStmt))
+		for i, stmt := range te.QueriesStmt {
+			res.QueriesStmt[i] = tx.Stmt(stmt)
+		}
+	}
+	return &res
+}
+
 func (te *TableExec) IsFiltered(err error) bool {
 	return err != nil && te.TableDef.ErrorFilter != nil && te.TableDef.ErrorFilter(err)
 }
@@ -154,7 +234,7 @@ func (te *TableExec) GetForSaveAll() (int, bool, error) {
 	} else {
 		Log.Debugf("%s table was already created. Checking number of rows.", te.GetFullTableName())
 		var nbRows int
-		count, err := te.env.GetConnection().Query(fmt.Sprintf("select count(*) from %s", te.GetFullTableName()))
+		count, err := te.env.GetConnection().Query(fmt.Sprintf("select count(*) from %s %s", te.GetFullTableName(), te.TableDef.ExpectedCountFilter))
 		if err != nil {
 			Log.Error(err)
 			return 0, false, err
@@ -181,6 +261,7 @@ func (te *TableExec) GetForSaveAll() (int, bool, error) {
 }
 
 func (te *TableExec) SelectAllForLoad() (*sql.Rows, error) {
+	defer te.observeQuery(time.Now(), selectAllQueryName)
 	if te.TableDef.ExpectedCount > 0 && te.WasCreated() {
 		return nil, m3util.MakeQsmErrorf("could not load since table %s was just created", te.GetFullTableName())
 	}
@@ -192,7 +273,12 @@ func (te *TableExec) SelectAllForLoad() (*sql.Rows, error) {
 }
 
 func (te *TableExec) Insert(args ...interface{}) error {
-	res, err := te.InsertStmt.Exec(args...)
+	return te.InsertContext(context.Background(), args...)
+}
+
+func (te *TableExec) InsertContext(ctx context.Context, args ...interface{}) error {
+	defer te.observeQueryContext(ctx, time.Now(), insertQueryName)
+	res, err := te.InsertStmt.ExecContext(ctx, args...)
 	if err != nil {
 		return m3util.MakeWrapQsmErrorf(err, "executing insert for table %s with args %v got error %v", te.tableName, args, err)
 	}
@@ -217,7 +303,12 @@ func (te *TableExec) Insert(args ...interface{}) error {
 }
 
 func (te *TableExec) InsertReturnId(args ...interface{}) (int64, error) {
-	row := te.InsertStmt.QueryRow(args...)
+	return te.InsertReturnIdContext(context.Background(), args...)
+}
+
+func (te *TableExec) InsertReturnIdContext(ctx context.Context, args ...interface{}) (int64, error) {
+	defer te.observeQueryContext(ctx, time.Now(), insertQueryName)
+	row := te.InsertStmt.QueryRowContext(ctx, args...)
 	var id int64
 	err := row.Scan(&id)
 	if err != nil {
@@ -240,8 +331,13 @@ func (te *TableExec) checkQueriesPrepared() {
 }
 
 func (te *TableExec) Update(queryId int, args ...interface{}) (int, error) {
+	return te.UpdateContext(context.Background(), queryId, args...)
+}
+
+func (te *TableExec) UpdateContext(ctx context.Context, queryId int, args ...interface{}) (int, error) {
+	defer te.observeQueryContext(ctx, time.Now(), strconv.Itoa(queryId))
 	te.checkQueriesPrepared()
-	res, err := te.QueriesStmt[queryId].Exec(args...)
+	res, err := te.QueriesStmt[queryId].ExecContext(ctx, args...)
 	if err != nil {
 		return 0, m3util.MakeWrapQsmErrorf(err, "executing update for table %s for query %d with args %v got error '%s'", te.tableName, queryId, args, err.Error())
 	}
@@ -256,8 +352,14 @@ func (te *TableExec) Update(queryId int, args ...interface{}) (int, error) {
 }
 
 func (te *TableExec) Query(queryId int, args ...interface{}) (*sql.Rows, error) {
+	return te.QueryContext(context.Background(), queryId, args...)
+}
+
+// Query cancelled with the context, and timed in its request trace
+func (te *TableExec) QueryContext(ctx context.Context, queryId int, args ...interface{}) (*sql.Rows, error) {
+	defer te.observeQueryContext(ctx, time.Now(), strconv.Itoa(queryId))
 	te.checkQueriesPrepared()
-	rows, err := te.QueriesStmt[queryId].Query(args...)
+	rows, err := te.QueriesStmt[queryId].QueryContext(ctx, args...)
 	if err != nil {
 		return nil, m3util.MakeWrapQsmErrorf(err, "executing query %d for table %s with args %v got error %v", queryId, te.tableName, args, err)
 	}
@@ -268,8 +370,13 @@ func (te *TableExec) Query(queryId int, args ...interface{}) (*sql.Rows, error)
 }
 
 func (te *TableExec) QueryRow(queryId int, args ...interface{}) *sql.Row {
+	return te.QueryRowContext(context.Background(), queryId, args...)
+}
+
+func (te *TableExec) QueryRowContext(ctx context.Context, queryId int, args ...interface{}) *sql.Row {
+	defer te.observeQueryContext(ctx, time.Now(), strconv.Itoa(queryId))
 	te.checkQueriesPrepared()
-	row := te.QueriesStmt[queryId].QueryRow(args...)
+	row := te.QueriesStmt[queryId].QueryRowContext(ctx, args...)
 	if Log.IsTrace() {
 		Log.Tracef("query row %d on table %s with args %v", queryId, te.tableName, args)
 	}
@@ -336,6 +443,13 @@ func (te *TableExec) initForTable(tableName string) error {
 		if Log.IsDebug() {
 			Log.Debugf("Table %s already exists", fullTableName)
 		}
+		for _, migration := range te.TableDef.Migrations {
+			alterQuery := fmt.Sprintf("alter table %s %s", fullTableName, migration)
+			_, err = db.Exec(alterQuery)
+			if err != nil {
+				return m3util.MakeWrapQsmErrorf(err, "could not migrate table %s using '%s' due to error %v", fullTableName, alterQuery, err)
+			}
+		}
 		te.created = false
 		te.checked = true
 		return nil
@@ -0,0 +1,281 @@
+package m3server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Authentication of the REST API with tokens declared in a local JSON file.
+Only the SHA-256 hash of each token is stored in the file.
+If no file is found the API stays open like before with a warning at startup, except the destructive routes on
+MainEnv that are refused, and no CORS origin is allowed.
+*/
+type AuthScope int
+
+const (
+	// No token needed
+	ScopePublic AuthScope = iota
+	ScopeRead
+	ScopeCompute
+	ScopeAdmin
+)
+
+var authScopeNames = [...]string{"public", "read", "compute", "admin"}
+
+const (
+	AuthFileEnvKey  = "QSM_AUTH_FILE"
+	defaultAuthFile = "auth.json"
+)
+
+func (scope AuthScope) String() string {
+	return authScopeNames[scope]
+}
+
+func ParseAuthScope(name string) (AuthScope, error) {
+	for i, scopeName := range authScopeNames {
+		if scopeName == name {
+			return AuthScope(i), nil
+		}
+	}
+	return ScopePublic, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "unknown auth scope %q", name)
+}
+
+// One API token entry of the auth file
+type AuthToken struct {
+	Name string `json:"name"`
+	// Hex encoded SHA-256 of the token
+	Hash   string   `json:"hash"`
+	Scopes []string `json:"scopes"`
+	// The env ids this token can access, all if empty
+	Envs []int `json:"envs"`
+
+	hashBytes []byte
+	scopes    map[AuthScope]bool
+	envs      map[m3util.QsmEnvID]bool
+}
+
+type AuthConfig struct {
+	Tokens []*AuthToken `json:"tokens"`
+	// Origins allowed by CORS, none if empty
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// The minimum scope per route keyed like routeDocs, routes not listed need admin
+var routeScopes = map[string]AuthScope{
+	"GET /healthz":      ScopePublic,
+	"GET /readyz":       ScopePublic,
+	"GET /openapi.json": ScopePublic,
+
+	"GET /":          ScopeRead,
+	"GET /metrics":   ScopeRead,
+	"GET /list-env":  ScopeRead,
+	"GET /log":       ScopeRead,
+	"POST /log":      ScopeAdmin,
+	"POST /init-env": ScopeCompute,
+	// Destructive on MainEnv needs admin see destructiveRoutes
+	"DELETE /drop-env": ScopeCompute,
+
+	"GET /point-data":      ScopeRead,
+	"POST /growth-context": ScopeCompute,
+
+	"GET /path-context":       ScopeRead,
+	"POST /path-context":      ScopeCompute,
+	"GET /path-context/graph": ScopeRead,
+	"PUT /max-dist":           ScopeCompute,
+	"GET /path-nodes":         ScopeRead,
+	"GET /nb-path-nodes":      ScopeRead,
+
+	"GET /space":    ScopeRead,
+	"POST /space":   ScopeCompute,
+	"DELETE /space": ScopeCompute,
+
+	"GET /event":  ScopeRead,
+	"POST /event": ScopeCompute,
+
+	"GET /event-nodes":       ScopeRead,
+	"GET /space-time":        ScopeRead,
+	"GET /space-time/export": ScopeRead,
+	"GET /space-time/graph":  ScopeRead,
+}
+
+// Routes deleting data, on MainEnv they need the admin scope
+var destructiveRoutes = map[string]bool{
+	"DELETE /drop-env": true,
+	"DELETE /space":    true,
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// A new random token with its hash to put in the auth file
+func GenerateToken() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", m3util.MakeWrapQsmErrorf(err, "could not generate random token due to %v", err)
+	}
+	token := hex.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+func (conf *AuthConfig) init() error {
+	for i, token := range conf.Tokens {
+		if token.Name == "" {
+			token.Name = fmt.Sprintf("token-%d", i)
+		}
+		hashBytes, err := hex.DecodeString(token.Hash)
+		if err != nil || len(hashBytes) != sha256.Size {
+			return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "token %q hash %q is not a hex SHA-256", token.Name, token.Hash)
+		}
+		token.hashBytes = hashBytes
+		token.scopes = make(map[AuthScope]bool, len(token.Scopes))
+		for _, scopeName := range token.Scopes {
+			scope, err := ParseAuthScope(scopeName)
+			if err != nil {
+				return m3util.MakeWrapQsmErrorf(err, "token %q has invalid scope: %v", token.Name, err)
+			}
+			token.scopes[scope] = true
+		}
+		token.envs = make(map[m3util.QsmEnvID]bool, len(token.Envs))
+		for _, envId := range token.Envs {
+			token.envs[m3util.QsmEnvID(envId)] = true
+		}
+	}
+	return nil
+}
+
+func ParseAuthConfig(data []byte) (*AuthConfig, error) {
+	conf := new(AuthConfig)
+	err := json.Unmarshal(data, conf)
+	if err != nil {
+		return nil, m3util.MakeWrapQsmCodeErrorf(m3util.ErrBadRequest, err, "could not parse auth config due to %v", err)
+	}
+	err = conf.init()
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+/*
+Load the auth file from QSM_AUTH_FILE or auth.json in the conf dir.
+Returns nil without error if no file exists, meaning the API is not protected except for destructiveRoutes on MainEnv.
+*/
+func LoadAuthConfig() (*AuthConfig, error) {
+	filePath := os.Getenv(AuthFileEnvKey)
+	if filePath == "" {
+		confDir, _ := m3util.FindConfDir()
+		filePath = filepath.Join(confDir, defaultAuthFile)
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			Log.Warnf("No auth file %s, the REST API is open to all but the destructive routes on env %d", filePath, m3util.MainEnv)
+			return nil, nil
+		}
+	}
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, m3util.MakeWrapQsmErrorf(err, "could not read auth file %s due to %v", filePath, err)
+	}
+	conf, err := ParseAuthConfig(data)
+	if err != nil {
+		return nil, m3util.MakeWrapQsmErrorf(err, "invalid auth file %s: %v", filePath, err)
+	}
+	Log.Infof("Loaded %d API tokens from %s", len(conf.Tokens), filePath)
+	return conf, nil
+}
+
+func (conf *AuthConfig) GetAllowedOrigins() []string {
+	if conf == nil {
+		return nil
+	}
+	return conf.AllowedOrigins
+}
+
+func (conf *AuthConfig) findToken(token string) *AuthToken {
+	hash := sha256.Sum256([]byte(token))
+	var found *AuthToken
+	// Go through all of them to not leak which one matched by timing
+	for _, at := range conf.Tokens {
+		if subtle.ConstantTimeCompare(at.hashBytes, hash[:]) == 1 {
+			found = at
+		}
+	}
+	return found
+}
+
+// Admin includes compute which includes read
+func (at *AuthToken) hasScope(scope AuthScope) bool {
+	for s := scope; s <= ScopeAdmin; s++ {
+		if at.scopes[s] {
+			return true
+		}
+	}
+	return false
+}
+
+func (at *AuthToken) canAccessEnv(envId m3util.QsmEnvID) bool {
+	return len(at.envs) == 0 || at.envs[envId]
+}
+
+func getRouteScope(method string, path string, envId m3util.QsmEnvID) AuthScope {
+	key := getRouteDocKey(method, path)
+	scope, ok := routeScopes[key]
+	if !ok {
+		return ScopeAdmin
+	}
+	if envId == m3util.MainEnv && destructiveRoutes[key] {
+		return ScopeAdmin
+	}
+	return scope
+}
+
+func getBearerToken(r *http.Request) string {
+	authHeader := r.Header.Get(m3api.HttpAuthorizationKey)
+	if !strings.HasPrefix(authHeader, m3api.HttpBearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(authHeader, m3api.HttpBearerPrefix))
+}
+
+// Returns the http status and error if the request on this route and env is not allowed
+func (conf *AuthConfig) authorize(r *http.Request, path string, envId m3util.QsmEnvID) (int, error) {
+	if conf == nil {
+		// Without tokens nobody is admin, so the data of the main env cannot be deleted
+		if envId == m3util.MainEnv && destructiveRoutes[getRouteDocKey(r.Method, path)] {
+			return http.StatusForbidden, m3util.MakeQsmCodeErrorf(m3util.ErrForbidden, "%s %s on env %d needs an auth file with an admin token", r.Method, path, envId)
+		}
+		return http.StatusOK, nil
+	}
+	scope := getRouteScope(r.Method, path, envId)
+	if scope == ScopePublic {
+		return http.StatusOK, nil
+	}
+	token := getBearerToken(r)
+	if token == "" {
+		return http.StatusUnauthorized, m3util.MakeQsmCodeErrorf(m3util.ErrUnauthenticated, "%s %s needs a bearer token", r.Method, path)
+	}
+	at := conf.findToken(token)
+	if at == nil {
+		return http.StatusUnauthorized, m3util.MakeQsmCodeErrorf(m3util.ErrUnauthenticated, "%s %s got an unknown token", r.Method, path)
+	}
+	if !at.canAccessEnv(envId) {
+		return http.StatusForbidden, m3util.MakeQsmCodeErrorf(m3util.ErrForbidden, "token %q cannot access env %d", at.Name, envId)
+	}
+	if !at.hasScope(scope) {
+		return http.StatusForbidden, m3util.MakeQsmCodeErrorf(m3util.ErrForbidden, "token %q needs scope %s for %s %s on env %d", at.Name, scope.String(), r.Method, path, envId)
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,142 @@
+package m3server
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAllRoutesHaveScope(t *testing.T) {
+	app := makeRoutesOnlyApp()
+	keys, err := getRouteKeys(app.Router)
+	assert.NoError(t, err)
+	for _, key := range keys {
+		_, ok := routeScopes[key]
+		assert.True(t, ok, "route %q has no entry in routeScopes", key)
+	}
+}
+
+func TestParseAuthConfig(t *testing.T) {
+	_, err := ParseAuthConfig([]byte(`{"tokens":[{"name":"bad","hash":"1234","scopes":["read"]}]}`))
+	assert.Error(t, err)
+	_, err = ParseAuthConfig([]byte(fmt.Sprintf(`{"tokens":[{"hash":%q,"scopes":["super"]}]}`, HashToken("t"))))
+	assert.Error(t, err)
+
+	conf, err := ParseAuthConfig([]byte(fmt.Sprintf(`{"tokens":[{"hash":%q,"scopes":["read"]}]}`, HashToken("t"))))
+	assert.NoError(t, err)
+	assert.Equal(t, "token-0", conf.Tokens[0].Name)
+	assert.Empty(t, conf.GetAllowedOrigins())
+	assert.NotNil(t, conf.findToken("t"))
+	assert.Nil(t, conf.findToken("other"))
+
+	conf, err = ParseAuthConfig([]byte(fmt.Sprintf(`{"allowed_origins":["http://localhost:8082"],"tokens":[{"hash":%q,"scopes":["read"]}]}`, HashToken("t"))))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://localhost:8082"}, conf.GetAllowedOrigins())
+}
+
+func TestLoadAuthConfigWithoutConfDir(t *testing.T) {
+	Log.SetDebug()
+	wd, err := os.Getwd()
+	if !assert.NoError(t, err) {
+		return
+	}
+	dir, err := ioutil.TempDir("", "qsm-auth")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	os.Unsetenv(AuthFileEnvKey)
+
+	// No conf dir means no auth file, and not a panic
+	conf, err := LoadAuthConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, conf)
+}
+
+func TestAuthorizeRoutes(t *testing.T) {
+	data := fmt.Sprintf(`{"tokens":[
+{"name":"reader","hash":%q,"scopes":["read"]},
+{"name":"tests","hash":%q,"scopes":["compute"],"envs":[%d,%d]},
+{"name":"admin","hash":%q,"scopes":["admin"]}]}`,
+		HashToken("reader-token"), HashToken("tests-token"), m3util.MainEnv, m3util.PathTempEnv, HashToken("admin-token"))
+	conf, err := ParseAuthConfig([]byte(data))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	app := &QsmApp{Router: mux.NewRouter(), Auth: conf}
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		SendResponse(w, http.StatusOK, "done")
+	}
+	app.AddHandler("/healthz", ok).Methods("GET")
+	app.AddHandler("/space", ok).Methods("GET")
+	app.AddHandler("/space", ok).Methods("DELETE")
+	app.AddHandler("/drop-env", ok).Methods("DELETE")
+
+	tests := []struct {
+		method, path, token string
+		envId               m3util.QsmEnvID
+		expected            int
+	}{
+		{"GET", "/healthz", "", m3util.MainEnv, http.StatusOK},
+		{"GET", "/space", "", m3util.MainEnv, http.StatusUnauthorized},
+		{"GET", "/space", "wrong-token", m3util.MainEnv, http.StatusUnauthorized},
+		{"GET", "/space", "reader-token", m3util.MainEnv, http.StatusOK},
+		{"DELETE", "/space", "reader-token", m3util.PathTempEnv, http.StatusForbidden},
+		{"DELETE", "/space", "tests-token", m3util.PathTempEnv, http.StatusOK},
+		{"DELETE", "/space", "tests-token", m3util.SpaceTempEnv, http.StatusForbidden},
+		{"DELETE", "/space", "tests-token", m3util.MainEnv, http.StatusForbidden},
+		{"DELETE", "/drop-env", "tests-token", m3util.MainEnv, http.StatusForbidden},
+		{"DELETE", "/drop-env", "admin-token", m3util.MainEnv, http.StatusOK},
+		{"GET", "/space", "admin-token", m3util.SpaceTempEnv, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, nil)
+		assert.NoError(t, err)
+		req.Header.Set(m3api.HttpEnvIdKey, tt.envId.String())
+		if tt.token != "" {
+			req.Header.Set(m3api.HttpAuthorizationKey, m3api.HttpBearerPrefix+tt.token)
+		}
+		rr := httptest.NewRecorder()
+		app.Router.ServeHTTP(rr, req)
+		assert.Equal(t, tt.expected, rr.Code, "%s %s with %q on env %d", tt.method, tt.path, tt.token, tt.envId)
+	}
+}
+
+func TestAuthorizeWithoutConfig(t *testing.T) {
+	var conf *AuthConfig
+	assert.Empty(t, conf.GetAllowedOrigins())
+
+	tests := []struct {
+		method, path string
+		envId        m3util.QsmEnvID
+		expected     int
+	}{
+		{"GET", "/space", m3util.MainEnv, http.StatusOK},
+		{"POST", "/space", m3util.MainEnv, http.StatusOK},
+		{"DELETE", "/space", m3util.MainEnv, http.StatusForbidden},
+		{"DELETE", "/drop-env", m3util.MainEnv, http.StatusForbidden},
+		{"DELETE", "/space", m3util.SpaceTempEnv, http.StatusOK},
+		{"DELETE", "/drop-env", m3util.PathTempEnv, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.path, nil)
+		assert.NoError(t, err)
+		status, err := conf.authorize(req, tt.path, tt.envId)
+		assert.Equal(t, tt.expected, status, "%s %s on env %d", tt.method, tt.path, tt.envId)
+		if tt.expected == http.StatusOK {
+			assert.NoError(t, err)
+		} else {
+			assert.Equal(t, m3util.ErrForbidden, m3util.GetQsmErrorCode(err))
+		}
+	}
+}
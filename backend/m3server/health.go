@@ -0,0 +1,110 @@
+package m3server
+
+import (
+	"encoding/json"
+	"github.com/freddy33/qsm-go/backend/m3db"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"net/http"
+	"time"
+)
+
+var serverStartTime = time.Now()
+
+// The part of the DB environment needed for readiness, only checking the state of an already created environment
+type readinessEnv interface {
+	GetId() m3util.QsmEnvID
+	PingOnce() error
+	IsSchemaChecked() bool
+	DataChecked(dataIdx int) bool
+}
+
+// Plain JSON response for the bodies not defined as protobuf messages
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		SendResponse(w, http.StatusInternalServerError, "Failed to marshal %T due to: %s", v, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	if err != nil {
+		Log.Errorf("failed to send data to response due to %q", err.Error())
+	}
+}
+
+// Process is up and serving requests
+func healthz(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, &m3api.HealthStatusMsg{
+		Status:        m3api.HealthStatusUp,
+		EnvId:         int(GetEnvId(r)),
+		SchemaVersion: m3api.SchemaVersion,
+		Uptime:        time.Since(serverStartTime).Round(time.Second).String(),
+	})
+}
+
+func checkReadiness(env readinessEnv) (bool, *m3api.HealthStatusMsg) {
+	res := &m3api.HealthStatusMsg{
+		EnvId:  int(env.GetId()),
+		Checks: make([]m3api.HealthCheckMsg, 0, 3),
+	}
+	allOk := true
+	addCheck := func(name string, ok bool, message string) {
+		allOk = allOk && ok
+		res.Checks = append(res.Checks, m3api.HealthCheckMsg{Name: name, Ok: ok, Message: message})
+	}
+
+	err := env.PingOnce()
+	if err != nil {
+		addCheck("db_ping", false, err.Error())
+	} else {
+		addCheck("db_ping", true, "")
+	}
+	if env.IsSchemaChecked() {
+		addCheck("schema", true, "")
+	} else {
+		addCheck("schema", false, "schema not checked yet")
+	}
+	if env.DataChecked(m3util.PointIdx) {
+		addCheck("point_data", true, "")
+	} else {
+		addCheck("point_data", false, "point data not initialized yet")
+	}
+
+	if allOk {
+		res.Status = m3api.HealthStatusReady
+	} else {
+		res.Status = m3api.HealthStatusNotReady
+	}
+	return allOk, res
+}
+
+// DB reachable, the environment data initialized and not shutting down, 503 otherwise
+func (app *QsmApp) readyz(w http.ResponseWriter, r *http.Request) {
+	if app.IsDraining() {
+		writeJson(w, http.StatusServiceUnavailable, &m3api.HealthStatusMsg{
+			Status: m3api.HealthStatusNotReady,
+			EnvId:  int(GetEnvId(r)),
+			Checks: []m3api.HealthCheckMsg{{Name: "draining", Ok: false, Message: "server is shutting down"}},
+		})
+		return
+	}
+	envId := GetEnvId(r)
+	// Never create the environment here, it may fail hard when the DB is down
+	env := m3db.GetExistingEnvironment(envId)
+	if env == nil {
+		writeJson(w, http.StatusServiceUnavailable, &m3api.HealthStatusMsg{
+			Status: m3api.HealthStatusNotReady,
+			EnvId:  int(envId),
+			Checks: []m3api.HealthCheckMsg{{Name: "env", Ok: false, Message: "environment not opened yet"}},
+		})
+		return
+	}
+	ready, msg := checkReadiness(env)
+	if ready {
+		writeJson(w, http.StatusOK, msg)
+	} else {
+		writeJson(w, http.StatusServiceUnavailable, msg)
+	}
+}
@@ -0,0 +1,94 @@
+package m3server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/freddy33/qsm-go/backend/m3db"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeReadinessEnv struct {
+	pingErr       error
+	schemaChecked bool
+	pointChecked  bool
+}
+
+func (env *fakeReadinessEnv) GetId() m3util.QsmEnvID {
+	return m3util.PointTestEnv
+}
+
+func (env *fakeReadinessEnv) PingOnce() error {
+	return env.pingErr
+}
+
+func (env *fakeReadinessEnv) IsSchemaChecked() bool {
+	return env.schemaChecked
+}
+
+func (env *fakeReadinessEnv) DataChecked(dataIdx int) bool {
+	return dataIdx == m3util.PointIdx && env.pointChecked
+}
+
+func TestCheckReadiness(t *testing.T) {
+	env := &fakeReadinessEnv{pingErr: m3util.MakeQsmErrorf("connection refused")}
+	ready, msg := checkReadiness(env)
+	assert.False(t, ready)
+	assert.Equal(t, m3api.HealthStatusNotReady, msg.Status)
+	assert.Equal(t, int(m3util.PointTestEnv), msg.EnvId)
+	if assert.Len(t, msg.Checks, 3) {
+		assert.Equal(t, "db_ping", msg.Checks[0].Name)
+		assert.False(t, msg.Checks[0].Ok)
+		assert.Equal(t, "connection refused", msg.Checks[0].Message)
+	}
+
+	env.pingErr = nil
+	env.schemaChecked = true
+	ready, msg = checkReadiness(env)
+	assert.False(t, ready)
+	assert.True(t, msg.Checks[0].Ok)
+	assert.True(t, msg.Checks[1].Ok)
+	assert.False(t, msg.Checks[2].Ok)
+
+	env.pointChecked = true
+	ready, msg = checkReadiness(env)
+	assert.True(t, ready)
+	assert.Equal(t, m3api.HealthStatusReady, msg.Status)
+}
+
+func TestReadyzUnknownEnv(t *testing.T) {
+	envId := m3util.QsmEnvID(42)
+	app := &QsmApp{Router: mux.NewRouter()}
+	req, err := http.NewRequest("GET", "/readyz", nil)
+	assert.NoError(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), m3api.HttpEnvIdKey, envId))
+	rr := httptest.NewRecorder()
+	app.readyz(rr, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	msg := &m3api.HealthStatusMsg{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), msg))
+	assert.Equal(t, m3api.HealthStatusNotReady, msg.Status)
+	assert.Equal(t, int(envId), msg.EnvId)
+	if assert.Len(t, msg.Checks, 1) {
+		assert.Equal(t, "env", msg.Checks[0].Name)
+	}
+	// The environment was not created by the readiness check
+	_, ok := m3util.GetExistingEnvironment(envId)
+	assert.False(t, ok)
+}
+
+// Fingerprint of the table definitions for each schema version, add the new one when increasing m3api.SchemaVersion
+var schemaFingerprints = map[int]string{
+	2: "a37878cd82c0f54be86a1cb57b20599df9b2f45eded65de9f9d4db61ed1d2d68",
+}
+
+func TestSchemaVersion(t *testing.T) {
+	fingerprint := m3db.GetSchemaFingerprint()
+	assert.Equal(t, schemaFingerprints[m3api.SchemaVersion], fingerprint,
+		"table definitions changed, increase m3api.SchemaVersion and add the fingerprint %s for it", fingerprint)
+}
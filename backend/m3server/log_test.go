@@ -0,0 +1,86 @@
+package m3server
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestGetAndSetLogLevels(t *testing.T) {
+	app := makeRoutesOnlyApp()
+	Log.SetInfo()
+
+	call := func(method string, uri string) (int, *m3api.LogLevelsMsg) {
+		req, err := http.NewRequest(method, uri, nil)
+		assert.NoError(t, err)
+		req.Header.Set(m3api.HttpEnvIdKey, m3util.PointTestEnv.String())
+		rr := httptest.NewRecorder()
+		app.Router.ServeHTTP(rr, req)
+		res := &m3api.LogLevelsMsg{}
+		if rr.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), res))
+		}
+		return rr.Code, res
+	}
+	findLevel := func(msg *m3api.LogLevelsMsg, name string) string {
+		for _, ll := range msg.Loggers {
+			if ll.Name == name {
+				return ll.Level
+			}
+		}
+		return ""
+	}
+
+	status, levels := call("GET", "/log")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "text", levels.Format)
+	assert.Equal(t, "INFO", findLevel(levels, "m3server"))
+	assert.Equal(t, len(m3util.GetAllLoggerLevels()), len(levels.Loggers))
+
+	status, levels = call("POST", "/log?m3server=debug&path=1")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"m3path", "m3server", "pathdb"}, levels.Updated)
+	assert.Equal(t, "DEBUG", findLevel(levels, "m3server"))
+	assert.Equal(t, "DEBUG", findLevel(levels, "m3path"))
+	assert.True(t, Log.IsDebug())
+
+	status, _ = call("POST", "/log?m3server=LOUD")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = call("POST", "/log?not-a-logger=INFO")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	Log.SetInfo()
+	m3util.SetLoggerLevel("m3path", m3util.INFO)
+	m3util.SetLoggerLevel("pathdb", m3util.INFO)
+}
+
+func TestJsonLogOutput(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if !assert.NoError(t, err) {
+		return
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	m3util.SetJsonLogOutput(true)
+	Log.WithFields(m3util.LogFields{m3util.LogEnvIdField: m3util.PointTestEnv, "path_ctx": 12}).Warnf("json %d", 1234)
+	m3util.SetJsonLogOutput(false)
+	os.Stdout = stdout
+	assert.NoError(t, writer.Close())
+
+	scanner := bufio.NewScanner(reader)
+	assert.True(t, scanner.Scan())
+	line := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line), "not JSON %q", scanner.Text())
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, "m3server", line["logger"])
+	assert.Equal(t, float64(m3util.PointTestEnv), line["env_id"])
+	assert.Equal(t, "json 1,234", line["msg"])
+	assert.Contains(t, line["caller"], "log_test.go:")
+	assert.Equal(t, float64(12), line["fields"].(map[string]interface{})["path_ctx"])
+}
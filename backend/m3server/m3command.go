@@ -0,0 +1,133 @@
+package m3server
+
+import (
+	"github.com/freddy33/qsm-go/backend/spacedb"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"strconv"
+)
+
+// Options of the render, export and graph commands, see ReadCommandArgs
+type CommandOptions struct {
+	// Name or id of the space
+	Space string
+	// Id of the path context of the graph command, the space is used if not set
+	PathCtx int
+	From    m3space.DistAndTime
+	// Negative means the current max time of the space
+	To m3space.DistAndTime
+	// Time of the exported space time, negative means the current max time of the space
+	Time m3space.DistAndTime
+	// Default depends on the command
+	Format        string
+	OutDir        string
+	Width, Height int
+	// Rotation in degrees around the Z axis
+	Angle float64
+	// Rotation in degrees added at each SVG frame
+	AngleStep float64
+	// The drawing filter of rendered frames, see m3gl.SpaceDrawingFilter
+	ColorMask  uint8
+	ManyColors uint8
+	ShowEmpty  bool
+}
+
+// The command flags with a value
+var commandFlags = map[string]bool{
+	"-space":       true,
+	"-path-ctx":    true,
+	"-from":        true,
+	"-to":          true,
+	"-time":        true,
+	"-format":      true,
+	"-out":         true,
+	"-width":       true,
+	"-height":      true,
+	"-angle":       true,
+	"-angle-step":  true,
+	"-color-mask":  true,
+	"-many-colors": true,
+	"-show-empty":  true,
+}
+
+func DefaultCommandOptions() CommandOptions {
+	return CommandOptions{To: -1, Time: -1, Width: 800, Height: 600, ColorMask: 0xff}
+}
+
+/*
+Extract the flags of the render, export and graph commands from the args.
+Returns the args not used, and an error if a flag has no value or an invalid one.
+*/
+func ReadCommandArgs(args []string) ([]string, CommandOptions, error) {
+	opts := DefaultCommandOptions()
+	others := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		if !commandFlags[flag] {
+			others = append(others, flag)
+			continue
+		}
+		if i+1 >= len(args) {
+			return others, opts, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "flag %s needs a value", flag)
+		}
+		i++
+		value := args[i]
+		var err error
+		switch flag {
+		case "-space":
+			opts.Space = value
+		case "-format":
+			opts.Format = value
+		case "-out":
+			opts.OutDir = value
+		case "-from", "-to", "-time":
+			var t int
+			t, err = strconv.Atoi(value)
+			switch flag {
+			case "-from":
+				opts.From = m3space.DistAndTime(t)
+			case "-to":
+				opts.To = m3space.DistAndTime(t)
+			default:
+				opts.Time = m3space.DistAndTime(t)
+			}
+		case "-path-ctx":
+			opts.PathCtx, err = strconv.Atoi(value)
+		case "-width":
+			opts.Width, err = strconv.Atoi(value)
+		case "-height":
+			opts.Height, err = strconv.Atoi(value)
+		case "-angle":
+			opts.Angle, err = strconv.ParseFloat(value, 64)
+		case "-angle-step":
+			opts.AngleStep, err = strconv.ParseFloat(value, 64)
+		case "-color-mask", "-many-colors":
+			var v uint64
+			v, err = strconv.ParseUint(value, 0, 8)
+			if flag == "-color-mask" {
+				opts.ColorMask = uint8(v)
+			} else {
+				opts.ManyColors = uint8(v)
+			}
+		case "-show-empty":
+			opts.ShowEmpty, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return others, opts, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "flag %s=%q is invalid", flag, value)
+		}
+	}
+	return others, opts, nil
+}
+
+func findSpace(spaceData *spacedb.ServerSpacePackData, nameOrId string) (m3space.SpaceIfc, error) {
+	if nameOrId == "" {
+		return nil, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "needs a space name or id with -space")
+	}
+	id, err := strconv.Atoi(nameOrId)
+	for _, space := range spaceData.GetAllSpaces() {
+		if space.GetName() == nameOrId || (err == nil && space.GetId() == id) {
+			return space, nil
+		}
+	}
+	return nil, m3util.MakeQsmCodeErrorf(m3util.ErrNotFound, "space %q not found in env %d", nameOrId, spaceData.GetEnvId())
+}
@@ -0,0 +1,36 @@
+package m3server
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReadCommandArgs(t *testing.T) {
+	others, opts, err := ReadCommandArgs([]string{"render", "-space", "ui3", "-from", "2", "-to", "5", "-width", "320", "-angle", "45", "-format", "ply", "-time", "4", "-path-ctx", "12", "-color-mask", "0x5", "-show-empty", "true", "-test"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"render", "-test"}, others)
+	assert.Equal(t, "ui3", opts.Space)
+	assert.Equal(t, m3space.DistAndTime(2), opts.From)
+	assert.Equal(t, m3space.DistAndTime(5), opts.To)
+	assert.Equal(t, 320, opts.Width)
+	assert.Equal(t, 600, opts.Height)
+	assert.Equal(t, 45.0, opts.Angle)
+	assert.Equal(t, "ply", opts.Format)
+	assert.Equal(t, m3space.DistAndTime(4), opts.Time)
+	assert.Equal(t, 12, opts.PathCtx)
+	assert.Equal(t, uint8(5), opts.ColorMask)
+	assert.Equal(t, uint8(0), opts.ManyColors)
+	assert.True(t, opts.ShowEmpty)
+
+	_, opts, err = ReadCommandArgs([]string{"server"})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultCommandOptions(), opts)
+
+	_, _, err = ReadCommandArgs([]string{"render", "-to"})
+	assert.Error(t, err)
+	_, _, err = ReadCommandArgs([]string{"render", "-width", "wide"})
+	assert.Error(t, err)
+	assert.Equal(t, m3util.ErrBadRequest, m3util.GetQsmErrorCode(err))
+}
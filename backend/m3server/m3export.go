@@ -0,0 +1,116 @@
+package m3server
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/backend/m3db"
+	"github.com/freddy33/qsm-go/backend/pointdb"
+	"github.com/freddy33/qsm-go/backend/spacedb"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Query param of the export routes
+const exportFormatParam = "format"
+
+// Populate the space time and collect its nodes and links
+func collectSpaceTimeScene(r *http.Request, env *m3db.QsmDbEnvironment, space m3space.SpaceIfc, time m3space.DistAndTime) (*m3space.SpaceTimeScene, error) {
+	spaceTime := space.GetSpaceTimeAt(time).(*spacedb.SpaceTime)
+	err := spaceTime.PopulateContext(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer m3util.StartSpan(r.Context(), "nodes_visit")()
+	return m3space.CollectScene(spaceTime, pointdb.GetServerPointPackData(env))
+}
+
+func exportSpaceTime(w http.ResponseWriter, r *http.Request) {
+	Log.Infof("Receive exportSpaceTime")
+
+	reqMsg := &m3api.SpaceTimeRequestMsg{}
+	if !ReadRequestMsg(w, r, reqMsg) {
+		return
+	}
+	format, err := m3space.ParseExportFormat(r.URL.Query().Get(exportFormatParam))
+	if err != nil {
+		SendError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
+	space := spacedb.GetServerSpacePackData(env).GetSpace(int(reqMsg.SpaceId))
+	if space == nil || space.(*spacedb.SpaceDb) == nil {
+		SendError(w, r, http.StatusNotFound, m3util.MakeQsmCodeErrorf(m3util.ErrNotFound, "space id %d not found", reqMsg.SpaceId))
+		return
+	}
+	scene, err := collectSpaceTimeScene(r, env, space, m3space.DistAndTime(reqMsg.CurrentTime))
+	if err != nil {
+		SendError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", scene.Name()+"."+format.Extension()))
+	w.WriteHeader(http.StatusOK)
+	err = scene.Write(w, format)
+	if err != nil {
+		Log.Errorf("export of %s failed after the headers were sent: %v", scene.Name(), err)
+	}
+}
+
+/*
+Write the space time of opts.Space at opts.Time in opts.Format, gltf by default, in opts.OutDir or build/export by default.
+Returns the file written.
+*/
+func ExportSpaceTimeEnv(env *m3db.QsmDbEnvironment, opts CommandOptions) (string, error) {
+	formatName := opts.Format
+	if formatName == "" {
+		formatName = string(m3space.GltfFormat)
+	}
+	format, err := m3space.ParseExportFormat(formatName)
+	if err != nil {
+		return "", err
+	}
+	space, err := findSpace(spacedb.GetServerSpacePackData(env), opts.Space)
+	if err != nil {
+		return "", err
+	}
+	time := opts.Time
+	if time < 0 {
+		time = space.GetMaxTime()
+	}
+	spaceTime := space.GetSpaceTimeAt(time)
+	scene, err := m3space.CollectScene(spaceTime, pointdb.GetServerPointPackData(env))
+	if err != nil {
+		return "", err
+	}
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = filepath.Join(m3util.GetBuildDir(), "export")
+	}
+	fileName := fmt.Sprintf("%s-%d.%s", scene.Name(), env.GetId(), format.Extension())
+	return writeExportFile(outDir, fileName, func(f *os.File) error {
+		return scene.Write(f, format)
+	})
+}
+
+// Create the file in outDir and write it, returns the file path
+func writeExportFile(outDir string, fileName string, write func(f *os.File) error) (string, error) {
+	err := os.MkdirAll(outDir, 0755)
+	if err != nil {
+		return "", m3util.MakeWrapQsmErrorf(err, "could not create export dir %s due to %v", outDir, err)
+	}
+	filePath := filepath.Join(outDir, fileName)
+	f, err := os.Create(filePath)
+	if err != nil {
+		return "", m3util.MakeWrapQsmErrorf(err, "could not create export file %s due to %v", filePath, err)
+	}
+	defer m3util.CloseFile(f)
+	return filePath, write(f)
+}
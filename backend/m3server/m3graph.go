@@ -0,0 +1,165 @@
+package m3server
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/backend/m3db"
+	"github.com/freddy33/qsm-go/backend/pathdb"
+	"github.com/freddy33/qsm-go/backend/pointdb"
+	"github.com/freddy33/qsm-go/backend/spacedb"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/freddy33/qsm-go/model/m3path"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+func sendGraph(w http.ResponseWriter, g *m3path.Graph, format m3path.GraphFormat) {
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", g.Name+"."+format.Extension()))
+	w.WriteHeader(http.StatusOK)
+	err := g.Write(w, format)
+	if err != nil {
+		Log.Errorf("graph %s failed after the headers were sent: %v", g.Name, err)
+	}
+}
+
+// The path nodes from dist to to_dist, or from the root up to dist if to_dist is zero
+func getPathContextGraph(w http.ResponseWriter, r *http.Request) {
+	Log.Infof("Receive getPathContextGraph")
+
+	format, err := m3path.ParseGraphFormat(r.URL.Query().Get(exportFormatParam))
+	if err != nil {
+		SendError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	reqMsg, pathCtx := extractPathNodeRequest(w, r)
+	if reqMsg == nil {
+		return
+	}
+	valid, fromDist, toDist := verifyMaxDist(w, r, pathCtx, reqMsg)
+	if !valid {
+		return
+	}
+	if toDist <= 0 {
+		fromDist, toDist = 0, fromDist
+	}
+
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
+	pathNodes, err := pathCtx.(*pathdb.PathContextDb).GetPathNodesBetweenContext(r.Context(), fromDist, toDist)
+	if err != nil {
+		SendError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	g, err := m3path.MakePathGraph(pathCtx, pathNodes, pointdb.GetServerPointPackData(env))
+	if err != nil {
+		SendError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	sendGraph(w, g, format)
+}
+
+func getSpaceTimeGraph(w http.ResponseWriter, r *http.Request) {
+	Log.Infof("Receive getSpaceTimeGraph")
+
+	format, err := m3path.ParseGraphFormat(r.URL.Query().Get(exportFormatParam))
+	if err != nil {
+		SendError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	reqMsg := &m3api.SpaceTimeRequestMsg{}
+	if !ReadRequestMsg(w, r, reqMsg) {
+		return
+	}
+
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
+	space := spacedb.GetServerSpacePackData(env).GetSpace(int(reqMsg.SpaceId))
+	if space == nil || space.(*spacedb.SpaceDb) == nil {
+		SendError(w, r, http.StatusNotFound, m3util.MakeQsmCodeErrorf(m3util.ErrNotFound, "space id %d not found", reqMsg.SpaceId))
+		return
+	}
+	spaceTime := space.GetSpaceTimeAt(m3space.DistAndTime(reqMsg.CurrentTime)).(*spacedb.SpaceTime)
+	err = spaceTime.PopulateContext(r.Context())
+	if err != nil {
+		SendError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	g, err := m3space.CollectSpaceTimeGraph(spaceTime, pointdb.GetServerPointPackData(env))
+	if err != nil {
+		SendError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	sendGraph(w, g, format)
+}
+
+/*
+Write the graph of the path context opts.PathCtx between opts.From and opts.To if set,
+otherwise of the space time of opts.Space at opts.Time.
+The file goes in opts.OutDir or build/graph by default, d3 JSON if no opts.Format.
+*/
+func ExportGraphEnv(env *m3db.QsmDbEnvironment, opts CommandOptions) (string, error) {
+	formatName := opts.Format
+	if formatName == "" {
+		formatName = string(m3path.D3JsonFormat)
+	}
+	format, err := m3path.ParseGraphFormat(formatName)
+	if err != nil {
+		return "", err
+	}
+	var g *m3path.Graph
+	if opts.PathCtx > 0 {
+		g, err = collectPathGraph(env, opts)
+	} else {
+		g, err = collectSpaceTimeGraph(env, opts)
+	}
+	if err != nil {
+		return "", err
+	}
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = filepath.Join(m3util.GetBuildDir(), "graph")
+	}
+	fileName := fmt.Sprintf("%s-%d.%s", g.Name, env.GetId(), format.Extension())
+	return writeExportFile(outDir, fileName, func(f *os.File) error {
+		return g.Write(f, format)
+	})
+}
+
+func collectPathGraph(env *m3db.QsmDbEnvironment, opts CommandOptions) (*m3path.Graph, error) {
+	pathCtx := pathdb.GetServerPathPackData(env).GetPathCtx(m3path.PathContextId(opts.PathCtx))
+	if pathCtx == nil {
+		return nil, m3util.MakeQsmCodeErrorf(m3util.ErrNotFound, "path context id %d not found in env %d", opts.PathCtx, env.GetId())
+	}
+	toDist := int(opts.To)
+	if toDist < 0 {
+		toDist = pathCtx.GetMaxDist()
+	}
+	if toDist > pathCtx.GetMaxDist() || int(opts.From) > toDist {
+		return nil, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "dist %d to %d invalid for path context id %d with max dist %d",
+			opts.From, toDist, opts.PathCtx, pathCtx.GetMaxDist())
+	}
+	pathNodes, err := pathCtx.GetPathNodesBetween(int(opts.From), toDist)
+	if err != nil {
+		return nil, err
+	}
+	return m3path.MakePathGraph(pathCtx, pathNodes, pointdb.GetServerPointPackData(env))
+}
+
+func collectSpaceTimeGraph(env *m3db.QsmDbEnvironment, opts CommandOptions) (*m3path.Graph, error) {
+	space, err := findSpace(spacedb.GetServerSpacePackData(env), opts.Space)
+	if err != nil {
+		return nil, err
+	}
+	time := opts.Time
+	if time < 0 {
+		time = space.GetMaxTime()
+	}
+	return m3space.CollectSpaceTimeGraph(space.GetSpaceTimeAt(time), pointdb.GetServerPointPackData(env))
+}
@@ -0,0 +1,58 @@
+package m3server
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/backend/m3db"
+	"github.com/freddy33/qsm-go/backend/pointdb"
+	"github.com/freddy33/qsm-go/backend/spacedb"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3gl"
+	"math"
+	"path/filepath"
+)
+
+/*
+Write the PNG frames of a space from opts.From to opts.To in opts.OutDir, by default build/render/<space name>.
+With the svg format writes SVG frames rotating by opts.AngleStep and an index.html animating them.
+Uses the same drawing elements, filter and camera as the UI without needing OpenGL.
+*/
+func RenderSpaceFramesEnv(env *m3db.QsmDbEnvironment, opts CommandOptions) ([]string, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "invalid render size %dx%d", opts.Width, opts.Height)
+	}
+	space, err := findSpace(spacedb.GetServerSpacePackData(env), opts.Space)
+	if err != nil {
+		return nil, err
+	}
+	to := opts.To
+	if to < 0 {
+		to = space.GetMaxTime()
+	}
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = filepath.Join(m3util.GetBuildDir(), "render", fmt.Sprintf("%s-%d", space.GetName(), env.GetId()))
+	}
+
+	world := m3gl.MakeWorldFromSpace(pointdb.GetServerPointPackData(env), space, 0.0)
+	world.Width = opts.Width
+	world.Height = opts.Height
+	world.Angle.Value = opts.Angle * math.Pi / 180.0
+	filter := world.Filter
+	filter.EventColorMask = opts.ColorMask
+	filter.EventOutgrowthManyColorsThreshold = opts.ManyColors
+	filter.DisplayEmptyNodes = opts.ShowEmpty
+	filter.DisplayEmptyConnections = opts.ShowEmpty
+	switch opts.Format {
+	case "", "png":
+		world.Filter = filter
+		return world.RenderFrames(opts.From, to, outDir)
+	case "svg":
+		svgOpts := m3gl.DefaultSvgExportOptions()
+		svgOpts.From = opts.From
+		svgOpts.To = to
+		svgOpts.AnglePerStep = opts.AngleStep * math.Pi / 180.0
+		svgOpts.Filter = &filter
+		return world.ExportSVGFrames(svgOpts, outDir)
+	}
+	return nil, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "render format %q unknown, should be png or svg", opts.Format)
+}
@@ -0,0 +1,36 @@
+package m3server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/freddy33/qsm-go/backend/m3db"
+	"github.com/freddy33/qsm-go/backend/pathdb"
+	"github.com/freddy33/qsm-go/backend/pointdb"
+	"github.com/freddy33/qsm-go/m3util"
+)
+
+// Fit the predicted size models from the path nodes of the environment, save them and write them in the gen doc folder
+func FitPredictedSizeModelsEnv(env *m3db.QsmDbEnvironment) error {
+	models, err := pathdb.GetServerPathPackData(env).CalculatePredictedSizeModels()
+	if err != nil {
+		return err
+	}
+	err = pointdb.GetServerPointPackData(env).SavePredictedSizeModels(models)
+	if err != nil {
+		return err
+	}
+
+	csvFile := m3util.CreateFile(m3util.GetGenDocDir(), "PredictedSizeModels.csv")
+	defer m3util.CloseFile(csvFile)
+	csvWriter := csv.NewWriter(csvFile)
+	m3util.Write(csvWriter, []string{"growth_type", "growth_index", "a", "b", "c", "max_dist"})
+	for _, model := range models {
+		Log.Infof("%s fitted up to distance %d", model.String(), model.MaxDist)
+		m3util.Write(csvWriter, []string{
+			fmt.Sprint(int(model.GrowthType)), fmt.Sprint(model.GrowthIndex),
+			fmt.Sprintf("%.6f", model.A), fmt.Sprintf("%.6f", model.B), fmt.Sprintf("%.6f", model.C),
+			fmt.Sprint(model.MaxDist)})
+	}
+	csvWriter.Flush()
+	return nil
+}
@@ -0,0 +1,44 @@
+package m3server
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var httpRequests = m3util.QsmMetrics.Counter("qsm_http_requests_total",
+	"Number of REST API requests per method, route and status", "method", "route", "status")
+var httpRequestDuration = m3util.QsmMetrics.Histogram("qsm_http_request_duration_seconds",
+	"Duration of the REST API requests per method, route and status", m3util.DefaultDurationBuckets, "method", "route", "status")
+
+// Keep the status sent by the handler, 200 if only Write is called
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Record count and latency of the route using the path template to avoid one metric per URL
+func instrumentHandler(route string, handleFunc func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handleFunc(sr, r)
+		status := strconv.Itoa(sr.status)
+		httpRequests.Inc(r.Method, route, status)
+		httpRequestDuration.ObserveSince(start, r.Method, route, status)
+	}
+}
+
+func getMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	err := m3util.QsmMetrics.WriteText(w)
+	if err != nil {
+		Log.Errorf("failed to send metrics due to %q", err.Error())
+	}
+}
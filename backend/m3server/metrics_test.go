@@ -0,0 +1,43 @@
+package m3server
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	handler := instrumentHandler("/test-metrics", func(w http.ResponseWriter, r *http.Request) {
+		SendErrorf(w, r, http.StatusNotFound, "nothing here")
+	})
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("GET", "/test-metrics", nil)
+		assert.NoError(t, err)
+		handler(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, 3.0, httpRequests.Get("GET", "/test-metrics", "404"))
+	assert.Equal(t, uint64(3), httpRequestDuration.GetCount("GET", "/test-metrics", "404"))
+
+	gauge := 12.0
+	m3util.QsmMetrics.GaugeFunc("qsm_test_gauge", "Test gauge", func() []m3util.GaugeValue {
+		return []m3util.GaugeValue{{LabelValues: []string{"1"}, Value: gauge}}
+	}, "env")
+
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	getMetrics(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain"))
+	body := rr.Body.String()
+	assert.Contains(t, body, "# TYPE qsm_http_requests_total counter\n")
+	assert.Contains(t, body, `qsm_http_requests_total{method="GET",route="/test-metrics",status="404"} 3`+"\n")
+	assert.Contains(t, body, `qsm_http_request_duration_seconds_count{method="GET",route="/test-metrics",status="404"} 3`+"\n")
+	assert.Contains(t, body, `qsm_http_request_duration_seconds_bucket{method="GET",route="/test-metrics",status="404",le="+Inf"} 3`+"\n")
+	assert.Contains(t, body, "# TYPE qsm_db_query_duration_seconds histogram\n")
+	assert.Contains(t, body, "# TYPE qsm_path_nodes_created_total counter\n")
+	assert.Contains(t, body, `qsm_test_gauge{env="1"} 12`+"\n")
+}
@@ -0,0 +1,374 @@
+package m3server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const OpenApiVersion = "3.0.3"
+
+// Documentation of one method on one route of the REST API
+type routeDoc struct {
+	summary string
+	// The message read from query params for GET, or from the body in JSON or protobuf
+	request proto.Message
+	// The messages sent back with status 200, nil for plain text responses
+	responses []proto.Message
+	// Success status codes sending back a plain text message
+	textStatuses []int
+	// Query params not in the request message, with their description
+	queryParams map[string]string
+	// Content types of a 200 file download instead of messages
+	fileTypes []string
+}
+
+// Content types of the graph routes, see m3path.GraphFormat
+var graphFileTypes = []string{"application/graphml+xml", "text/vnd.graphviz", "application/json"}
+
+// All the documented routes with key "METHOD path" matching the routes registered in MakeApp
+var routeDocs = map[string]routeDoc{
+	"GET /":             {summary: "Display the environment id used", textStatuses: []int{http.StatusOK}},
+	"GET /openapi.json": {summary: "This OpenAPI document"},
+	"GET /metrics":      {summary: "Request, DB, path and cache metrics in Prometheus text format", textStatuses: []int{http.StatusOK}},
+	"GET /healthz":      {summary: "JSON status UP with the schema version when the process serves requests"},
+	"GET /readyz":       {summary: "JSON status READY when the DB answers and the environment data is initialized, 503 otherwise"},
+	"GET /log":          {summary: "JSON list of all the loggers with their level and the log output format"},
+	"POST /log":         {summary: "Change log levels with query params package=LEVEL, returns the JSON list of levels"},
+
+	"GET /list-env":    {summary: "List all the environments with their DB schema size", responses: []proto.Message{&m3api.EnvListMsg{}}},
+	"POST /init-env":   {summary: "Create and fill the DB schema of the environment", textStatuses: []int{http.StatusCreated}},
+	"DELETE /drop-env": {summary: "Drop the DB schema of the environment", textStatuses: []int{http.StatusOK}},
+
+	"GET /point-data": {summary: "All the static point data: connections, trios, growth contexts, cubes, path builders",
+		responses: []proto.Message{&m3api.PointPackDataMsg{}}},
+	"POST /growth-context": {summary: "Register a custom growth context from a trio sequence",
+		request: &m3api.GrowthContextMsg{}, responses: []proto.Message{&m3api.GrowthContextMsg{}}},
+
+	"GET /path-context": {summary: "All the path contexts if path_ctx_id is negative, or the one matching path_ctx_id",
+		request: &m3api.PathContextIdMsg{}, responses: []proto.Message{&m3api.PathContextListMsg{}, &m3api.PathContextMsg{}}},
+	"POST /path-context": {summary: "Get or create the path context for a growth type, index and offset",
+		request: &m3api.PathContextRequestMsg{}, responses: []proto.Message{&m3api.PathContextMsg{}}},
+	"GET /path-context/graph": {summary: "Download the path nodes from dist to to_dist, or from the root up to dist, as a graph with trio and connection ids",
+		request: &m3api.PathNodesRequestMsg{}, queryParams: map[string]string{exportFormatParam: "One of graphml, dot or d3"},
+		fileTypes: graphFileTypes},
+	"PUT /max-dist": {summary: "Grow the path context up to the requested distance",
+		request: &m3api.PathNodesRequestMsg{}, responses: []proto.Message{&m3api.PathNodesResponseMsg{}},
+		textStatuses: []int{http.StatusAccepted}},
+	"GET /path-nodes": {summary: "The path nodes at dist, or between dist and to_dist",
+		request: &m3api.PathNodesRequestMsg{}, responses: []proto.Message{&m3api.PathNodesResponseMsg{}}},
+	"GET /nb-path-nodes": {summary: "The number of path nodes at dist, or between dist and to_dist",
+		request: &m3api.PathNodesRequestMsg{}, responses: []proto.Message{&m3api.PathNodesResponseMsg{}}},
+
+	"GET /space": {summary: "All the spaces of the environment", responses: []proto.Message{&m3api.SpaceListMsg{}}},
+	"POST /space": {summary: "Create a new space",
+		request: &m3api.SpaceMsg{}, responses: []proto.Message{&m3api.SpaceMsg{}}},
+	"DELETE /space": {summary: "Delete a space and all its events",
+		request: &m3api.SpaceMsg{}, textStatuses: []int{http.StatusOK}},
+
+	"GET /event": {summary: "The events of a space",
+		request: &m3api.FindEventsMsg{}, responses: []proto.Message{&m3api.EventListMsg{}}},
+	"POST /event": {summary: "Create a new event in a space",
+		request: &m3api.CreateEventRequestMsg{}, responses: []proto.Message{&m3api.EventMsg{}}},
+
+	"GET /event-nodes": {summary: "The node events of an event",
+		request: &m3api.FindNodeEventsMsg{}, responses: []proto.Message{&m3api.NodeEventListMsg{}}},
+	"GET /space-time": {summary: "All the active nodes of a space at a given time",
+		request: &m3api.SpaceTimeRequestMsg{}, responses: []proto.Message{&m3api.SpaceTimeResponseMsg{}}},
+	"GET /space-time/export": {summary: "Download the nodes and links of a space at a given time as a 3D scene",
+		request: &m3api.SpaceTimeRequestMsg{}, queryParams: map[string]string{exportFormatParam: "One of gltf, ply or obj"},
+		fileTypes: []string{"model/gltf+json", "application/x-ply", "model/obj"}},
+	"GET /space-time/graph": {summary: "Download the nodes and links of a space at a given time as a graph with trio and connection ids",
+		request: &m3api.SpaceTimeRequestMsg{}, queryParams: map[string]string{exportFormatParam: "One of graphml, dot or d3"},
+		fileTypes: graphFileTypes},
+}
+
+type openApiBuilder struct {
+	doc     map[string]interface{}
+	paths   map[string]interface{}
+	schemas map[string]interface{}
+}
+
+func getRouteDocKey(method, path string) string {
+	return method + " " + path
+}
+
+// Walk the router and return all the "METHOD path" keys, routes without methods are treated as GET
+func getRouteKeys(router *mux.Router) ([]string, error) {
+	res := make([]string, 0, len(routeDocs))
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil || len(methods) == 0 {
+			methods = []string{"GET"}
+		}
+		for _, method := range methods {
+			res = append(res, getRouteDocKey(method, path))
+		}
+		return nil
+	})
+	return res, err
+}
+
+// The registered routes without entry in routeDocs
+func getUndocumentedRoutes(router *mux.Router) ([]string, error) {
+	keys, err := getRouteKeys(router)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0)
+	for _, key := range keys {
+		if _, ok := routeDocs[key]; !ok {
+			res = append(res, key)
+		}
+	}
+	return res, nil
+}
+
+func GenerateOpenApi(router *mux.Router) (map[string]interface{}, error) {
+	keys, err := getRouteKeys(router)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	b := &openApiBuilder{
+		paths:   make(map[string]interface{}),
+		schemas: make(map[string]interface{}),
+	}
+	for _, key := range keys {
+		sepIdx := strings.Index(key, " ")
+		method := key[:sepIdx]
+		path := key[sepIdx+1:]
+		rd, ok := routeDocs[key]
+		if !ok {
+			Log.Warnf("Route %q is not documented", key)
+			rd = routeDoc{summary: "Undocumented"}
+		}
+		pathItem, ok := b.paths[path].(map[string]interface{})
+		if !ok {
+			pathItem = make(map[string]interface{})
+			b.paths[path] = pathItem
+		}
+		pathItem[strings.ToLower(method)] = b.operation(method, rd)
+	}
+
+	b.doc = map[string]interface{}{
+		"openapi": OpenApiVersion,
+		"info": map[string]interface{}{
+			"title":       "QSM backend API",
+			"description": "Responses are JSON by default, protobuf when the request is protobuf or Accept is application/x-protobuf",
+			"version":     "1",
+		},
+		"paths": b.paths,
+		"components": map[string]interface{}{
+			"schemas": b.schemas,
+			"parameters": map[string]interface{}{
+				m3api.HttpEnvIdKey: map[string]interface{}{
+					"name":        m3api.HttpEnvIdKey,
+					"in":          "header",
+					"required":    false,
+					"description": "The environment id to use, the server one if not provided",
+					"schema":      map[string]interface{}{"type": "integer"},
+				},
+			},
+		},
+	}
+	return b.doc, nil
+}
+
+func (b *openApiBuilder) operation(method string, rd routeDoc) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary":    rd.summary,
+		"parameters": []interface{}{map[string]interface{}{"$ref": "#/components/parameters/" + m3api.HttpEnvIdKey}},
+	}
+	paramNames := make([]string, 0, len(rd.queryParams))
+	for name := range rd.queryParams {
+		paramNames = append(paramNames, name)
+	}
+	sort.Strings(paramNames)
+	for _, name := range paramNames {
+		op["parameters"] = append(op["parameters"].([]interface{}), map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"required":    true,
+			"description": rd.queryParams[name],
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	if rd.request != nil {
+		if method == "GET" {
+			op["parameters"] = append(op["parameters"].([]interface{}), b.queryParameters(rd.request)...)
+		} else {
+			ref := b.schemaRef(getMsgDescriptor(rd.request))
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json":       map[string]interface{}{"schema": ref},
+					"application/x-protobuf": map[string]interface{}{"schema": ref},
+				},
+			}
+		}
+	}
+
+	textContent := map[string]interface{}{
+		"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+	}
+	errorRef := b.schemaRef(getMsgDescriptor(&m3api.ErrorMsg{}))
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{
+			"description": "Error with a QsmErrorCode",
+			"content": map[string]interface{}{
+				"application/json":       map[string]interface{}{"schema": errorRef},
+				"application/x-protobuf": map[string]interface{}{"schema": errorRef},
+			},
+		},
+	}
+	if len(rd.responses) > 0 {
+		var schema interface{}
+		if len(rd.responses) == 1 {
+			schema = b.schemaRef(getMsgDescriptor(rd.responses[0]))
+		} else {
+			oneOf := make([]interface{}, len(rd.responses))
+			for i, msg := range rd.responses {
+				oneOf[i] = b.schemaRef(getMsgDescriptor(msg))
+			}
+			schema = map[string]interface{}{"oneOf": oneOf}
+		}
+		responses["200"] = map[string]interface{}{
+			"description": "Success",
+			"content": map[string]interface{}{
+				"application/json":       map[string]interface{}{"schema": schema},
+				"application/x-protobuf": map[string]interface{}{"schema": schema},
+			},
+		}
+	} else if len(rd.fileTypes) > 0 {
+		content := make(map[string]interface{}, len(rd.fileTypes))
+		for _, fileType := range rd.fileTypes {
+			content[fileType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}
+		}
+		responses["200"] = map[string]interface{}{"description": "Success", "content": content}
+	} else if len(rd.textStatuses) == 0 {
+		// Plain JSON not from protobuf messages
+		responses["200"] = map[string]interface{}{
+			"description": "Success",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}},
+			},
+		}
+	}
+	for _, status := range rd.textStatuses {
+		responses[fmt.Sprint(status)] = map[string]interface{}{"description": http.StatusText(status), "content": textContent}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// GET requests read the scalar fields of the message from query params using the proto field names
+func (b *openApiBuilder) queryParameters(msg proto.Message) []interface{} {
+	fields := getMsgDescriptor(msg).Fields()
+	res := make([]interface{}, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind || fd.IsMap() {
+			continue
+		}
+		res = append(res, map[string]interface{}{
+			"name":     string(fd.Name()),
+			"in":       "query",
+			"required": false,
+			"schema":   b.fieldSchema(fd),
+		})
+	}
+	return res
+}
+
+func getMsgDescriptor(msg proto.Message) protoreflect.MessageDescriptor {
+	return proto.MessageV2(msg).ProtoReflect().Descriptor()
+}
+
+func (b *openApiBuilder) schemaRef(md protoreflect.MessageDescriptor) map[string]interface{} {
+	name := string(md.Name())
+	if _, ok := b.schemas[name]; !ok {
+		schema := map[string]interface{}{"type": "object"}
+		// Register before the fields for recursive messages
+		b.schemas[name] = schema
+		properties := make(map[string]interface{})
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			// The JSON encoding of the server uses the proto field names
+			properties[string(fd.Name())] = b.fieldSchema(fd)
+		}
+		schema["properties"] = properties
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func (b *openApiBuilder) fieldSchema(fd protoreflect.FieldDescriptor) interface{} {
+	if fd.IsMap() {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": b.fieldSchema(fd.MapValue()),
+		}
+	}
+	var single interface{}
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		single = b.schemaRef(fd.Message())
+	case protoreflect.BoolKind:
+		single = map[string]interface{}{"type": "boolean"}
+	case protoreflect.EnumKind:
+		single = map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		single = map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		single = map[string]interface{}{"type": "integer", "format": "int32", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		single = map[string]interface{}{"type": "integer", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		single = map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.FloatKind:
+		single = map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		single = map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		single = map[string]interface{}{"type": "string"}
+	case protoreflect.BytesKind:
+		single = map[string]interface{}{"type": "string", "format": "byte"}
+	default:
+		single = map[string]interface{}{}
+	}
+	if fd.IsList() {
+		return map[string]interface{}{"type": "array", "items": single}
+	}
+	return single
+}
+
+func (app *QsmApp) openApiSpec(w http.ResponseWriter, r *http.Request) {
+	doc, err := GenerateOpenApi(app.Router)
+	if err != nil {
+		SendError(w, r, http.StatusInternalServerError, m3util.MakeWrapQsmErrorf(err, "Failed to generate OpenAPI document due to: %s", err.Error()))
+		return
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		SendError(w, r, http.StatusInternalServerError, m3util.MakeWrapQsmErrorf(err, "Failed to marshal OpenAPI document due to: %s", err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		Log.Errorf("failed to send data to response due to %q", err.Error())
+	}
+}
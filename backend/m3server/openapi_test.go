@@ -0,0 +1,114 @@
+package m3server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Only the routes are needed, no DB environment
+func makeRoutesOnlyApp() *QsmApp {
+	app := &QsmApp{Router: mux.NewRouter()}
+	app.addRoutes()
+	return app
+}
+
+func TestAllRoutesDocumented(t *testing.T) {
+	app := makeRoutesOnlyApp()
+	undocumented, err := getUndocumentedRoutes(app.Router)
+	assert.NoError(t, err)
+	assert.Empty(t, undocumented, "routes registered in MakeApp without entry in routeDocs")
+
+	keys, err := getRouteKeys(app.Router)
+	assert.NoError(t, err)
+	registered := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		registered[key] = true
+	}
+	for key := range routeDocs {
+		assert.True(t, registered[key], "documented route %q is not registered", key)
+	}
+}
+
+func collectRefs(node interface{}, refs map[string]bool) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if key == "$ref" {
+				refs[value.(string)] = true
+			} else {
+				collectRefs(value, refs)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			collectRefs(value, refs)
+		}
+	}
+}
+
+func TestOpenApiSpec(t *testing.T) {
+	app := makeRoutesOnlyApp()
+	req, err := http.NewRequest("GET", "/openapi.json", nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	app.openApiSpec(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	doc := make(map[string]interface{})
+	err = json.Unmarshal(rr.Body.Bytes(), &doc)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, OpenApiVersion, doc["openapi"])
+
+	paths := doc["paths"].(map[string]interface{})
+	for key := range routeDocs {
+		sepIdx := strings.Index(key, " ")
+		pathItem, ok := paths[key[sepIdx+1:]].(map[string]interface{})
+		if assert.True(t, ok, "missing path for %q", key) {
+			assert.NotNil(t, pathItem[strings.ToLower(key[:sepIdx])], "missing operation for %q", key)
+		}
+	}
+
+	// GET requests are documented with query parameters
+	getNodes := paths["/path-nodes"].(map[string]interface{})["get"].(map[string]interface{})
+	paramNames := make([]string, 0)
+	for _, param := range getNodes["parameters"].([]interface{}) {
+		name, ok := param.(map[string]interface{})["name"]
+		if ok {
+			paramNames = append(paramNames, name.(string))
+		}
+	}
+	assert.Equal(t, []string{"path_ctx_id", "dist", "to_dist"}, paramNames)
+
+	// Export downloads a file in the requested format
+	export := paths["/space-time/export"].(map[string]interface{})["get"].(map[string]interface{})
+	exportParams := export["parameters"].([]interface{})
+	assert.Equal(t, exportFormatParam, exportParams[1].(map[string]interface{})["name"])
+	exportOk := export["responses"].(map[string]interface{})["200"].(map[string]interface{})
+	assert.Contains(t, exportOk["content"], "model/gltf+json")
+
+	// Every reference points to a defined component
+	components := doc["components"].(map[string]interface{})
+	schemas := components["schemas"].(map[string]interface{})
+	assert.Contains(t, schemas, "PathNodesResponseMsg")
+	assert.Contains(t, schemas, "PathNodeMsg")
+	assert.Contains(t, schemas, "PointMsg")
+	refs := make(map[string]bool)
+	collectRefs(doc, refs)
+	for ref := range refs {
+		parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+		if assert.Len(t, parts, 2, "bad ref %q", ref) {
+			section, ok := components[parts[0]].(map[string]interface{})
+			if assert.True(t, ok, "bad ref %q", ref) {
+				assert.Contains(t, section, parts[1], "bad ref %q", ref)
+			}
+		}
+	}
+}
@@ -13,7 +13,10 @@ import (
 func getPathContexts(w http.ResponseWriter, r *http.Request) {
 	Log.Infof("Receive getPathContexts")
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	pathData := pathdb.GetServerPathPackData(env)
 
 	reqMsg := &m3api.PathContextIdMsg{}
@@ -46,7 +49,7 @@ func getPathContexts(w http.ResponseWriter, r *http.Request) {
 	} else {
 		pathCtx := pathData.GetPathCtxDb(m3path.PathContextId(reqMsg.PathCtxId))
 		if pathCtx == nil {
-			SendResponse(w, http.StatusNotFound, "Path context with ID %d does not exists", reqMsg.PathCtxId)
+			SendErrorf(w, r, http.StatusNotFound, "Path context with ID %d does not exists", reqMsg.PathCtxId)
 			return
 		}
 		toSendMsg = pathContextToMsg(pathCtx)
@@ -62,14 +65,17 @@ func createPathContext(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	pathData := pathdb.GetServerPathPackData(env)
 	pathCtx, err := pathData.GetPathCtxDbFromAttributes(
 		m3point.GrowthType(reqMsg.GetGrowthType()),
 		int(reqMsg.GetGrowthIndex()),
 		int(reqMsg.GetGrowthOffset()))
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -86,7 +92,21 @@ func pathContextToMsg(pathCtx *pathdb.PathContextDb) *m3api.PathContextMsg {
 		GrowthOffset:    int32(pathCtx.GetGrowthOffset()),
 		RootPathNode:    pathNodeToMsg(pathNodeDb),
 		MaxDist:         int32(pathCtx.GetMaxDist()),
+		DistStats:       distStatsToMsg(pathCtx.GetDistStats()),
+	}
+}
+
+func distStatsToMsg(stats []m3path.PathDistStat) []*m3api.PathDistStatMsg {
+	res := make([]*m3api.PathDistStatMsg, len(stats))
+	for i, stat := range stats {
+		res[i] = &m3api.PathDistStatMsg{
+			D:           int32(stat.D),
+			NbNodes:     int32(stat.NbNodes),
+			NbDeadEnds:  int32(stat.NbDeadEnds),
+			NbConflicts: int32(stat.NbConflicts),
+		}
 	}
+	return res
 }
 
 func increaseMaxDist(w http.ResponseWriter, r *http.Request) {
@@ -106,13 +126,13 @@ func increaseMaxDist(w http.ResponseWriter, r *http.Request) {
 
 	// Below distance 25 all allowed, above only increases of 3 are allowed
 	if reqDist > 25 && reqDist-initialMaxDist > 3 {
-		SendResponse(w, http.StatusRequestEntityTooLarge, "Path context %d has max dist %d which is too far away from the requested dist %d.\n"+
+		SendErrorf(w, r, http.StatusRequestEntityTooLarge, "Path context %d has max dist %d which is too far away from the requested dist %d.\n"+
 			"Please request smaller increment in max distance.", pathCtx.GetId(), initialMaxDist, reqDist)
 		return
 	}
-	err := pathCtx.RequestNewMaxDist(reqDist)
+	err := pathCtx.(*pathdb.PathContextDb).RequestNewMaxDistContext(r.Context(), reqDist)
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	finalMaxDist := pathCtx.GetMaxDist()
@@ -136,13 +156,14 @@ func getPathNodes(w http.ResponseWriter, r *http.Request) {
 	}
 	var pathNodes []m3path.PathNode
 	var err error
+	pathCtxDb := pathCtx.(*pathdb.PathContextDb)
 	if toDist <= 0 {
-		pathNodes, err = pathCtx.GetPathNodesAt(fromDist)
+		pathNodes, err = pathCtxDb.GetPathNodesAtContext(r.Context(), fromDist)
 	} else {
-		pathNodes, err = pathCtx.GetPathNodesBetween(fromDist, toDist)
+		pathNodes, err = pathCtxDb.GetPathNodesBetweenContext(r.Context(), fromDist, toDist)
 	}
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	nbPathNodes := len(pathNodes)
@@ -176,7 +197,7 @@ func getNbPathNodes(w http.ResponseWriter, r *http.Request) {
 		nbPathNodes = pathCtx.GetNumberOfNodesBetween(fromDist, toDist)
 	}
 	if nbPathNodes < 1 {
-		SendResponse(w, http.StatusInternalServerError, "Could not retrieve the count of %s between dist %d and %d", pathCtx.String(), fromDist, toDist)
+		SendErrorf(w, r, http.StatusInternalServerError, "Could not retrieve the count of %s between dist %d and %d", pathCtx.String(), fromDist, toDist)
 		return
 	}
 	resMsg := createPathNodesResponse(pathCtx, fromDist, toDist, nbPathNodes)
@@ -200,18 +221,18 @@ func verifyMaxDist(w http.ResponseWriter, r *http.Request, pathCtx m3path.PathCo
 	toDist := int(reqMsg.ToDist)
 	if toDist <= 0 {
 		if fromDist > pathCtx.GetMaxDist() {
-			SendResponse(w, http.StatusUnprocessableEntity, "path context id %d has a max dist %d which is below the requested %d",
+			SendErrorf(w, r, http.StatusUnprocessableEntity, "path context id %d has a max dist %d which is below the requested %d",
 				reqMsg.GetPathCtxId(), pathCtx.GetMaxDist(), fromDist)
 			return false, -1, -1
 		}
 	} else {
 		if toDist < fromDist {
-			SendResponse(w, http.StatusBadRequest, "request to_dist value %d not zero but below the starting requested dist %d for request on path context id %d",
+			SendErrorf(w, r, http.StatusBadRequest, "request to_dist value %d not zero but below the starting requested dist %d for request on path context id %d",
 				toDist, fromDist, reqMsg.GetPathCtxId())
 			return false, -1, -1
 		}
 		if toDist > pathCtx.GetMaxDist() {
-			SendResponse(w, http.StatusUnprocessableEntity, "path context id %d has a max dist %d which is below the requested %d",
+			SendErrorf(w, r, http.StatusUnprocessableEntity, "path context id %d has a max dist %d which is below the requested %d",
 				reqMsg.GetPathCtxId(), pathCtx.GetMaxDist(), toDist)
 			return false, -1, -1
 		}
@@ -225,12 +246,15 @@ func extractPathNodeRequest(w http.ResponseWriter, r *http.Request) (*m3api.Path
 		return nil, nil
 	}
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return nil, nil
+	}
 	pathData := pathdb.GetServerPathPackData(env)
 
 	pathCtx := pathData.GetPathCtx(m3path.PathContextId(reqMsg.GetPathCtxId()))
 	if pathCtx == nil {
-		SendResponse(w, http.StatusBadRequest, "path context id %d does not exists", reqMsg.GetPathCtxId())
+		SendErrorf(w, r, http.StatusNotFound, "path context id %d does not exists", reqMsg.GetPathCtxId())
 		return nil, nil
 	}
 	return reqMsg, pathCtx
@@ -4,6 +4,7 @@ import (
 	"github.com/freddy33/qsm-go/backend/pointdb"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/freddy33/qsm-go/model/m3point"
 	"net/http"
 )
 
@@ -12,7 +13,10 @@ var Log = m3util.NewLogger("m3server", m3util.INFO)
 func retrievePointData(w http.ResponseWriter, r *http.Request) {
 	Log.Infof("Receive retrievePointData")
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	pointData := pointdb.GetServerPointPackData(env)
 	msg := m3api.PointPackDataMsg{}
 
@@ -55,15 +59,73 @@ func retrievePointData(w http.ResponseWriter, r *http.Request) {
 	}
 	Log.Debug("sending all valid trios and permutations")
 
-	msg.AllGrowthContexts = make([]*m3api.GrowthContextMsg, len(pointData.AllGrowthContexts))
-	for idx, gc := range pointData.AllGrowthContexts {
-		msg.AllGrowthContexts[idx] = &m3api.GrowthContextMsg{
-			GrowthContextId: int32(gc.GetId()),
-			GrowthType:      int32(gc.GetGrowthType()),
-			GrowthIndex:     int32(gc.GetGrowthIndex()),
-		}
+	allGrowthContexts := pointData.GetAllGrowthContexts()
+	msg.AllGrowthContexts = make([]*m3api.GrowthContextMsg, len(allGrowthContexts))
+	for idx, gc := range allGrowthContexts {
+		msg.AllGrowthContexts[idx] = growthContextToMsg(gc)
 	}
 	Log.Debug("sending all growth context", len(msg.AllGrowthContexts))
 
+	sizeModels := pointData.GetAllPredictedSizeModels()
+	msg.PredictedSizeModels = make([]*m3api.PredictedSizeModelMsg, len(sizeModels))
+	for idx, model := range sizeModels {
+		msg.PredictedSizeModels[idx] = &m3api.PredictedSizeModelMsg{
+			GrowthType:  int32(model.GrowthType),
+			GrowthIndex: int32(model.GrowthIndex),
+			A:           model.A,
+			B:           model.B,
+			C:           model.C,
+			MaxDist:     int32(model.MaxDist),
+		}
+	}
+	Log.Debug("sending all predicted size models", len(msg.PredictedSizeModels))
+
 	WriteResponseMsg(w, r, &msg)
 }
+
+func createGrowthContext(w http.ResponseWriter, r *http.Request) {
+	Log.Infof("Receive createGrowthContext")
+
+	reqMsg := &m3api.GrowthContextMsg{}
+	if !ReadRequestMsg(w, r, reqMsg) {
+		return
+	}
+
+	trioSequence := make([]m3point.TrioIndex, len(reqMsg.GetTrioSequence()))
+	for i, trIdx := range reqMsg.GetTrioSequence() {
+		if trIdx < 0 {
+			SendErrorf(w, r, http.StatusBadRequest, "trio index %d at %d in sequence %v is invalid", trIdx, i, reqMsg.GetTrioSequence())
+			return
+		}
+		trioSequence[i] = m3point.TrioIndex(trIdx)
+	}
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
+	pointData := pointdb.GetServerPointPackData(env)
+	growthCtx, err := pointData.AddCustomGrowthContext(trioSequence)
+	if err != nil {
+		// A sequence failing the validation is a bad request
+		SendError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	WriteResponseMsg(w, r, growthContextToMsg(growthCtx))
+}
+
+func growthContextToMsg(growthCtx m3point.GrowthContext) *m3api.GrowthContextMsg {
+	var trioSequence []int32
+	if len(growthCtx.GetTrioSequence()) > 0 {
+		trioSequence = make([]int32, len(growthCtx.GetTrioSequence()))
+		for i, trIdx := range growthCtx.GetTrioSequence() {
+			trioSequence[i] = int32(trIdx)
+		}
+	}
+	return &m3api.GrowthContextMsg{
+		GrowthContextId: int32(growthCtx.GetId()),
+		GrowthType:      int32(growthCtx.GetGrowthType()),
+		GrowthIndex:     int32(growthCtx.GetGrowthIndex()),
+		TrioSequence:    trioSequence,
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"log"
 	"net/http"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,46 +27,133 @@ type QsmApp struct {
 	Server         *http.Server
 	Router         *mux.Router
 	Env            *m3db.QsmDbEnvironment
+	// nil when no auth file, all requests allowed
+	Auth *AuthConfig
+	// Draining of the computations on shutdown
+	lifecycle appLifecycle
+}
+
+// Writes the Server-Timing header of the request trace just before the status
+type traceResponseWriter struct {
+	http.ResponseWriter
+	trace         *m3util.RequestTrace
+	headerWritten bool
+}
+
+func (tw *traceResponseWriter) WriteHeader(status int) {
+	if !tw.headerWritten {
+		tw.headerWritten = true
+		tw.Header().Set(m3api.HttpServerTimingKey, tw.trace.ServerTiming())
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *traceResponseWriter) Write(b []byte) (int, error) {
+	if !tw.headerWritten {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Use the request id sent by the caller or generate one, and start the trace of the request
+func startRequestTrace(w http.ResponseWriter, r *http.Request) (*traceResponseWriter, context.Context) {
+	requestId := r.Header.Get(m3api.HttpRequestIdKey)
+	if requestId == "" {
+		requestId = m3util.NewRequestId()
+		r.Header.Set(m3api.HttpRequestIdKey, requestId)
+	}
+	w.Header().Set(m3api.HttpRequestIdKey, requestId)
+	trace := m3util.NewRequestTrace(requestId)
+	return &traceResponseWriter{ResponseWriter: w, trace: trace}, m3util.WithTrace(r.Context(), trace)
 }
 
 func (app *QsmApp) AddHandler(path string, handleFunc func(http.ResponseWriter, *http.Request)) *mux.Route {
-	return app.Router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-		envId := app.Env.GetId()
+	return app.Router.HandleFunc(path, instrumentHandler(path, func(w http.ResponseWriter, r *http.Request) {
+		tw, ctx := startRequestTrace(w, r)
+		r = r.WithContext(ctx)
+		defer logRequestTrace(r, tw.trace)
+		var envId m3util.QsmEnvID
 		fromHeader := r.Header.Get(m3api.HttpEnvIdKey)
 		if fromHeader == "" {
+			envId = app.Env.GetId()
 			r.Header.Add(m3api.HttpEnvIdKey, app.Env.GetEnvNumber())
 		} else {
-			envId = m3util.ReadEnvId(fmt.Sprintf("header var %q", m3api.HttpEnvIdKey), fromHeader)
+			id, err := strconv.Atoi(fromHeader)
+			if err != nil {
+				SendError(tw, r, http.StatusBadRequest, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "header %s=%q is not an env id", m3api.HttpEnvIdKey, fromHeader))
+				return
+			}
+			envId = m3util.QsmEnvID(id)
 		}
-		ctx := context.WithValue(r.Context(), m3api.HttpEnvIdKey, envId)
-		handleFunc(w, r.WithContext(ctx))
-	})
+		status, err := app.Auth.authorize(r, path, envId)
+		if err != nil {
+			SendError(tw, r, status, err)
+			return
+		}
+		ctx = context.WithValue(ctx, m3api.HttpEnvIdKey, envId)
+		if isComputeRoute(getRouteDocKey(r.Method, path)) {
+			app.runComputation(tw, r.WithContext(ctx), handleFunc)
+			return
+		}
+		handleFunc(tw, r.WithContext(ctx))
+	}))
+}
+
+func logRequestTrace(r *http.Request, trace *m3util.RequestTrace) {
+	if m3util.TraceLog.IsDebug() {
+		m3util.TraceLog.Debugf("request %s %s %s done in %v: %s", trace.GetId(), r.Method, r.URL.Path, trace.GetElapsed(), trace.ServerTiming())
+	}
 }
 
 func GetEnvId(r *http.Request) m3util.QsmEnvID {
 	return r.Context().Value(m3api.HttpEnvIdKey).(m3util.QsmEnvID)
 }
 
-func GetEnvironment(r *http.Request) *m3db.QsmDbEnvironment {
-	env := m3db.GetEnvironment(GetEnvId(r))
+// The environment of the request opened on first use. If the DB cannot be reached the error is sent and nil returned.
+func GetEnvironment(w http.ResponseWriter, r *http.Request) *m3db.QsmDbEnvironment {
+	env, err := m3db.OpenEnvironment(GetEnvId(r))
+	if err != nil {
+		SendError(w, r, http.StatusServiceUnavailable, err)
+		return nil
+	}
 	if !env.DataChecked(m3util.SpaceIdx) {
 		spacedb.GetSpaceDbFullEnv(env.GetId())
 	}
 	return env
 }
 
+// Plain text response, errors should use SendError to respect the content type negotiation
 func SendResponse(w http.ResponseWriter, status int, format string, args ...interface{}) {
 	if status >= 400 {
 		Log.Errorf(format, args...)
 	}
-	w.WriteHeader(status)
+	// Headers are ignored once the status is written
 	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(status)
 	_, err := fmt.Fprintf(w, format, args...)
 	if err != nil {
 		log.Printf("failed to send data to response due to %q", err.Error())
 	}
 }
 
+// Send an ErrorMsg using the QsmErrorCode of the error, or the one matching the status if none
+// The code of the error, when it has one, decides the HTTP status. Otherwise the code is derived from the status.
+func SendError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	code := m3util.GetQsmErrorCode(err)
+	if code == m3util.ErrUnknown {
+		code = m3api.HttpStatusToErrorCode(status)
+	} else {
+		status = m3api.ErrorCodeToHttpStatus(code)
+	}
+	requestId := r.Header.Get(m3api.HttpRequestIdKey)
+	Log.Errorf("%s %s failed with %d %s: %v", r.Method, r.URL.Path, status, code.String(), err)
+	writeMsg(w, r, status, m3api.MakeErrorMsg(code, err, requestId))
+}
+
+func SendErrorf(w http.ResponseWriter, r *http.Request, status int, format string, args ...interface{}) {
+	SendError(w, r, status, m3util.MakeQsmErrorf(format, args...))
+}
+
 func getRequestType(w http.ResponseWriter, r *http.Request) string {
 	reqContentType := r.Header.Get("Content-Type")
 	if strings.HasPrefix(reqContentType, "application/json") {
@@ -93,7 +181,7 @@ func ReadRequestMsg(w http.ResponseWriter, r *http.Request, reqMsg proto.Message
 	}
 
 	if err != nil {
-		SendResponse(w, http.StatusBadRequest, "req body could not be read req body due to: %s", err.Error())
+		SendErrorf(w, r, http.StatusBadRequest, "req body could not be read req body due to: %s", err.Error())
 		return false
 	}
 	if reqContentType == "query" {
@@ -106,60 +194,52 @@ func ReadRequestMsg(w http.ResponseWriter, r *http.Request, reqMsg proto.Message
 		return false
 	}
 	if err != nil {
-		SendResponse(w, http.StatusBadRequest, "req body could not be parsed due to: %s", err.Error())
+		SendErrorf(w, r, http.StatusBadRequest, "req body could not be parsed due to: %s", err.Error())
 		return false
 	}
 	return true
 }
 
 func WriteResponseMsg(w http.ResponseWriter, r *http.Request, resMsg proto.Message) {
-	var useProtobuf, useJson bool
+	writeMsg(w, r, http.StatusOK, resMsg)
+}
 
-	reqContentType := getRequestType(w, r)
-	// Return same type has request payload by default
-	if reqContentType == "query" {
-		// return json payload by default on query params
-		useProtobuf = false
-		useJson = true
-	} else if reqContentType == "json" {
-		useProtobuf = false
-		useJson = true
-	} else if reqContentType == "proto" {
-		useProtobuf = true
-		useJson = false
-	} else {
-		return
-	}
-	// If accept tells me ok to use proto switch to it
-	acceptContents := r.Header.Values("Accept")
-	for _, ac := range acceptContents {
+// Return same type has request payload by default, unless accept allows protobuf
+func useProtobufResponse(r *http.Request) bool {
+	for _, ac := range r.Header.Values("Accept") {
 		if strings.HasPrefix(ac, "application/x-protobuf") {
-			useProtobuf = true
-			useJson = false
-			break
+			return true
 		}
 	}
+	// return json payload by default on query params
+	return getRequestType(nil, r) == "proto"
+}
 
+func writeMsg(w http.ResponseWriter, r *http.Request, status int, resMsg proto.Message) {
 	typeName := reflect.TypeOf(resMsg).String()
 	typeName = strings.TrimPrefix(typeName, "*")
 
 	var data []byte
 	var err error
-	if useProtobuf {
+	var contentType string
+	endSerialize := m3util.StartSpan(r.Context(), "serialize")
+	if useProtobufResponse(r) {
 		data, err = proto.Marshal(resMsg)
-		w.Header().Set("Content-Type", "application/x-protobuf; messageType="+typeName)
-	} else if useJson {
-		data, err = json.Marshal(resMsg)
-		w.Header().Set("Content-Type", "application/json; messageType="+typeName)
+		contentType = "application/x-protobuf; messageType=" + typeName
 	} else {
-		SendResponse(w, http.StatusBadRequest, "No acceptable content type for response found in %v", acceptContents)
+		data, err = json.Marshal(resMsg)
+		contentType = "application/json; messageType=" + typeName
 	}
+	endSerialize()
 
 	if err != nil {
+		// Not an ErrorMsg since marshalling is what failed
 		SendResponse(w, http.StatusInternalServerError, "Failed to marshal %q due to: %s", typeName, err.Error())
 		return
 	}
 
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
 	_, err = w.Write(data)
 	if err != nil {
 		Log.Errorf("failed to send data to response due to %q", err.Error())
@@ -172,19 +252,23 @@ func home(w http.ResponseWriter, r *http.Request) {
 
 func listEnv(w http.ResponseWriter, r *http.Request) {
 	// Need direct DB connection no schema
-	dbConf := config.NewDBConfig()
+	dbConf, err := config.NewDBConfig()
+	if err != nil {
+		SendError(w, r, http.StatusInternalServerError, err)
+		return
+	}
 	env := m3db.NewQsmDbEnvironment(dbConf)
 	defer env.CloseDb()
 
 	if env.GetConnection() == nil {
 		err := env.OpenDb()
 		if err != nil {
-			SendResponse(w, http.StatusInternalServerError, err.Error())
+			SendError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 	}
 	if !env.Ping() {
-		SendResponse(w, http.StatusInternalServerError, fmt.Sprintf("Could not open DB connection to %s:%d %q", dbConf.DBHost, dbConf.DBPort, dbConf.DBName))
+		SendErrorf(w, r, http.StatusInternalServerError, "Could not open DB connection to %s:%d %q", dbConf.DBHost, dbConf.DBPort, dbConf.DBName)
 		return
 	}
 
@@ -200,7 +284,7 @@ func listEnv(w http.ResponseWriter, r *http.Request) {
 		"    WHERE ns.nspname like 'qsm%') t" +
 		" GROUP BY schema_name ORDER BY schema_size DESC")
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -213,12 +297,12 @@ func listEnv(w http.ResponseWriter, r *http.Request) {
 		var schemaSize, totDbSize int64
 		err = rows.Scan(&schemaName, &schemaSize, &totDbSize)
 		if err != nil {
-			SendResponse(w, http.StatusInternalServerError, err.Error())
+			SendError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 		envId, err := strconv.Atoi(strings.TrimPrefix(schemaName, "qsm"))
 		if err != nil {
-			SendResponse(w, http.StatusInternalServerError, err.Error())
+			SendError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
@@ -236,79 +320,90 @@ func listEnv(w http.ResponseWriter, r *http.Request) {
 func initializeEnv(w http.ResponseWriter, r *http.Request) {
 	Log.Infof("Receive initializeEnv")
 	envId := GetEnvId(r)
-	spacedb.GetSpaceDbFullEnv(envId)
+	if GetEnvironment(w, r) == nil {
+		return
+	}
 	SendResponse(w, http.StatusCreated, "Test env id %d was initialized", envId)
 }
 
 func dropEnv(w http.ResponseWriter, r *http.Request) {
 	Log.Infof("Receive dropEnv")
-	env := m3db.GetEnvironment(GetEnvId(r))
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	envId := env.GetId()
 	env.Destroy()
 	SendResponse(w, http.StatusOK, "Test env id %d was deleted", envId)
 }
 
+func makeLogLevelsMsg(updated []string) *m3api.LogLevelsMsg {
+	levels := m3util.GetAllLoggerLevels()
+	res := &m3api.LogLevelsMsg{Format: "text", Loggers: make([]m3api.LoggerLevelMsg, 0, len(levels)), Updated: updated}
+	if m3util.IsJsonLogOutput() {
+		res.Format = "json"
+	}
+	for name, level := range levels {
+		res.Loggers = append(res.Loggers, m3api.LoggerLevelMsg{Name: name, Level: m3util.GetLevelName(level)})
+	}
+	sort.Slice(res.Loggers, func(i, j int) bool {
+		return res.Loggers[i].Name < res.Loggers[j].Name
+	})
+	sort.Strings(res.Updated)
+	return res
+}
+
+func getLogLevels(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, makeLogLevelsMsg(nil))
+}
+
+// Group names setting the level of several loggers
+var logLevelGroups = map[string][]string{
+	"services": {"pointdb", "pathdb", "spacedb"},
+	"space":    {"m3space", "spacedb"},
+	"path":     {"m3path", "pathdb"},
+	"point":    {"m3point", "pointdb"},
+}
+
 func logLevel(w http.ResponseWriter, r *http.Request) {
 	Log.Infof("Receive logLevel")
 
 	values := r.URL.Query()
 	if len(values) == 0 {
-		SendResponse(w, http.StatusBadRequest, "Please provide a new level for packages as query parameter!")
+		SendErrorf(w, r, http.StatusBadRequest, "Please provide a new level for packages as query parameter!")
 		return
 	}
+	updated := make([]string, 0, len(values))
 	for packName, listLevels := range values {
 		if len(listLevels) != 1 {
-			SendResponse(w, http.StatusBadRequest, "Please provide a specific level for package name %q in your query parameter!", packName)
+			SendErrorf(w, r, http.StatusBadRequest, "Please provide a specific level for package name %q in your query parameter!", packName)
 			return
 		}
-		foundLevel := m3util.LogLevel(-1)
-		newLevel := strings.ToUpper(listLevels[0])
-		for _, lv := range m3util.GetAllLogLevels() {
-			if m3util.GetLevelName(lv) == newLevel {
-				foundLevel = lv
-				break
-			}
-		}
-		if foundLevel < 0 {
-			intVal, err := strconv.Atoi(newLevel)
-			if err != nil {
-				SendResponse(w, http.StatusBadRequest, "The level provided %q for package name %q is not valid.", newLevel, packName)
-				return
-			}
-			for _, lv := range m3util.GetAllLogLevels() {
-				if intVal == int(lv) {
-					foundLevel = lv
-					break
-				}
-			}
-		}
-		if foundLevel < 0 {
-			SendResponse(w, http.StatusBadRequest, "The level provided %q for package name %q is not valid.", newLevel, packName)
+		foundLevel, ok := m3util.ParseLogLevel(listLevels[0])
+		if !ok {
+			SendErrorf(w, r, http.StatusBadRequest, "The level provided %q for package name %q is not valid.", listLevels[0], packName)
 			return
 		}
 		if packName == "all" {
 			m3util.SetLogLevelForAll(foundLevel)
-		} else if packName == "services" {
-			m3util.SetLoggerLevel("pointdb", foundLevel)
-			m3util.SetLoggerLevel("pathdb", foundLevel)
-			m3util.SetLoggerLevel("spacedb", foundLevel)
-		} else if packName == "space" {
-			m3util.SetLoggerLevel("m3space", foundLevel)
-			m3util.SetLoggerLevel("spacedb", foundLevel)
-		} else if packName == "path" {
-			m3util.SetLoggerLevel("m3path", foundLevel)
-			m3util.SetLoggerLevel("pathdb", foundLevel)
-		} else if packName == "point" {
-			m3util.SetLoggerLevel("m3point", foundLevel)
-			m3util.SetLoggerLevel("pointdb", foundLevel)
-		} else {
+			for name := range m3util.GetAllLoggerLevels() {
+				updated = append(updated, name)
+			}
+		} else if group, ok := logLevelGroups[packName]; ok {
+			for _, name := range group {
+				m3util.SetLoggerLevel(name, foundLevel)
+			}
+			updated = append(updated, group...)
+		} else if m3util.GetLogger(packName) != nil {
 			m3util.SetLoggerLevel(packName, foundLevel)
+			updated = append(updated, packName)
+		} else {
+			SendErrorf(w, r, http.StatusNotFound, "No logger named %q", packName)
+			return
 		}
 	}
 
-	// TODO: Send in response the log levels updated
-	response := "Updated Log Levels"
-	SendResponse(w, http.StatusOK, response)
+	writeJson(w, http.StatusOK, makeLogLevelsMsg(updated))
 }
 
 func MakeApp(envId m3util.QsmEnvID) *QsmApp {
@@ -316,11 +411,26 @@ func MakeApp(envId m3util.QsmEnvID) *QsmApp {
 		envId = m3util.GetDefaultEnvId()
 	}
 	env := m3db.GetEnvironment(envId)
+	auth, err := LoadAuthConfig()
+	if err != nil {
+		Log.Fatal(err)
+		return nil
+	}
 	r := mux.NewRouter()
-	app := &QsmApp{Router: r, Env: env}
+	app := &QsmApp{Router: r, Env: env, Auth: auth}
+	app.addRoutes()
+	return app
+}
+
+// All the routes need to be documented in routeDocs of openapi.go and have a scope in routeScopes of auth.go
+func (app *QsmApp) addRoutes() {
 	app.AddHandler("/", home)
+	app.AddHandler("/openapi.json", app.openApiSpec).Methods("GET")
+	app.AddHandler("/metrics", getMetrics).Methods("GET")
+	app.AddHandler("/healthz", healthz).Methods("GET")
+	app.AddHandler("/readyz", app.readyz).Methods("GET")
 
-	// TODO: MAke also a getter to list current log level
+	app.AddHandler("/log", getLogLevels).Methods("GET")
 	app.AddHandler("/log", logLevel).Methods("POST")
 
 	app.AddHandler("/list-env", listEnv).Methods("GET")
@@ -328,9 +438,11 @@ func MakeApp(envId m3util.QsmEnvID) *QsmApp {
 	app.AddHandler("/drop-env", dropEnv).Methods("DELETE")
 
 	app.AddHandler("/point-data", retrievePointData).Methods("GET")
+	app.AddHandler("/growth-context", createGrowthContext).Methods("POST")
 
 	app.AddHandler("/path-context", getPathContexts).Methods("GET")
 	app.AddHandler("/path-context", createPathContext).Methods("POST")
+	app.AddHandler("/path-context/graph", getPathContextGraph).Methods("GET")
 	app.AddHandler("/max-dist", increaseMaxDist).Methods("PUT")
 	app.AddHandler("/path-nodes", getPathNodes).Methods("GET")
 	app.AddHandler("/nb-path-nodes", getNbPathNodes).Methods("GET")
@@ -344,6 +456,6 @@ func MakeApp(envId m3util.QsmEnvID) *QsmApp {
 
 	app.AddHandler("/event-nodes", getNodeEvents).Methods("GET")
 	app.AddHandler("/space-time", getSpaceTime).Methods("GET")
-
-	return app
+	app.AddHandler("/space-time/export", exportSpaceTime).Methods("GET")
+	app.AddHandler("/space-time/graph", getSpaceTimeGraph).Methods("GET")
 }
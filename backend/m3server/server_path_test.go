@@ -55,6 +55,25 @@ func TestPathContextCreateAndIncrease(t *testing.T) {
 	}
 }
 
+func TestPathContextType0CreateAndIncrease(t *testing.T) {
+	m3util.SetToTestMode()
+	Log.SetInfo()
+	qsmApp := getTestServerApp(t)
+	router := qsmApp.Router
+
+	// Type 0 growth contexts are after the 52 other ones
+	pathCtxId, maxDist := callCreatePathContext(t, qsmApp, 0, 3, 2, 55, 3)
+	if pathCtxId <= 0 {
+		return
+	}
+
+	good := callGetPathNodes(t, pathCtxId, &maxDist, router, 1, 0, 3) &&
+		callGetPathNodes(t, pathCtxId, &maxDist, router, 2, 0, 6)
+	if !good {
+		Log.Info("failed!")
+	}
+}
+
 func callGetAllPathContext(t *testing.T, qsmApp *QsmApp) (int, bool) {
 	resMsg := &m3api.PathContextListMsg{}
 	if !sendAndReceive(t, &requestTest{
@@ -7,6 +7,7 @@ import (
 	"github.com/c2h5oh/datasize"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/freddy33/qsm-go/model/m3point"
 	"github.com/freddy33/urlquery"
 	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/mux"
@@ -62,12 +63,62 @@ func TestLogLevelSetter(t *testing.T) {
 	getApp(m3util.PointTestEnv).Router.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusOK, rr.Result().StatusCode, "Fail to call /log")
 	contentType := rr.Header().Get("Content-Type")
-	assert.Equal(t, "text/plain", contentType, "fail on "+contentType)
+	assert.Equal(t, "application/json", contentType, "fail on "+contentType)
+	levelsMsg := &m3api.LogLevelsMsg{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), levelsMsg))
+	assert.Equal(t, []string{"m3server"}, levelsMsg.Updated)
 
 	assert.False(t, Log.IsDebug())
 	assert.True(t, Log.IsInfo())
 }
 
+func TestSendError(t *testing.T) {
+	req, err := http.NewRequest("GET", "/path-nodes?path_ctx_id=12", nil)
+	assert.NoError(t, err, "Could create request")
+	req.Header.Set(m3api.HttpRequestIdKey, "test-req-1")
+	rr := httptest.NewRecorder()
+	SendErrorf(rr, req, http.StatusNotFound, "path context id %d does not exists", 12)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "application/json; messageType=m3api.ErrorMsg", rr.Header().Get("Content-Type"))
+	errMsg := &m3api.ErrorMsg{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), errMsg))
+	assert.Equal(t, int32(m3util.ErrNotFound), errMsg.Code)
+	assert.Equal(t, "path context id 12 does not exists", errMsg.Message)
+	assert.Equal(t, "test-req-1", errMsg.RequestId)
+
+	// Code of the error wins over the status, and protobuf is sent back when accepted
+	req.Header.Set("Accept", "application/x-protobuf")
+	rr = httptest.NewRecorder()
+	cause := m3util.MakeQsmCodeErrorf(m3util.ErrInvalidState, "max dist too low")
+	SendError(rr, req, http.StatusInternalServerError, m3util.MakeWrapQsmErrorf(cause, "cannot get nodes"))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, "application/x-protobuf; messageType=m3api.ErrorMsg", rr.Header().Get("Content-Type"))
+	errMsg = &m3api.ErrorMsg{}
+	assert.NoError(t, proto.Unmarshal(rr.Body.Bytes(), errMsg))
+	assert.Equal(t, int32(m3util.ErrInvalidState), errMsg.Code)
+	assert.Equal(t, "cannot get nodes", errMsg.Message)
+	assert.Equal(t, []string{"max dist too low"}, errMsg.Details)
+}
+
+func TestBadEnvIdHeader(t *testing.T) {
+	app := &QsmApp{Router: mux.NewRouter()}
+	called := false
+	app.AddHandler("/space", func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}).Methods("GET")
+	req, err := http.NewRequest("GET", "/space", nil)
+	assert.NoError(t, err)
+	req.Header.Set(m3api.HttpEnvIdKey, "main")
+	rr := httptest.NewRecorder()
+	app.Router.ServeHTTP(rr, req)
+	assert.False(t, called)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	errMsg := &m3api.ErrorMsg{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), errMsg))
+	assert.Equal(t, int32(m3util.ErrBadRequest), errMsg.Code)
+	assert.Contains(t, errMsg.Message, `"main"`)
+}
+
 func TestListEnv(t *testing.T) {
 	Log.SetInfo()
 	router := getApp(m3util.PointTestEnv).Router
@@ -110,14 +161,15 @@ func TestReadPointData(t *testing.T) {
 
 	assert.Equal(t, 50, len(pMsg.AllConnections))
 	assert.Equal(t, 200, len(pMsg.AllTrios))
-	assert.Equal(t, 52, len(pMsg.AllGrowthContexts))
+	assert.Equal(t, m3point.TotalNbContexts, len(pMsg.AllGrowthContexts))
 }
 
 func verifyStatus(t *testing.T, rr *httptest.ResponseRecorder, req *requestTest) bool {
 	statusCode := rr.Result().StatusCode
 	if !assert.Equal(t, http.StatusOK, statusCode, "fail on %v", req) {
-		msg := "Content not text/plain"
-		if rr.Header().Get("Content-Type") == "text/plain" {
+		msg := "Content not text/plain or json"
+		respContentType := rr.Header().Get("Content-Type")
+		if respContentType == "text/plain" || strings.HasPrefix(respContentType, "application/json") {
 			b, err := ioutil.ReadAll(rr.Body)
 			if !assert.NoError(t, err, "Fail to read bytes for %v", req) {
 				return false
@@ -0,0 +1,108 @@
+package m3server
+
+import (
+	"context"
+	"github.com/freddy33/qsm-go/m3util"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+State used to drain the app on shutdown:
+new compute requests are refused, the running ones see their context cancelled
+and stop at the next path distance, then the app waits for them before the DB environments are closed.
+*/
+type appLifecycle struct {
+	once         sync.Once
+	ctx          context.Context
+	cancel       context.CancelFunc
+	draining     int32
+	computations sync.WaitGroup
+}
+
+// Read routes that may grow path contexts and events
+var growingReadRoutes = map[string]bool{
+	"GET /space-time":        true,
+	"GET /event-nodes":       true,
+	"GET /space-time/export": true,
+	"GET /space-time/graph":  true,
+}
+
+func isComputeRoute(key string) bool {
+	return routeScopes[key] == ScopeCompute || growingReadRoutes[key]
+}
+
+func (app *QsmApp) getLifecycle() *appLifecycle {
+	lc := &app.lifecycle
+	lc.once.Do(func() {
+		lc.ctx, lc.cancel = context.WithCancel(context.Background())
+	})
+	return lc
+}
+
+func (app *QsmApp) IsDraining() bool {
+	return atomic.LoadInt32(&app.getLifecycle().draining) == 1
+}
+
+/*
+Run a compute request with a context also cancelled on shutdown.
+Sends a 503 if the app is draining.
+*/
+func (app *QsmApp) runComputation(w http.ResponseWriter, r *http.Request, handleFunc func(http.ResponseWriter, *http.Request)) {
+	lc := app.getLifecycle()
+	if app.IsDraining() {
+		SendError(w, r, http.StatusServiceUnavailable, m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "server is shutting down, %s %s refused", r.Method, r.URL.Path))
+		return
+	}
+	lc.computations.Add(1)
+	defer lc.computations.Done()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-lc.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	handleFunc(w, r.WithContext(ctx))
+}
+
+/*
+Stop accepting compute requests, cancel the running ones and wait for them and the HTTP server to finish.
+The DB environments can be closed after, an error is returned if the timeout is reached.
+*/
+func (app *QsmApp) Shutdown(timeout time.Duration) error {
+	lc := app.getLifecycle()
+	atomic.StoreInt32(&lc.draining, 1)
+	Log.Infof("Draining the running computations with timeout %v", timeout)
+	lc.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var serverErr error
+	if app.Server != nil {
+		// Waits for all the active requests
+		serverErr = app.Server.Shutdown(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		lc.computations.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		Log.Infof("All computations done")
+	case <-ctx.Done():
+		return m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "computations still running after %v", timeout)
+	}
+	if serverErr != nil {
+		return m3util.MakeWrapQsmErrorf(serverErr, "HTTP server shutdown failed due to %v", serverErr)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package m3server
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsComputations(t *testing.T) {
+	app := &QsmApp{Router: mux.NewRouter()}
+	started := make(chan bool)
+	cancelled := make(chan bool, 1)
+	app.AddHandler("/space-time", func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		select {
+		case <-r.Context().Done():
+			cancelled <- true
+		case <-time.After(5 * time.Second):
+			cancelled <- false
+		}
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+	app.AddHandler("/log", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+
+	call := func(uri string) int {
+		req, err := http.NewRequest("GET", uri, nil)
+		assert.NoError(t, err)
+		req.Header.Set(m3api.HttpEnvIdKey, m3util.PointTestEnv.String())
+		rr := httptest.NewRecorder()
+		app.Router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.True(t, isComputeRoute("GET /space-time"))
+	assert.True(t, isComputeRoute("PUT /max-dist"))
+	assert.False(t, isComputeRoute("GET /log"))
+
+	inFlight := make(chan int)
+	go func() {
+		inFlight <- call("/space-time")
+	}()
+	<-started
+	assert.False(t, app.IsDraining())
+	assert.NoError(t, app.Shutdown(2*time.Second))
+	assert.True(t, <-cancelled, "running computation not cancelled")
+	assert.Equal(t, http.StatusOK, <-inFlight)
+
+	assert.True(t, app.IsDraining())
+	assert.Equal(t, http.StatusServiceUnavailable, call("/space-time"))
+	assert.Equal(t, http.StatusOK, call("/log"))
+}
@@ -13,11 +13,14 @@ import (
 
 func getSpaces(w http.ResponseWriter, r *http.Request) {
 	Log.Infof("Receive getSpaces")
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	spd := spacedb.GetServerSpacePackData(env)
 	err := spd.LoadAllSpaces()
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	allSpaces := spd.GetAllSpaces()
@@ -38,12 +41,15 @@ func createSpace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 
 	space, err := spacedb.CreateSpace(env, reqMsg.SpaceName, m3space.DistAndTime(reqMsg.ActiveThreshold),
 		int(reqMsg.MaxTriosPerPoint), int(reqMsg.MaxNodesPerPoint))
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -58,14 +64,17 @@ func deleteSpace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	spaceData := spacedb.GetServerSpacePackData(env)
 
 	spaceId := int(reqMsg.SpaceId)
 	spaceName := reqMsg.SpaceName
 	nbDeleted, err := spaceData.DeleteSpace(spaceId, spaceName)
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, "Trying to delete %d %q got: %s", spaceId, spaceName, err.Error())
+		SendError(w, r, http.StatusInternalServerError, m3util.MakeWrapQsmErrorf(err, "Trying to delete %d %q got: %s", spaceId, spaceName, err.Error()))
 		return
 	}
 
@@ -93,11 +102,14 @@ func getEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	spaceData := spacedb.GetServerSpacePackData(env)
 	space := spaceData.GetSpace(int(reqMsg.SpaceId)).(*spacedb.SpaceDb)
 	if space == nil {
-		SendResponse(w, http.StatusNotFound, "Space id %d does not exists", reqMsg.SpaceId)
+		SendErrorf(w, r, http.StatusNotFound, "Space id %d does not exists", reqMsg.SpaceId)
 		return
 	}
 
@@ -122,7 +134,7 @@ func getEvents(w http.ResponseWriter, r *http.Request) {
 	for i, evt := range events {
 		resMsg.Events[i], err = createEventMsg(evt)
 		if err != nil {
-			SendResponse(w, http.StatusInternalServerError, err.Error())
+			SendError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 	}
@@ -138,23 +150,26 @@ func createEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	spaceData := spacedb.GetServerSpacePackData(env)
 	space := spaceData.GetSpace(int(reqMsg.SpaceId))
 	if space == nil {
-		SendResponse(w, http.StatusNotFound, "Space id %d does not exists", reqMsg.SpaceId)
+		SendErrorf(w, r, http.StatusNotFound, "Space id %d does not exists", reqMsg.SpaceId)
 		return
 	}
 	event, err := space.CreateEvent(m3point.GrowthType(reqMsg.GrowthType), int(reqMsg.GrowthIndex), int(reqMsg.GrowthOffset),
 		m3space.DistAndTime(reqMsg.CreationTime), m3api.PointMsgToPoint(reqMsg.Center), m3space.EventColor(reqMsg.Color))
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	resMsg, err := createEventMsg(event)
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -216,22 +231,25 @@ func getNodeEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	spaceData := spacedb.GetServerSpacePackData(env)
 	space := spaceData.GetSpace(int(reqMsg.SpaceId)).(*spacedb.SpaceDb)
 	if space == nil {
-		SendResponse(w, http.StatusNotFound, "Space id %d does not exists", reqMsg.SpaceId)
+		SendErrorf(w, r, http.StatusNotFound, "Space id %d does not exists", reqMsg.SpaceId)
 		return
 	}
 	event := space.GetEvent(m3space.EventId(reqMsg.EventId))
 	if event == nil {
-		SendResponse(w, http.StatusNotFound, "Space id %d does not have events %d", reqMsg.SpaceId, reqMsg.EventId)
+		SendErrorf(w, r, http.StatusNotFound, "Space id %d does not have events %d", reqMsg.SpaceId, reqMsg.EventId)
 		return
 	}
 
-	nodes, err := event.GetActiveNodesAt(m3space.DistAndTime(reqMsg.AtTime))
+	nodes, err := event.(*spacedb.EventDb).GetActiveNodesDbAtContext(r.Context(), m3space.DistAndTime(reqMsg.AtTime))
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -240,7 +258,7 @@ func getNodeEvents(w http.ResponseWriter, r *http.Request) {
 	for i, node := range nodes {
 		resMsg.Nodes[i], err = createNodeEventMsg(node)
 		if err != nil {
-			SendResponse(w, http.StatusInternalServerError, err.Error())
+			SendError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 	}
@@ -256,14 +274,17 @@ func getSpaceTime(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	env := GetEnvironment(r)
+	env := GetEnvironment(w, r)
+	if env == nil {
+		return
+	}
 	spaceData := spacedb.GetServerSpacePackData(env)
 	space := spaceData.GetSpace(int(reqMsg.SpaceId)).(*spacedb.SpaceDb)
 
 	spaceTime := space.GetSpaceTimeAt(m3space.DistAndTime(reqMsg.CurrentTime)).(*spacedb.SpaceTime)
-	err := spaceTime.Populate()
+	err := spaceTime.PopulateContext(r.Context())
 	if err != nil {
-		SendResponse(w, http.StatusInternalServerError, err.Error())
+		SendError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -279,19 +300,21 @@ func getSpaceTime(w http.ResponseWriter, r *http.Request) {
 	for i, evt := range activeEvents {
 		resMsg.ActiveEvents[i], err = createEventMsg(evt)
 		if err != nil {
-			SendResponse(w, http.StatusInternalServerError, err.Error())
+			SendError(w, r, http.StatusInternalServerError, err)
 			return
 		}
 	}
 	nodesMsgBuilder, err := makeNodeMsgBuilder(reqMsg, spaceTime)
 	if err != nil {
 		// Nothing found in this space time may be due to too strong filter
-		SendResponse(w, http.StatusNotFound, err.Error())
+		SendError(w, r, http.StatusNotFound, err)
 		return
 	}
+	endVisit := m3util.StartSpan(r.Context(), "nodes_visit")
 	spaceTime.VisitNodes(nodesMsgBuilder)
+	endVisit()
 	if nodesMsgBuilder.buildError != nil {
-		SendResponse(w, http.StatusInternalServerError, nodesMsgBuilder.buildError.Error())
+		SendError(w, r, http.StatusInternalServerError, nodesMsgBuilder.buildError)
 		return
 	}
 	resMsg.FilteredNodes = nodesMsgBuilder.foundNodes
@@ -0,0 +1,62 @@
+package m3server
+
+import (
+	"context"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestTraceHeaders(t *testing.T) {
+	app := &QsmApp{Router: mux.NewRouter()}
+	var seenRequestId string
+	app.AddHandler("/traced", func(w http.ResponseWriter, r *http.Request) {
+		seenRequestId = m3util.GetRequestId(r.Context())
+		endSpan := m3util.StartSpan(r.Context(), "db_test")
+		time.Sleep(time.Millisecond)
+		endSpan()
+		m3util.GetTrace(r.Context()).AddSpan("db_test", time.Millisecond)
+		WriteResponseMsg(w, r, &m3api.EnvListMsg{})
+	}).Methods("GET")
+
+	call := func(requestId string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/traced", nil)
+		assert.NoError(t, err)
+		req.Header.Set(m3api.HttpEnvIdKey, m3util.PointTestEnv.String())
+		if requestId != "" {
+			req.Header.Set(m3api.HttpRequestIdKey, requestId)
+		}
+		rr := httptest.NewRecorder()
+		app.Router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr
+	}
+
+	rr := call("")
+	generated := rr.Header().Get(m3api.HttpRequestIdKey)
+	assert.Len(t, generated, 16)
+	assert.Equal(t, generated, seenRequestId)
+	timing := rr.Header().Get(m3api.HttpServerTimingKey)
+	assert.True(t, strings.HasPrefix(timing, `db_test;dur=`), "wrong timing %q", timing)
+	assert.Contains(t, timing, `;desc="2 calls"`)
+	assert.Contains(t, timing, "serialize;dur=")
+	assert.Contains(t, timing, "total;dur=")
+
+	rr = call("caller-id-1")
+	assert.Equal(t, "caller-id-1", rr.Header().Get(m3api.HttpRequestIdKey))
+	assert.Equal(t, "caller-id-1", seenRequestId)
+}
+
+func TestNoTraceNoop(t *testing.T) {
+	var trace *m3util.RequestTrace
+	trace.AddSpan("nothing", time.Second)
+	assert.Equal(t, "", trace.ServerTiming())
+	assert.Nil(t, trace.GetSpans())
+	m3util.StartSpan(context.Background(), "no_ctx")()
+}
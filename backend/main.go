@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/freddy33/qsm-go/backend/pathdb"
 	"github.com/freddy33/qsm-go/backend/spacedb"
 	"github.com/rs/cors"
 
@@ -22,6 +22,17 @@ import (
 )
 
 var runningApp *m3server.QsmApp
+var shutdownTimeout time.Duration
+
+// The commands opening a DB environment
+var dbCommands = map[string]bool{
+	"server":  true,
+	"gentxt":  true,
+	"fitsize": true,
+	"render":  true,
+	"export":  true,
+	"graph":   true,
+}
 
 func listenSignals() {
 	sigchan := make(chan os.Signal, 1)
@@ -55,50 +66,91 @@ func listenSignals() {
 	}
 }
 
-func createAppAndListen(port string) {
+func createAppAndListen(serverConfig config.Config) {
 	defer m3util.CloseAll()
 	runningApp = m3server.MakeApp(m3util.GetDefaultEnvId())
+	shutdownTimeout = serverConfig.ShutdownTimeout
+
+	allowedOrigins := serverConfig.AllowedOrigins
+	if len(allowedOrigins) == 0 {
+		allowedOrigins = runningApp.Auth.GetAllowedOrigins()
+	}
 
-	c := cors.New(cors.Options{
-		AllowedOrigins: []string{"*"},
+	corsOptions := cors.Options{
+		AllowedOrigins: allowedOrigins,
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders: []string{"Content-Type", "QsmEnvId"},
-	})
+		AllowedHeaders: []string{"Content-Type", "QsmEnvId", "Authorization", "X-Request-Id"},
+		ExposedHeaders: []string{"X-Request-Id", "Server-Timing"},
+	}
+	if len(allowedOrigins) == 0 {
+		// An empty list means all origins for cors, so refuse them explicitly
+		log.Printf("No CORS origins configured, cross origin requests are refused")
+		corsOptions.AllowOriginFunc = func(origin string) bool {
+			return false
+		}
+	}
+	c := cors.New(corsOptions)
 
 	handler := c.Handler(runningApp.Router)
 
-	runningApp.Server = &http.Server{Addr: ":" + port, Handler: handler}
+	runningApp.Server = &http.Server{Addr: ":" + serverConfig.ServerPort, Handler: handler}
 	runningApp.HttpServerDone = &sync.WaitGroup{}
 	runningApp.HttpServerDone.Add(1)
-	log.Printf("Starting server on port=%s", port)
+	log.Printf("Starting server on port=%s", serverConfig.ServerPort)
 	go launchServer()
 	runningApp.HttpServerDone.Wait()
 }
 
 func launchServer() {
 	err := runningApp.Server.ListenAndServe()
-	if err != nil {
+	// Closed by killServer which ends the wait
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatal("ListenAndServe: ", err)
 	}
 }
 
+// Drain the running computations before the DB environments are closed at the end of createAppAndListen
 func killServer() {
 	fmt.Println("Kill server called")
+	if runningApp == nil {
+		return
+	}
 	defer runningApp.HttpServerDone.Done()
-	if runningApp != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := runningApp.Server.Shutdown(ctx); err != nil {
-			log.Fatal("failed to call shutdown on server", err)
-		}
+	if err := runningApp.Shutdown(shutdownTimeout); err != nil {
+		log.Print("failed to drain the server: ", err)
 	}
 }
 
 func main() {
-	others := m3util.ReadVerbose()
+	defer m3util.CloseLogFiles()
+	others, err := config.ReadArgs(m3util.ReadVerbose())
+	if err != nil {
+		log.Fatal("invalid command line: ", err)
+	}
+	serverConfig, err := config.NewServerConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	others, commandOptions, err := m3server.ReadCommandArgs(others)
+	if err != nil {
+		log.Fatal("invalid command line: ", err)
+	}
+	for _, o := range others {
+		if dbCommands[o] {
+			// All the environments use this DB configuration, so fail now and not on first use
+			err = serverConfig.ValidateDB()
+			if err != nil {
+				log.Fatal(err)
+			}
+			break
+		}
+	}
+	m3util.SetDefaultEnvId(serverConfig.DefaultEnvId)
+	pathdb.SetNbParallelProcesses(serverConfig.NbParallelProcesses)
+
 	didSomething := false
 	runServer := false
-	for i, o := range others {
+	for _, o := range others {
 		switch o {
 		case "server":
 			// Run the server at the end
@@ -108,8 +160,46 @@ func main() {
 			// TODO: Make a REST API and UI for retrieving this data
 			m3server.GenerateTextFilesEnv(spacedb.GetSpaceDbFullEnv(m3util.GetDefaultEnvId()))
 			didSomething = true
-		case "-env":
-			m3util.SetDefaultEnvId(m3util.ReadEnvId("backend main", others[i+1]))
+		case "fitsize":
+			// Needs path nodes already calculated in the environment
+			err := m3server.FitPredictedSizeModelsEnv(spacedb.GetSpaceDbFullEnv(m3util.GetDefaultEnvId()))
+			if err != nil {
+				log.Fatal("failed to fit predicted size models: ", err)
+			}
+			didSomething = true
+		case "render":
+			// PNG frames of a space without OpenGL, in build/render by default
+			files, err := m3server.RenderSpaceFramesEnv(spacedb.GetSpaceDbFullEnv(m3util.GetDefaultEnvId()), commandOptions)
+			if err != nil {
+				log.Fatal("failed to render frames: ", err)
+			}
+			fmt.Println("Rendered", len(files), "frames")
+			didSomething = true
+		case "export":
+			// 3D scene of a space time in gltf, ply or obj, in build/export by default
+			filePath, err := m3server.ExportSpaceTimeEnv(spacedb.GetSpaceDbFullEnv(m3util.GetDefaultEnvId()), commandOptions)
+			if err != nil {
+				log.Fatal("failed to export space time: ", err)
+			}
+			fmt.Println("Exported", filePath)
+			didSomething = true
+		case "graph":
+			// GraphML, DOT or d3 JSON of a path context or space time, in build/graph by default
+			filePath, err := m3server.ExportGraphEnv(spacedb.GetSpaceDbFullEnv(m3util.GetDefaultEnvId()), commandOptions)
+			if err != nil {
+				log.Fatal("failed to export graph: ", err)
+			}
+			fmt.Println("Exported", filePath)
+			didSomething = true
+		case "gentoken":
+			// Print a new API token and the hash to add in the auth file
+			token, hash, err := m3server.GenerateToken()
+			if err != nil {
+				log.Fatal("failed to generate token: ", err)
+			}
+			fmt.Println("token:", token)
+			fmt.Println("hash: ", hash)
+			didSomething = true
 		}
 	}
 	if !didSomething {
@@ -118,8 +208,7 @@ func main() {
 	}
 	if runServer {
 		go listenSignals()
-		serverConfig := config.NewServerConfig()
-		createAppAndListen(serverConfig.ServerPort)
+		createAppAndListen(serverConfig)
 		fmt.Println("Exiting main")
 	}
 }
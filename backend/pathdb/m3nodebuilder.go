@@ -10,7 +10,7 @@ type OpenNodeBuilder struct {
 	d              int
 	expectedSize   int
 	openNodesMap   ServerPathNodeMap
-	insertConflict int
+	insertConflict int32
 }
 
 func (pathCtx *PathContextDb) createCurrentNodeBuilder() (*OpenNodeBuilder, error) {
@@ -30,7 +30,7 @@ func (pathCtx *PathContextDb) createCurrentNodeBuilder() (*OpenNodeBuilder, erro
 	res := new(OpenNodeBuilder)
 	res.pathCtx = pathCtx
 	res.d = lastNodes[0].D()
-	res.expectedSize = m3path.CalculatePredictedSize(pathCtx.GetGrowthType(), res.d)
+	res.expectedSize = m3path.CalculatePredictedSize(pathCtx.GetGrowthCtx(), res.d)
 	if res.expectedSize > 32 {
 		res.openNodesMap = MakeHashPathNodeMap(res.expectedSize)
 	} else {
@@ -49,7 +49,7 @@ func createNextNodeBuilder(previous *OpenNodeBuilder) *OpenNodeBuilder {
 	res := new(OpenNodeBuilder)
 	res.pathCtx = previous.pathCtx
 	res.d = previous.d + 1
-	res.expectedSize = m3path.CalculatePredictedSize(res.pathCtx.GetGrowthType(), res.d)
+	res.expectedSize = m3path.CalculatePredictedSize(res.pathCtx.GetGrowthCtx(), res.d)
 	if res.expectedSize > 32 {
 		res.openNodesMap = MakeHashPathNodeMap(res.expectedSize)
 	} else {
@@ -60,3 +60,45 @@ func fillPathContext(t *testing.T, pathCtx *PathContextDb, until int) bool {
 	}
 	return true
 }
+
+func TestCustomPathContextFilling(t *testing.T) {
+	Log.SetInfo()
+	Log.SetAssert(true)
+	m3point.Log.SetInfo()
+	m3point.Log.SetAssert(true)
+	m3util.SetToTestMode()
+
+	env := GetPathDbFullEnv(m3util.PathTestEnv)
+	pointData := pointdb.GetServerPointPackData(env)
+	pathData := GetServerPathPackData(env)
+
+	trioSequence := []m3point.TrioIndex{0, 5, 0, 6}
+	growthCtx, err := pointData.AddCustomGrowthContext(trioSequence)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, m3point.CustomGrowthType, growthCtx.GetGrowthType())
+	assert.True(t, growthCtx.GetId() >= m3point.TotalNbContexts)
+	assert.Equal(t, len(trioSequence), growthCtx.GetMaxOffset())
+
+	// Registering the same cycle returns the same growth context
+	sameCtx, err := pointData.AddCustomGrowthContext([]m3point.TrioIndex{0, 6, 0, 5})
+	assert.NoError(t, err)
+	assert.Equal(t, growthCtx.GetId(), sameCtx.GetId())
+
+	_, err = pointData.AddCustomGrowthContext([]m3point.TrioIndex{0, 4})
+	assert.Error(t, err)
+
+	_, err = pathData.GetPathCtxDbFromAttributes(m3point.CustomGrowthType, growthCtx.GetGrowthIndex(), len(trioSequence))
+	assert.Error(t, err)
+	for offset := 0; offset < len(trioSequence); offset++ {
+		pathCtx, err := pathData.GetPathCtxDbFromAttributes(m3point.CustomGrowthType, growthCtx.GetGrowthIndex(), offset)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, growthCtx.GetId(), pathCtx.GetGrowthCtx().GetId())
+		if !fillPathContext(t, pathCtx, 6) {
+			return
+		}
+	}
+}
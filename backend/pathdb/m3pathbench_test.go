@@ -63,7 +63,7 @@ func runPathContext(pathCtx *PathContextDb, until int) (int, int, error) {
 	}
 	for d := 0; d < until; d++ {
 		if LogDataTest.IsInfo() {
-			predictedIntLen := m3path.CalculatePredictedSize(pathCtx.GetGrowthType(), d)
+			predictedIntLen := m3path.CalculatePredictedSize(pathCtx.GetGrowthCtx(), d)
 			finalLen := pathCtx.GetNumberOfNodesAt(d)
 			errorBar := math.Abs(float64(finalLen-predictedIntLen)) / float64(predictedIntLen)
 			// If final length way too small => error
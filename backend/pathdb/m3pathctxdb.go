@@ -1,6 +1,7 @@
 package pathdb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/freddy33/qsm-go/backend/m3db"
@@ -8,7 +9,10 @@ import (
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3path"
 	"github.com/freddy33/qsm-go/model/m3point"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type PathContextDb struct {
@@ -22,15 +26,14 @@ type PathContextDb struct {
 
 	rootNode *PathNodeDb
 
+	// Node counts per distance up to max dist
+	distStats *m3path.PathDistStats
+
 	increaseDistMutex  sync.Mutex
 	currentNodeBuilder *OpenNodeBuilder
 }
 
 func (pathCtx *PathContextDb) createRootNode() error {
-	if pathCtx.id <= 0 {
-		return m3util.MakeQsmErrorf("trying to init root node on not inserted in DB path context %s", pathCtx.String())
-	}
-
 	// the path builder enforce origin as the center
 	origin := m3point.Origin
 
@@ -39,7 +42,16 @@ func (pathCtx *PathContextDb) createRootNode() error {
 		return m3util.MakeWrapQsmErrorf(err, "could not get or insert the origin point %v due to %s", origin, err.Error())
 	}
 
-	nodeBuilder := pathCtx.pointData.GetPathNodeBuilder(pathCtx.growthCtx, pathCtx.growthOffset, origin)
+	return pathCtx.insertRootNode(pathCtx.pathData.pathCtxTe, pathCtx.pathNodesTe(), pathCtx.distStatsTe(), pathPoint)
+}
+
+// Insert the root node at the origin pathPoint with its dist stat and path builder id using the table execs given
+func (pathCtx *PathContextDb) insertRootNode(pathCtxTe, pathNodesTe, distStatsTe *m3db.TableExec, pathPoint *m3path.PathPoint) error {
+	if pathCtx.id <= 0 {
+		return m3util.MakeQsmErrorf("trying to init root node on not inserted in DB path context %s", pathCtx.String())
+	}
+
+	nodeBuilder := pathCtx.pointData.GetPathNodeBuilder(pathCtx.growthCtx, pathCtx.growthOffset, m3point.Origin)
 
 	rootNode := getNewPathNodeDb()
 	rootNode.pathCtxId = pathCtx.id
@@ -49,14 +61,24 @@ func (pathCtx *PathContextDb) createRootNode() error {
 	rootNode.pathPoint = *pathPoint
 	rootNode.d = 0
 
-	err = rootNode.syncInDb()
+	_, err := rootNode.insertInDbWith(pathNodesTe)
 	if err != nil {
 		return m3util.MakeWrapQsmErrorf(err, "could not insert the root node %s of path context %s due to %v", rootNode.String(), pathCtx.String(), err)
 	}
 
 	pathCtx.rootNode = rootNode
 
-	rowAffected, err := pathCtx.pathData.pathCtxTe.Update(UpdatePathBuilderId, pathCtx.id, rootNode.pathBuilderId)
+	stat := m3path.PathDistStat{D: 0, NbNodes: 1}
+	err = pathCtx.insertDistStat(distStatsTe, stat)
+	if err != nil {
+		return err
+	}
+	err = pathCtx.addDistStat(stat)
+	if err != nil {
+		return err
+	}
+
+	rowAffected, err := pathCtxTe.Update(UpdatePathBuilderId, pathCtx.id, rootNode.pathBuilderId)
 	if err != nil {
 		return m3util.MakeWrapQsmErrorf(err, "could not update path context %s with new path builder id %d due to %v", pathCtx.String(), rootNode.pathBuilderId, err)
 	}
@@ -76,7 +98,11 @@ func (pathCtx *PathContextDb) pointsTe() *m3db.TableExec {
 }
 
 func (pathCtx *PathContextDb) insertInDb() error {
-	id64, err := pathCtx.pathData.pathCtxTe.InsertReturnId(pathCtx.GetGrowthCtx().GetId(), pathCtx.GetGrowthOffset())
+	return pathCtx.insertInDbWith(pathCtx.pathData.pathCtxTe)
+}
+
+func (pathCtx *PathContextDb) insertInDbWith(te *m3db.TableExec) error {
+	id64, err := te.InsertReturnId(pathCtx.GetGrowthCtx().GetId(), pathCtx.GetGrowthOffset())
 	if err != nil {
 		return err
 	}
@@ -121,7 +147,15 @@ func (pathCtx *PathContextDb) GetRootPathNode() m3path.PathNode {
 	return pathCtx.rootNode
 }
 
+func (pathCtx *PathContextDb) GetDistStats() []m3path.PathDistStat {
+	return pathCtx.distStats.GetAll()
+}
+
 func (pathCtx *PathContextDb) GetNumberOfNodesBetween(fromDist int, toDist int) int {
+	nb, ok := pathCtx.distStats.GetNumberOfNodesBetween(fromDist, toDist)
+	if ok {
+		return nb
+	}
 	row := pathCtx.pathData.pathNodesTe.QueryRow(CountPathNodesByCtxAndBetweenDistance, pathCtx.GetId(), fromDist, toDist)
 	var count int
 	err := row.Scan(&count)
@@ -133,6 +167,10 @@ func (pathCtx *PathContextDb) GetNumberOfNodesBetween(fromDist int, toDist int)
 }
 
 func (pathCtx *PathContextDb) GetNumberOfNodesAt(dist int) int {
+	nb, ok := pathCtx.distStats.GetNumberOfNodesAt(dist)
+	if ok {
+		return nb
+	}
 	row := pathCtx.pathData.pathNodesTe.QueryRow(CountPathNodesByCtxAndDistance, pathCtx.GetId(), dist)
 	var count int
 	err := row.Scan(&count)
@@ -229,6 +267,10 @@ func (pathCtx *PathContextDb) makeNewNodes(current, next *OpenNodeBuilder, on *P
 }
 
 func (pathCtx *PathContextDb) GetPathNodesAt(dist int) ([]m3path.PathNode, error) {
+	return pathCtx.GetPathNodesAtContext(context.Background(), dist)
+}
+
+func (pathCtx *PathContextDb) GetPathNodesAtContext(ctx context.Context, dist int) ([]m3path.PathNode, error) {
 	if dist == 0 && pathCtx.rootNode != nil {
 		res := make([]m3path.PathNode, 1)
 		res[0] = pathCtx.rootNode
@@ -238,13 +280,14 @@ func (pathCtx *PathContextDb) GetPathNodesAt(dist int) ([]m3path.PathNode, error
 	if dist > 0 {
 		Log.Debugf("Retrieving all path nodes of %s for dist %d", pathCtx.String(), dist)
 	}
+	defer m3util.StartSpan(ctx, "path_nodes")()
 	te := pathCtx.pathData.pathNodesTe
-	rows, err := te.Query(SelectPathNodesByCtxAndDistance, pathCtx.GetId(), dist)
+	rows, err := te.QueryContext(ctx, SelectPathNodesByCtxAndDistance, pathCtx.GetId(), dist)
 	if err != nil {
 		return nil, err
 	}
 	defer te.CloseRows(rows)
-	res := make([]m3path.PathNode, 0, m3path.CalculatePredictedSize(pathCtx.GetGrowthType(), dist))
+	res := make([]m3path.PathNode, 0, m3path.CalculatePredictedSize(pathCtx.GetGrowthCtx(), dist))
 	for rows.Next() {
 		pn, err := createPathNodeFromDbRows(rows)
 		if err != nil {
@@ -265,14 +308,19 @@ func (pathCtx *PathContextDb) GetPathNodesAt(dist int) ([]m3path.PathNode, error
 }
 
 func (pathCtx *PathContextDb) GetPathNodesBetween(fromDist, toDist int) ([]m3path.PathNode, error) {
+	return pathCtx.GetPathNodesBetweenContext(context.Background(), fromDist, toDist)
+}
+
+func (pathCtx *PathContextDb) GetPathNodesBetweenContext(ctx context.Context, fromDist, toDist int) ([]m3path.PathNode, error) {
+	defer m3util.StartSpan(ctx, "path_nodes")()
 	te := pathCtx.pathData.pathNodesTe
-	rows, err := te.Query(SelectPathNodesByCtxAndBetweenDistance, pathCtx.GetId(), fromDist, toDist)
+	rows, err := te.QueryContext(ctx, SelectPathNodesByCtxAndBetweenDistance, pathCtx.GetId(), fromDist, toDist)
 	if err != nil {
 		return nil, err
 	}
 	totalSize := 0
 	for d := fromDist; d <= toDist; d++ {
-		totalSize += m3path.CalculatePredictedSize(pathCtx.GetGrowthType(), d)
+		totalSize += m3path.CalculatePredictedSize(pathCtx.GetGrowthCtx(), d)
 	}
 	defer te.CloseRows(rows)
 	res := make([]m3path.PathNode, 0, totalSize)
@@ -295,14 +343,34 @@ func (pathCtx *PathContextDb) GetPathNodesBetween(fromDist, toDist int) ([]m3pat
 // TODO: This should be in path data entry of the env
 var nbParallelProcesses = 8
 
+// From the parallelism of the backend configuration
+func SetNbParallelProcesses(nb int) {
+	if nb < 1 {
+		Log.Errorf("number of parallel processes %d should be at least 1", nb)
+		return
+	}
+	nbParallelProcesses = nb
+}
+
 func (pathCtx *PathContextDb) RequestNewMaxDist(requestDist int) error {
+	return pathCtx.RequestNewMaxDistContext(context.Background(), requestDist)
+}
+
+// Stops between two distances if the context is done, each distance is a path_next_dist span of the request trace
+func (pathCtx *PathContextDb) RequestNewMaxDistContext(ctx context.Context, requestDist int) error {
 	if requestDist <= pathCtx.GetMaxDist() {
 		return nil
 	}
 	Log.Debugf("Path context %s will set to new dist %d from %d", pathCtx.String(), requestDist, pathCtx.GetMaxDist())
 	nbExecution := 0
 	for d := pathCtx.GetMaxDist() + 1; d <= requestDist; d++ {
+		if ctx.Err() != nil {
+			return m3util.MakeWrapQsmCodeErrorf(m3util.ErrUnavailable, ctx.Err(), "path context %d stopped at max dist %d before %d due to %v",
+				pathCtx.GetId(), pathCtx.GetMaxDist(), requestDist, ctx.Err())
+		}
+		endSpan := m3util.StartSpan(ctx, "path_next_dist")
 		err := pathCtx.calculateNextMaxDist()
+		endSpan()
 		if err != nil {
 			return err
 		}
@@ -319,6 +387,7 @@ func (pathCtx *PathContextDb) RequestNewMaxDist(requestDist int) error {
 func (pathCtx *PathContextDb) calculateNextMaxDist() error {
 	pathCtx.increaseDistMutex.Lock()
 	defer pathCtx.increaseDistMutex.Unlock()
+	startTime := time.Now()
 
 	current, err := pathCtx.createCurrentNodeBuilder()
 	if err != nil {
@@ -375,7 +444,7 @@ func (pathCtx *PathContextDb) calculateNextMaxDist() error {
 			return true
 		} else {
 			if on.state == InConflictNode {
-				next.insertConflict++
+				atomic.AddInt32(&next.insertConflict, 1)
 			}
 		}
 		return false
@@ -385,6 +454,7 @@ func (pathCtx *PathContextDb) calculateNextMaxDist() error {
 	}
 
 	rc.Reset()
+	nbDeadEnds := int32(0)
 	// Update all the previous path node to DB
 	// TODO: The update nodes may not be those only
 	current.openNodesMap.Range(func(point m3point.Point, on *PathNodeDb) bool {
@@ -395,7 +465,12 @@ func (pathCtx *PathContextDb) calculateNextMaxDist() error {
 		} else {
 			if on.state == InConflictNode {
 				rc.SendError(m3util.MakeQsmErrorf("current path node %s cannot be in conflict!", on.String()))
-				current.insertConflict++
+				atomic.AddInt32(&current.insertConflict, 1)
+			}
+		}
+		for i := 0; i < m3path.NbConnections; i++ {
+			if on.IsDeadEnd(i) {
+				atomic.AddInt32(&nbDeadEnds, 1)
 			}
 		}
 		return false
@@ -407,14 +482,42 @@ func (pathCtx *PathContextDb) calculateNextMaxDist() error {
 	}
 	Log.Infof("%s from=%d to=%d : move from %d to %d nodes with %d conflicts", pathCtx.String(), current.d, next.d, current.openNodesSize(), next.openNodesSize(), next.insertConflict)
 
-	pathCtx.maxDist = next.d
-	rowAffected, err := pathCtx.pathData.pathCtxTe.Update(UpdateMaxDist, pathCtx.id, pathCtx.maxDist)
+	// Conflicting nodes were not saved in DB
+	stat := m3path.PathDistStat{
+		D:           next.d,
+		NbNodes:     next.openNodesSize() - int(next.insertConflict),
+		NbDeadEnds:  int(nbDeadEnds),
+		NbConflicts: int(next.insertConflict),
+	}
+	// The stat and the new max dist are saved together, so a failed move can be calculated again
+	err = pathCtx.pathData.env.InTransaction(func(tx *sql.Tx) error {
+		err := pathCtx.insertDistStat(pathCtx.distStatsTe().InTx(tx), stat)
+		if err != nil {
+			return err
+		}
+		rowAffected, err := pathCtx.pathData.pathCtxTe.InTx(tx).Update(UpdateMaxDist, pathCtx.id, next.d)
+		if err != nil {
+			return m3util.MakeWrapQsmErrorf(err, "could not update path context %s with new max dist %d due to %v", pathCtx.String(), next.d, err)
+		}
+		if rowAffected != 1 {
+			return m3util.MakeQsmErrorf("updating path context %s with new max dist %d returned wrong rows %d", pathCtx.String(), next.d, rowAffected)
+		}
+		return nil
+	})
 	if err != nil {
-		return m3util.MakeWrapQsmErrorf(err, "could not update path context %s with new max dist %d due to %v", pathCtx.String(), pathCtx.maxDist, err)
+		return err
 	}
-	if rowAffected != 1 {
-		return m3util.MakeQsmErrorf("updating path context %s with new max dist %d returned wrong rows %d", pathCtx.String(), pathCtx.maxDist, rowAffected)
+	err = pathCtx.addDistStat(stat)
+	if err != nil {
+		return err
 	}
+	pathCtx.maxDist = next.d
+
+	ctxLabel := strconv.Itoa(int(pathCtx.id))
+	pathNodesCreated.Add(float64(stat.NbNodes), ctxLabel)
+	pathNodesConflicts.Add(float64(stat.NbConflicts), ctxLabel)
+	pathDeadEnds.Add(float64(stat.NbDeadEnds), ctxLabel)
+	nextMaxDistDuration.ObserveSince(startTime, ctxLabel)
 
 	pathCtx.currentNodeBuilder = next
 	current.openNodesMap.Clear()
@@ -454,6 +557,7 @@ func createPathCtxFromDbRows(rows *sql.Rows, pathData *ServerPathPackData) (*Pat
 	pathCtx := new(PathContextDb)
 	pathCtx.pathData = pathData
 	pathCtx.pointData = pointdb.GetServerPointPackData(pathData.env)
+	pathCtx.distStats = m3path.MakePathDistStats(32)
 	var growthCtxId, pathBuilderId int
 	err := rows.Scan(&pathCtx.id, &growthCtxId, &pathCtx.growthOffset, &pathBuilderId, &pathCtx.maxDist)
 	if err != nil {
@@ -475,6 +579,11 @@ func createPathCtxFromDbRows(rows *sql.Rows, pathData *ServerPathPackData) (*Pat
 		return nil, m3util.MakeQsmErrorf("The path builder id at %s do not match %d != %d", pathCtx.String(), pathCtx.rootNode.pathBuilderId, pathBuilderId)
 	}
 
+	err = pathCtx.loadDistStats()
+	if err != nil {
+		return nil, err
+	}
+
 	return pathCtx, nil
 }
 
@@ -492,3 +601,86 @@ func (pathCtx *PathContextDb) getPathNodeIdByPoint(pointId int64) int64 {
 	}
 	return res
 }
+
+/***************************************************************/
+// Path Context Distance Stats Functions
+/***************************************************************/
+
+func (pathCtx *PathContextDb) distStatsTe() *m3db.TableExec {
+	return pathCtx.pathData.pathDistStatsTe
+}
+
+func (pathCtx *PathContextDb) saveDistStat(stat m3path.PathDistStat) error {
+	err := pathCtx.insertDistStat(pathCtx.distStatsTe(), stat)
+	if err != nil {
+		return err
+	}
+	return pathCtx.addDistStat(stat)
+}
+
+func (pathCtx *PathContextDb) insertDistStat(te *m3db.TableExec, stat m3path.PathDistStat) error {
+	err := te.Insert(pathCtx.id, stat.D, stat.NbNodes, stat.NbDeadEnds, stat.NbConflicts)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not save dist stats %v of path context %s due to %v", stat, pathCtx.String(), err)
+	}
+	return nil
+}
+
+func (pathCtx *PathContextDb) addDistStat(stat m3path.PathDistStat) error {
+	if !pathCtx.distStats.Add(stat) {
+		return m3util.MakeQsmErrorf("dist stats of path context %s at %d is not the next one after %d", pathCtx.String(), stat.D, pathCtx.distStats.GetMaxDist())
+	}
+	return nil
+}
+
+func (pathCtx *PathContextDb) loadDistStats() error {
+	rows, err := pathCtx.distStatsTe().Query(SelectDistStatsByCtx, pathCtx.id)
+	if err != nil {
+		return err
+	}
+	defer pathCtx.distStatsTe().CloseRows(rows)
+	for rows.Next() {
+		stat := m3path.PathDistStat{}
+		err = rows.Scan(&stat.D, &stat.NbNodes, &stat.NbDeadEnds, &stat.NbConflicts)
+		if err != nil {
+			return m3util.MakeWrapQsmErrorf(err, "could not read dist stats of path context %s due to %v", pathCtx.String(), err)
+		}
+		if !pathCtx.distStats.Add(stat) {
+			return m3util.MakeQsmErrorf("dist stats of path context %s has a hole at %d", pathCtx.String(), stat.D)
+		}
+	}
+	if pathCtx.distStats.GetMaxDist() < pathCtx.maxDist {
+		return pathCtx.fillMissingDistStats()
+	}
+	return nil
+}
+
+// For path contexts calculated before the stats existed, only the number of nodes can be recovered
+func (pathCtx *PathContextDb) fillMissingDistStats() error {
+	te := pathCtx.pathNodesTe()
+	rows, err := te.Query(CountPathNodesByCtxPerDistance, pathCtx.id)
+	if err != nil {
+		return err
+	}
+	missing := make([]m3path.PathDistStat, 0, pathCtx.maxDist+1)
+	for rows.Next() {
+		stat := m3path.PathDistStat{}
+		err = rows.Scan(&stat.D, &stat.NbNodes)
+		if err != nil {
+			te.CloseRows(rows)
+			return m3util.MakeWrapQsmErrorf(err, "could not count path nodes of %s due to %v", pathCtx.String(), err)
+		}
+		if stat.D > pathCtx.distStats.GetMaxDist() && stat.D <= pathCtx.maxDist {
+			missing = append(missing, stat)
+		}
+	}
+	te.CloseRows(rows)
+	for _, stat := range missing {
+		err = pathCtx.saveDistStat(stat)
+		if err != nil {
+			return err
+		}
+	}
+	Log.Infof("Filled %d missing dist stats for %s", len(missing), pathCtx.String())
+	return nil
+}
@@ -84,3 +84,37 @@ func TestMakeNewPathCtx(t *testing.T) {
 	Log.Infof("Total move next DB test took %v", moveNext.Sub(rootCreated))
 
 }
+
+func TestPathDistStats(t *testing.T) {
+	Log.SetAssert(true)
+	m3point.Log.SetAssert(true)
+	m3util.SetToTestMode()
+	env := GetPathDbFullEnv(m3util.PathTestEnv)
+	pathData := GetServerPathPackData(env)
+
+	pathCtx, err := pathData.GetPathCtxDbFromAttributes(m3point.GrowthType(2), 1, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = pathCtx.RequestNewMaxDist(6)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stats := pathCtx.GetDistStats()
+	assert.Equal(t, pathCtx.GetMaxDist()+1, len(stats))
+	total := 0
+	for d, stat := range stats {
+		assert.Equal(t, d, stat.D)
+		// Stats and DB count should always match
+		row := pathData.pathNodesTe.QueryRow(CountPathNodesByCtxAndDistance, pathCtx.GetId(), d)
+		var count int
+		assert.NoError(t, row.Scan(&count))
+		assert.Equal(t, count, stat.NbNodes, "wrong count at %d", d)
+		assert.Equal(t, count, pathCtx.GetNumberOfNodesAt(d))
+		total += count
+	}
+	assert.Equal(t, 1, stats[0].NbNodes)
+	assert.Equal(t, total, pathCtx.GetNumberOfNodesBetween(0, pathCtx.GetMaxDist()))
+	assert.Equal(t, total-1, pathCtx.GetNumberOfNodesBetween(1, pathCtx.GetMaxDist()))
+}
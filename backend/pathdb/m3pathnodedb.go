@@ -3,6 +3,7 @@ package pathdb
 import (
 	"database/sql"
 	"fmt"
+	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/backend/pointdb"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3path"
@@ -339,10 +340,13 @@ func (pn *PathNodeDb) syncInDb() error {
 }
 
 func (pn *PathNodeDb) insertInDb() (bool, error) {
+	return pn.insertInDbWith(pn.pathCtx.pathNodesTe())
+}
+
+func (pn *PathNodeDb) insertInDbWith(te *m3db.TableExec) (bool, error) {
 	if pn.pathPoint == m3path.NilPathPoint {
 		return false, m3util.MakeQsmErrorf("cannot sync in DB path node %s with no point info", pn.String())
 	}
-	te := pn.pathCtx.pathNodesTe()
 	pathNodeIds := pn.GetLinkIdsForDb()
 	id, err := te.InsertReturnId(pn.pathCtxId, pn.pathBuilderId, pn.pathBuilderIdx, pn.TrioId, pn.pathPoint.Id, pn.d,
 		pn.ConnectionMask,
@@ -0,0 +1,83 @@
+package pathdb
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3path"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"sort"
+)
+
+// Below distance 2 the sizes are fixed
+const minSizeModelDist = 2
+
+// Above this mean relative error of the growth type model, a specific model is fitted for the growth index
+const maxTypeModelError = 0.05
+
+/*
+Fit the quadratic trend lines of the number of path nodes per distance from all the path nodes saved in this environment.
+One model per growth type, and one per growth index when the growth type model does not fit this index well enough.
+*/
+func (pathData *ServerPathPackData) CalculatePredictedSizeModels() ([]*m3point.PredictedSizeModel, error) {
+	err := pathData.InitAllPathContexts()
+	if err != nil {
+		return nil, err
+	}
+
+	te := pathData.pathNodesTe
+	rows, err := te.Query(CountPathNodesPerCtxAndDistance)
+	if err != nil {
+		return nil, err
+	}
+	defer te.CloseRows(rows)
+
+	// Samples of (distance, number of nodes) per growth type and then per growth index
+	samplesPerType := make(map[m3point.GrowthType]map[int][][2]int)
+	for rows.Next() {
+		var pathCtxId, d, nbNodes int
+		err = rows.Scan(&pathCtxId, &d, &nbNodes)
+		if err != nil {
+			return nil, m3util.MakeWrapQsmErrorf(err, "could not read path nodes count per distance due to %v", err)
+		}
+		pathCtx := pathData.GetPathCtxDb(m3path.PathContextId(pathCtxId))
+		// Only the distances fully calculated are good samples
+		if pathCtx == nil || d < minSizeModelDist || d > pathCtx.GetMaxDist() {
+			continue
+		}
+		samplesPerIndex, ok := samplesPerType[pathCtx.GetGrowthType()]
+		if !ok {
+			samplesPerIndex = make(map[int][][2]int)
+			samplesPerType[pathCtx.GetGrowthType()] = samplesPerIndex
+		}
+		samplesPerIndex[pathCtx.GetGrowthIndex()] = append(samplesPerIndex[pathCtx.GetGrowthIndex()], [2]int{d, nbNodes})
+	}
+
+	res := make([]*m3point.PredictedSizeModel, 0, len(samplesPerType))
+	for growthType, samplesPerIndex := range samplesPerType {
+		allSamples := make([][2]int, 0)
+		for _, samples := range samplesPerIndex {
+			allSamples = append(allSamples, samples...)
+		}
+		typeModel := m3point.FitPredictedSizeModel(growthType, m3point.AllGrowthIndexes, allSamples)
+		if typeModel == nil {
+			Log.Infof("not enough samples to fit size model for %s", growthType.String())
+			continue
+		}
+		res = append(res, typeModel)
+		for growthIndex, samples := range samplesPerIndex {
+			if typeModel.MeanRelativeError(samples) <= maxTypeModelError {
+				continue
+			}
+			indexModel := m3point.FitPredictedSizeModel(growthType, growthIndex, samples)
+			if indexModel != nil {
+				res = append(res, indexModel)
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].GrowthType != res[j].GrowthType {
+			return res[i].GrowthType < res[j].GrowthType
+		}
+		return res[i].GrowthIndex < res[j].GrowthIndex
+	})
+	return res, nil
+}
@@ -1,6 +1,7 @@
 package pathdb
 
 import (
+	"database/sql"
 	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/backend/pointdb"
 	"github.com/freddy33/qsm-go/m3util"
@@ -13,9 +14,10 @@ type ServerPathPackData struct {
 	env        *m3db.QsmDbEnvironment
 	pathCtxMap map[m3path.PathContextId]*PathContextDb
 
-	pointsTe    *m3db.TableExec
-	pathCtxTe   *m3db.TableExec
-	pathNodesTe *m3db.TableExec
+	pointsTe        *m3db.TableExec
+	pathCtxTe       *m3db.TableExec
+	pathNodesTe     *m3db.TableExec
+	pathDistStatsTe *m3db.TableExec
 
 	// All PathContexts centered at origin with growth type + offset
 	allPathContextsLoadMutex sync.Mutex
@@ -67,17 +69,30 @@ func (pathData *ServerPathPackData) GetPathCtx(id m3path.PathContextId) m3path.P
 	return pathData.GetPathCtxDb(id)
 }
 
+// For custom growth contexts the number of indexes grows and the max offset is the one of the longest sequence
+func getAllCtxMaxOffset(growthType m3point.GrowthType) int {
+	if growthType.IsCustom() {
+		return m3point.MaxCustomSequenceSize
+	}
+	return growthType.GetMaxOffset()
+}
+
 func (pathData *ServerPathPackData) addPathContext(pathCtx *PathContextDb) {
 	growthType := pathCtx.GetGrowthType()
 	nbIndexes := growthType.GetNbIndexes()
-	maxOffset := growthType.GetMaxOffset()
+	maxOffset := getAllCtxMaxOffset(growthType)
+	if growthType.IsCustom() {
+		nbIndexes = pathCtx.GetGrowthIndex() + 1
+	}
 	contexts := pathData.AllCenterContexts[growthType]
-	if len(contexts) == 0 {
+	if len(contexts) < nbIndexes*maxOffset {
 		allCtxSize := nbIndexes * maxOffset
-		contexts = make([]*PathContextDb, allCtxSize)
+		newContexts := make([]*PathContextDb, allCtxSize)
 		for i := 0; i < allCtxSize; i++ {
-			contexts[i] = nil
+			newContexts[i] = nil
 		}
+		copy(newContexts, contexts)
+		contexts = newContexts
 		pathData.AllCenterContexts[growthType] = contexts
 	}
 	allCtxIdx := pathCtx.GetGrowthIndex()*maxOffset + pathCtx.GetGrowthOffset()
@@ -98,7 +113,8 @@ func (pathData *ServerPathPackData) InitAllPathContexts() error {
 
 	te := pathData.pathCtxTe
 	nbPathCtx, toFill, err := te.GetForSaveAll()
-	if err != nil {
+	missingType0 := pointdb.IsBeforeGrowthType0(err, nbPathContextsBeforeType0)
+	if err != nil && !missingType0 {
 		return err
 	}
 	if !toFill {
@@ -114,12 +130,21 @@ func (pathData *ServerPathPackData) InitAllPathContexts() error {
 			}
 			pathData.addPathContext(pathCtx)
 		}
+		if missingType0 {
+			err = pathData.addMissingGrowthType0PathContexts()
+			if err != nil {
+				return err
+			}
+		}
 	} else {
 		Log.Info("Creating and saving all path contexts in DB")
 		pointData := pointdb.GetServerPointPackData(pathData.env)
 		for _, growthCtx := range pointData.GetAllGrowthContexts() {
-			ctxType := growthCtx.GetGrowthType()
-			maxOffset := ctxType.GetMaxOffset()
+			if growthCtx.GetGrowthType().IsCustom() {
+				// Created on demand in GetPathCtxDbFromAttributes
+				continue
+			}
+			maxOffset := growthCtx.GetMaxOffset()
 			for offset := 0; offset < maxOffset; offset++ {
 				var err error
 				pathCtx, err := pathData.internalCreatePathCtxDb(growthCtx, offset)
@@ -143,23 +168,54 @@ func (pathData *ServerPathPackData) GetPathCtxFromAttributes(growthType m3point.
 }
 
 func (pathData *ServerPathPackData) GetPathCtxDbFromAttributes(growthType m3point.GrowthType, growthIndex int, growthOffset int) (*PathContextDb, error) {
+	if !growthType.IsValid() {
+		return nil, m3util.MakeQsmErrorf("growth type %d does not exists", growthType)
+	}
+	if !growthType.IsCustom() && (growthIndex < 0 || growthIndex >= growthType.GetNbIndexes()) {
+		return nil, m3util.MakeQsmErrorf("growth index %d for growth type %d should be in [0,%d[", growthIndex, growthType, growthType.GetNbIndexes())
+	}
 	growthCtx := pointdb.GetServerPointPackData(pathData.env).GetGrowthContextByTypeAndIndex(growthType, growthIndex)
 	if growthCtx == nil {
 		return nil, m3util.MakeQsmErrorf("could not find Growth Context for %d %d", growthType, growthIndex)
 	}
+	if growthOffset < 0 || growthOffset >= growthCtx.GetMaxOffset() {
+		return nil, m3util.MakeQsmErrorf("growth offset %d for growth context %s should be in [0,%d[", growthOffset, growthCtx.String(), growthCtx.GetMaxOffset())
+	}
 	err := pathData.InitAllPathContexts()
 	if err != nil {
 		return nil, err
 	}
-	allCtxIdx := growthIndex*growthType.GetMaxOffset() + growthOffset
+	allCtxIdx := growthIndex*getAllCtxMaxOffset(growthType) + growthOffset
 	contexts := pathData.AllCenterContexts[growthType]
-	if len(contexts) > allCtxIdx {
+	if len(contexts) > allCtxIdx && contexts[allCtxIdx] != nil {
 		return contexts[allCtxIdx], nil
 	}
+	if growthType.IsCustom() {
+		return pathData.createCustomPathCtxDb(growthCtx, growthOffset)
+	}
 	return nil, m3util.MakeQsmErrorf("could not find Path Context for %d %d %d", growthType, growthIndex, growthOffset)
 }
 
-func (pathData *ServerPathPackData) internalCreatePathCtxDb(growthCtx m3point.GrowthContext, offset int) (*PathContextDb, error) {
+func (pathData *ServerPathPackData) createCustomPathCtxDb(growthCtx m3point.GrowthContext, offset int) (*PathContextDb, error) {
+	pathData.allPathContextsLoadMutex.Lock()
+	defer pathData.allPathContextsLoadMutex.Unlock()
+
+	// May have been created while waiting for the lock
+	allCtxIdx := growthCtx.GetGrowthIndex()*m3point.MaxCustomSequenceSize + offset
+	contexts := pathData.AllCenterContexts[growthCtx.GetGrowthType()]
+	if len(contexts) > allCtxIdx && contexts[allCtxIdx] != nil {
+		return contexts[allCtxIdx], nil
+	}
+
+	pathCtx, err := pathData.internalCreatePathCtxDb(growthCtx, offset)
+	if err != nil {
+		return nil, err
+	}
+	pathData.addPathContext(pathCtx)
+	return pathCtx, nil
+}
+
+func (pathData *ServerPathPackData) makePathCtxDb(growthCtx m3point.GrowthContext, offset int) *PathContextDb {
 	pathCtx := PathContextDb{}
 	pathCtx.pathData = pathData
 	pathCtx.pointData = pointdb.GetServerPointPackData(pathData.env)
@@ -167,18 +223,70 @@ func (pathData *ServerPathPackData) internalCreatePathCtxDb(growthCtx m3point.Gr
 	pathCtx.growthOffset = offset
 	pathCtx.rootNode = nil
 	pathCtx.maxDist = 0
+	pathCtx.distStats = m3path.MakePathDistStats(32)
+	return &pathCtx
+}
+
+func (pathData *ServerPathPackData) internalCreatePathCtxDb(growthCtx m3point.GrowthContext, offset int) (*PathContextDb, error) {
+	pathCtx := pathData.makePathCtxDb(growthCtx, offset)
 
 	err := pathCtx.insertInDb()
 	if err != nil {
 		return nil, m3util.MakeWrapQsmErrorf(err, "could not save new path context %s due to %v", pathCtx.String(), err)
 	}
 
-	pathData.pathCtxMap[pathCtx.GetId()] = &pathCtx
+	pathData.pathCtxMap[pathCtx.GetId()] = pathCtx
 
 	err = pathCtx.createRootNode()
 	if err != nil {
 		return nil, err
 	}
 
-	return &pathCtx, nil
+	return pathCtx, nil
+}
+
+/*
+Insert in one transaction the path contexts of the growth type 0 contexts, with their root nodes,
+missing from tables filled before type 0 existed. They are added to the maps only after the commit.
+*/
+func (pathData *ServerPathPackData) addMissingGrowthType0PathContexts() error {
+	te := pathData.pathCtxTe
+	Log.Infof("Adding the growth type 0 path contexts to table %s", te.GetFullTableName())
+	origin := m3point.Origin
+	pathPoint, err := pathData.GetOrCreatePoint(origin)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not get or insert the origin point %v due to %s", origin, err.Error())
+	}
+	added := make([]*PathContextDb, 0, m3point.GetTotalNbPathContexts()-nbPathContextsBeforeType0)
+	err = pathData.env.InTransaction(func(tx *sql.Tx) error {
+		pathCtxTe := te.InTx(tx)
+		pathNodesTe := pathData.pathNodesTe.InTx(tx)
+		distStatsTe := pathData.pathDistStatsTe.InTx(tx)
+		for _, growthCtx := range pointdb.GetServerPointPackData(pathData.env).GetAllGrowthContexts() {
+			if growthCtx.GetGrowthType() != m3point.GrowthType(0) {
+				continue
+			}
+			for offset := 0; offset < growthCtx.GetMaxOffset(); offset++ {
+				pathCtx := pathData.makePathCtxDb(growthCtx, offset)
+				err := pathCtx.insertInDbWith(pathCtxTe)
+				if err != nil {
+					return m3util.MakeWrapQsmErrorf(err, "could not save new path context %s due to %v", pathCtx.String(), err)
+				}
+				err = pathCtx.insertRootNode(pathCtxTe, pathNodesTe, distStatsTe, pathPoint)
+				if err != nil {
+					return err
+				}
+				added = append(added, pathCtx)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, pathCtx := range added {
+		pathData.pathCtxMap[pathCtx.GetId()] = pathCtx
+		pathData.addPathContext(pathCtx)
+	}
+	return nil
 }
@@ -1,23 +1,52 @@
 package pathdb
 
 import (
+	"fmt"
 	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/backend/pointdb"
 	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
 )
 
 var Log = m3util.NewLogger("pathdb", m3util.INFO)
 
 const (
-	PointsTable       = "points"
-	PathContextsTable = "path_contexts"
-	PathNodesTable    = "path_nodes"
+	PointsTable        = "points"
+	PathContextsTable  = "path_contexts"
+	PathNodesTable     = "path_nodes"
+	PathDistStatsTable = "path_ctx_dist_stats"
 )
 
+var pathNodesCreated = m3util.QsmMetrics.Counter("qsm_path_nodes_created_total",
+	"Number of path nodes saved per path context", "path_ctx")
+var pathNodesConflicts = m3util.QsmMetrics.Counter("qsm_path_nodes_conflicts_total",
+	"Number of new path nodes rejected because the point is already used in the path context", "path_ctx")
+var pathDeadEnds = m3util.QsmMetrics.Counter("qsm_path_dead_ends_total",
+	"Number of connections becoming dead ends per path context", "path_ctx")
+var nextMaxDistDuration = m3util.QsmMetrics.Histogram("qsm_path_next_max_dist_duration_seconds",
+	"Duration of the calculation of one more distance per path context", m3util.DefaultDurationBuckets, "path_ctx")
+
+func collectPathPointsSizes() []m3util.GaugeValue {
+	res := make([]m3util.GaugeValue, 0)
+	m3util.RangeEnvironments(func(env m3util.QsmEnvironment) {
+		pathData, ok := env.GetData(m3util.PathIdx).(*ServerPathPackData)
+		if ok && pathData != nil {
+			res = append(res, m3util.GaugeValue{
+				LabelValues: []string{env.GetId().String()},
+				Value:       float64(pathData.pathPointsMap.Size()),
+			})
+		}
+	})
+	return res
+}
+
 func init() {
+	m3util.QsmMetrics.GaugeFunc("qsm_path_points_cached", "Number of points in the path points map cache",
+		collectPathPointsSizes, "env")
 	m3db.AddTableDef(createPointsTableDef())
 	m3db.AddTableDef(createPathContextsTableDef())
 	m3db.AddTableDef(creatPathNodesTableDef())
+	m3db.AddTableDef(createPathDistStatsTableDef())
 }
 
 const (
@@ -49,6 +78,9 @@ const (
 	UpdateMaxDist       = 1
 )
 
+// Number of path contexts saved in the envs filled before the growth type 0 existed
+const nbPathContextsBeforeType0 = 200
+
 func createPathContextsTableDef() *m3db.TableDefinition {
 	res := m3db.TableDefinition{}
 	res.Name = PathContextsTable
@@ -63,7 +95,9 @@ func createPathContextsTableDef() *m3db.TableDefinition {
 	res.Insert = "(growth_ctx_id, growth_offset, path_builders_id) values ($1,$2,NULL) returning id"
 	allFields := "id, growth_ctx_id, growth_offset, path_builders_id, max_dist"
 	res.SelectAll = "select " + allFields + " from %s"
-	res.ExpectedCount = 200
+	// Path contexts of custom growth contexts are created on demand
+	res.ExpectedCount = m3point.GetTotalNbPathContexts()
+	res.ExpectedCountFilter = fmt.Sprintf("where growth_ctx_id < %d", m3point.TotalNbContexts)
 	res.Queries = make([]string, 2)
 	res.Queries[UpdatePathBuilderId] = "update %s set path_builders_id = $2 where id = $1"
 	res.Queries[UpdateMaxDist] = "update %s set max_dist = $2 where id = $1"
@@ -79,6 +113,8 @@ const (
 	SelectPathNodesByCtxAndBetweenDistance
 	CountPathNodesByCtx
 	SelectPathNodeIdByCtxAndPointId
+	CountPathNodesPerCtxAndDistance
+	CountPathNodesByCtxPerDistance
 )
 
 func creatPathNodesTableDef() *m3db.TableDefinition {
@@ -112,7 +148,7 @@ func creatPathNodesTableDef() *m3db.TableDefinition {
 		" $8,$9,$10) returning id"
 	res.SelectAll = "not to call select all on node path"
 	res.ExpectedCount = -1
-	res.Queries = make([]string, 8)
+	res.Queries = make([]string, 10)
 	res.QueryTableRefs = make(map[int][]string, 1)
 	selectAllFields := "id, path_ctx_id, path_builders_id, path_builder_idx, trio_id, point_id, d," +
 		" connection_mask," +
@@ -148,12 +184,41 @@ func creatPathNodesTableDef() *m3db.TableDefinition {
 	res.Queries[SelectPathNodeIdByCtxAndPointId] = "select id " +
 		" from %s where path_ctx_id = $1 and point_id = $2"
 
+	res.Queries[CountPathNodesPerCtxAndDistance] = "select path_ctx_id, d, count(id)" +
+		" from %s group by path_ctx_id, d"
+
+	res.Queries[CountPathNodesByCtxPerDistance] = "select d, count(id)" +
+		" from %s where path_ctx_id = $1 group by d order by d"
+
+	return &res
+}
+
+const (
+	SelectDistStatsByCtx = 0
+)
+
+func createPathDistStatsTableDef() *m3db.TableDefinition {
+	res := m3db.TableDefinition{}
+	res.Name = PathDistStatsTable
+	res.DdlColumns = "(path_ctx_id integer NOT NULL REFERENCES %s (id)," +
+		" d integer NOT NULL," +
+		" nb_nodes integer NOT NULL," +
+		" nb_dead_ends integer NOT NULL DEFAULT 0," +
+		" nb_conflicts integer NOT NULL DEFAULT 0," +
+		" PRIMARY KEY (path_ctx_id, d))"
+	res.DdlColumnsRefs = []string{PathContextsTable}
+	res.Insert = "(path_ctx_id, d, nb_nodes, nb_dead_ends, nb_conflicts) values ($1,$2,$3,$4,$5)"
+	res.SelectAll = "not to call select all on path dist stats"
+	res.ExpectedCount = -1
+	res.Queries = make([]string, 1)
+	res.Queries[SelectDistStatsByCtx] = "select d, nb_nodes, nb_dead_ends, nb_conflicts" +
+		" from %s where path_ctx_id = $1 order by d"
 	return &res
 }
 
 func (pathData *ServerPathPackData) createTables() {
-	tableNames := [3]string{PointsTable, PathContextsTable, PathNodesTable}
-	pathTableExecs := [3]*m3db.TableExec{}
+	tableNames := [4]string{PointsTable, PathContextsTable, PathNodesTable, PathDistStatsTable}
+	pathTableExecs := [4]*m3db.TableExec{}
 
 	// IMPORTANT: Create ALL the tables before preparing the queries
 	var err error
@@ -177,6 +242,7 @@ func (pathData *ServerPathPackData) createTables() {
 	pathData.pointsTe = pathTableExecs[0]
 	pathData.pathCtxTe = pathTableExecs[1]
 	pathData.pathNodesTe = pathTableExecs[2]
+	pathData.pathDistStatsTe = pathTableExecs[3]
 }
 
 func GetPathDbFullEnv(envId m3util.QsmEnvID) *m3db.QsmDbEnvironment {
@@ -2,6 +2,7 @@ package pointdb
 
 import (
 	"fmt"
+	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3point"
 	"github.com/stretchr/testify/assert"
@@ -129,3 +130,110 @@ func assertValidConnDetails(t *testing.T, ppd *ServerPointPackData, p1, p2 m3poi
 	assert.NotEqual(t, m3point.EmptyConnDetails, connDetails2, msg)
 	assert.Equal(t, m3point.MakeVector(p2, p1), connDetails2.Vector, msg)
 }
+
+func getInMemoryPointTestData() *ServerPointPackData {
+	ppd := new(ServerPointPackData)
+	ppd.env = new(m3db.QsmDbEnvironment)
+	ppd.env.SetData(m3util.PointIdx, ppd)
+	ppd.AllConnections, ppd.AllConnectionsByVector = ppd.calculateConnectionDetails()
+	ppd.ConnectionsLoaded = true
+	ppd.AllTrioDetails = ppd.calculateAllTrioDetails()
+	ppd.TrioDetailsLoaded = true
+	ppd.AllGrowthContexts = ppd.calculateAllGrowthContexts()
+	ppd.GrowthContextsLoaded = true
+	ppd.cubeIdsPerKey = make(map[CubeKeyId]int)
+	ppd.cubesLoaded = true
+	return ppd
+}
+
+func TestValidateTrioSequence(t *testing.T) {
+	Log.SetAssert(true)
+	ppd := getInMemoryPointTestData()
+
+	for _, validTrio := range ppd.GetValidNextTrio() {
+		assert.NoError(t, m3point.ValidateTrioSequence(ppd, validTrio[:]))
+	}
+	for _, perm := range ppd.GetAllMod4Permutations() {
+		assert.NoError(t, m3point.ValidateTrioSequence(ppd, perm[:]))
+	}
+	for _, perm := range ppd.GetAllMod8Permutations() {
+		assert.NoError(t, m3point.ValidateTrioSequence(ppd, perm[:]))
+	}
+	// Same trio can come back in the cycle
+	assert.NoError(t, m3point.ValidateTrioSequence(ppd, []m3point.TrioIndex{0, 5, 0, 6}))
+
+	// Too short, too long, not base trio, prime trios next to each other, and odd size
+	assert.Error(t, m3point.ValidateTrioSequence(ppd, []m3point.TrioIndex{0}))
+	assert.Error(t, m3point.ValidateTrioSequence(ppd, []m3point.TrioIndex{0, 5, 0, 6, 0, 5, 0, 6, 0, 5}))
+	assert.Error(t, m3point.ValidateTrioSequence(ppd, []m3point.TrioIndex{0, 12}))
+	err := m3point.ValidateTrioSequence(ppd, []m3point.TrioIndex{0, 1})
+	assert.Error(t, err)
+	assert.Equal(t, m3util.ErrBadRequest, m3util.GetQsmErrorCode(err))
+	for i := 0; i < 4; i++ {
+		for j := 4; j < 8; j++ {
+			if isPrime(m3point.TrioIndex(i), m3point.TrioIndex(j)) {
+				assert.Error(t, m3point.ValidateTrioSequence(ppd, []m3point.TrioIndex{m3point.TrioIndex(i), m3point.TrioIndex(j)}))
+			}
+		}
+	}
+	assert.Error(t, m3point.ValidateTrioSequence(ppd, []m3point.TrioIndex{0, 5, 1}))
+
+	assert.True(t, m3point.SameTrioSequence([]m3point.TrioIndex{0, 4, 1, 5}, []m3point.TrioIndex{1, 5, 0, 4}))
+	assert.False(t, m3point.SameTrioSequence([]m3point.TrioIndex{0, 4, 1, 5}, []m3point.TrioIndex{0, 5, 1, 4}))
+	assert.False(t, m3point.SameTrioSequence([]m3point.TrioIndex{0, 4}, []m3point.TrioIndex{0, 4, 0, 4}))
+}
+
+func TestCustomGrowthContextCubes(t *testing.T) {
+	Log.SetAssert(true)
+	ppd := getInMemoryPointTestData()
+
+	// All the cycles of size 2 and 4, and a few with repeated trios of size 6 and 8
+	sequences := make([][]m3point.TrioIndex, 0, 200)
+	for _, validTrio := range ppd.GetValidNextTrio() {
+		sequences = append(sequences, validTrio[:])
+	}
+	for _, a := range ppd.GetValidNextTrio() {
+		for _, b := range ppd.GetValidNextTrio() {
+			seq := []m3point.TrioIndex{a[0], a[1], b[0], b[1]}
+			if m3point.ValidateTrioSequence(ppd, seq) == nil {
+				sequences = append(sequences, seq)
+			}
+		}
+	}
+	sequences = append(sequences,
+		[]m3point.TrioIndex{0, 5, 0, 6, 0, 7},
+		[]m3point.TrioIndex{3, 4, 2, 4, 1, 4, 2, 4})
+
+	for _, seq := range sequences {
+		if !assert.NoError(t, m3point.ValidateTrioSequence(ppd, seq)) {
+			return
+		}
+		growthCtx, cubeIds, builders := ppd.calculateCustomGrowthContext(seq)
+		assert.Equal(t, m3point.TotalNbContexts, growthCtx.GetId())
+		assert.Equal(t, m3point.CustomGrowthType, growthCtx.GetGrowthType())
+		assert.Equal(t, 0, growthCtx.GetGrowthIndex())
+		assert.Equal(t, len(seq), growthCtx.GetMaxOffset())
+		if !assert.Equal(t, len(cubeIds), len(builders), "wrong nb builders for %v", seq) {
+			return
+		}
+		for i, builder := range builders {
+			assert.Equal(t, i+1, builder.Ctx.CubeId)
+			assert.Equal(t, seq[0], growthCtx.GetBaseTrioIndex(ppd, 0, 0))
+		}
+		max := m3point.CInt(2 * len(seq))
+		for offset := 0; offset < len(seq); offset++ {
+			for x := -max; x <= max; x++ {
+				for y := -max; y <= max; y++ {
+					for z := -max; z <= max; z++ {
+						mp := m3point.Point{x, y, z}.Mul(m3point.THREE)
+						key := CubeKeyId{GrowthCtxId: growthCtx.GetId(), Cube: CreateTrioCube(ppd, growthCtx, offset, mp)}
+						_, ok := cubeIds[key]
+						if !assert.True(t, ok, "did not find cube for %v at %d and %v", seq, offset, mp) {
+							return
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package pointdb
 
 import (
+	"database/sql"
+	"fmt"
 	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/lib/pq"
 )
 
 const (
 	GrowthContextsTable = "growth_contexts"
+	// The growth contexts saved before the type 0 ones were added with the following ids
+	nbGrowthContextsBeforeType0 = 52
 )
 
 func init() {
@@ -19,10 +24,15 @@ func createGrowthContextsTableDef() *m3db.TableDefinition {
 	res.Name = GrowthContextsTable
 	res.DdlColumns = "(id smallint PRIMARY KEY," +
 		" ctx_type smallint," +
-		" ctx_index smallint, UNIQUE (ctx_type, ctx_index) )"
-	res.Insert = "(id, ctx_type, ctx_index) values ($1,$2,$3)"
-	res.SelectAll = "select id, ctx_type, ctx_index from %s"
-	res.ExpectedCount = 52
+		" ctx_index smallint," +
+		" trio_sequence smallint[] NULL, UNIQUE (ctx_type, ctx_index) )"
+	res.Insert = "(id, ctx_type, ctx_index, trio_sequence) values ($1,$2,$3,$4)"
+	res.SelectAll = "select id, ctx_type, ctx_index, trio_sequence from %s order by id"
+	// Tables created before the custom growth contexts
+	res.Migrations = []string{"add column if not exists trio_sequence smallint[] NULL"}
+	// Custom growth contexts are added at runtime
+	res.ExpectedCount = m3point.TotalNbContexts
+	res.ExpectedCountFilter = fmt.Sprintf("where ctx_type <> %d", m3point.CustomGrowthType)
 	return &res
 }
 
@@ -42,10 +52,17 @@ func (pointData *ServerPointPackData) loadGrowthContexts() error {
 	for rows.Next() {
 		growthCtx := m3point.BaseGrowthContext{}
 		growthCtx.Env = pointData.env
-		err := rows.Scan(&growthCtx.Id, &growthCtx.GrowthType, &growthCtx.GrowthIndex)
+		var trioSequence pq.Int64Array
+		err := rows.Scan(&growthCtx.Id, &growthCtx.GrowthType, &growthCtx.GrowthIndex, &trioSequence)
 		if err != nil {
 			return m3util.MakeWrapQsmErrorf(err, "failed to load trio context line %d", len(res))
 		} else {
+			if len(trioSequence) > 0 {
+				growthCtx.TrioSequence = make([]m3point.TrioIndex, len(trioSequence))
+				for i, trIdx := range trioSequence {
+					growthCtx.TrioSequence[i] = m3point.TrioIndex(trIdx)
+				}
+			}
 			res = append(res, &growthCtx)
 		}
 	}
@@ -59,6 +76,9 @@ func (pointData *ServerPointPackData) loadGrowthContexts() error {
 func (pointData *ServerPointPackData) saveAllGrowthContexts() (int, error) {
 	te := pointData.growthCtxTe
 	inserted, toFill, err := te.GetForSaveAll()
+	if IsBeforeGrowthType0(err, nbGrowthContextsBeforeType0) {
+		return pointData.addMissingGrowthType0Contexts(inserted)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -68,7 +88,7 @@ func (pointData *ServerPointPackData) saveAllGrowthContexts() (int, error) {
 			Log.Debugf("Populating table %s with %d elements", te.GetFullTableName(), len(growthContexts))
 		}
 		for _, growthCtx := range growthContexts {
-			err := te.Insert(growthCtx.GetId(), growthCtx.GetGrowthType(), growthCtx.GetGrowthIndex())
+			err := te.Insert(growthCtx.GetId(), growthCtx.GetGrowthType(), growthCtx.GetGrowthIndex(), nil)
 			if err != nil {
 				return inserted, err
 			} else {
@@ -80,6 +100,35 @@ func (pointData *ServerPointPackData) saveAllGrowthContexts() (int, error) {
 	return inserted, nil
 }
 
+// Insert in one transaction the type 0 growth contexts missing from tables filled before type 0 existed
+func (pointData *ServerPointPackData) addMissingGrowthType0Contexts(inserted int) (int, error) {
+	te := pointData.growthCtxTe
+	Log.Infof("Adding the growth type 0 contexts to table %s", te.GetFullTableName())
+	err := pointData.env.InTransaction(func(tx *sql.Tx) error {
+		txTe := te.InTx(tx)
+		for _, growthCtx := range pointData.calculateAllGrowthContexts() {
+			if growthCtx.GetId() < nbGrowthContextsBeforeType0 {
+				continue
+			}
+			err := txTe.Insert(growthCtx.GetId(), growthCtx.GetGrowthType(), growthCtx.GetGrowthIndex(), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return inserted, err
+	}
+	return m3point.TotalNbContexts, nil
+}
+
+// True if the saved rows are the full set as it was before the growth type 0 contexts were added
+func IsBeforeGrowthType0(err error, nbRowsBeforeType0 int) bool {
+	wrongCount, ok := err.(*m3db.QsmWrongCount)
+	return ok && wrongCount.Actual() == nbRowsBeforeType0
+}
+
 func (pointData *ServerPointPackData) calculateAllGrowthContexts() []m3point.GrowthContext {
 	res := make([]m3point.GrowthContext, m3point.TotalNbContexts)
 	idx := 0
@@ -94,3 +143,113 @@ func (pointData *ServerPointPackData) calculateAllGrowthContexts() []m3point.Gro
 	return res
 }
 
+/***************************************************************/
+// Custom growth contexts
+/***************************************************************/
+
+// Register a new growth context cycling through the given trio indexes, or return the existing one for the same cycle.
+// The cubes and path builders of the new growth context are calculated and saved right away.
+func (pointData *ServerPointPackData) AddCustomGrowthContext(trioSequence []m3point.TrioIndex) (m3point.GrowthContext, error) {
+	pointData.CheckPathBuildersInitialized()
+	err := m3point.ValidateTrioSequence(pointData, trioSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	pointData.customCtxMutex.Lock()
+	defer pointData.customCtxMutex.Unlock()
+
+	for _, growthCtx := range pointData.AllGrowthContexts {
+		if growthCtx.GetGrowthType().IsCustom() && m3point.SameTrioSequence(growthCtx.GetTrioSequence(), trioSequence) {
+			return growthCtx, nil
+		}
+	}
+
+	growthCtx, cubeIds, builders := pointData.calculateCustomGrowthContext(trioSequence)
+
+	dbTrioSequence := make(pq.Int64Array, len(trioSequence))
+	for i, trIdx := range trioSequence {
+		dbTrioSequence[i] = int64(trIdx)
+	}
+	err = pointData.env.InTransaction(func(tx *sql.Tx) error {
+		err := pointData.growthCtxTe.InTx(tx).Insert(growthCtx.GetId(), growthCtx.GetGrowthType(), growthCtx.GetGrowthIndex(), dbTrioSequence)
+		if err != nil {
+			return err
+		}
+		cubesTe := pointData.trioCubesTe.InTx(tx)
+		for cubeKey, cubeId := range cubeIds {
+			err = insertCube(cubesTe, cubeId, cubeKey)
+			if err != nil {
+				return err
+			}
+		}
+		buildersTe := pointData.pathBuildersTe.InTx(tx)
+		for _, builder := range builders {
+			err = insertPathBuilder(buildersTe, builder.Ctx.CubeId, builder)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Readers may be using the current collections, so always replace them with new ones
+	newCubeIdsPerKey := make(map[CubeKeyId]int, len(pointData.cubeIdsPerKey)+len(cubeIds))
+	for cubeKey, cubeId := range pointData.cubeIdsPerKey {
+		newCubeIdsPerKey[cubeKey] = cubeId
+	}
+	for cubeKey, cubeId := range cubeIds {
+		newCubeIdsPerKey[cubeKey] = cubeId
+	}
+	newPathBuilders := make([]*RootPathNodeBuilder, len(pointData.pathBuilders), len(pointData.pathBuilders)+len(builders))
+	copy(newPathBuilders, pointData.pathBuilders)
+	newPathBuilders = append(newPathBuilders, builders...)
+	newGrowthContexts := make([]m3point.GrowthContext, len(pointData.AllGrowthContexts), len(pointData.AllGrowthContexts)+1)
+	copy(newGrowthContexts, pointData.AllGrowthContexts)
+	newGrowthContexts = append(newGrowthContexts, growthCtx)
+
+	pointData.dataMutex.Lock()
+	pointData.cubeIdsPerKey = newCubeIdsPerKey
+	pointData.pathBuilders = newPathBuilders
+	pointData.AllGrowthContexts = newGrowthContexts
+	pointData.dataMutex.Unlock()
+
+	Log.Infof("Environment %d registered %s for trio sequence %v with %d cubes", pointData.GetEnvId(), growthCtx.String(), trioSequence, len(cubeIds))
+	return growthCtx, nil
+}
+
+// Create the new custom growth context with its cubes and path builders without changing the pack data.
+// The path builders are returned in cube id order following the last existing path builder.
+func (pointData *ServerPointPackData) calculateCustomGrowthContext(trioSequence []m3point.TrioIndex) (m3point.GrowthContext, map[CubeKeyId]int, []*RootPathNodeBuilder) {
+	nbCustom := 0
+	for _, growthCtx := range pointData.AllGrowthContexts {
+		if growthCtx.GetGrowthType().IsCustom() {
+			nbCustom++
+		}
+	}
+	growthCtx := &m3point.BaseGrowthContext{
+		Env:          pointData.env,
+		Id:           len(pointData.AllGrowthContexts),
+		GrowthType:   m3point.CustomGrowthType,
+		GrowthIndex:  nbCustom,
+		TrioSequence: make([]m3point.TrioIndex, len(trioSequence)),
+	}
+	copy(growthCtx.TrioSequence, trioSequence)
+
+	cubes := pointData.calculateContextCubes(growthCtx)
+	cubeIds := make(map[CubeKeyId]int, len(cubes))
+	builders := make([]*RootPathNodeBuilder, len(cubes))
+	firstCubeId := len(pointData.cubeIdsPerKey) + 1
+	for i, cube := range cubes {
+		cubeId := firstCubeId + i
+		cubeIds[CubeKeyId{GrowthCtxId: growthCtx.GetId(), Cube: cube}] = cubeId
+		root := RootPathNodeBuilder{}
+		root.Ctx = &PathBuilderContext{GrowthCtx: growthCtx, CubeId: cubeId}
+		pointData.populateFromCube(&root, cube)
+		builders[i] = &root
+	}
+	return growthCtx, cubeIds, builders
+}
@@ -11,8 +11,8 @@ import (
 const(
 	ExpectedNbConns = 50
 	ExpectedNbTrios = 200
-	ExpectedNbGrowthContexts = 52
-	ExpectedNbCubes = 5192
+	ExpectedNbGrowthContexts = 60
+	ExpectedNbCubes = 7088
 	ExpectedNbPathBuilders = ExpectedNbCubes
 )
 
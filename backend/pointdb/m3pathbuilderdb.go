@@ -1,6 +1,8 @@
 package pointdb
 
 import (
+	"database/sql"
+	"fmt"
 	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3point"
@@ -63,7 +65,9 @@ func createPathBuilderContextTableDef() *m3db.TableDefinition {
 		" conn31, last_inter31, next_main_conn31, next_inter_conn31," +
 		" conn32, last_inter32, next_main_conn32, next_inter_conn32" +
 		" from %s"
+	// Path builders of custom growth contexts are added at runtime
 	res.ExpectedCount = TotalNumberOfCubes
+	res.ExpectedCountFilter = fmt.Sprintf("where ctx_id < %d", m3point.TotalNbContexts)
 	return &res
 }
 
@@ -77,7 +81,8 @@ func (pointData *ServerPointPackData) loadPathBuilders() error {
 	if err != nil {
 		return err
 	}
-	res := make([]*RootPathNodeBuilder, TotalNumberOfCubes+1)
+	// Cubes are loaded first, and there is one path builder per cube
+	res := make([]*RootPathNodeBuilder, len(pointData.cubeIdsPerKey)+1)
 
 	for rows.Next() {
 		var cubeId, trioIndexId int
@@ -131,6 +136,9 @@ func (pointData *ServerPointPackData) loadPathBuilders() error {
 func (pointData *ServerPointPackData) saveAllPathBuilders() (int, error) {
 	te := pointData.pathBuildersTe
 	inserted, toFill, err := te.GetForSaveAll()
+	if IsBeforeGrowthType0(err, nbCubesBeforeType0) {
+		return pointData.addMissingGrowthType0PathBuilders(inserted)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -143,33 +151,7 @@ func (pointData *ServerPointPackData) saveAllPathBuilders() (int, error) {
 			if cubeId == 0 {
 				continue
 			}
-			interPNs := [3]*IntermediatePathNodeBuilder{}
-			interConnIds := [3][2]m3point.ConnectionId{}
-			lastInterPNs := [3][2]*LastPathNodeBuilder{}
-			for i, pl := range rootNode.PathLinks {
-				ipn, ok := pl.PathNode.(*IntermediatePathNodeBuilder)
-				if !ok {
-					return 0, m3util.MakeQsmErrorf("trying to convert path node to intermediate failed for %v", pl)
-				}
-				interPNs[i] = ipn
-				for j := 0; j < 2; j++ {
-					ipl := ipn.PathLinks[j]
-					interConnIds[i][j] = ipl.ConnId
-					lipn, ok := ipl.PathNode.(*LastPathNodeBuilder)
-					if !ok {
-						return 0, m3util.MakeQsmErrorf("trying to convert path node to last intermediate failed for %v", ipl)
-					}
-					lastInterPNs[i][j] = lipn
-				}
-			}
-			err := te.Insert(cubeId, rootNode.Ctx.GrowthCtx.GetId(), rootNode.TrIdx,
-				interPNs[0].TrIdx, interPNs[1].TrIdx, interPNs[2].TrIdx,
-				interConnIds[0][0], lastInterPNs[0][0].TrIdx, lastInterPNs[0][0].NextMainConnId, lastInterPNs[0][0].NextInterConnId,
-				interConnIds[0][1], lastInterPNs[0][1].TrIdx, lastInterPNs[0][1].NextMainConnId, lastInterPNs[0][1].NextInterConnId,
-				interConnIds[1][0], lastInterPNs[1][0].TrIdx, lastInterPNs[1][0].NextMainConnId, lastInterPNs[1][0].NextInterConnId,
-				interConnIds[1][1], lastInterPNs[1][1].TrIdx, lastInterPNs[1][1].NextMainConnId, lastInterPNs[1][1].NextInterConnId,
-				interConnIds[2][0], lastInterPNs[2][0].TrIdx, lastInterPNs[2][0].NextMainConnId, lastInterPNs[2][0].NextInterConnId,
-				interConnIds[2][1], lastInterPNs[2][1].TrIdx, lastInterPNs[2][1].NextMainConnId, lastInterPNs[2][1].NextInterConnId)
+			err := insertPathBuilder(te, cubeId, rootNode)
 			if err != nil {
 				Log.Fatal(err)
 			} else {
@@ -181,9 +163,60 @@ func (pointData *ServerPointPackData) saveAllPathBuilders() (int, error) {
 	return inserted, nil
 }
 
+// Insert in one transaction the path builders of the type 0 growth contexts cubes
+func (pointData *ServerPointPackData) addMissingGrowthType0PathBuilders(inserted int) (int, error) {
+	te := pointData.pathBuildersTe
+	Log.Infof("Adding the growth type 0 path builders to table %s", te.GetFullTableName())
+	builders := pointData.calculateAllPathBuilders()
+	err := pointData.env.InTransaction(func(tx *sql.Tx) error {
+		txTe := te.InTx(tx)
+		for cubeId := nbCubesBeforeType0 + 1; cubeId <= TotalNumberOfCubes; cubeId++ {
+			err := insertPathBuilder(txTe, cubeId, builders[cubeId])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return inserted, err
+	}
+	return TotalNumberOfCubes, nil
+}
+
+func insertPathBuilder(te *m3db.TableExec, cubeId int, rootNode *RootPathNodeBuilder) error {
+	interPNs := [3]*IntermediatePathNodeBuilder{}
+	interConnIds := [3][2]m3point.ConnectionId{}
+	lastInterPNs := [3][2]*LastPathNodeBuilder{}
+	for i, pl := range rootNode.PathLinks {
+		ipn, ok := pl.PathNode.(*IntermediatePathNodeBuilder)
+		if !ok {
+			return m3util.MakeQsmErrorf("trying to convert path node to intermediate failed for %v", pl)
+		}
+		interPNs[i] = ipn
+		for j := 0; j < 2; j++ {
+			ipl := ipn.PathLinks[j]
+			interConnIds[i][j] = ipl.ConnId
+			lipn, ok := ipl.PathNode.(*LastPathNodeBuilder)
+			if !ok {
+				return m3util.MakeQsmErrorf("trying to convert path node to last intermediate failed for %v", ipl)
+			}
+			lastInterPNs[i][j] = lipn
+		}
+	}
+	return te.Insert(cubeId, rootNode.Ctx.GrowthCtx.GetId(), rootNode.TrIdx,
+		interPNs[0].TrIdx, interPNs[1].TrIdx, interPNs[2].TrIdx,
+		interConnIds[0][0], lastInterPNs[0][0].TrIdx, lastInterPNs[0][0].NextMainConnId, lastInterPNs[0][0].NextInterConnId,
+		interConnIds[0][1], lastInterPNs[0][1].TrIdx, lastInterPNs[0][1].NextMainConnId, lastInterPNs[0][1].NextInterConnId,
+		interConnIds[1][0], lastInterPNs[1][0].TrIdx, lastInterPNs[1][0].NextMainConnId, lastInterPNs[1][0].NextInterConnId,
+		interConnIds[1][1], lastInterPNs[1][1].TrIdx, lastInterPNs[1][1].NextMainConnId, lastInterPNs[1][1].NextInterConnId,
+		interConnIds[2][0], lastInterPNs[2][0].TrIdx, lastInterPNs[2][0].NextMainConnId, lastInterPNs[2][0].NextInterConnId,
+		interConnIds[2][1], lastInterPNs[2][1].TrIdx, lastInterPNs[2][1].NextMainConnId, lastInterPNs[2][1].NextInterConnId)
+}
+
 func (pointData *ServerPointPackData) calculateAllPathBuilders() []*RootPathNodeBuilder {
 	pointData.CheckCubesInitialized()
-	res := make([]*RootPathNodeBuilder, TotalNumberOfCubes+1)
+	res := make([]*RootPathNodeBuilder, len(pointData.cubeIdsPerKey)+1)
 	res[0] = nil
 	for cubeKey, cubeId := range pointData.cubeIdsPerKey {
 		root := RootPathNodeBuilder{}
@@ -195,9 +228,11 @@ func (pointData *ServerPointPackData) calculateAllPathBuilders() []*RootPathNode
 }
 
 func (pointData *ServerPointPackData) Populate(rpnb *RootPathNodeBuilder) {
+	pointData.populateFromCube(rpnb, pointData.GetCubeById(rpnb.Ctx.CubeId).Cube)
+}
+
+func (pointData *ServerPointPackData) populateFromCube(rpnb *RootPathNodeBuilder, cube CubeOfTrioIndex) {
 	growthCtx := rpnb.Ctx.GrowthCtx
-	cubeKey := pointData.GetCubeById(rpnb.Ctx.CubeId)
-	cube := cubeKey.Cube
 	rpnb.TrIdx = cube.Center
 	td := pointData.GetTrioDetails(rpnb.TrIdx)
 	for i, cd := range td.Conns {
@@ -0,0 +1,93 @@
+package pointdb
+
+import (
+	"database/sql"
+	"github.com/freddy33/qsm-go/backend/m3db"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+)
+
+const (
+	PredictedSizeModelsTable = "predicted_size_models"
+)
+
+const (
+	DeleteAllSizeModels = 0
+)
+
+func init() {
+	m3db.AddTableDef(createPredictedSizeModelsTableDef())
+}
+
+func createPredictedSizeModelsTableDef() *m3db.TableDefinition {
+	res := m3db.TableDefinition{}
+	res.Name = PredictedSizeModelsTable
+	res.DdlColumns = "(id serial PRIMARY KEY," +
+		" growth_type smallint NOT NULL," +
+		" growth_index smallint NOT NULL," +
+		" a double precision NOT NULL, b double precision NOT NULL, c double precision NOT NULL," +
+		" max_dist integer NOT NULL," +
+		" CONSTRAINT size_model_growth_key UNIQUE (growth_type, growth_index))"
+	res.Insert = "(growth_type, growth_index, a, b, c, max_dist) values ($1,$2,$3,$4,$5,$6)"
+	res.SelectAll = "select growth_type, growth_index, a, b, c, max_dist from %s"
+	// Filled by the size model analysis, empty until it runs
+	res.ExpectedCount = -1
+	res.Queries = make([]string, 1)
+	res.Queries[DeleteAllSizeModels] = "delete from %s"
+	return &res
+}
+
+/***************************************************************/
+// Predicted Size Models Load and Save
+/***************************************************************/
+
+func (pointData *ServerPointPackData) loadPredictedSizeModels() error {
+	te := pointData.sizeModelsTe
+	rows, err := te.SelectAllForLoad()
+	if err != nil {
+		return err
+	}
+	res := make([]*m3point.PredictedSizeModel, 0, m3point.TotalNbContexts)
+	for rows.Next() {
+		model := m3point.PredictedSizeModel{}
+		err := rows.Scan(&model.GrowthType, &model.GrowthIndex, &model.A, &model.B, &model.C, &model.MaxDist)
+		if err != nil {
+			return m3util.MakeWrapQsmErrorf(err, "failed to load predicted size model line %d", len(res))
+		}
+		res = append(res, &model)
+	}
+
+	pointData.PredictedSizeModels = res
+	pointData.sizeModelsLoaded = true
+
+	return nil
+}
+
+// Replace all the stored predicted size models with the new ones in one transaction
+func (pointData *ServerPointPackData) SavePredictedSizeModels(models []*m3point.PredictedSizeModel) error {
+	err := pointData.env.InTransaction(func(tx *sql.Tx) error {
+		te := pointData.sizeModelsTe.InTx(tx)
+		_, err := te.Update(DeleteAllSizeModels)
+		if err != nil {
+			return err
+		}
+		for _, model := range models {
+			err = te.Insert(model.GrowthType, model.GrowthIndex, model.A, model.B, model.C, model.MaxDist)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	pointData.dataMutex.Lock()
+	pointData.PredictedSizeModels = models
+	pointData.sizeModelsLoaded = true
+	pointData.dataMutex.Unlock()
+	if Log.IsInfo() {
+		Log.Infof("Environment %d saved %d predicted size models", pointData.GetEnvId(), len(models))
+	}
+	return nil
+}
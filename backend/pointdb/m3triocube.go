@@ -28,7 +28,9 @@ func (c CubeKeyId) GetCube() CubeOfTrioIndex {
 }
 
 const (
-	TotalNumberOfCubes = 5192
+	TotalNumberOfCubes = 7088
+	// The cubes saved before the growth type 0 contexts were added
+	nbCubesBeforeType0 = 5192
 )
 
 /***************************************************************/
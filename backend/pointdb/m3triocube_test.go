@@ -55,11 +55,18 @@ func (pointData *ServerPointPackData) distinctCubes(growthCtx m3point.GrowthCont
 		cube := CreateTrioCube(pointData, growthCtx, offset, m3point.Origin)
 		allCubes[cube]++
 	}
-	for x := -max; x <= max; x++ {
-		for y := -max; y <= max; y++ {
-			for z := -max; z <= max; z++ {
-				cube := CreateTrioCube(pointData, growthCtx, 0, m3point.Point{x, y, z}.Mul(m3point.THREE))
-				allCubes[cube]++
+	// For type 0 the offset changes the sequence of trios
+	maxSpaceOffset := 1
+	if growthCtx.GetGrowthType() == 0 {
+		maxSpaceOffset = maxOffset
+	}
+	for offset := 0; offset < maxSpaceOffset; offset++ {
+		for x := -max; x <= max; x++ {
+			for y := -max; y <= max; y++ {
+				for z := -max; z <= max; z++ {
+					cube := CreateTrioCube(pointData, growthCtx, offset, m3point.Point{x, y, z}.Mul(m3point.THREE))
+					allCubes[cube]++
+				}
 			}
 		}
 	}
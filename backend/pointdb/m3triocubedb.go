@@ -1,6 +1,8 @@
 package pointdb
 
 import (
+	"database/sql"
+	"fmt"
 	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3point"
@@ -58,7 +60,9 @@ func createContextCubesTableDef() *m3db.TableDefinition {
 		" middle_edges_MXPY, middle_edges_MXMY, middle_edges_MXPZ, middle_edges_MXMZ, " +
 		" middle_edges_PYPZ, middle_edges_PYMZ, middle_edges_MYPZ, middle_edges_MYMZ" +
 		" from %s"
+	// Cubes of custom growth contexts are added at runtime
 	res.ExpectedCount = TotalNumberOfCubes
+	res.ExpectedCountFilter = fmt.Sprintf("where ctx_id < %d", m3point.TotalNbContexts)
 	return &res
 }
 
@@ -102,6 +106,9 @@ func (pointData *ServerPointPackData) loadContextCubes() error {
 func (pointData *ServerPointPackData) saveAllContextCubes() (int, error) {
 	te := pointData.trioCubesTe
 	inserted, toFill, err := te.GetForSaveAll()
+	if IsBeforeGrowthType0(err, nbCubesBeforeType0) {
+		return pointData.addMissingGrowthType0Cubes(inserted)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -111,12 +118,7 @@ func (pointData *ServerPointPackData) saveAllContextCubes() (int, error) {
 			Log.Debugf("Populating table %s with %d elements", te.GetFullTableName(), len(cubeKeys))
 		}
 		for cubeKey, cubeId := range cubeKeys {
-			cube := cubeKey.Cube
-			err := te.Insert(cubeId, cubeKey.GrowthCtxId, cube.Center,
-				cube.CenterFaces[0], cube.CenterFaces[1], cube.CenterFaces[2], cube.CenterFaces[3], cube.CenterFaces[4], cube.CenterFaces[5],
-				cube.MiddleEdges[0], cube.MiddleEdges[1], cube.MiddleEdges[2], cube.MiddleEdges[3],
-				cube.MiddleEdges[4], cube.MiddleEdges[5], cube.MiddleEdges[6], cube.MiddleEdges[7],
-				cube.MiddleEdges[8], cube.MiddleEdges[9], cube.MiddleEdges[10], cube.MiddleEdges[11])
+			err := insertCube(te, cubeId, cubeKey)
 			if err != nil {
 				return inserted, err
 			} else {
@@ -128,6 +130,38 @@ func (pointData *ServerPointPackData) saveAllContextCubes() (int, error) {
 	return inserted, nil
 }
 
+// Insert in one transaction the cubes of the type 0 growth contexts, all with ids after the existing ones
+func (pointData *ServerPointPackData) addMissingGrowthType0Cubes(inserted int) (int, error) {
+	te := pointData.trioCubesTe
+	Log.Infof("Adding the growth type 0 cubes to table %s", te.GetFullTableName())
+	err := pointData.env.InTransaction(func(tx *sql.Tx) error {
+		txTe := te.InTx(tx)
+		for cubeKey, cubeId := range pointData.calculateAllContextCubes() {
+			if cubeId <= nbCubesBeforeType0 {
+				continue
+			}
+			err := insertCube(txTe, cubeId, cubeKey)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return inserted, err
+	}
+	return TotalNumberOfCubes, nil
+}
+
+func insertCube(te *m3db.TableExec, cubeId int, cubeKey CubeKeyId) error {
+	cube := cubeKey.Cube
+	return te.Insert(cubeId, cubeKey.GrowthCtxId, cube.Center,
+		cube.CenterFaces[0], cube.CenterFaces[1], cube.CenterFaces[2], cube.CenterFaces[3], cube.CenterFaces[4], cube.CenterFaces[5],
+		cube.MiddleEdges[0], cube.MiddleEdges[1], cube.MiddleEdges[2], cube.MiddleEdges[3],
+		cube.MiddleEdges[4], cube.MiddleEdges[5], cube.MiddleEdges[6], cube.MiddleEdges[7],
+		cube.MiddleEdges[8], cube.MiddleEdges[9], cube.MiddleEdges[10], cube.MiddleEdges[11])
+}
+
 /***************************************************************/
 // CubeListBuilder Functions
 /***************************************************************/
@@ -136,41 +170,7 @@ func (pointData *ServerPointPackData) calculateAllContextCubes() map[CubeKeyId]i
 	res := make(map[CubeKeyId]int, TotalNumberOfCubes)
 	cubeIdx := 1
 	for _, growthCtx := range pointData.GetAllGrowthContexts() {
-		cl := CubeListBuilder{ppd: pointData, growthCtx: growthCtx}
-		switch growthCtx.GetGrowthType() {
-		case 1:
-			cl.populate(1)
-		case 3:
-			cl.populate(6)
-		case 2:
-			cl.populate(1)
-		case 4:
-			cl.populate(4)
-		case 8:
-			cl.populate(8)
-		}
-		sort.Slice(cl.allCubes, func(i, j int) bool {
-			c1 := cl.allCubes[i]
-			c2 := cl.allCubes[j]
-			centerDiff := int(c1.Center) - int(c2.Center)
-			if centerDiff != 0 {
-				return centerDiff < 0
-			}
-			for cfIdx := 0; cfIdx < len(c1.CenterFaces); cfIdx++ {
-				cfDiff := int(c1.CenterFaces[cfIdx]) - int(c2.CenterFaces[cfIdx])
-				if cfDiff != 0 {
-					return cfDiff < 0
-				}
-			}
-			for meIdx := 0; meIdx < len(c1.MiddleEdges); meIdx++ {
-				meDiff := int(c1.MiddleEdges[meIdx]) - int(c2.MiddleEdges[meIdx])
-				if meDiff != 0 {
-					return meDiff < 0
-				}
-			}
-			return false
-		})
-		for _, cube := range cl.allCubes {
+		for _, cube := range pointData.calculateContextCubes(growthCtx) {
 			key := CubeKeyId{GrowthCtxId: growthCtx.GetId(), Cube: cube}
 			_, alreadyIn := res[key]
 			if !alreadyIn {
@@ -182,20 +182,71 @@ func (pointData *ServerPointPackData) calculateAllContextCubes() map[CubeKeyId]i
 	return res
 }
 
-func (cl *CubeListBuilder) populate(max m3point.CInt) {
+// Return the sorted list of all distinct cubes used by the growth context
+func (pointData *ServerPointPackData) calculateContextCubes(growthCtx m3point.GrowthContext) []CubeOfTrioIndex {
+	cl := CubeListBuilder{ppd: pointData, growthCtx: growthCtx}
+	switch growthCtx.GetGrowthType() {
+	case 0:
+		// The offset changes the sequence of trios and not only where it starts
+		cl.populate(m3point.GrowthType0CycleSize, true)
+	case 1:
+		cl.populate(1, false)
+	case 3:
+		cl.populate(6, false)
+	case 2:
+		cl.populate(1, false)
+	case 4:
+		cl.populate(4, false)
+	case 8:
+		cl.populate(8, false)
+	case m3point.CustomGrowthType:
+		// Like the permutations the offset is only where the sequence starts
+		cl.populate(m3point.CInt(len(growthCtx.GetTrioSequence())), false)
+	}
+	sort.Slice(cl.allCubes, func(i, j int) bool {
+		c1 := cl.allCubes[i]
+		c2 := cl.allCubes[j]
+		centerDiff := int(c1.Center) - int(c2.Center)
+		if centerDiff != 0 {
+			return centerDiff < 0
+		}
+		for cfIdx := 0; cfIdx < len(c1.CenterFaces); cfIdx++ {
+			cfDiff := int(c1.CenterFaces[cfIdx]) - int(c2.CenterFaces[cfIdx])
+			if cfDiff != 0 {
+				return cfDiff < 0
+			}
+		}
+		for meIdx := 0; meIdx < len(c1.MiddleEdges); meIdx++ {
+			meDiff := int(c1.MiddleEdges[meIdx]) - int(c2.MiddleEdges[meIdx])
+			if meDiff != 0 {
+				return meDiff < 0
+			}
+		}
+		return false
+	})
+	return cl.allCubes
+}
+
+func (cl *CubeListBuilder) populate(max m3point.CInt, spaceForAllOffsets bool) {
 	allCubesMap := make(map[CubeOfTrioIndex]int)
 	// For center populate for all offsets
-	maxOffset := cl.growthCtx.GetGrowthType().GetMaxOffset()
+	maxOffset := cl.growthCtx.GetMaxOffset()
 	for offset := 0; offset < maxOffset; offset++ {
 		cube := CreateTrioCube(cl.ppd, cl.growthCtx, offset, Origin)
 		allCubesMap[cube]++
 	}
 	// Go through space
-	for x := -max; x <= max; x++ {
-		for y := -max; y <= max; y++ {
-			for z := -max; z <= max; z++ {
-				cube := CreateTrioCube(cl.ppd, cl.growthCtx, 0, m3point.Point{x, y, z}.Mul(m3point.THREE))
-				allCubesMap[cube]++
+	maxSpaceOffset := 1
+	if spaceForAllOffsets {
+		maxSpaceOffset = maxOffset
+	}
+	for offset := 0; offset < maxSpaceOffset; offset++ {
+		for x := -max; x <= max; x++ {
+			for y := -max; y <= max; y++ {
+				for z := -max; z <= max; z++ {
+					cube := CreateTrioCube(cl.ppd, cl.growthCtx, offset, m3point.Point{x, y, z}.Mul(m3point.THREE))
+					allCubesMap[cube]++
+				}
 			}
 		}
 	}
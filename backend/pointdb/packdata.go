@@ -4,6 +4,7 @@ import (
 	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3point"
+	"sync"
 )
 
 type ServerPointPackData struct {
@@ -18,11 +19,20 @@ type ServerPointPackData struct {
 	pathBuilders       []*RootPathNodeBuilder
 	pathBuildersLoaded bool
 
+	sizeModelsLoaded bool
+
+	// Only one custom growth context registration at a time
+	customCtxMutex sync.Mutex
+	// Guards the collections replaced at runtime: growth contexts, cubes and path builders by a custom growth context
+	// registration, and the predicted size models by the size analysis
+	dataMutex sync.RWMutex
+
 	connDetailsTe  *m3db.TableExec
 	trioDetailsTe  *m3db.TableExec
 	growthCtxTe    *m3db.TableExec
 	trioCubesTe    *m3db.TableExec
 	pathBuildersTe *m3db.TableExec
+	sizeModelsTe   *m3db.TableExec
 }
 
 func GetServerPointPackData(env m3util.QsmEnvironment) *ServerPointPackData {
@@ -42,6 +52,7 @@ func (pointData *ServerPointPackData) ResetFlags() {
 	pointData.GrowthContextsLoaded = false
 	pointData.cubesLoaded = false
 	pointData.pathBuildersLoaded = false
+	pointData.sizeModelsLoaded = false
 }
 
 func (pointData *ServerPointPackData) CheckCubesInitialized() {
@@ -56,17 +67,53 @@ func (pointData *ServerPointPackData) CheckPathBuildersInitialized() {
 	}
 }
 
+func (pointData *ServerPointPackData) GetAllGrowthContexts() []m3point.GrowthContext {
+	pointData.dataMutex.RLock()
+	defer pointData.dataMutex.RUnlock()
+	return pointData.BasePointPackData.GetAllGrowthContexts()
+}
+
+func (pointData *ServerPointPackData) GetGrowthContextById(id int) m3point.GrowthContext {
+	pointData.dataMutex.RLock()
+	defer pointData.dataMutex.RUnlock()
+	return pointData.BasePointPackData.GetGrowthContextById(id)
+}
+
+func (pointData *ServerPointPackData) GetGrowthContextByTypeAndIndex(growthType m3point.GrowthType, index int) m3point.GrowthContext {
+	pointData.dataMutex.RLock()
+	defer pointData.dataMutex.RUnlock()
+	return pointData.BasePointPackData.GetGrowthContextByTypeAndIndex(growthType, index)
+}
+
+func (pointData *ServerPointPackData) GetAllPredictedSizeModels() []*m3point.PredictedSizeModel {
+	pointData.dataMutex.RLock()
+	defer pointData.dataMutex.RUnlock()
+	return pointData.PredictedSizeModels
+}
+
+func (pointData *ServerPointPackData) GetPredictedSizeModel(growthType m3point.GrowthType, growthIndex int) *m3point.PredictedSizeModel {
+	pointData.dataMutex.RLock()
+	defer pointData.dataMutex.RUnlock()
+	return pointData.BasePointPackData.GetPredictedSizeModel(growthType, growthIndex)
+}
+
 func (pointData *ServerPointPackData) GetNbPathBuilders() int {
 	pointData.CheckPathBuildersInitialized()
+	pointData.dataMutex.RLock()
+	defer pointData.dataMutex.RUnlock()
 	return len(pointData.pathBuilders)
 }
 
 func (pointData *ServerPointPackData) GetRootPathNodeBuilderById(cubeId int) PathNodeBuilder {
+	pointData.dataMutex.RLock()
+	defer pointData.dataMutex.RUnlock()
 	return pointData.pathBuilders[cubeId]
 }
 
 func (pointData *ServerPointPackData) GetCubeById(cubeId int) CubeKeyId {
 	pointData.CheckCubesInitialized()
+	pointData.dataMutex.RLock()
+	defer pointData.dataMutex.RUnlock()
 	for cubeKey, id := range pointData.cubeIdsPerKey {
 		if id == cubeId {
 			return cubeKey
@@ -78,7 +125,9 @@ func (pointData *ServerPointPackData) GetCubeById(cubeId int) CubeKeyId {
 
 func (pointData *ServerPointPackData) GetCubeIdByKey(cubeKey CubeKeyId) int {
 	pointData.CheckCubesInitialized()
+	pointData.dataMutex.RLock()
 	id, ok := pointData.cubeIdsPerKey[cubeKey]
+	pointData.dataMutex.RUnlock()
 	if !ok {
 		Log.Fatalf("trying to find cube %v which does not exists", cubeKey)
 		return -1
@@ -95,8 +144,8 @@ func (pointData *ServerPointPackData) GetPathNodeBuilder(growthCtx m3point.Growt
 }
 
 func (pointData *ServerPointPackData) createTables() {
-	tableNames := [5]string{ConnectionDetailsTable, TrioDetailsTable, GrowthContextsTable, TrioCubesTable, PathBuildersTable}
-	pointTableExecs := [5]*m3db.TableExec{}
+	tableNames := [6]string{ConnectionDetailsTable, TrioDetailsTable, GrowthContextsTable, TrioCubesTable, PathBuildersTable, PredictedSizeModelsTable}
+	pointTableExecs := [6]*m3db.TableExec{}
 
 	// IMPORTANT: Create ALL the tables before preparing the queries
 	var err error
@@ -122,4 +171,5 @@ func (pointData *ServerPointPackData) createTables() {
 	pointData.growthCtxTe = pointTableExecs[2]
 	pointData.trioCubesTe = pointTableExecs[3]
 	pointData.pathBuildersTe = pointTableExecs[4]
+	pointData.sizeModelsTe = pointTableExecs[5]
 }
@@ -10,6 +10,7 @@ func (pointData *ServerPointPackData) InitializeAll() {
 	pointData.initGrowthContexts()
 	pointData.initContextCubes()
 	pointData.initPathBuilders()
+	pointData.initPredictedSizeModels()
 }
 
 func (pointData *ServerPointPackData) GetValidNextTrio() [12][2]m3point.TrioIndex {
@@ -79,6 +80,17 @@ func (pointData *ServerPointPackData) initPathBuilders() {
 	}
 }
 
+func (pointData *ServerPointPackData) initPredictedSizeModels() {
+	if !pointData.sizeModelsLoaded {
+		err := pointData.loadPredictedSizeModels()
+		if err != nil {
+			Log.Fatal(err)
+			return
+		}
+		Log.Debugf("Environment %d has %d predicted size models", pointData.GetEnvId(), len(pointData.PredictedSizeModels))
+	}
+}
+
 func (pointData *ServerPointPackData) FillDb() {
 	n, err := pointData.saveAllConnectionDetails()
 	if err != nil {
@@ -130,4 +142,7 @@ func (pointData *ServerPointPackData) FillDb() {
 		Log.Infof("Environment %d has %d path builders", pointData.GetEnvId(), n)
 	}
 	pointData.initPathBuilders()
+
+	// Nothing to fill, the models are saved by the size model analysis
+	pointData.initPredictedSizeModels()
 }
@@ -1,6 +1,7 @@
 package spacedb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/freddy33/qsm-go/backend/pathdb"
@@ -123,7 +124,8 @@ func (evt *EventDb) getNodeLinkIds(pathNodeDb *pathdb.PathNodeDb) ([3]m3point.In
 	return evtNodeLinkIds, nil
 }
 
-func (evt *EventDb) increaseMaxNodeTime() error {
+func (evt *EventDb) increaseMaxNodeTime(ctx context.Context) error {
+	defer m3util.StartSpan(ctx, "event_grow")()
 	evt.increaseNodeMutex.Lock()
 	defer evt.increaseNodeMutex.Unlock()
 
@@ -135,11 +137,11 @@ func (evt *EventDb) increaseMaxNodeTime() error {
 		return err
 	}
 	dTime := nextTime - evt.creationTime
-	err = evt.pathCtx.RequestNewMaxDist(int(dTime))
+	err = evt.pathCtx.RequestNewMaxDistContext(ctx, int(dTime))
 	if err != nil {
 		return err
 	}
-	pathNodes, err := evt.pathCtx.GetPathNodesAt(int(dTime))
+	pathNodes, err := evt.pathCtx.GetPathNodesAtContext(ctx, int(dTime))
 	if err != nil {
 		return err
 	}
@@ -217,9 +219,14 @@ func (evt *EventDb) GetActiveNodesAt(currentTime m3space.DistAndTime) ([]m3space
 }
 
 func (evt *EventDb) GetActiveNodesDbAt(currentTime m3space.DistAndTime) ([]*NodeEventDb, error) {
+	return evt.GetActiveNodesDbAtContext(context.Background(), currentTime)
+}
+
+// Grows the event up to current time if needed, then loads the active nodes in an event_nodes span
+func (evt *EventDb) GetActiveNodesDbAtContext(ctx context.Context, currentTime m3space.DistAndTime) ([]*NodeEventDb, error) {
 	var err error
 	for evt.maxNodeTime < currentTime {
-		err = evt.increaseMaxNodeTime()
+		err = evt.increaseMaxNodeTime(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -235,15 +242,16 @@ func (evt *EventDb) GetActiveNodesDbAt(currentTime m3space.DistAndTime) ([]*Node
 		return res, nil
 	}
 
+	defer m3util.StartSpan(ctx, "event_nodes")()
 	te := evt.space.spaceData.nodesTe
 	var rows *sql.Rows
 	var expectedNbNodes int
 	if useBetween {
 		expectedNbNodes = evt.pathCtx.GetNumberOfNodesBetween(int(from-evt.creationTime), int(to-evt.creationTime))
-		rows, err = te.Query(SelectNodesBetween, evt.GetId(), from, to)
+		rows, err = te.QueryContext(ctx, SelectNodesBetween, evt.GetId(), from, to)
 	} else {
 		expectedNbNodes = evt.pathCtx.GetNumberOfNodesAt(int(currentTime - evt.creationTime))
-		rows, err = te.Query(SelectNodesAt, evt.GetId(), currentTime)
+		rows, err = te.QueryContext(ctx, SelectNodesAt, evt.GetId(), currentTime)
 	}
 	if err != nil {
 		return nil, err
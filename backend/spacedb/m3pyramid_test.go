@@ -102,8 +102,8 @@ func TestSpaceAllPyramids(t *testing.T) {
 			space := createNewSpace(t, env, spaceName, m3space.ZeroDistAndTime)
 			found, originalPyramid, foundTime, finalPyramid, nbPoss := RunSpacePyramidWithParams(space, pSize, ctxs, idxs, offsets)
 			if found {
-				orgSize := GetPyramidSize(originalPyramid)
-				finalSize := GetPyramidSize(finalPyramid)
+				orgSize := GetPolyhedronSize(originalPyramid)
+				finalSize := GetPolyhedronSize(finalPyramid)
 				diff := m3point.AbsDInt(orgSize - finalSize)
 				ratio := float64(diff) / float64(orgSize)
 				LogData.Infof("%d %d %v %d %d %d %d %d %.5f",
@@ -134,8 +134,8 @@ func TestSpaceRunPySize4(t *testing.T) {
 	found, originalPyramid, time, finalPyramid, nbPoss := RunSpacePyramidWithParams(space, 4, [4]m3point.GrowthType{2, 2, 2, 2}, [4]int{0, 0, 0, 0}, [4]int{0, 0, 0, 0})
 	// TODO: Reactivate after space node fix
 	//assert.True(t, found)
-	orgSize := GetPyramidSize(originalPyramid)
-	finalSize := GetPyramidSize(finalPyramid)
+	orgSize := GetPolyhedronSize(originalPyramid)
+	finalSize := GetPolyhedronSize(finalPyramid)
 	diff := m3point.AbsDInt(orgSize - finalSize)
 	LogStat.Infof("%v %d %v %v %d %d %d %d", found, time, originalPyramid, finalPyramid, nbPoss, orgSize, finalSize, diff)
 
@@ -144,12 +144,40 @@ func TestSpaceRunPySize4(t *testing.T) {
 	found, originalPyramid, time, finalPyramid, nbPoss = RunSpacePyramidWithParams(space, 4, [4]m3point.GrowthType{2, 2, 2, 2}, [4]int{0, 0, 0, 3}, [4]int{0, 0, 0, 0})
 	// TODO: Reactivate after space node fix
 	//assert.True(t, found)
-	orgSize = GetPyramidSize(originalPyramid)
-	finalSize = GetPyramidSize(finalPyramid)
+	orgSize = GetPolyhedronSize(originalPyramid)
+	finalSize = GetPolyhedronSize(finalPyramid)
 	diff = m3point.AbsDInt(orgSize - finalSize)
 	LogStat.Infof("%v %d %v %v %d %d %d %d", found, time, originalPyramid, finalPyramid, nbPoss, orgSize, finalSize, diff)
 }
 
+func TestSpaceRunPolyhedrons(t *testing.T) {
+	Log.SetWarn()
+	LogStat.SetInfo()
+	env := getPyramidTestEnv()
+
+	for _, centers := range [][]m3point.Point{BiPyramidCenters, OctahedronCenters, CubeCenters} {
+		nbEvents := len(centers)
+		space := createNewSpace(t, env, fmt.Sprintf("TestSpaceRunPolyhedrons-%d", nbEvents), m3space.ZeroDistAndTime)
+		growthTypes := make([]m3point.GrowthType, nbEvents)
+		indexes := make([]int, nbEvents)
+		offsets := make([]int, nbEvents)
+		for i := range growthTypes {
+			growthTypes[i] = 8
+		}
+		found, originalPoly, time, finalPoly, nbPoss, err := RunSpacePolyhedronWithParams(space, 3, centers,
+			growthTypes, indexes, offsets, nbEvents, m3space.DistAndTime(12))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, nbEvents, originalPoly.GetNbPoints())
+		if found {
+			assert.Equal(t, nbEvents, finalPoly.GetNbPoints())
+		}
+		LogStat.Infof("%d %v %d %v %v %d %d %d", nbEvents, found, time, originalPoly.GetPoints(), finalPoly.GetPoints(), nbPoss,
+			GetPolyhedronSize(originalPoly), GetPolyhedronSize(finalPoly))
+	}
+}
+
 func TestSpaceRunPySize3(t *testing.T) {
 	Log.SetWarn()
 	LogStat.SetInfo()
@@ -170,3 +198,56 @@ func runSpaceTest(t *testing.T, pSize m3point.CInt, spaceName string) {
 	offsets := [4]int{0, 0, 0, 4}
 	RunSpacePyramidWithParams(space, pSize, growthTypes, indexes, offsets)
 }
+
+func TestMakeThreeIds(t *testing.T) {
+	tIds := MakeThreeIds([]m3space.EventId{3, 1, 2})
+	assert.Equal(t, []ThreeIds{{1, 2, 3}}, tIds)
+
+	tIds = MakeThreeIds([]m3space.EventId{4, 3, 2, 1})
+	assert.Equal(t, 4, len(tIds))
+	assert.Contains(t, tIds, ThreeIds{1, 3, 4})
+
+	for nbEvents := 5; nbEvents <= MaxPolyhedronPoints; nbEvents++ {
+		ids := make([]m3space.EventId, nbEvents)
+		for i := range ids {
+			ids[i] = m3space.EventId(nbEvents - i)
+		}
+		tIds = MakeThreeIds(ids)
+		assert.Equal(t, nbEvents*(nbEvents-1)*(nbEvents-2)/6, len(tIds), "failed for %d events", nbEvents)
+		for _, tId := range tIds {
+			assert.True(t, tId[0] < tId[1] && tId[1] < tId[2], "three ids %v not ordered", tId)
+		}
+	}
+}
+
+func TestPolyhedronBuilder(t *testing.T) {
+	// Each corner of the cube is the meeting point of a different set of three events
+	pointsPer3Ids := make(map[ThreeIds][]m3point.Point, len(CubeCenters))
+	for i, p := range CubeCenters {
+		id := m3space.EventId(i)
+		pointsPer3Ids[ThreeIds{id, id + 1, id + 2}] = []m3point.Point{p.Mul(2)}
+	}
+	// Add a bigger alternative for the first corner
+	firstIds := ThreeIds{0, 1, 2}
+	pointsPer3Ids[firstIds] = append(pointsPer3Ids[firstIds], CubeCenters[0].Mul(3))
+
+	builder := MakePolyhedronBuilder(len(CubeCenters))
+	builder.createPolyhedrons(pointsPer3Ids, Polyhedron{}, 0, 0)
+	allPolys := builder.GetAllPolyhedrons()
+	assert.Equal(t, 2, len(allPolys))
+	cube := MakePolyhedron(CubeCenters...)
+	for poly, size := range allPolys {
+		assert.Equal(t, len(CubeCenters), poly.GetNbPoints())
+		assert.Equal(t, GetPolyhedronSize(poly), size)
+		assert.True(t, size >= GetPolyhedronSize(cube)*4)
+	}
+
+	// Searching for pyramids in the cube points
+	builder = MakePolyhedronBuilder(PyramidNbPoints)
+	builder.createPolyhedrons(pointsPer3Ids, Polyhedron{}, 0, len(pointsPer3Ids)-PyramidNbPoints)
+	for poly := range builder.GetAllPolyhedrons() {
+		assert.Equal(t, PyramidNbPoints, poly.GetNbPoints())
+		assert.Equal(t, poly, poly.ordered())
+	}
+	assert.True(t, len(builder.GetAllPolyhedrons()) > 0)
+}
@@ -1,6 +1,7 @@
 package spacedb
 
 import (
+	"context"
 	"fmt"
 	"github.com/freddy33/qsm-go/backend/m3db"
 	"github.com/freddy33/qsm-go/backend/pathdb"
@@ -331,6 +332,11 @@ func (st *SpaceTime) queryPathContext(evt *EventDb) (int, int, bool) {
 }
 
 func (st *SpaceTime) Populate() error {
+	return st.PopulateContext(context.Background())
+}
+
+// Load the active nodes of all events once, the first call timed in a populate span of the request trace
+func (st *SpaceTime) PopulateContext(ctx context.Context) error {
 	if st.populated {
 		return st.populatedError
 	}
@@ -341,6 +347,7 @@ func (st *SpaceTime) Populate() error {
 	if st.populated {
 		return st.populatedError
 	}
+	defer m3util.StartSpan(ctx, "populate")()
 
 	events := st.GetActiveEvents()
 	st.activeEvents = make([]*EventDb, len(events))
@@ -349,7 +356,7 @@ func (st *SpaceTime) Populate() error {
 	for i := range events {
 		evt := events[i].(*EventDb)
 		st.activeEvents[i] = evt
-		nodeList, err := evt.GetActiveNodesDbAt(st.currentTime)
+		nodeList, err := evt.GetActiveNodesDbAtContext(ctx, st.currentTime)
 		if err != nil {
 			st.populatedError = err
 			st.populated = true
@@ -11,25 +11,67 @@ import (
 
 var LogRun = m3util.NewDataLogger("m3run", m3util.DEBUG)
 
-func GetPyramidSize(points [4]m3point.Point) m3point.DInt {
+const (
+	// The color of an event is a bit in an uint8 mask, so no more than 8 events and points
+	MaxPolyhedronPoints = 8
+	PyramidNbPoints     = 4
+)
+
+// The default event centers (to be multiplied by the size) per number of events
+var PyramidCenters = []m3point.Point{{3, 0, 3}, {-3, 3, 3}, {-3, -3, 3}, {0, 0, -3}}
+var BiPyramidCenters = []m3point.Point{{3, 0, 0}, {-3, 3, 0}, {-3, -3, 0}, {0, 0, 3}, {0, 0, -3}}
+var OctahedronCenters = []m3point.Point{{3, 0, 0}, {-3, 0, 0}, {0, 3, 0}, {0, -3, 0}, {0, 0, 3}, {0, 0, -3}}
+var CubeCenters = []m3point.Point{{3, 3, 3}, {3, 3, -3}, {3, -3, 3}, {3, -3, -3},
+	{-3, 3, 3}, {-3, 3, -3}, {-3, -3, 3}, {-3, -3, -3}}
+
+// A polyhedron of up to MaxPolyhedronPoints points.
+// Using a fixed size array so it can be used as map key.
+type Polyhedron struct {
+	nbPoints int
+	points   [MaxPolyhedronPoints]m3point.Point
+}
+
+func MakePolyhedron(points ...m3point.Point) Polyhedron {
+	if len(points) > MaxPolyhedronPoints {
+		Log.Fatalf("cannot create a polyhedron of %d points, max is %d", len(points), MaxPolyhedronPoints)
+	}
+	res := Polyhedron{nbPoints: len(points)}
+	copy(res.points[:], points)
+	return res
+}
+
+func (poly Polyhedron) GetNbPoints() int {
+	return poly.nbPoints
+}
+
+func (poly Polyhedron) GetPoints() []m3point.Point {
+	res := make([]m3point.Point, poly.nbPoints)
+	copy(res, poly.points[:poly.nbPoints])
+	return res
+}
+
+func (poly Polyhedron) withPoint(pos int, p m3point.Point) Polyhedron {
+	// poly is already a copy
+	poly.points[pos] = p
+	if pos >= poly.nbPoints {
+		poly.nbPoints = pos + 1
+	}
+	return poly
+}
+
+func GetPolyhedronSize(poly Polyhedron) m3point.DInt {
 	// Sum all the edges
 	totalSize := m3point.DInt(0)
-	totalSize += m3point.MakeVector(points[0], points[1]).DistanceSquared()
-	totalSize += m3point.MakeVector(points[0], points[2]).DistanceSquared()
-	totalSize += m3point.MakeVector(points[0], points[3]).DistanceSquared()
-	totalSize += m3point.MakeVector(points[1], points[2]).DistanceSquared()
-	totalSize += m3point.MakeVector(points[1], points[3]).DistanceSquared()
-	totalSize += m3point.MakeVector(points[2], points[3]).DistanceSquared()
+	for i := 0; i < poly.nbPoints; i++ {
+		for j := i + 1; j < poly.nbPoints; j++ {
+			totalSize += m3point.MakeVector(poly.points[i], poly.points[j]).DistanceSquared()
+		}
+	}
 	return totalSize
 }
 
-type Pyramid [4]m3point.Point
-
-func (pyramid Pyramid) ordered() Pyramid {
-	slice := make([]m3point.Point, 4)
-	for i, p := range pyramid {
-		slice[i] = p
-	}
+func (poly Polyhedron) ordered() Polyhedron {
+	slice := poly.GetPoints()
 	sort.Slice(slice, func(i, j int) bool {
 		iP := slice[i]
 		jP := slice[j]
@@ -53,59 +95,80 @@ func (pyramid Pyramid) ordered() Pyramid {
 		}
 		return false
 	})
-	return Pyramid{slice[0], slice[1], slice[2], slice[3]}
+	return MakePolyhedron(slice...)
 }
 
 func createPyramidWithParams(space *SpaceDb, pyramidSize m3point.CInt, ctxTypes [4]m3point.GrowthType, indexes [4]int, offsets [4]int) {
-	_, err := space.CreateEvent(ctxTypes[0], indexes[0], offsets[0], m3space.ZeroDistAndTime, m3point.Point{3, 0, 3}.Mul(pyramidSize), m3space.RedEvent)
+	err := createEventsWithParams(space, pyramidSize, PyramidCenters, ctxTypes[:], indexes[:], offsets[:])
 	if err != nil {
 		Log.Error(err)
-		return
 	}
-	_, err = space.CreateEvent(ctxTypes[1], indexes[1], offsets[1], m3space.ZeroDistAndTime, m3point.Point{-3, 3, 3}.Mul(pyramidSize), m3space.GreenEvent)
-	if err != nil {
-		Log.Error(err)
-		return
+}
+
+/*
+Create one event per center point (multiplied by size). The colors are given in order of the centers.
+*/
+func createEventsWithParams(space *SpaceDb, size m3point.CInt, centers []m3point.Point, ctxTypes []m3point.GrowthType, indexes []int, offsets []int) error {
+	nbEvents := len(centers)
+	if nbEvents > MaxPolyhedronPoints {
+		return m3util.MakeQsmErrorf("cannot create %d events, max is %d", nbEvents, MaxPolyhedronPoints)
 	}
-	_, err = space.CreateEvent(ctxTypes[2], indexes[2], offsets[2], m3space.ZeroDistAndTime, m3point.Point{-3, -3, 3}.Mul(pyramidSize), m3space.BlueEvent)
-	if err != nil {
-		Log.Error(err)
-		return
+	if len(ctxTypes) != nbEvents || len(indexes) != nbEvents || len(offsets) != nbEvents {
+		return m3util.MakeQsmErrorf("all params should have the %d entries but got %d types, %d indexes and %d offsets",
+			nbEvents, len(ctxTypes), len(indexes), len(offsets))
 	}
-	_, err = space.CreateEvent(ctxTypes[3], indexes[3], offsets[3], m3space.ZeroDistAndTime, m3point.Point{0, 0, -3}.Mul(pyramidSize), m3space.YellowEvent)
-	if err != nil {
-		Log.Error(err)
-		return
+	for i, center := range centers {
+		_, err := space.CreateEvent(ctxTypes[i], indexes[i], offsets[i], m3space.ZeroDistAndTime, center.Mul(size), m3space.EventColor(1<<uint(i)))
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func RunSpacePyramidWithParams(space *SpaceDb, pSize m3point.CInt, ctxTypes [4]m3point.GrowthType, indexes [4]int, offsets [4]int) (bool, Pyramid, m3space.DistAndTime, Pyramid, int) {
+func RunSpacePyramidWithParams(space *SpaceDb, pSize m3point.CInt, ctxTypes [4]m3point.GrowthType, indexes [4]int, offsets [4]int) (bool, Polyhedron, m3space.DistAndTime, Polyhedron, int) {
 	createPyramidWithParams(space, pSize, ctxTypes, indexes, offsets)
+	return runSpaceFindPolyhedron(space, PyramidNbPoints, m3space.DistAndTime(9))
+}
 
-	originalPyramid := Pyramid{}
-	idx := 0
+/*
+Create the events at the centers given and run the space until a polyhedron of nbPoints points,
+where 3 events meet, is found or finalTime is reached.
+*/
+func RunSpacePolyhedronWithParams(space *SpaceDb, pSize m3point.CInt, centers []m3point.Point,
+	ctxTypes []m3point.GrowthType, indexes []int, offsets []int,
+	nbPoints int, finalTime m3space.DistAndTime) (bool, Polyhedron, m3space.DistAndTime, Polyhedron, int, error) {
+	if nbPoints < m3point.THREE || nbPoints > MaxPolyhedronPoints {
+		return false, Polyhedron{}, m3space.ZeroDistAndTime, Polyhedron{}, 0,
+			m3util.MakeQsmErrorf("polyhedron size should be between %d and %d not %d", m3point.THREE, MaxPolyhedronPoints, nbPoints)
+	}
+	err := createEventsWithParams(space, pSize, centers, ctxTypes, indexes, offsets)
+	if err != nil {
+		return false, Polyhedron{}, m3space.ZeroDistAndTime, Polyhedron{}, 0, err
+	}
+	found, originalPoly, foundTime, bestPoly, nbPoss := runSpaceFindPolyhedron(space, nbPoints, finalTime)
+	return found, originalPoly, foundTime, bestPoly, nbPoss, nil
+}
+
+func runSpaceFindPolyhedron(space *SpaceDb, nbPoints int, finalTime m3space.DistAndTime) (bool, Polyhedron, m3space.DistAndTime, Polyhedron, int) {
+	centers := make([]m3point.Point, 0, MaxPolyhedronPoints)
 	for _, evt := range space.GetActiveEventsAt(0) {
 		if evt != nil {
 			pa, err := evt.GetCenterNode().GetPoint()
 			if err != nil {
 				Log.Error(err)
-				originalPyramid[idx] = m3point.Origin
+				centers = append(centers, m3point.Origin)
 			} else {
-				originalPyramid[idx] = *pa
+				centers = append(centers, *pa)
 			}
-			idx++
 		}
 	}
-	originalPyramid = originalPyramid.ordered()
-	LogRun.Infof("Starting with pyramid %v : %d", originalPyramid, GetPyramidSize(originalPyramid))
+	originalPoly := MakePolyhedron(centers...).ordered()
+	LogRun.Infof("Starting with polyhedron %v : %d", originalPoly.GetPoints(), GetPolyhedronSize(originalPoly))
 
 	expectedTime := m3space.ZeroDistAndTime
-	finalTime := m3space.DistAndTime(9)
-	//if finalTime < DistAndTime(25) {
-	//	finalTime = DistAndTime(25)
-	//}
 	found := false
-	var bestPyramid Pyramid
+	var bestPoly Polyhedron
 	var bestSize m3point.DInt
 	var nbPossibilities int
 	var spaceTime *SpaceTime
@@ -119,70 +182,81 @@ func RunSpacePyramidWithParams(space *SpaceDb, pSize m3point.CInt, ctxTypes [4]m
 		nbThreeIdsActive := len(pointsPer3Ids)
 		if nbThreeIdsActive >= 3 {
 			LogRun.Debugf("Found a 3 match with %d elements", nbThreeIdsActive)
-			if nbThreeIdsActive >= 4 {
-				LogRun.Debug("Found a 4 match")
-				builder := PyramidBuilder{make(map[Pyramid]m3point.DInt, 1)}
-				builder.createPyramids(pointsPer3Ids, &Pyramid{}, 0, nbThreeIdsActive-4)
-				allPyramids := builder.allPyramids
-				nbPossibilities = len(allPyramids)
-				LogRun.Debugf("AllPyramids %d", nbPossibilities)
-				if len(allPyramids) > 0 {
+			if nbThreeIdsActive >= nbPoints {
+				LogRun.Debugf("Found a %d match", nbPoints)
+				builder := MakePolyhedronBuilder(nbPoints)
+				builder.createPolyhedrons(pointsPer3Ids, Polyhedron{}, 0, nbThreeIdsActive-nbPoints)
+				allPolyhedrons := builder.allPolyhedrons
+				nbPossibilities = len(allPolyhedrons)
+				LogRun.Debugf("AllPolyhedrons %d", nbPossibilities)
+				if len(allPolyhedrons) > 0 {
 					bestSize = m3point.DInt(0)
-					for pyramid, size := range allPyramids {
-						LogRun.Debugf("%v : %d", pyramid, size)
+					for poly, size := range allPolyhedrons {
+						LogRun.Debugf("%v : %d", poly.GetPoints(), size)
 						if size > bestSize {
 							bestSize = size
-							bestPyramid = pyramid
+							bestPoly = poly
 						}
 					}
 					found = true
-					LogRun.Infof("We have a winner out of %d possible %v at size %d", nbPossibilities, bestPyramid, bestSize)
+					LogRun.Infof("We have a winner out of %d possible %v at size %d", nbPossibilities, bestPoly.GetPoints(), bestSize)
 					break
 				}
 			}
 		}
 	}
 	if spaceTime == nil {
-		return false, originalPyramid, m3space.ZeroDistAndTime, Pyramid{}, 0
+		return false, originalPoly, m3space.ZeroDistAndTime, Polyhedron{}, 0
+	}
+	return found, originalPoly, spaceTime.GetCurrentTime(), bestPoly, nbPossibilities
+}
+
+// Builder to extract possible polyhedrons out of a list of ThreeIds that have common points
+type PolyhedronBuilder struct {
+	// The number of points of the polyhedrons to build
+	nbPoints int
+	// All the possible polyhedrons built out
+	allPolyhedrons map[Polyhedron]m3point.DInt
+}
+
+func MakePolyhedronBuilder(nbPoints int) *PolyhedronBuilder {
+	if nbPoints <= 0 || nbPoints > MaxPolyhedronPoints {
+		Log.Fatalf("cannot build polyhedrons of %d points, max is %d", nbPoints, MaxPolyhedronPoints)
 	}
-	return found, originalPyramid, spaceTime.GetCurrentTime(), bestPyramid, nbPossibilities
+	return &PolyhedronBuilder{nbPoints, make(map[Polyhedron]m3point.DInt, 1)}
 }
 
-// Builder to extract possible pyramids out of a list of ThreeIds that have common points
-type PyramidBuilder struct {
-	// All the possible pyramids built out
-	allPyramids map[Pyramid]m3point.DInt
+func (b *PolyhedronBuilder) GetAllPolyhedrons() map[Polyhedron]m3point.DInt {
+	return b.allPolyhedrons
 }
 
-func (b *PyramidBuilder) createPyramids(currentPointsPer3Ids map[ThreeIds][]m3point.Point, currentPyramid *Pyramid, currentPos int, possibleSkip int) {
+func (b *PolyhedronBuilder) createPolyhedrons(currentPointsPer3Ids map[ThreeIds][]m3point.Point, currentPoly Polyhedron, currentPos int, possibleSkip int) {
 	// Recursive Algorithm:
 	// Find threeIds with smallest list of points (small3Ids),
 	// Iterate though each point in the list of points for this small3Ids -> pickedPoint,
-	//   Stop Condition: If currentPos is 3:
-	//     - Create all the pyramids with the currentPos point being pickedPoint
+	//   Stop Condition: If currentPos is the last point (nbPoints-1):
+	//     - Create all the polyhedrons with the currentPos point being pickedPoint
 	//   Logic for next call:
 	//     - Recreate the map of PointsPerThreeIds removing the small3Ids and the pickedPoint from all the lists
-	//     - Recurse to createPyramids with params:
+	//     - Recurse to createPolyhedrons with params:
 	//       - the new maps filtered above
-	//       - new pyramid with the currentPos point being pickedPoint
+	//       - new polyhedron with the currentPos point being pickedPoint
 	//       - currentPos + 1
 	curLength := len(currentPointsPer3Ids)
 	if curLength == 0 {
 		log.Fatal("Should never reach here with an empty map")
 	}
-	if curLength == 1 && currentPos != 3 {
-		log.Fatal("Reached the end of the map but not the ned of the pyramid building for:", currentPos, currentPointsPer3Ids)
+	lastPos := b.nbPoints - 1
+	if curLength == 1 && currentPos != lastPos {
+		log.Fatal("Reached the end of the map but not the end of the polyhedron building for:", currentPos, currentPointsPer3Ids)
 	}
 
-	// Last points in pyramid
-	// TODO: May be allowed bigger structure
-	if currentPos == 3 {
+	// Last points in polyhedron
+	if currentPos == lastPos {
 		for _, points := range currentPointsPer3Ids {
 			for _, pickedPoint := range points {
-				// Dereference creates a copy
-				newPyramid := *(currentPyramid)
-				newPyramid[currentPos] = pickedPoint
-				b.allPyramids[newPyramid.ordered()] = GetPyramidSize(newPyramid)
+				newPoly := currentPoly.withPoint(currentPos, pickedPoint)
+				b.allPolyhedrons[newPoly.ordered()] = GetPolyhedronSize(newPoly)
 			}
 		}
 		return
@@ -209,14 +283,12 @@ func (b *PyramidBuilder) createPyramids(currentPointsPer3Ids map[ThreeIds][]m3po
 				newCurrentPointsPer3Ids[tIds] = points
 			}
 		}
-		b.createPyramids(newCurrentPointsPer3Ids, currentPyramid, currentPos, possibleSkip-1)
+		b.createPolyhedrons(newCurrentPointsPer3Ids, currentPoly, currentPos, possibleSkip-1)
 	}
 
 	// Do the full logic
 	for _, pickedPoint := range currentPointsPer3Ids[small3Ids] {
-		// Dereference creates a copy
-		newPyramid := *(currentPyramid)
-		newPyramid[currentPos] = pickedPoint
+		newPoly := currentPoly.withPoint(currentPos, pickedPoint)
 		newCurrentPointsPer3Ids := make(map[ThreeIds][]m3point.Point, curLength-1)
 		for tIds, points := range currentPointsPer3Ids {
 			if tIds != small3Ids {
@@ -229,6 +301,6 @@ func (b *PyramidBuilder) createPyramids(currentPointsPer3Ids map[ThreeIds][]m3po
 				newCurrentPointsPer3Ids[tIds] = newList
 			}
 		}
-		b.createPyramids(newCurrentPointsPer3Ids, &newPyramid, currentPos+1, possibleSkip)
+		b.createPolyhedrons(newCurrentPointsPer3Ids, newPoly, currentPos+1, possibleSkip)
 	}
 }
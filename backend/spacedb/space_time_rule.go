@@ -58,20 +58,26 @@ func SortEventIDs(ids *[]m3space.EventId) {
 	})
 }
 
+/*
+Return all the combinations of three events out of the list of event ids.
+The ids are sorted, and each ThreeIds is sorted.
+*/
 func MakeThreeIds(ids []m3space.EventId) []ThreeIds {
 	SortEventIDs(&ids)
-	if len(ids) == 3 {
-		return []ThreeIds{{ids[0], ids[1], ids[2]}}
-	} else if len(ids) == 4 {
-		return []ThreeIds{
-			{ids[0], ids[1], ids[2]},
-			{ids[0], ids[2], ids[3]},
-			{ids[0], ids[1], ids[3]},
-			{ids[1], ids[2], ids[3]},
+	n := len(ids)
+	if n < m3point.THREE {
+		Log.Errorf("cannot make three ids out of %d events %v", n, ids)
+		return nil
+	}
+	res := make([]ThreeIds, 0, n*(n-1)*(n-2)/6)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			for k := j + 1; k < n; k++ {
+				res = append(res, ThreeIds{ids[i], ids[j], ids[k]})
+			}
 		}
 	}
-	Log.Fatal("WHAT!")
-	return nil
+	return res
 }
 
 func (tIds ThreeIds) contains(id m3space.EventId) bool {
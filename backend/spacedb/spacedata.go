@@ -31,10 +31,10 @@ func (spaceData *ServerSpacePackData) DeleteSpace(id int, name string) (int, err
 	}
 	space, ok := spaceData.allSpaces[id]
 	if !ok {
-		return 0, m3util.MakeQsmErrorf("Space id %d not found!", id)
+		return 0, m3util.MakeQsmCodeErrorf(m3util.ErrNotFound, "Space id %d not found!", id)
 	}
 	if space.GetName() != name {
-		return 0, m3util.MakeQsmErrorf("Space id %d name is %q not %q!", id, space.GetName(), name)
+		return 0, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "Space id %d name is %q not %q!", id, space.GetName(), name)
 	}
 	totalDeleted := 0
 	eventIds := space.GetEventIdsForMsg()
@@ -15,7 +15,23 @@ const (
 	NodesTable  = "nodes"
 )
 
+func collectLoadedSpaces() []m3util.GaugeValue {
+	res := make([]m3util.GaugeValue, 0)
+	m3util.RangeEnvironments(func(env m3util.QsmEnvironment) {
+		spaceData, ok := env.GetData(m3util.SpaceIdx).(*ServerSpacePackData)
+		if ok && spaceData != nil {
+			res = append(res, m3util.GaugeValue{
+				LabelValues: []string{env.GetId().String()},
+				Value:       float64(len(spaceData.allSpaces)),
+			})
+		}
+	})
+	return res
+}
+
 func init() {
+	m3util.QsmMetrics.GaugeFunc("qsm_spaces_loaded", "Number of spaces loaded in memory",
+		collectLoadedSpaces, "env")
 	m3db.AddTableDef(createSpacesTableDef())
 	m3db.AddTableDef(createEventsTableDef())
 	m3db.AddTableDef(createNodesTableDef())
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/golang/protobuf/proto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	pointDataCacheName = "point-data"
+	cacheFileExt       = ".pb"
+)
+
+/*
+Protobuf messages received from the backend kept on disk between runs.
+There is one directory per env id and backend schema version, so a schema change starts an empty cache.
+A nil cache is disabled, loads always miss and saves do nothing.
+*/
+type ClientCache struct {
+	dir string
+}
+
+// The cache in rootDir for the env and schema version, nil if rootDir is empty
+func MakeClientCache(rootDir string, envId m3util.QsmEnvID, schemaVersion int) *ClientCache {
+	if rootDir == "" {
+		return nil
+	}
+	return &ClientCache{dir: filepath.Join(envCacheDir(rootDir, envId), fmt.Sprintf("schema-%d", schemaVersion))}
+}
+
+func envCacheDir(rootDir string, envId m3util.QsmEnvID) string {
+	return filepath.Join(rootDir, fmt.Sprintf("env-%d", envId))
+}
+
+// Remove the cached files of all the schema versions of the env
+func ClearEnvCache(rootDir string, envId m3util.QsmEnvID) error {
+	if rootDir == "" {
+		return nil
+	}
+	dir := envCacheDir(rootDir, envId)
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not clear cache dir %s due to %v", dir, err)
+	}
+	return nil
+}
+
+func pathNodesCacheName(id int) string {
+	return fmt.Sprintf("path-nodes-%d", id)
+}
+
+func (cache *ClientCache) Dir() string {
+	if cache == nil {
+		return ""
+	}
+	return cache.dir
+}
+
+func (cache *ClientCache) fileName(name string) string {
+	return filepath.Join(cache.dir, name+cacheFileExt)
+}
+
+// Fill msg from the cached file, false if not cached or not readable
+func (cache *ClientCache) load(name string, msg proto.Message) bool {
+	if cache == nil {
+		return false
+	}
+	data, err := ioutil.ReadFile(cache.fileName(name))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Log.Warnf("could not read cache file %s due to %v", cache.fileName(name), err)
+		}
+		return false
+	}
+	err = proto.Unmarshal(data, msg)
+	if err != nil {
+		Log.Warnf("dropping corrupted cache file %s due to %v", cache.fileName(name), err)
+		cache.remove(name)
+		return false
+	}
+	Log.Debugf("loaded %s from cache %s", name, cache.dir)
+	return true
+}
+
+// Write the message in a temporary file renamed at the end, so readers never see half a file
+func (cache *ClientCache) save(name string, msg proto.Message) {
+	if cache == nil {
+		return
+	}
+	err := cache.write(name, msg)
+	if err != nil {
+		Log.Warn(err)
+	}
+}
+
+func (cache *ClientCache) write(name string, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not marshal %s for cache due to %v", name, err)
+	}
+	err = os.MkdirAll(cache.dir, 0755)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not create cache dir %s due to %v", cache.dir, err)
+	}
+	f, err := ioutil.TempFile(cache.dir, name+"-*.tmp")
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not create cache file for %s due to %v", name, err)
+	}
+	_, err = f.Write(data)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), cache.fileName(name))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return m3util.MakeWrapQsmErrorf(err, "could not write cache file %s due to %v", cache.fileName(name), err)
+	}
+	return nil
+}
+
+func (cache *ClientCache) remove(name string) {
+	if cache == nil {
+		return
+	}
+	err := os.Remove(cache.fileName(name))
+	if err != nil && !os.IsNotExist(err) {
+		Log.Warnf("could not remove cache file %s due to %v", cache.fileName(name), err)
+	}
+}
+
+// Remove all the cached files of the env and schema version
+func (cache *ClientCache) Clear() error {
+	if cache == nil {
+		return nil
+	}
+	err := os.RemoveAll(cache.dir)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not clear cache dir %s due to %v", cache.dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/freddy33/qsm-go/model/m3path"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientCache(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "qsm-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	// Disabled cache never finds anything
+	var disabled *ClientCache
+	assert.Nil(t, MakeClientCache("", 3, 1))
+	disabled.save(pointDataCacheName, &m3api.PointPackDataMsg{})
+	assert.False(t, disabled.load(pointDataCacheName, &m3api.PointPackDataMsg{}))
+	assert.NoError(t, disabled.Clear())
+
+	cache := MakeClientCache(rootDir, 3, 1)
+	assert.Equal(t, filepath.Join(rootDir, "env-3", "schema-1"), cache.Dir())
+	pMsg := &m3api.PathNodesResponseMsg{PathCtxId: 12, ToDist: 3, MaxDist: 7}
+	assert.False(t, cache.load(pathNodesCacheName(12), new(m3api.PathNodesResponseMsg)))
+	cache.save(pathNodesCacheName(12), pMsg)
+	loaded := new(m3api.PathNodesResponseMsg)
+	assert.True(t, cache.load(pathNodesCacheName(12), loaded))
+	assert.True(t, proto.Equal(pMsg, loaded))
+
+	// Another schema version does not see it
+	assert.False(t, MakeClientCache(rootDir, 3, 2).load(pathNodesCacheName(12), new(m3api.PathNodesResponseMsg)))
+
+	// Corrupted files are dropped
+	assert.NoError(t, ioutil.WriteFile(cache.fileName(pointDataCacheName), []byte{0xff, 0xff}, 0644))
+	assert.False(t, cache.load(pointDataCacheName, new(m3api.PointPackDataMsg)))
+	_, err = os.Stat(cache.fileName(pointDataCacheName))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, cache.Clear())
+	assert.False(t, cache.load(pathNodesCacheName(12), new(m3api.PathNodesResponseMsg)))
+
+	// Dropping the env clears all its schema versions but not the other envs
+	other := MakeClientCache(rootDir, 4, 1)
+	other.save(pathNodesCacheName(12), pMsg)
+	for v := 1; v <= 2; v++ {
+		MakeClientCache(rootDir, 3, v).save(pathNodesCacheName(12), pMsg)
+	}
+	assert.NoError(t, ClearEnvCache(rootDir, 3))
+	assert.NoError(t, ClearEnvCache("", 3))
+	_, err = os.Stat(filepath.Join(rootDir, "env-3"))
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, other.load(pathNodesCacheName(12), new(m3api.PathNodesResponseMsg)))
+}
+
+func TestPathNodesCacheInvalidation(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "qsm-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	env := &QsmApiEnvironment{cache: MakeClientCache(rootDir, 3, 1)}
+	pathCtx := &PathContextCl{env: env, id: 5, maxDist: 2, fetchedToDist: 1, pathNodeMap: MakeHashPathNodeMap(16)}
+	for d, p := range []m3point.Point{{0, 0, 0}, {1, 1, 0}} {
+		pn := getNewPathNodeCl()
+		pn.pathCtx = pathCtx
+		pn.id = m3path.PathNodeId(d + 10)
+		pn.d = d
+		pn.point = p
+		pn.trioDetails = &m3point.TrioDetails{Id: m3point.TrioIndex(d + 3)}
+		pn.linkNodes[1] = 10
+		pathCtx.pathNodeMap.AddPathNode(pn)
+		if d == 0 {
+			pathCtx.rootNode = pn
+		}
+	}
+	pathCtx.saveCachedPathNodes()
+
+	saved := new(m3api.PathNodesResponseMsg)
+	assert.True(t, env.cache.load(pathNodesCacheName(5), saved))
+	assert.Equal(t, int32(1), saved.GetToDist())
+	assert.Equal(t, int32(2), saved.GetMaxDist())
+	assert.Equal(t, 2, len(saved.GetPathNodes()))
+	for _, pnMsg := range saved.GetPathNodes() {
+		assert.Equal(t, pnMsg.GetD()+3, pnMsg.GetTrioId())
+		assert.Equal(t, []int64{-1, 10, -1}, pnMsg.GetLinkedPathNodeIds())
+	}
+
+	// A new run restores the cached nodes only if the max dist from the backend is the same
+	pointData := &ClientPointPackData{}
+	pointData.AllTrioDetails = []*m3point.TrioDetails{{Id: 0}, {Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}}
+	pointData.TrioDetailsLoaded = true
+	restored := &PathContextCl{env: env, pointData: pointData, id: 5, maxDist: 2, fetchedToDist: -1, pathNodeMap: MakeHashPathNodeMap(16)}
+	restored.loadCachedPathNodes()
+	assert.Equal(t, 1, restored.fetchedToDist)
+	assert.Equal(t, 2, restored.CountAllPathNodes())
+	grown := &PathContextCl{env: env, pointData: pointData, id: 5, maxDist: 3, fetchedToDist: -1, pathNodeMap: MakeHashPathNodeMap(16)}
+	grown.loadCachedPathNodes()
+	assert.Equal(t, -1, grown.fetchedToDist)
+	assert.Equal(t, 0, grown.CountAllPathNodes())
+	assert.False(t, env.cache.load(pathNodesCacheName(5), new(m3api.PathNodesResponseMsg)))
+	pathCtx.saveCachedPathNodes()
+
+	// Same max dist keeps everything
+	pathCtx.checkMaxDist(2)
+	assert.Equal(t, 1, pathCtx.fetchedToDist)
+	assert.Equal(t, 2, pathCtx.CountAllPathNodes())
+
+	// New max dist keeps only the root and drops the cached files
+	pathCtx.checkMaxDist(3)
+	assert.Equal(t, 3, pathCtx.GetMaxDist())
+	assert.Equal(t, -1, pathCtx.fetchedToDist)
+	assert.Equal(t, 1, pathCtx.CountAllPathNodes())
+	assert.Equal(t, pathCtx.rootNode, pathCtx.GetPathNodeMap().GetPathNode(m3point.Origin))
+	assert.False(t, env.cache.load(pathNodesCacheName(5), new(m3api.PathNodesResponseMsg)))
+}
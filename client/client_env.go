@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/freddy33/qsm-go/client/config"
 	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
 	"net/http"
 	"strings"
 	"time"
@@ -14,12 +15,24 @@ var Log = m3util.NewLogger("client", m3util.INFO)
 type ClientConnection struct {
 	backendRootURL string
 	envId          m3util.QsmEnvID
+	apiToken       string
 	httpClient     http.Client
+	// Timeout of each attempt of a call
+	timeout     time.Duration
+	retryPolicy RetryPolicy
+	breaker     *CircuitBreaker
+	// Sent by the backend health status, 0 until checked
+	schemaVersion int
 }
 
 type QsmApiEnvironment struct {
 	m3util.BaseQsmEnvironment
 	clConn *ClientConnection
+	// Invalid config found at creation, returned when initializing
+	configErr error
+	// On disk cache of the backend data, nil if disabled
+	cache    *ClientCache
+	cacheDir string
 }
 
 func createNewApiEnv(envId m3util.QsmEnvID) m3util.QsmEnvironment {
@@ -30,23 +43,48 @@ func createNewApiEnv(envId m3util.QsmEnvID) m3util.QsmEnvironment {
 	result := new(ClientConnection)
 	result.backendRootURL = clientConfig.BackendRootURL
 	result.envId = envId
-	result.validate()
+	result.apiToken = clientConfig.ApiToken
+	result.timeout = clientConfig.RequestTimeout
+	result.retryPolicy = RetryPolicy{
+		MaxRetries:     clientConfig.MaxRetries,
+		InitialBackoff: clientConfig.RetryBackoff,
+		MaxBackoff:     clientConfig.MaxRetryBackoff,
+	}
+	result.breaker = MakeCircuitBreaker(clientConfig.BreakerThreshold, clientConfig.BreakerCooldown)
+	env.configErr = result.validate()
 	env.clConn = result
+	env.cacheDir = clientConfig.CacheDir
 
 	return &env
 }
 
-func (cl *ClientConnection) validate() {
+func (cl *ClientConnection) validate() error {
 	if cl.envId < 1 {
-		Log.Fatalf("Invalid client env id " + cl.envId.String() + " for root URL: " + cl.backendRootURL)
+		return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "invalid client env id %d for root URL: %s", cl.envId, cl.backendRootURL)
+	}
+	if cl.backendRootURL == "" {
+		return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "missing client root URL, set %s", config.BackendRootURLKey)
 	}
 	if len(cl.backendRootURL) < 4 {
-		Log.Fatalf("Invalid client root URL: " + cl.backendRootURL)
+		return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "invalid client root URL: %s", cl.backendRootURL)
 	}
 	if !strings.HasSuffix(cl.backendRootURL, "/") {
 		cl.backendRootURL = cl.backendRootURL + "/"
 	}
-	cl.httpClient = http.Client{Timeout: 20 * time.Second}
+	// Each attempt has its own timeout from the request context
+	cl.httpClient = http.Client{}
+	return nil
+}
+
+func (cl *ClientConnection) SetApiToken(token string) {
+	cl.apiToken = token
+}
+
+func (cl *ClientConnection) addHeaders(req *http.Request) {
+	req.Header.Add(m3api.HttpEnvIdKey, cl.envId.String())
+	if cl.apiToken != "" {
+		req.Header.Set(m3api.HttpAuthorizationKey, m3api.HttpBearerPrefix+cl.apiToken)
+	}
 }
 
 func (env *QsmApiEnvironment) Close() {
@@ -54,61 +92,114 @@ func (env *QsmApiEnvironment) Close() {
 	env.clConn.httpClient.CloseIdleConnections()
 }
 
-func GetInitializedApiEnv(envId m3util.QsmEnvID) *QsmApiEnvironment {
+func (env *QsmApiEnvironment) GetCache() *ClientCache {
+	return env.cache
+}
+
+// The backend data was dropped or recreated, the cached data of all schema versions has new ids
+func (env *QsmApiEnvironment) clearCache() {
+	err := ClearEnvCache(env.cacheDir, env.GetId())
+	if err != nil {
+		Log.Warn(err)
+	}
+}
+
+func GetInitializedApiEnv(envId m3util.QsmEnvID) (*QsmApiEnvironment, error) {
 	return GetOrCreateInitializedApiEnv(envId, false, m3util.TestMode)
 }
 
-func GetOrCreateInitializedApiEnv(envId m3util.QsmEnvID, callDrop, callInit bool) *QsmApiEnvironment {
+// The env connected to a backend answering, without loading any data
+func GetApiEnv(envId m3util.QsmEnvID) (*QsmApiEnvironment, error) {
 	env := m3util.GetEnvironmentWithCreator(envId, createNewApiEnv).(*QsmApiEnvironment)
+	if env.configErr != nil {
+		return nil, env.configErr
+	}
 	cl := env.clConn
 
-	if !cl.CheckServerUp() {
-		Log.Fatalf("Test backend server down!")
-		return nil
+	err := cl.CheckHealth()
+	if err != nil {
+		return nil, m3util.MakeWrapQsmErrorf(err, "backend server for env %d down: %v", envId, err)
+	}
+	if env.cache == nil {
+		env.cache = MakeClientCache(env.cacheDir, env.GetId(), cl.schemaVersion)
+	}
+	return env, nil
+}
+
+// The env with its data loaded from the backend, calling first drop and init on the backend if asked
+func GetOrCreateInitializedApiEnv(envId m3util.QsmEnvID, callDrop, callInit bool) (*QsmApiEnvironment, error) {
+	env, err := GetApiEnv(envId)
+	if err != nil {
+		return nil, err
 	}
 
 	if callDrop {
 		// Equivalent of calling filldb job
-		uri := "drop-env"
-		response, err := cl.ExecReq(http.MethodDelete, uri, nil, nil, false)
+		err = env.DropEnv()
 		if err != nil {
-			Log.Fatal(err)
-			return nil
-
+			return nil, err
 		}
-		substr := fmt.Sprintf("env id %d was deleted", cl.envId)
-		if strings.Contains(response, substr) {
-			Log.Debugf("All good on home response %q", response)
-		} else {
-			Log.Fatalf("The response from REST API end point %q did not have %s in %q", uri, substr, response)
-			return nil
-		}
-		env.SetData(m3util.PointIdx, nil)
-		env.SetData(m3util.PathIdx, nil)
-		env.SetData(m3util.SpaceIdx, nil)
 	}
 
 	if callInit {
 		// Equivalent of calling filldb job
-		uri := "init-env"
-		response, err := cl.ExecReq(http.MethodPost, uri, nil, nil, false)
+		err = env.InitEnv()
 		if err != nil {
-			Log.Fatal(err)
-			return nil
-		}
-		substr := fmt.Sprintf("env id %d was initialized", cl.envId)
-		if strings.Contains(response, substr) {
-			Log.Debugf("All good on home response %q", response)
-		} else {
-			Log.Fatalf("The response from REST API end point %q did not have %s in %q", uri, substr, response)
-			return nil
+			return nil, err
 		}
 	}
 
-	env.initializePointData()
+	err = env.initializePointData()
+	if err != nil {
+		return nil, err
+	}
 	env.initializePathData()
 	env.initializeSpaceData()
-	return env
+	return env, nil
+}
+
+// All the environments of the backend DB with their schema size
+func (env *QsmApiEnvironment) ListEnvs() ([]*m3api.EnvMsg, error) {
+	resMsg := new(m3api.EnvListMsg)
+	_, err := env.clConn.ExecReq(http.MethodGet, "list-env", nil, resMsg, false)
+	if err != nil {
+		return nil, err
+	}
+	return resMsg.GetEnvs(), nil
 }
 
+// Drop the backend DB schema of the env and all the data loaded from it
+func (env *QsmApiEnvironment) DropEnv() error {
+	err := env.clConn.execEnvReq(http.MethodDelete, "drop-env", fmt.Sprintf("env id %d was deleted", env.GetId()))
+	if err != nil {
+		return err
+	}
+	env.SetData(m3util.PointIdx, nil)
+	env.SetData(m3util.PathIdx, nil)
+	env.SetData(m3util.SpaceIdx, nil)
+	env.clearCache()
+	return nil
+}
+
+// Create and fill the backend DB schema of the env, waiting for the backend to be ready
+func (env *QsmApiEnvironment) InitEnv() error {
+	err := env.clConn.execEnvReq(http.MethodPost, "init-env", fmt.Sprintf("env id %d was initialized", env.GetId()))
+	if err != nil {
+		return err
+	}
+	env.clearCache()
+	return env.clConn.WaitServerReady(readyTimeout)
+}
 
+// Call an env end point answering a text containing substr
+func (cl *ClientConnection) execEnvReq(method string, uri string, substr string) error {
+	response, err := cl.ExecReq(method, uri, nil, nil, false)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(response, substr) {
+		return m3util.MakeQsmCodeErrorf(m3util.ErrInvalidState, "the response from REST API end point %q did not have %s in %q", uri, substr, response)
+	}
+	Log.Debugf("All good on %s response %q", uri, response)
+	return nil
+}
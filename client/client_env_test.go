@@ -0,0 +1,29 @@
+package client
+
+import (
+	"github.com/freddy33/qsm-go/client/config"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestGetApiEnvWithoutRootURL(t *testing.T) {
+	saved, ok := os.LookupEnv(config.BackendRootURLKey)
+	assert.NoError(t, os.Unsetenv(config.BackendRootURLKey))
+	defer func() {
+		if ok {
+			_ = os.Setenv(config.BackendRootURLKey, saved)
+		}
+	}()
+	envId := m3util.SpaceClientTempEnv
+	defer m3util.RemoveEnvFromMap(envId)
+
+	// An error and not an exit
+	env, err := GetApiEnv(envId)
+	assert.Nil(t, env)
+	if assert.Error(t, err) {
+		assert.Equal(t, m3util.ErrBadRequest, m3util.GetQsmErrorCode(err))
+		assert.Contains(t, err.Error(), config.BackendRootURLKey)
+	}
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/golang/protobuf/proto"
+	"strings"
+)
+
+// Error returned by the backend for a REST API call
+type ApiError struct {
+	Method    string
+	Uri       string
+	Status    int
+	Code      m3util.QsmErrorCode
+	Message   string
+	Details   []string
+	RequestId string
+}
+
+func (apiErr *ApiError) Error() string {
+	res := fmt.Sprintf("Query %s:%s failed with status %d %s: %s", apiErr.Method, apiErr.Uri, apiErr.Status, apiErr.Code.String(), apiErr.Message)
+	if apiErr.RequestId != "" {
+		res += " [request " + apiErr.RequestId + "]"
+	}
+	return res
+}
+
+func (apiErr *ApiError) GetCode() m3util.QsmErrorCode {
+	return apiErr.Code
+}
+
+// Build the error from the body of a failed response, falling back to the status for non ErrorMsg bodies
+func decodeApiError(method string, uri string, status int, contentType string, body []byte) *ApiError {
+	res := &ApiError{
+		Method:  method,
+		Uri:     uri,
+		Status:  status,
+		Code:    m3api.HttpStatusToErrorCode(status),
+		Message: strings.TrimSpace(string(body)),
+	}
+	errMsg := &m3api.ErrorMsg{}
+	var err error
+	if strings.HasPrefix(contentType, ContentTypeJson) {
+		err = json.Unmarshal(body, errMsg)
+	} else if strings.HasPrefix(contentType, ContentTypeProtobuf) {
+		err = proto.Unmarshal(body, errMsg)
+	} else {
+		return res
+	}
+	if err != nil {
+		Log.Warnf("Could not decode error message of %s:%s due to %v", method, uri, err)
+		return res
+	}
+	if errMsg.GetCode() != int32(m3util.ErrUnknown) {
+		res.Code = m3util.QsmErrorCode(errMsg.GetCode())
+	}
+	res.Message = errMsg.GetMessage()
+	res.Details = errMsg.GetDetails()
+	res.RequestId = errMsg.GetRequestId()
+	return res
+}
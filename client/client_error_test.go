@@ -0,0 +1,44 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3api"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestDecodeApiError(t *testing.T) {
+	errMsg := m3api.MakeErrorMsg(m3util.ErrInvalidState,
+		m3util.MakeWrapQsmErrorf(errors.New("root cause"), "path context %d max dist too low", 12), "req-1")
+	assert.Equal(t, []string{"root cause"}, errMsg.Details)
+
+	data, err := proto.Marshal(errMsg)
+	assert.NoError(t, err)
+	apiErr := decodeApiError("GET", "path-nodes", http.StatusUnprocessableEntity, ContentTypeProtobuf+"; messageType=m3api.ErrorMsg", data)
+	assert.Equal(t, m3util.ErrInvalidState, apiErr.Code)
+	assert.Equal(t, "path context 12 max dist too low", apiErr.Message)
+	assert.Equal(t, []string{"root cause"}, apiErr.Details)
+	assert.Equal(t, "req-1", apiErr.RequestId)
+
+	// Typed error found through wrapping
+	var wrapped error = m3util.MakeWrapQsmErrorf(apiErr, "loading failed")
+	assert.Equal(t, m3util.ErrInvalidState, m3util.GetQsmErrorCode(wrapped))
+	var target *ApiError
+	assert.True(t, errors.As(wrapped, &target))
+	assert.Equal(t, http.StatusUnprocessableEntity, target.Status)
+
+	data, err = json.Marshal(&m3api.ErrorMsg{Code: int32(m3util.ErrNotFound), Message: "no space"})
+	assert.NoError(t, err)
+	apiErr = decodeApiError("GET", "event", http.StatusNotFound, ContentTypeJson, data)
+	assert.Equal(t, m3util.ErrNotFound, apiErr.Code)
+	assert.Equal(t, "no space", apiErr.Message)
+
+	// Old servers sending plain text
+	apiErr = decodeApiError("GET", "event", http.StatusBadRequest, "text/plain", []byte("bad request\n"))
+	assert.Equal(t, m3util.ErrBadRequest, apiErr.Code)
+	assert.Equal(t, "bad request", apiErr.Message)
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3api"
@@ -11,7 +12,9 @@ import (
 	"github.com/golang/protobuf/proto"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 )
 
 const (
@@ -21,7 +24,17 @@ const (
 	ContentTypeJson     = "application/json"
 )
 
+const (
+	readyPollInterval = time.Second
+	readyTimeout      = 2 * time.Minute
+)
+
 func (cl *ClientConnection) ExecReq(method string, uri string, reqMsg proto.Message, respMsg proto.Message, useQueryParams bool) (string, error) {
+	return cl.ExecReqWithTimeout(cl.timeout, method, uri, reqMsg, respMsg, useQueryParams)
+}
+
+// Same as ExecReq with a specific timeout for each attempt
+func (cl *ClientConnection) ExecReqWithTimeout(timeout time.Duration, method string, uri string, reqMsg proto.Message, respMsg proto.Message, useQueryParams bool) (string, error) {
 	uri = strings.TrimPrefix(uri, "/")
 
 	var err error
@@ -36,15 +49,32 @@ func (cl *ClientConnection) ExecReq(method string, uri string, reqMsg proto.Mess
 			return ExecFailed, m3util.MakeWrapQsmErrorf(err, "Failed marshalling message using query %v in %s:%s for REST API end point %q due to: %v", useQueryParams, method, uri, cl.backendRootURL, err)
 		}
 	}
-	req, err := http.NewRequest(method, cl.backendRootURL+uri, bytes.NewReader(reqBytes))
+	response := ExecFailed
+	err = cl.withRetries(method, uri, func() (bool, error) {
+		var retry bool
+		var callErr error
+		response, retry, callErr = cl.execOnce(timeout, method, uri, reqMsg != nil, reqBytes, respMsg, useQueryParams)
+		return retry, callErr
+	})
+	if err != nil {
+		return ExecFailed, err
+	}
+	return response, nil
+}
+
+// One attempt of ExecReq, returning true with the error if the call can be retried
+func (cl *ClientConnection) execOnce(timeout time.Duration, method string, uri string, hasReq bool, reqBytes []byte, respMsg proto.Message, useQueryParams bool) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, cl.backendRootURL+uri, bytes.NewReader(reqBytes))
 	if err != nil {
-		return ExecFailed, m3util.MakeWrapQsmErrorf(err, "Could not request %s:%s for REST API end point %q due to: %v", method, uri, cl.backendRootURL, err)
+		return ExecFailed, false, m3util.MakeWrapQsmErrorf(err, "Could not request %s:%s for REST API end point %q due to: %v", method, uri, cl.backendRootURL, err)
 	}
 	if req == nil {
-		return ExecFailed, m3util.MakeQsmErrorf("Got a nil request %s:%s for REST API end point %q", method, uri, cl.backendRootURL)
+		return ExecFailed, false, m3util.MakeQsmErrorf("Got a nil request %s:%s for REST API end point %q", method, uri, cl.backendRootURL)
 	}
-	req.Header.Add(m3api.HttpEnvIdKey, cl.envId.String())
-	if reqMsg != nil {
+	cl.addHeaders(req)
+	if hasReq {
 		if useQueryParams {
 			req.URL.RawQuery = string(reqBytes)
 		} else {
@@ -56,28 +86,27 @@ func (cl *ClientConnection) ExecReq(method string, uri string, reqMsg proto.Mess
 	}
 	resp, err := cl.httpClient.Do(req)
 	if err != nil {
-		return ExecFailed, m3util.MakeWrapQsmErrorf(err, "Could not retrieve data from REST API %s:%s end point %q due to: %v", method, uri, cl.backendRootURL, err)
+		return ExecFailed, true, m3util.MakeWrapQsmCodeErrorf(m3util.ErrUnavailable, err, "Could not retrieve data from REST API %s:%s end point %q due to: %v", method, uri, cl.backendRootURL, err)
 	}
 	if resp == nil {
-		return ExecFailed, m3util.MakeQsmErrorf("Got a nil response from REST API %s:%s end point %q", method, uri, cl.backendRootURL)
+		return ExecFailed, true, m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "Got a nil response from REST API %s:%s end point %q", method, uri, cl.backendRootURL)
 	}
 
 	// Always read the whole body even on error
 	respBody := resp.Body
 	defer m3util.CloseBody(respBody)
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusCreated {
-		return ExecFailed, m3util.MakeQsmErrorf("Query %s:%s received wrong status %d", method, uri, resp.StatusCode)
-	}
-
 	respBytes, err := ioutil.ReadAll(respBody)
 	if err != nil {
-		return ExecFailed, m3util.MakeWrapQsmErrorf(err, "Could not read body from REST API end point %q due to %v", uri, err)
+		return ExecFailed, true, m3util.MakeWrapQsmCodeErrorf(m3util.ErrUnavailable, err, "Could not read body from REST API end point %q due to %v", uri, err)
 	}
 
 	responseContentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusCreated {
+		return ExecFailed, resp.StatusCode >= http.StatusInternalServerError, decodeApiError(method, uri, resp.StatusCode, responseContentType, respBytes)
+	}
 	if strings.HasPrefix(responseContentType, "text/plain") {
-		return string(respBytes), nil
+		return string(respBytes), false, nil
 	}
 
 	if respMsg != nil {
@@ -87,27 +116,104 @@ func (cl *ClientConnection) ExecReq(method string, uri string, reqMsg proto.Mess
 		} else if strings.HasPrefix(responseContentType, ContentTypeProtobuf) {
 			err = proto.Unmarshal(respBytes, respMsg)
 		} else {
-			return ExecFailed, m3util.MakeWrapQsmErrorf(err, "REST API end point %q returned unknown content type %q", uri, responseContentType)
+			return ExecFailed, false, m3util.MakeQsmErrorf("REST API end point %q returned unknown content type %q", uri, responseContentType)
 		}
 		if err != nil {
-			return ExecFailed, m3util.MakeWrapQsmErrorf(err, "Could not unmarshal from REST API end point %q due to %v", uri, err)
+			return ExecFailed, false, m3util.MakeWrapQsmErrorf(err, "Could not unmarshal from REST API end point %q due to %v", uri, err)
 		}
-		return ExecOK, nil
+		return ExecOK, false, nil
 	}
 
-	return string(respBytes), nil
+	return string(respBytes), false, nil
+}
+
+// Read the JSON status of the health end points, not ready is returned with the status and no error
+func (cl *ClientConnection) GetHealthStatus(uri string) (bool, *m3api.HealthStatusMsg, error) {
+	var ready bool
+	var status *m3api.HealthStatusMsg
+	err := cl.withRetries(http.MethodGet, uri, func() (bool, error) {
+		var retry bool
+		var callErr error
+		ready, status, retry, callErr = cl.getHealthStatusOnce(uri)
+		return retry, callErr
+	})
+	return ready, status, err
+}
+
+func (cl *ClientConnection) getHealthStatusOnce(uri string) (bool, *m3api.HealthStatusMsg, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cl.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cl.backendRootURL+uri, nil)
+	if err != nil {
+		return false, nil, false, m3util.MakeWrapQsmErrorf(err, "Could not request %s for REST API end point %q due to: %v", uri, cl.backendRootURL, err)
+	}
+	cl.addHeaders(req)
+	resp, err := cl.httpClient.Do(req)
+	if err != nil {
+		return false, nil, true, m3util.MakeWrapQsmCodeErrorf(m3util.ErrUnavailable, err, "Could not reach REST API %s end point %q due to: %v", uri, cl.backendRootURL, err)
+	}
+	defer m3util.CloseBody(resp.Body)
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, nil, true, m3util.MakeWrapQsmCodeErrorf(m3util.ErrUnavailable, err, "Could not read body from REST API end point %q due to %v", uri, err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return false, nil, resp.StatusCode >= http.StatusInternalServerError, decodeApiError(http.MethodGet, uri, resp.StatusCode, resp.Header.Get("Content-Type"), respBytes)
+	}
+	status := &m3api.HealthStatusMsg{}
+	err = json.Unmarshal(respBytes, status)
+	if err != nil {
+		return false, nil, false, m3util.MakeWrapQsmErrorf(err, "Could not unmarshal health status from REST API end point %q due to %v", uri, err)
+	}
+	return resp.StatusCode == http.StatusOK, status, false, nil
+}
+
+// Error if the backend process does not answer, keeping the schema version it sends
+func (cl *ClientConnection) CheckHealth() error {
+	up, status, err := cl.GetHealthStatus("healthz")
+	if err != nil {
+		return err
+	}
+	if !up {
+		return m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "backend env %d health status is %s", cl.envId, status.Status)
+	}
+	Log.Debugf("All good on health status %v", status)
+	cl.schemaVersion = status.SchemaVersion
+	return nil
 }
 
 func (cl *ClientConnection) CheckServerUp() bool {
-	response, err := cl.ExecReq(http.MethodGet, "", nil, nil, false)
+	err := cl.CheckHealth()
 	if err != nil {
 		Log.Error(err)
 		return false
 	}
-	Log.Debugf("All good on home response %q", response)
 	return true
 }
 
+// Poll readyz until the backend DB and environment are ready
+func (cl *ClientConnection) WaitServerReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ready, status, err := cl.GetHealthStatus("readyz")
+		if ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return m3util.MakeWrapQsmCodeErrorf(m3util.ErrUnavailable, err, "backend env %d not ready after %v: %v", cl.envId, timeout, err)
+			}
+			return m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "backend env %d not ready after %v: %v", cl.envId, timeout, status.Checks)
+		}
+		if err != nil {
+			Log.Infof("Backend env %d not reachable yet: %v", cl.envId, err)
+		} else {
+			Log.Infof("Backend env %d not ready yet: %v", cl.envId, status.Checks)
+		}
+		time.Sleep(readyPollInterval)
+	}
+}
+
 func (env *QsmApiEnvironment) initializeSpaceData() {
 	var spaceData *ClientSpacePackData
 	spaceIfc := env.GetData(m3util.SpaceIdx)
@@ -140,14 +246,14 @@ func (env *QsmApiEnvironment) initializePathData() {
 	}
 }
 
-func (env *QsmApiEnvironment) initializePointData() {
+func (env *QsmApiEnvironment) initializePointData() error {
 	var pointData *ClientPointPackData
 	ppdIfc := env.GetData(m3util.PointIdx)
 	if ppdIfc != nil {
 		pointData = ppdIfc.(*ClientPointPackData)
 		if pointData.GrowthContextsLoaded {
 			Log.Debugf("env %d already loaded", env.GetId())
-			return
+			return nil
 		}
 	}
 	if ppdIfc == nil {
@@ -158,14 +264,15 @@ func (env *QsmApiEnvironment) initializePointData() {
 	}
 
 	if pointData == nil {
-		Log.Fatalf("Something wrong above")
-		return
+		return m3util.MakeQsmErrorf("no point data for env %d", env.GetId())
 	}
 	pMsg := &m3api.PointPackDataMsg{}
-	_, err := env.clConn.ExecReq(http.MethodGet, "point-data", nil, pMsg, false)
-	if err != nil {
-		Log.Fatal(err)
-		return
+	if !env.cache.load(pointDataCacheName, pMsg) {
+		_, err := env.clConn.ExecReq(http.MethodGet, "point-data", nil, pMsg, false)
+		if err != nil {
+			return err
+		}
+		env.cache.save(pointDataCacheName, pMsg)
 	}
 
 	pointData.AllConnections = make([]*m3point.ConnectionDetails, len(pMsg.AllConnections))
@@ -210,15 +317,90 @@ func (env *QsmApiEnvironment) initializePointData() {
 
 	pointData.AllGrowthContexts = make([]m3point.GrowthContext, len(pMsg.AllGrowthContexts))
 	for idx, gc := range pMsg.AllGrowthContexts {
-		pointData.AllGrowthContexts[idx] = &m3point.BaseGrowthContext{
-			Env:         env,
-			Id:          int(gc.GetGrowthContextId()),
-			GrowthType:  m3point.GrowthType(gc.GetGrowthType()),
-			GrowthIndex: int(gc.GetGrowthIndex()),
-		}
+		pointData.AllGrowthContexts[idx] = msgToGrowthContext(env, gc)
 	}
 	pointData.GrowthContextsLoaded = true
 	Log.Debugf("loaded %d growth context", len(pointData.AllGrowthContexts))
+
+	pointData.PredictedSizeModels = make([]*m3point.PredictedSizeModel, len(pMsg.PredictedSizeModels))
+	for idx, model := range pMsg.PredictedSizeModels {
+		pointData.PredictedSizeModels[idx] = &m3point.PredictedSizeModel{
+			GrowthType:  m3point.GrowthType(model.GetGrowthType()),
+			GrowthIndex: int(model.GetGrowthIndex()),
+			A:           model.GetA(),
+			B:           model.GetB(),
+			C:           model.GetC(),
+			MaxDist:     int(model.GetMaxDist()),
+		}
+	}
+	Log.Debugf("loaded %d predicted size models", len(pointData.PredictedSizeModels))
+	return nil
+}
+
+func msgToGrowthContext(env *QsmApiEnvironment, gc *m3api.GrowthContextMsg) *m3point.BaseGrowthContext {
+	res := &m3point.BaseGrowthContext{
+		Env:         env,
+		Id:          int(gc.GetGrowthContextId()),
+		GrowthType:  m3point.GrowthType(gc.GetGrowthType()),
+		GrowthIndex: int(gc.GetGrowthIndex()),
+	}
+	if len(gc.GetTrioSequence()) > 0 {
+		res.TrioSequence = make([]m3point.TrioIndex, len(gc.GetTrioSequence()))
+		for i, trIdx := range gc.GetTrioSequence() {
+			res.TrioSequence[i] = m3point.TrioIndex(trIdx)
+		}
+	}
+	return res
+}
+
+// Register on the server a custom growth context cycling through the trio sequence
+func (pointData *ClientPointPackData) AddCustomGrowthContext(trioSequence []m3point.TrioIndex) (m3point.GrowthContext, error) {
+	err := m3point.ValidateTrioSequence(pointData, trioSequence)
+	if err != nil {
+		return nil, err
+	}
+	reqMsg := &m3api.GrowthContextMsg{TrioSequence: make([]int32, len(trioSequence))}
+	for i, trIdx := range trioSequence {
+		reqMsg.TrioSequence[i] = int32(trIdx)
+	}
+	pMsg := new(m3api.GrowthContextMsg)
+	_, err = pointData.env.clConn.ExecReq(http.MethodPost, "growth-context", reqMsg, pMsg, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same cycle already registered returns the existing growth context
+	for _, growthCtx := range pointData.AllGrowthContexts {
+		if growthCtx.GetId() == int(pMsg.GetGrowthContextId()) {
+			return growthCtx, nil
+		}
+	}
+	growthCtx := msgToGrowthContext(pointData.env, pMsg)
+	pointData.AllGrowthContexts = append(pointData.AllGrowthContexts, growthCtx)
+	// The cached point data does not have it
+	pointData.env.cache.remove(pointDataCacheName)
+	return growthCtx, nil
+}
+
+// Custom growth contexts registered by other clients are missing from the cached point data
+func (pointData *ClientPointPackData) loadMissingGrowthContext(id int) error {
+	if id < len(pointData.AllGrowthContexts) {
+		return nil
+	}
+	pointData.env.cache.remove(pointDataCacheName)
+	pMsg := &m3api.PointPackDataMsg{}
+	_, err := pointData.env.clConn.ExecReq(http.MethodGet, "point-data", nil, pMsg, false)
+	if err != nil {
+		return err
+	}
+	for _, gc := range pMsg.AllGrowthContexts[len(pointData.AllGrowthContexts):] {
+		pointData.AllGrowthContexts = append(pointData.AllGrowthContexts, msgToGrowthContext(pointData.env, gc))
+	}
+	if id >= len(pointData.AllGrowthContexts) {
+		return m3util.MakeQsmCodeErrorf(m3util.ErrNotFound, "growth context id %d not found in env %d", id, pointData.env.GetId())
+	}
+	pointData.env.cache.save(pointDataCacheName, pMsg)
+	return nil
 }
 
 func (pathData *ClientPathPackData) GetEnvId() m3util.QsmEnvID {
@@ -228,21 +410,43 @@ func (pathData *ClientPathPackData) GetEnvId() m3util.QsmEnvID {
 	return pathData.env.GetId()
 }
 
+// Always fetched since the max dist may have changed, only the path nodes are cached
 func (pathData *ClientPathPackData) GetPathCtx(id m3path.PathContextId) m3path.PathContext {
-	pathCtx, ok := pathData.pathCtxMap.Load(id)
-	if ok {
-		return (*PathContextCl)(pathCtx)
-	}
-
 	uri := "path-context"
 	reqMsg := &m3api.PathContextIdMsg{PathCtxId: int32(id)}
 	pMsg := new(m3api.PathContextMsg)
 	_, err := pathData.env.clConn.ExecReq(http.MethodGet, uri, reqMsg, pMsg, true)
 	if err != nil {
-		Log.Fatal(err)
+		Log.Error(err)
 		return nil
 	}
-	return MakePatchContextClient(pathData, pMsg)
+	pathCtxCl, err := MakePatchContextClient(pathData, pMsg)
+	if err != nil {
+		Log.Error(err)
+		return nil
+	}
+	return pathCtxCl
+}
+
+// All the path contexts created in the backend env, sorted by id
+func (pathData *ClientPathPackData) GetAllPathContexts() ([]m3path.PathContext, error) {
+	reqMsg := &m3api.PathContextIdMsg{PathCtxId: -1}
+	pMsg := new(m3api.PathContextListMsg)
+	_, err := pathData.env.clConn.ExecReq(http.MethodGet, "path-context", reqMsg, pMsg, true)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]m3path.PathContext, len(pMsg.GetPathContexts()))
+	for i, pathCtxMsg := range pMsg.GetPathContexts() {
+		res[i], err = MakePatchContextClient(pathData, pathCtxMsg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].GetId() < res[j].GetId()
+	})
+	return res, nil
 }
 
 func (pathData *ClientPathPackData) GetPathCtxFromAttributes(growthType m3point.GrowthType, growthIndex int, offset int) (m3path.PathContext, error) {
@@ -255,9 +459,7 @@ func (pathData *ClientPathPackData) GetPathCtxFromAttributes(growthType m3point.
 	pMsg := new(m3api.PathContextMsg)
 	_, err := pathData.env.clConn.ExecReq(http.MethodPost, uri, reqMsg, pMsg, true)
 	if err != nil {
-		Log.Fatal(err)
-		return nil, nil
+		return nil, err
 	}
-
-	return MakePatchContextClient(pathData, pMsg), nil
+	return MakePatchContextClient(pathData, pMsg)
 }
@@ -26,6 +26,10 @@ type PathContextCl struct {
 	pathNodeMap ClientPathNodeMap
 
 	maxDist int
+	// All the path nodes up to this distance are in the map, -1 if not fetched yet
+	fetchedToDist int
+	// Node counts per distance sent by the server, may be behind max dist
+	distStats *m3path.PathDistStats
 }
 
 type PathNodeCl struct {
@@ -42,20 +46,101 @@ type PathNodeCl struct {
 // PathContextCl Functions
 /***************************************************************/
 
-func MakePatchContextClient(pathData *ClientPathPackData, pMsg *m3api.PathContextMsg) *PathContextCl {
+func MakePatchContextClient(pathData *ClientPathPackData, pMsg *m3api.PathContextMsg) (*PathContextCl, error) {
 	pathCtx := new(PathContextCl)
 	pathCtx.id = m3path.PathContextId(pMsg.GetPathCtxId())
 	pathCtx.env = pathData.env
 	pointData := GetClientPointPackData(pathData.env)
 	pathCtx.pointData = pointData
+	err := pointData.loadMissingGrowthContext(int(pMsg.GetGrowthContextId()))
+	if err != nil {
+		return nil, err
+	}
 	pathCtx.growthCtx = pointData.GetGrowthContextById(int(pMsg.GetGrowthContextId()))
 	pathCtx.growthOffset = int(pMsg.GetGrowthOffset())
+	pathCtx.maxDist = int(pMsg.GetMaxDist())
+	pathCtx.fetchedToDist = -1
 	pathCtx.pathNodeMap = MakeHashPathNodeMap(1024)
 	pathCtx.rootNode = pathCtx.addPathNodeFromMsg(pMsg.RootPathNode)
+	pathCtx.distStats = m3path.MakePathDistStats(len(pMsg.GetDistStats()))
+	for _, statMsg := range pMsg.GetDistStats() {
+		pathCtx.distStats.Add(m3path.PathDistStat{
+			D:           int(statMsg.GetD()),
+			NbNodes:     int(statMsg.GetNbNodes()),
+			NbDeadEnds:  int(statMsg.GetNbDeadEnds()),
+			NbConflicts: int(statMsg.GetNbConflicts()),
+		})
+	}
+
+	actualPP, inserted := pathData.pathCtxMap.LoadOrStore(pathCtx.GetId(), unsafe.Pointer(pathCtx))
+	if inserted {
+		pathCtx.loadCachedPathNodes()
+		return pathCtx, nil
+	}
+
+	// Already known, the max dist of the message is the current one
+	actual := (*PathContextCl)(actualPP)
+	actual.checkMaxDist(pathCtx.maxDist)
+	return actual, nil
+}
+
+// Restore the path nodes fetched by a previous run if the max dist from the backend did not change since
+func (pathCtx *PathContextCl) loadCachedPathNodes() {
+	cacheName := pathNodesCacheName(int(pathCtx.id))
+	pMsg := new(m3api.PathNodesResponseMsg)
+	if !pathCtx.env.cache.load(cacheName, pMsg) {
+		return
+	}
+	if int(pMsg.GetMaxDist()) != pathCtx.maxDist {
+		Log.Infof("Dropping cached path nodes of %d computed up to %d since max dist is now %d", pathCtx.GetId(), pMsg.GetMaxDist(), pathCtx.maxDist)
+		pathCtx.env.cache.remove(cacheName)
+		return
+	}
+	for _, pnMsg := range pMsg.GetPathNodes() {
+		pathCtx.addPathNodeFromMsg(pnMsg)
+	}
+	pathCtx.fetchedToDist = int(pMsg.GetToDist())
+	Log.Debugf("Loaded %d cached path nodes up to %d for %d", len(pMsg.GetPathNodes()), pathCtx.fetchedToDist, pathCtx.GetId())
+}
 
-	actualPP, _ := pathData.pathCtxMap.LoadOrStore(pathCtx.GetId(), unsafe.Pointer(pathCtx))
+func (pathCtx *PathContextCl) saveCachedPathNodes() {
+	pMsg := &m3api.PathNodesResponseMsg{
+		PathCtxId: int32(pathCtx.id),
+		ToDist:    int32(pathCtx.fetchedToDist),
+		MaxDist:   int32(pathCtx.maxDist),
+		PathNodes: make([]*m3api.PathNodeMsg, 0, pathCtx.pathNodeMap.Size()),
+	}
+	pathCtx.pathNodeMap.RangePerPoint(func(point m3point.Point, pn *PathNodeCl) bool {
+		pMsg.PathNodes = append(pMsg.PathNodes, pn.toMsg())
+		return false
+	}, m3point.MakeRangeContext(false, 1, Log))
+	pathCtx.env.cache.save(pathNodesCacheName(int(pathCtx.id)), pMsg)
+}
 
-	return (*PathContextCl)(actualPP)
+// Connections and links of the nodes at the old max dist changed, all the fetched nodes beside the root are dropped
+func (pathCtx *PathContextCl) checkMaxDist(maxDist int) {
+	if maxDist == pathCtx.maxDist {
+		return
+	}
+	Log.Infof("Max dist of %d changed from %d to %d, invalidating its path nodes", pathCtx.GetId(), pathCtx.maxDist, maxDist)
+	pathCtx.maxDist = maxDist
+	pathCtx.fetchedToDist = -1
+	pathCtx.pathNodeMap = MakeHashPathNodeMap(1024)
+	pathCtx.pathNodeMap.AddPathNode(pathCtx.rootNode)
+	pathCtx.env.cache.remove(pathNodesCacheName(int(pathCtx.id)))
+}
+
+// Keep the fetched nodes from dist to toDist if they follow the ones already fetched
+func (pathCtx *PathContextCl) addFetchedPathNodes(pathNodes []*m3api.PathNodeMsg, fromDist, toDist int) {
+	for _, pMsg := range pathNodes {
+		pathCtx.addPathNodeFromMsg(pMsg)
+	}
+	if fromDist <= pathCtx.fetchedToDist+1 && toDist > pathCtx.fetchedToDist {
+		pathCtx.fetchedToDist = toDist
+	}
+	if len(pathNodes) > 0 {
+		pathCtx.saveCachedPathNodes()
+	}
 }
 
 func (pathCtx *PathContextCl) String() string {
@@ -182,16 +267,19 @@ func (pathCtx *PathContextCl) RequestNewMaxDist(requestDist int) error {
 	if response != ExecOK {
 		// We got status accepted meaning already done
 		if requestDist > pathCtx.maxDist {
-			pathCtx.maxDist = requestDist
+			pathCtx.checkMaxDist(requestDist)
 		}
 	} else {
-		pathCtx.maxDist = int(pMsg.MaxDist)
+		pathCtx.checkMaxDist(int(pMsg.MaxDist))
 	}
 	Log.Infof("New max dist is %d for %d", pathCtx.GetMaxDist(), pathCtx.GetId())
 	return nil
 }
 
 func (pathCtx *PathContextCl) GetPathNodesAt(dist int) ([]m3path.PathNode, error) {
+	if dist <= pathCtx.fetchedToDist {
+		return pathCtx.mapGetPathNodesAt(dist), nil
+	}
 	uri := "path-nodes"
 	reqMsg := &m3api.PathNodesRequestMsg{
 		PathCtxId: int32(pathCtx.GetId()),
@@ -204,15 +292,17 @@ func (pathCtx *PathContextCl) GetPathNodesAt(dist int) ([]m3path.PathNode, error
 		return nil, err
 	}
 	pathNodes := pMsg.GetPathNodes()
-	pathCtx.maxDist = int(pMsg.MaxDist)
+	pathCtx.checkMaxDist(int(pMsg.MaxDist))
 	Log.Infof("Received back %d path nodes back at %d for %d", len(pathNodes), dist, pathCtx.GetId())
-	for _, pMsg := range pathNodes {
-		pathCtx.addPathNodeFromMsg(pMsg)
-	}
+	pathCtx.addFetchedPathNodes(pathNodes, dist, dist)
 	return pathCtx.mapGetPathNodesAt(dist), nil
 }
 
 func (pathCtx *PathContextCl) GetNumberOfNodesAt(dist int) int {
+	nb, ok := pathCtx.distStats.GetNumberOfNodesAt(dist)
+	if ok {
+		return nb
+	}
 	uri := "nb-path-nodes"
 	reqMsg := &m3api.PathNodesRequestMsg{
 		PathCtxId: int32(pathCtx.GetId()),
@@ -226,12 +316,16 @@ func (pathCtx *PathContextCl) GetNumberOfNodesAt(dist int) int {
 		return -1
 	}
 	nbPathNodes := int(pMsg.GetNbPathNodes())
-	pathCtx.maxDist = int(pMsg.MaxDist)
+	pathCtx.checkMaxDist(int(pMsg.MaxDist))
 	Log.Infof("Received back nb path nodes = %d at %d for %d", nbPathNodes, dist, pathCtx.GetId())
 	return nbPathNodes
 }
 
 func (pathCtx *PathContextCl) GetNumberOfNodesBetween(fromDist int, toDist int) int {
+	nb, ok := pathCtx.distStats.GetNumberOfNodesBetween(fromDist, toDist)
+	if ok {
+		return nb
+	}
 	uri := "nb-path-nodes"
 	reqMsg := &m3api.PathNodesRequestMsg{
 		PathCtxId: int32(pathCtx.GetId()),
@@ -245,12 +339,15 @@ func (pathCtx *PathContextCl) GetNumberOfNodesBetween(fromDist int, toDist int)
 		return -1
 	}
 	nbPathNodes := int(pMsg.GetNbPathNodes())
-	pathCtx.maxDist = int(pMsg.MaxDist)
+	pathCtx.checkMaxDist(int(pMsg.MaxDist))
 	Log.Infof("Received back nb path nodes = %d from %d to %d for %d", nbPathNodes, fromDist, toDist, pathCtx.GetId())
 	return nbPathNodes
 }
 
 func (pathCtx *PathContextCl) GetPathNodesBetween(fromDist, toDist int) ([]m3path.PathNode, error) {
+	if toDist <= pathCtx.fetchedToDist {
+		return pathCtx.mapGetPathNodesBetween(fromDist, toDist), nil
+	}
 	uri := "path-nodes"
 	reqMsg := &m3api.PathNodesRequestMsg{
 		PathCtxId: int32(pathCtx.GetId()),
@@ -263,11 +360,9 @@ func (pathCtx *PathContextCl) GetPathNodesBetween(fromDist, toDist int) ([]m3pat
 		return nil, err
 	}
 	pathNodes := pMsg.GetPathNodes()
-	pathCtx.maxDist = int(pMsg.MaxDist)
+	pathCtx.checkMaxDist(int(pMsg.MaxDist))
 	Log.Infof("Received back %d path nodes back from %d to %d for %d", len(pathNodes), fromDist, toDist, pathCtx.GetId())
-	for _, pMsg := range pathNodes {
-		pathCtx.addPathNodeFromMsg(pMsg)
-	}
+	pathCtx.addFetchedPathNodes(pathNodes, fromDist, toDist)
 	return pathCtx.mapGetPathNodesBetween(fromDist, toDist), nil
 }
 
@@ -319,6 +414,19 @@ func (pn *PathNodeCl) String() string {
 	return fmt.Sprintf("PNCL%d-%d-%d-%d-%v", pn.id, pn.pathCtx.id, pn.d, pn.trioDetails.GetId(), pn.point)
 }
 
+func (pn *PathNodeCl) toMsg() *m3api.PathNodeMsg {
+	res := &m3api.PathNodeMsg{
+		PathNodeId:        int64(pn.id),
+		Point:             m3api.PointToPointMsg(pn.point),
+		D:                 int32(pn.d),
+		TrioId:            int32(pn.trioDetails.GetId()),
+		ConnectionMask:    uint32(pn.connectionMask),
+		LinkedPathNodeIds: make([]int64, m3path.NbConnections),
+	}
+	copy(res.LinkedPathNodeIds, pn.linkNodes[:])
+	return res
+}
+
 func (pn *PathNodeCl) GetId() m3path.PathNodeId {
 	return pn.id
 }
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	// Calls go through
+	BreakerClosed BreakerState = iota
+	// Too many failures, calls fail right away until the cooldown is over
+	BreakerOpen
+	// Cooldown over, a single call probes the backend
+	BreakerHalfOpen
+)
+
+var breakerStateNames = [...]string{"CLOSED", "OPEN", "HALF_OPEN"}
+
+func (state BreakerState) String() string {
+	return breakerStateNames[state]
+}
+
+// Retries of a call failing on connection or 5xx errors, the backoff doubles on each attempt up to MaxBackoff
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+/*
+Circuit breaker shared by all the calls of a connection.
+It opens after threshold consecutive connection or 5xx failures, so clients stop hammering a backend restarting.
+*/
+type CircuitBreaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+/***************************************************************/
+// RetryPolicy Functions
+/***************************************************************/
+
+// Wait before the retry number attempt starting at 0, between half and the full doubled backoff
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	d := policy.InitialBackoff
+	for i := 0; i < attempt && d < policy.MaxBackoff; i++ {
+		d *= 2
+	}
+	if policy.MaxBackoff > 0 && d > policy.MaxBackoff {
+		d = policy.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Jitter so clients restarted together do not retry together
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Only calls without side effects can be sent again
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+/***************************************************************/
+// CircuitBreaker Functions
+/***************************************************************/
+
+func MakeCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed, now: time.Now}
+}
+
+func (cb *CircuitBreaker) GetState() BreakerState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.checkCooldown()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) checkCooldown() {
+	if cb.state == BreakerOpen && cb.now().Sub(cb.openedAt) >= cb.cooldown {
+		cb.state = BreakerHalfOpen
+		cb.probing = false
+	}
+}
+
+// Error with ErrUnavailable code if the call should not be sent
+func (cb *CircuitBreaker) allow(method string, uri string) error {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.checkCooldown()
+	switch cb.state {
+	case BreakerOpen:
+		return m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "circuit breaker open after %d failures, not sending %s:%s for %v",
+			cb.failures, method, uri, cb.cooldown-cb.now().Sub(cb.openedAt))
+	case BreakerHalfOpen:
+		if cb.probing {
+			return m3util.MakeQsmCodeErrorf(m3util.ErrUnavailable, "circuit breaker waiting for a probe call, not sending %s:%s", method, uri)
+		}
+		cb.probing = true
+	}
+	return nil
+}
+
+// The backend answered, even with a client error
+func (cb *CircuitBreaker) success() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if cb.state != BreakerClosed {
+		Log.Infof("Backend answering again, closing circuit breaker")
+	}
+	cb.state = BreakerClosed
+	cb.failures = 0
+	cb.probing = false
+}
+
+func (cb *CircuitBreaker) failure() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.failures++
+	cb.probing = false
+	if cb.state == BreakerHalfOpen || (cb.state == BreakerClosed && cb.threshold > 0 && cb.failures >= cb.threshold) {
+		Log.Warnf("Opening circuit breaker for %v after %d failures", cb.cooldown, cb.failures)
+		cb.state = BreakerOpen
+		cb.openedAt = cb.now()
+	}
+}
+
+/***************************************************************/
+// ClientConnection Functions for retries
+/***************************************************************/
+
+func (cl *ClientConnection) SetRetryPolicy(policy RetryPolicy) {
+	cl.retryPolicy = policy
+}
+
+func (cl *ClientConnection) GetRetryPolicy() RetryPolicy {
+	return cl.retryPolicy
+}
+
+func (cl *ClientConnection) SetTimeout(timeout time.Duration) {
+	cl.timeout = timeout
+}
+
+func (cl *ClientConnection) GetBreaker() *CircuitBreaker {
+	return cl.breaker
+}
+
+/*
+Run call through the circuit breaker and retry it while it says so and the method is idempotent.
+Call returns true with its error on connection and 5xx errors.
+*/
+func (cl *ClientConnection) withRetries(method string, uri string, call func() (bool, error)) error {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		err := cl.breaker.allow(method, uri)
+		if err != nil {
+			if lastErr != nil {
+				// The failures of this call opened it
+				return lastErr
+			}
+			return err
+		}
+		retry, err := call()
+		if err == nil || !retry {
+			cl.breaker.success()
+			return err
+		}
+		cl.breaker.failure()
+		lastErr = err
+		if !isIdempotent(method) || attempt >= cl.retryPolicy.MaxRetries {
+			return err
+		}
+		wait := cl.retryPolicy.backoff(attempt)
+		Log.Infof("Retrying %s:%s in %v after attempt %d failed with: %v", method, uri, wait, attempt+1, err)
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func makeRetryTestConnection(url string, maxRetries int, threshold int) *ClientConnection {
+	return &ClientConnection{
+		backendRootURL: url + "/",
+		envId:          1,
+		timeout:        time.Second,
+		retryPolicy:    RetryPolicy{MaxRetries: maxRetries, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond},
+		breaker:        MakeCircuitBreaker(threshold, time.Minute),
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, expected := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		expected *= time.Millisecond
+		d := policy.backoff(attempt)
+		assert.True(t, d >= expected/2 && d <= expected, "attempt %d waits %v not in %v", attempt, d, expected)
+	}
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.backoff(3))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	cb := MakeCircuitBreaker(2, time.Minute)
+	cb.now = func() time.Time { return now }
+
+	assert.NoError(t, cb.allow("GET", "a"))
+	cb.failure()
+	assert.Equal(t, BreakerClosed, cb.GetState())
+	cb.success()
+	cb.failure()
+	assert.Equal(t, BreakerClosed, cb.GetState())
+	cb.failure()
+	assert.Equal(t, BreakerOpen, cb.GetState())
+	err := cb.allow("GET", "a")
+	assert.Error(t, err)
+	assert.Equal(t, m3util.ErrUnavailable, m3util.GetQsmErrorCode(err))
+
+	// A single probe after the cooldown, failing reopens it
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, cb.GetState())
+	assert.NoError(t, cb.allow("GET", "a"))
+	assert.Error(t, cb.allow("GET", "b"))
+	cb.failure()
+	assert.Equal(t, BreakerOpen, cb.GetState())
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, cb.allow("GET", "a"))
+	cb.success()
+	assert.Equal(t, BreakerClosed, cb.GetState())
+	assert.NoError(t, cb.allow("GET", "b"))
+}
+
+func TestExecReqRetries(t *testing.T) {
+	var nbCalls, nbFailures int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&nbCalls, 1)
+		switch r.URL.Path {
+		case "/flaky":
+			if atomic.AddInt32(&nbFailures, -1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprint(w, "done")
+	}))
+	defer srv.Close()
+	cl := makeRetryTestConnection(srv.URL, 2, 10)
+
+	// GET retried until it works
+	atomic.StoreInt32(&nbFailures, 2)
+	response, err := cl.ExecReq(http.MethodGet, "flaky", nil, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", response)
+	assert.Equal(t, int32(3), atomic.SwapInt32(&nbCalls, 0))
+
+	// Not more than the max retries
+	atomic.StoreInt32(&nbFailures, 5)
+	_, err = cl.ExecReq(http.MethodGet, "flaky", nil, nil, false)
+	assert.Error(t, err)
+	assert.Equal(t, m3util.ErrUnavailable, m3util.GetQsmErrorCode(err))
+	assert.Equal(t, int32(3), atomic.SwapInt32(&nbCalls, 0))
+
+	// No retries for POST and client errors
+	atomic.StoreInt32(&nbFailures, 1)
+	_, err = cl.ExecReq(http.MethodPost, "flaky", nil, nil, false)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.SwapInt32(&nbCalls, 0))
+	_, err = cl.ExecReq(http.MethodGet, "missing", nil, nil, false)
+	assert.Equal(t, m3util.ErrNotFound, m3util.GetQsmErrorCode(err))
+	assert.Equal(t, int32(1), atomic.SwapInt32(&nbCalls, 0))
+
+	// Per call timeout
+	response, err = cl.ExecReqWithTimeout(50*time.Millisecond, http.MethodPost, "slow", nil, nil, false)
+	assert.Error(t, err)
+	assert.Equal(t, m3util.ErrUnavailable, m3util.GetQsmErrorCode(err))
+	assert.Equal(t, ExecFailed, response)
+	response, err = cl.ExecReq(http.MethodPost, "slow", nil, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", response)
+}
+
+func TestExecReqBreaker(t *testing.T) {
+	var nbCalls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&nbCalls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	cl := makeRetryTestConnection(srv.URL, 5, 3)
+
+	// The failures of the call open the breaker, its own error is returned
+	_, err := cl.ExecReq(http.MethodGet, "down", nil, nil, false)
+	assert.Equal(t, http.StatusBadGateway, err.(*ApiError).Status)
+	assert.Equal(t, int32(3), atomic.SwapInt32(&nbCalls, 0))
+	assert.Equal(t, BreakerOpen, cl.GetBreaker().GetState())
+
+	_, err = cl.ExecReq(http.MethodGet, "down", nil, nil, false)
+	assert.Equal(t, m3util.ErrUnavailable, m3util.GetQsmErrorCode(err))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&nbCalls))
+
+	// Health checks go through the breaker too
+	_, _, err = cl.GetHealthStatus("healthz")
+	assert.Error(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&nbCalls))
+	assert.False(t, cl.CheckServerUp())
+}
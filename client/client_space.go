@@ -255,6 +255,20 @@ func (space *SpaceCl) GetEvent(id m3space.EventId) m3space.EventIfc {
 }
 
 func (space *SpaceCl) GetActiveEventsAt(atTime m3space.DistAndTime) []m3space.EventIfc {
+	res, err := space.FindEvents(atTime)
+	if err != nil {
+		Log.Error(err)
+		return nil
+	}
+	if len(res) == 0 {
+		Log.Infof("Did not find a single event at time %d for %s", atTime, space.String())
+		return nil
+	}
+	return res
+}
+
+// The events active at the time, or all the events of the space for a negative time
+func (space *SpaceCl) FindEvents(atTime m3space.DistAndTime) ([]m3space.EventIfc, error) {
 	uri := "event"
 	reqMsg := &m3api.FindEventsMsg{
 		EventId: int32(-1),
@@ -264,12 +278,7 @@ func (space *SpaceCl) GetActiveEventsAt(atTime m3space.DistAndTime) []m3space.Ev
 	resMsg := new(m3api.EventListMsg)
 	_, err := space.SpaceData.Env.clConn.ExecReq("GET", uri, reqMsg, resMsg, true)
 	if err != nil {
-		Log.Error(err)
-		return nil
-	}
-	if len(resMsg.Events) <= 0 {
-		Log.Infof("Did not find a single event at time %d for %s", atTime, space.String())
-		return nil
+		return nil, err
 	}
 
 	res := make([]m3space.EventIfc, len(resMsg.Events))
@@ -278,30 +287,42 @@ func (space *SpaceCl) GetActiveEventsAt(atTime m3space.DistAndTime) []m3space.Ev
 	for i, evtMsg := range resMsg.Events {
 		res[i], err = space.createEventFromMsg(pathData, pointData, evtMsg)
 		if err != nil {
-			Log.Error(err)
-			return nil
+			return nil, err
 		}
 	}
-	return res
+	return res, nil
 }
 
 func (space *SpaceCl) GetSpaceTimeAt(atTime m3space.DistAndTime) m3space.SpaceTimeIfc {
+	st, err := space.GetFilteredSpaceTimeAt(atTime, 0, 0xff)
+	if err != nil {
+		Log.Error(err)
+		return nil
+	}
+	return st
+}
+
+// The space time with only the nodes of at least minNbEvents events or with a color in colorMask
+func (space *SpaceCl) GetFilteredSpaceTimeAt(atTime m3space.DistAndTime, minNbEvents int, colorMask uint8) (*SpaceTimeCl, error) {
 	uri := "space-time"
 
 	reqMsg := &m3api.SpaceTimeRequestMsg{
 		SpaceId:           int32(space.GetId()),
 		CurrentTime:       int32(atTime),
-		MinNbEventsFilter: 0,
-		ColorMaskFilter:   0xffffffff,
+		MinNbEventsFilter: int32(minNbEvents),
+		ColorMaskFilter:   uint32(colorMask),
 	}
 	resMsg := new(m3api.SpaceTimeResponseMsg)
 	_, err := space.SpaceData.Env.clConn.ExecReq("GET", uri, reqMsg, resMsg, false)
 	if err != nil {
-		Log.Error(err)
-		return nil
+		return nil, err
 	}
 
-	return createSpaceTimeFromMsg(space, resMsg)
+	st := createSpaceTimeFromMsg(space, resMsg)
+	if st == nil {
+		return nil, m3util.MakeQsmErrorf("could not read the space time of %s at %d", space.String(), atTime)
+	}
+	return st, nil
 }
 
 func (space *SpaceCl) CreateEvent(growthType m3point.GrowthType, growthIndex int, growthOffset int,
@@ -244,3 +244,21 @@ func (stn *SpaceTimeNodeCl) VisitConnections(visitConn func(evtNode *SpaceTimeNo
 		}
 	}
 }
+
+func (stn *SpaceTimeNodeCl) GetNodeEventDetails() []m3space.NodeEventDetails {
+	res := make([]m3space.NodeEventDetails, len(stn.nodeEvents))
+	for i, nodeEvt := range stn.nodeEvents {
+		trioIdx := m3point.NilTrioIndex
+		if nodeEvt.trioDetails != nil {
+			trioIdx = nodeEvt.trioDetails.GetId()
+		}
+		res[i] = m3space.NodeEventDetails{
+			EventId:        nodeEvt.eventId,
+			CreationTime:   nodeEvt.creationTime,
+			D:              nodeEvt.d,
+			TrioIndex:      trioIdx,
+			ConnectionMask: nodeEvt.connectionMask,
+		}
+	}
+	return res
+}
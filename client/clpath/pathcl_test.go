@@ -27,7 +27,10 @@ func TestClientAllPathCtx(t *testing.T) {
 	Log.SetAssert(true)
 	m3util.SetToTestMode()
 
-	env := client.GetInitializedApiEnv(m3util.TestClientEnv)
+	env, err := client.GetInitializedApiEnv(m3util.TestClientEnv)
+	if !assert.NoError(t, err) {
+		return
+	}
 
 	for _, growthType := range m3point.GetAllGrowthTypes() {
 		if !runForPathCtxType(t, env, 25, growthType, 0.1) {
@@ -69,7 +72,7 @@ func runPathContext(pathCtx m3path.PathContext, until int) (int, int, error) {
 	}
 	for d := 0; d < until; d++ {
 		if LogDataTest.IsInfo() {
-			predictedIntLen := m3path.CalculatePredictedSize(pathCtx.GetGrowthType(), d)
+			predictedIntLen := m3path.CalculatePredictedSize(pathCtx.GetGrowthCtx(), d)
 			finalLen := pathCtx.GetNumberOfNodesAt(d)
 			errorBar := math.Abs(float64(finalLen-predictedIntLen)) / float64(predictedIntLen)
 			// If final length way too small => error
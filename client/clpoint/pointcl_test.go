@@ -15,7 +15,10 @@ func TestDisplayPathBuilders(t *testing.T) {
 	Log.SetAssert(true)
 	m3util.SetToTestMode()
 
-	env := client.GetInitializedApiEnv(m3util.TestClientEnv)
+	env, err := client.GetInitializedApiEnv(m3util.TestClientEnv)
+	if !assert.NoError(t, err) {
+		return
+	}
 	ppd := client.GetClientPointPackData(env)
 	assert.Equal(t, m3point.TotalNbContexts, len(ppd.AllGrowthContexts))
 	growthCtx := ppd.GetGrowthContextByTypeAndIndex(m3point.GrowthType(8), 0)
@@ -26,8 +26,11 @@ func getSpaceTestEnv() m3util.QsmEnvironment {
 		return spaceEnv
 	}
 	m3util.SetToTestMode()
-	spaceEnv := client.GetOrCreateInitializedApiEnv(m3util.SpaceClientTempEnv, false, true)
-	return spaceEnv
+	env, err := client.GetOrCreateInitializedApiEnv(m3util.SpaceClientTempEnv, false, true)
+	if err != nil {
+		Log.Fatal(err)
+	}
+	return env
 }
 
 func createNewSpace(t *testing.T, spaceName string, threshold m3space.DistAndTime) *client.SpaceCl {
@@ -1,18 +1,91 @@
 package config
 
 import (
-	"github.com/freddy33/qsm-go/m3util"
 	_ "github.com/joho/godotenv/autoload"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// QSM_CACHE_DIR value disabling the on disk cache
+const CacheDisabled = "off"
+
+const BackendRootURLKey = "BACKEND_ROOT_URL"
+
+const (
+	DefaultRequestTimeout   = 20 * time.Second
+	DefaultMaxRetries       = 3
+	DefaultRetryBackoff     = 500 * time.Millisecond
+	DefaultMaxRetryBackoff  = 10 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
 )
 
 type Config struct {
 	BackendRootURL string
+	// Optional bearer token when the backend has an auth file
+	ApiToken string
+	// Root of the on disk cache of the backend data, empty if disabled
+	CacheDir string
+	// Timeout of each attempt of a REST call
+	RequestTimeout time.Duration
+	// Retries of idempotent calls failing on connection or 5xx errors, with a backoff doubling up to the max
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// Consecutive failures opening the circuit breaker, and how long it stays open
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
 func NewConfig() Config {
 	config := Config{
-		BackendRootURL: m3util.GetCompulsoryEnv("BACKEND_ROOT_URL"),
+		// Checked when the env is created, so a missing one is an error and not an exit
+		BackendRootURL: os.Getenv(BackendRootURLKey),
+		ApiToken:       os.Getenv("QSM_API_TOKEN"),
+		CacheDir:       getCacheDir(),
+
+		RequestTimeout:   getEnvDuration("QSM_CLIENT_TIMEOUT", DefaultRequestTimeout),
+		MaxRetries:       getEnvInt("QSM_CLIENT_RETRIES", DefaultMaxRetries),
+		RetryBackoff:     getEnvDuration("QSM_CLIENT_BACKOFF", DefaultRetryBackoff),
+		MaxRetryBackoff:  getEnvDuration("QSM_CLIENT_MAX_BACKOFF", DefaultMaxRetryBackoff),
+		BreakerThreshold: getEnvInt("QSM_CLIENT_BREAKER_THRESHOLD", DefaultBreakerThreshold),
+		BreakerCooldown:  getEnvDuration("QSM_CLIENT_BREAKER_COOLDOWN", DefaultBreakerCooldown),
 	}
 
 	return config
 }
+
+// QSM_CACHE_DIR if set, otherwise qsm-go in the user cache dir
+func getCacheDir() string {
+	dir := os.Getenv("QSM_CACHE_DIR")
+	if dir == CacheDisabled {
+		return ""
+	}
+	if dir != "" {
+		return dir
+	}
+	userDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(userDir, "qsm-go")
+}
+
+// Missing or invalid values use the default
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	i, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || i < 0 {
+		return defaultValue
+	}
+	return i
+}
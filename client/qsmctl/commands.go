@@ -0,0 +1,591 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/freddy33/qsm-go/client"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3path"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type command struct {
+	usage string
+	run   func(ctx *cmdContext, args []string) (*table, error)
+}
+
+type cmdContext struct {
+	envId m3util.QsmEnvID
+}
+
+var commands = map[string]map[string]command{
+	"env": {
+		"list": {"", envList},
+		"init": {"", envInit},
+		"drop": {"-confirm qsm<env id>", envDrop},
+	},
+	"path": {
+		"list":   {"", pathList},
+		"create": {"<growth type> <growth index> <growth offset>", pathCreate},
+		"extend": {"<path ctx id> <dist> [-step 1]", pathExtend},
+		"nodes":  {"<path ctx id> [-from 0] [-to max dist]", pathNodes},
+	},
+	"space": {
+		"list":   {"", spaceList},
+		"create": {"<name> [-threshold 3] [-max-trios 2] [-max-nodes 4]", spaceCreate},
+		"delete": {"<space id or name>", spaceDelete},
+	},
+	"event": {
+		"list":   {"<space id or name> [-at -1 for all]", eventList},
+		"create": {"<space id or name> [-type 8] [-index 0] [-offset 0] [-time 0] [-center 0,0,0] [-color red]", eventCreate},
+	},
+	"spacetime": {
+		"query": {"<space id or name> [-time 0] [-min-events 0] [-colors red,blue or mask] [-nodes]", spaceTimeQuery},
+	},
+}
+
+var colorNames = map[m3space.EventColor]string{
+	m3space.RedEvent:    "red",
+	m3space.GreenEvent:  "green",
+	m3space.BlueEvent:   "blue",
+	m3space.YellowEvent: "yellow",
+}
+
+/***************************************************************/
+// Arguments Functions
+/***************************************************************/
+
+// Parse the flags wherever they are and return exactly the named positional arguments
+func parseArgs(flags *flag.FlagSet, args []string, names ...string) ([]string, error) {
+	positional := make([]string, 0, len(names))
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != len(names) {
+		return nil, fmt.Errorf("%s needs the arguments <%s> but got %v", flags.Name(), strings.Join(names, "> <"), positional)
+	}
+	return positional, nil
+}
+
+func parseInt(name string, value string) (int, error) {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s should be a number not %q", name, value)
+	}
+	return i, nil
+}
+
+// A point written x,y,z
+func parsePoint(value string) (m3point.Point, error) {
+	coords := strings.Split(value, ",")
+	if len(coords) != 3 {
+		return m3point.Origin, fmt.Errorf("point should be x,y,z not %q", value)
+	}
+	var p m3point.Point
+	for i, c := range coords {
+		v, err := parseInt("point coordinate", strings.TrimSpace(c))
+		if err != nil {
+			return m3point.Origin, err
+		}
+		p[i] = m3point.CInt(v)
+	}
+	return p, nil
+}
+
+func parseColor(value string) (m3space.EventColor, error) {
+	for color, name := range colorNames {
+		if strings.EqualFold(name, value) {
+			return color, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown color %q, should be one of red, green, blue or yellow", value)
+}
+
+// A mask written as a number or a comma separated list of color names
+func parseColorMask(value string) (uint8, error) {
+	mask, err := strconv.ParseUint(value, 0, 8)
+	if err == nil {
+		return uint8(mask), nil
+	}
+	res := uint8(0)
+	for _, name := range strings.Split(value, ",") {
+		color, err := parseColor(strings.TrimSpace(name))
+		if err != nil {
+			return 0, err
+		}
+		res |= uint8(color)
+	}
+	return res, nil
+}
+
+func colorMaskNames(mask uint8) string {
+	names := make([]string, 0, len(m3space.AllColors))
+	for _, color := range m3space.AllColors {
+		if mask&uint8(color) != 0 {
+			names = append(names, colorNames[color])
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+/***************************************************************/
+// Environment Functions
+/***************************************************************/
+
+// Connected to the backend without loading anything
+func (ctx *cmdContext) apiEnv() (*client.QsmApiEnvironment, error) {
+	return client.GetApiEnv(ctx.envId)
+}
+
+// Connected with the point, path and space data loaded
+func (ctx *cmdContext) loadedEnv() (*client.QsmApiEnvironment, error) {
+	return client.GetOrCreateInitializedApiEnv(ctx.envId, false, false)
+}
+
+func (ctx *cmdContext) findSpace(idOrName string) (*client.SpaceCl, error) {
+	env, err := ctx.loadedEnv()
+	if err != nil {
+		return nil, err
+	}
+	spaceData := client.GetClientSpacePackData(env)
+	err = spaceData.LoadAllSpaces()
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.Atoi(idOrName)
+	for _, space := range spaceData.GetAllSpaces() {
+		if (err == nil && space.GetId() == id) || space.GetName() == idOrName {
+			return space.(*client.SpaceCl), nil
+		}
+	}
+	return nil, m3util.MakeQsmCodeErrorf(m3util.ErrNotFound, "no space %q in env %d", idOrName, ctx.envId)
+}
+
+func envList(ctx *cmdContext, args []string) (*table, error) {
+	_, err := parseArgs(flag.NewFlagSet("env list", flag.ContinueOnError), args)
+	if err != nil {
+		return nil, err
+	}
+	env, err := ctx.apiEnv()
+	if err != nil {
+		return nil, err
+	}
+	envs, err := env.ListEnvs()
+	if err != nil {
+		return nil, err
+	}
+	res := makeTable("env_id", "schema_name", "schema_size", "schema_size_percent")
+	for _, e := range envs {
+		res.addRow(e.GetEnvId(), e.GetSchemaName(), e.GetSchemaSize(), e.GetSchemaSizePercent())
+	}
+	return res, nil
+}
+
+func envInit(ctx *cmdContext, args []string) (*table, error) {
+	_, err := parseArgs(flag.NewFlagSet("env init", flag.ContinueOnError), args)
+	if err != nil {
+		return nil, err
+	}
+	env, err := ctx.apiEnv()
+	if err != nil {
+		return nil, err
+	}
+	err = env.InitEnv()
+	if err != nil {
+		return nil, err
+	}
+	res := makeTable("env_id", "status")
+	res.addRow(int(ctx.envId), "initialized")
+	return res, nil
+}
+
+func envDrop(ctx *cmdContext, args []string) (*table, error) {
+	flags := flag.NewFlagSet("env drop", flag.ContinueOnError)
+	confirm := flags.String("confirm", "", "The full schema name of the env to drop")
+	_, err := parseArgs(flags, args)
+	if err != nil {
+		return nil, err
+	}
+	schemaName := "qsm" + ctx.envId.String()
+	if *confirm != schemaName {
+		return nil, fmt.Errorf("dropping env %d needs the full schema name with -confirm %s", ctx.envId, schemaName)
+	}
+	env, err := ctx.apiEnv()
+	if err != nil {
+		return nil, err
+	}
+	err = env.DropEnv()
+	if err != nil {
+		return nil, err
+	}
+	res := makeTable("env_id", "status")
+	res.addRow(int(ctx.envId), "dropped")
+	return res, nil
+}
+
+/***************************************************************/
+// Path Context Functions
+/***************************************************************/
+
+func pathCtxTable() *table {
+	return makeTable("path_ctx_id", "growth_type", "growth_index", "growth_offset", "max_dist")
+}
+
+func addPathCtxRow(res *table, pathCtx m3path.PathContext) {
+	res.addRow(int(pathCtx.GetId()), int(pathCtx.GetGrowthType()), pathCtx.GetGrowthIndex(), pathCtx.GetGrowthOffset(), pathCtx.GetMaxDist())
+}
+
+func (ctx *cmdContext) findPathCtx(idStr string) (m3path.PathContext, error) {
+	id, err := parseInt("path ctx id", idStr)
+	if err != nil {
+		return nil, err
+	}
+	env, err := ctx.loadedEnv()
+	if err != nil {
+		return nil, err
+	}
+	pathCtx := client.GetClientPathPackData(env).GetPathCtx(m3path.PathContextId(id))
+	if pathCtx == nil {
+		return nil, m3util.MakeQsmCodeErrorf(m3util.ErrNotFound, "could not load path context %d of env %d", id, ctx.envId)
+	}
+	return pathCtx, nil
+}
+
+func pathList(ctx *cmdContext, args []string) (*table, error) {
+	_, err := parseArgs(flag.NewFlagSet("path list", flag.ContinueOnError), args)
+	if err != nil {
+		return nil, err
+	}
+	env, err := ctx.loadedEnv()
+	if err != nil {
+		return nil, err
+	}
+	pathContexts, err := client.GetClientPathPackData(env).GetAllPathContexts()
+	if err != nil {
+		return nil, err
+	}
+	res := pathCtxTable()
+	for _, pathCtx := range pathContexts {
+		addPathCtxRow(res, pathCtx)
+	}
+	return res, nil
+}
+
+func pathCreate(ctx *cmdContext, args []string) (*table, error) {
+	positional, err := parseArgs(flag.NewFlagSet("path create", flag.ContinueOnError), args, "growth type", "growth index", "growth offset")
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int, len(positional))
+	for i, arg := range positional {
+		values[i], err = parseInt([]string{"growth type", "growth index", "growth offset"}[i], arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	env, err := ctx.loadedEnv()
+	if err != nil {
+		return nil, err
+	}
+	pathCtx, err := client.GetClientPathPackData(env).GetPathCtxFromAttributes(m3point.GrowthType(values[0]), values[1], values[2])
+	if err != nil {
+		return nil, err
+	}
+	res := pathCtxTable()
+	addPathCtxRow(res, pathCtx)
+	return res, nil
+}
+
+// Increase the max dist up to dist by steps, timing each call like the inc_to of the old api script
+func pathExtend(ctx *cmdContext, args []string) (*table, error) {
+	flags := flag.NewFlagSet("path extend", flag.ContinueOnError)
+	step := flags.Int("step", 1, "The max dist increase of each call")
+	positional, err := parseArgs(flags, args, "path ctx id", "dist")
+	if err != nil {
+		return nil, err
+	}
+	if *step < 1 {
+		return nil, fmt.Errorf("step should be positive not %d", *step)
+	}
+	dist, err := parseInt("dist", positional[1])
+	if err != nil {
+		return nil, err
+	}
+	pathCtx, err := ctx.findPathCtx(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	res := makeTable("path_ctx_id", "dist", "duration_ms", "max_dist", "nb_path_nodes")
+	for d := pathCtx.GetMaxDist(); d < dist; {
+		d += *step
+		if d > dist {
+			d = dist
+		}
+		start := time.Now()
+		err = pathCtx.RequestNewMaxDist(d)
+		if err != nil {
+			return res, err
+		}
+		res.addRow(int(pathCtx.GetId()), d, time.Since(start).Milliseconds(), pathCtx.GetMaxDist(), pathCtx.GetNumberOfNodesAt(d))
+	}
+	return res, nil
+}
+
+func pathNodes(ctx *cmdContext, args []string) (*table, error) {
+	flags := flag.NewFlagSet("path nodes", flag.ContinueOnError)
+	from := flags.Int("from", 0, "The first dist")
+	to := flags.Int("to", -1, "The last dist, the max dist of the path context if negative")
+	positional, err := parseArgs(flags, args, "path ctx id")
+	if err != nil {
+		return nil, err
+	}
+	pathCtx, err := ctx.findPathCtx(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	toDist := *to
+	if toDist < 0 {
+		toDist = pathCtx.GetMaxDist()
+	}
+	pathNodes, err := pathCtx.GetPathNodesBetween(*from, toDist)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pathNodes, func(i, j int) bool {
+		if pathNodes[i].D() != pathNodes[j].D() {
+			return pathNodes[i].D() < pathNodes[j].D()
+		}
+		return pathNodes[i].GetId() < pathNodes[j].GetId()
+	})
+	res := makeTable("path_node_id", "d", "x", "y", "z", "trio_id")
+	for _, pn := range pathNodes {
+		p := pn.P()
+		res.addRow(int64(pn.GetId()), pn.D(), int(p.X()), int(p.Y()), int(p.Z()), int(pn.GetTrioIndex()))
+	}
+	return res, nil
+}
+
+/***************************************************************/
+// Space and Event Functions
+/***************************************************************/
+
+func spaceTable() *table {
+	return makeTable("space_id", "name", "active_threshold", "max_trios", "max_nodes", "max_time", "max_coord")
+}
+
+func addSpaceRow(res *table, space m3space.SpaceIfc) {
+	res.addRow(space.GetId(), space.GetName(), int(space.GetActiveThreshold()), space.GetMaxTriosPerPoint(),
+		space.GetMaxNodesPerPoint(), int(space.GetMaxTime()), int(space.GetMaxCoord()))
+}
+
+func spaceList(ctx *cmdContext, args []string) (*table, error) {
+	_, err := parseArgs(flag.NewFlagSet("space list", flag.ContinueOnError), args)
+	if err != nil {
+		return nil, err
+	}
+	env, err := ctx.loadedEnv()
+	if err != nil {
+		return nil, err
+	}
+	spaceData := client.GetClientSpacePackData(env)
+	err = spaceData.LoadAllSpaces()
+	if err != nil {
+		return nil, err
+	}
+	spaces := spaceData.GetAllSpaces()
+	sort.Slice(spaces, func(i, j int) bool {
+		return spaces[i].GetId() < spaces[j].GetId()
+	})
+	res := spaceTable()
+	for _, space := range spaces {
+		addSpaceRow(res, space)
+	}
+	return res, nil
+}
+
+func spaceCreate(ctx *cmdContext, args []string) (*table, error) {
+	flags := flag.NewFlagSet("space create", flag.ContinueOnError)
+	threshold := flags.Int("threshold", 3, "The time path nodes stay active")
+	maxTrios := flags.Int("max-trios", 2, "The max number of trios per point")
+	maxNodes := flags.Int("max-nodes", 4, "The max number of path nodes per point")
+	positional, err := parseArgs(flags, args, "name")
+	if err != nil {
+		return nil, err
+	}
+	env, err := ctx.loadedEnv()
+	if err != nil {
+		return nil, err
+	}
+	space, err := client.GetClientSpacePackData(env).CreateSpace(positional[0], m3space.DistAndTime(*threshold), *maxTrios, *maxNodes)
+	if err != nil {
+		return nil, err
+	}
+	res := spaceTable()
+	addSpaceRow(res, space)
+	return res, nil
+}
+
+func spaceDelete(ctx *cmdContext, args []string) (*table, error) {
+	positional, err := parseArgs(flag.NewFlagSet("space delete", flag.ContinueOnError), args, "space id or name")
+	if err != nil {
+		return nil, err
+	}
+	space, err := ctx.findSpace(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	_, err = space.SpaceData.DeleteSpace(space.GetId(), space.GetName())
+	if err != nil {
+		return nil, err
+	}
+	res := makeTable("space_id", "name", "status")
+	res.addRow(space.GetId(), space.GetName(), "deleted")
+	return res, nil
+}
+
+func eventTable() *table {
+	return makeTable("event_id", "color", "growth_type", "growth_index", "growth_offset", "creation_time", "x", "y", "z")
+}
+
+func addEventRow(res *table, evt m3space.EventIfc) error {
+	center, err := evt.GetCenterNode().GetPoint()
+	if err != nil {
+		return err
+	}
+	pathCtx := evt.GetPathContext()
+	res.addRow(int(evt.GetId()), colorNames[evt.GetColor()], int(pathCtx.GetGrowthType()), pathCtx.GetGrowthIndex(),
+		pathCtx.GetGrowthOffset(), int(evt.GetCreationTime()), int(center.X()), int(center.Y()), int(center.Z()))
+	return nil
+}
+
+func eventList(ctx *cmdContext, args []string) (*table, error) {
+	flags := flag.NewFlagSet("event list", flag.ContinueOnError)
+	at := flags.Int("at", -1, "Only the events active at this time, all of them if negative")
+	positional, err := parseArgs(flags, args, "space id or name")
+	if err != nil {
+		return nil, err
+	}
+	space, err := ctx.findSpace(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	events, err := space.FindEvents(m3space.DistAndTime(*at))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].GetId() < events[j].GetId()
+	})
+	res := eventTable()
+	for _, evt := range events {
+		err = addEventRow(res, evt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func eventCreate(ctx *cmdContext, args []string) (*table, error) {
+	flags := flag.NewFlagSet("event create", flag.ContinueOnError)
+	growthType := flags.Int("type", 8, "The growth type of the event path")
+	growthIndex := flags.Int("index", 0, "The growth index of the event path")
+	growthOffset := flags.Int("offset", 0, "The growth offset of the event path")
+	creationTime := flags.Int("time", 0, "The creation time of the event")
+	centerStr := flags.String("center", "0,0,0", "The center point of the event as x,y,z")
+	colorStr := flags.String("color", "red", "The color of the event: red, green, blue or yellow")
+	positional, err := parseArgs(flags, args, "space id or name")
+	if err != nil {
+		return nil, err
+	}
+	center, err := parsePoint(*centerStr)
+	if err != nil {
+		return nil, err
+	}
+	color, err := parseColor(*colorStr)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ctx.findSpace(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	evt, err := space.CreateEvent(m3point.GrowthType(*growthType), *growthIndex, *growthOffset,
+		m3space.DistAndTime(*creationTime), center, color)
+	if err != nil {
+		return nil, err
+	}
+	res := eventTable()
+	err = addEventRow(res, evt)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+/***************************************************************/
+// Space Time Functions
+/***************************************************************/
+
+type stNodeCollector []m3space.SpaceTimeNodeIfc
+
+func (nodes *stNodeCollector) VisitNode(node m3space.SpaceTimeNodeIfc) {
+	*nodes = append(*nodes, node)
+}
+
+func spaceTimeQuery(ctx *cmdContext, args []string) (*table, error) {
+	flags := flag.NewFlagSet("spacetime query", flag.ContinueOnError)
+	atTime := flags.Int("time", 0, "The current time of the space time")
+	minEvents := flags.Int("min-events", 0, "Only the nodes of at least this number of events")
+	colorsStr := flags.String("colors", "255", "Only the nodes with one of these colors, a mask or names like red,blue")
+	withNodes := flags.Bool("nodes", false, "List the filtered nodes instead of the summary")
+	positional, err := parseArgs(flags, args, "space id or name")
+	if err != nil {
+		return nil, err
+	}
+	colorMask, err := parseColorMask(*colorsStr)
+	if err != nil {
+		return nil, err
+	}
+	space, err := ctx.findSpace(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	st, err := space.GetFilteredSpaceTimeAt(m3space.DistAndTime(*atTime), *minEvents, colorMask)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(stNodeCollector, 0)
+	st.VisitNodes(&nodes)
+
+	if !*withNodes {
+		res := makeTable("space_id", "time", "nb_active_events", "nb_active_nodes", "nb_filtered_nodes")
+		res.addRow(space.GetId(), int(st.GetCurrentTime()), len(st.GetActiveEvents()), st.GetNbActiveNodes(), len(nodes))
+		return res, nil
+	}
+
+	res := makeTable("point_id", "x", "y", "z", "nb_events", "colors", "has_root", "last_accessed")
+	for _, node := range nodes {
+		p, err := node.GetPoint()
+		if err != nil {
+			return nil, err
+		}
+		res.addRow(int64(node.GetPointId()), int(p.X()), int(p.Y()), int(p.Z()), len(node.GetEventIds()),
+			colorMaskNames(node.GetColorMask()), node.HasRoot(), int(node.GetLastAccessed()))
+	}
+	return res, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"io"
+	"os"
+	"sort"
+)
+
+const defaultBackendRootURL = "http://localhost:3002"
+
+/*
+Command line client of the backend REST API, replacing the curl and jq calls of the old api script.
+Usage: qsmctl [-v] [-env N] [-url root URL] [-token API token] [-o table|json|csv] <group> <command> [args]
+*/
+func main() {
+	// Logs go to stdout with the results, keep only the warnings unless -v
+	m3util.SetLogLevelForAll(m3util.WARN)
+	others := m3util.ReadVerbose()
+	err := run(os.Stdout, others)
+	m3util.CloseAll()
+	if err != nil {
+		if err != flag.ErrHelp {
+			_, _ = fmt.Fprintln(os.Stderr, "ERROR:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(out io.Writer, args []string) error {
+	flags := flag.NewFlagSet("qsmctl", flag.ContinueOnError)
+	flags.Usage = func() { printUsage(flags) }
+	envNumber := flags.Int("env", -1, "The env id, default from "+m3util.QsmEnvNumberKey)
+	rootURL := flags.String("url", "", "The backend root URL, default from BACKEND_ROOT_URL or "+defaultBackendRootURL)
+	token := flags.String("token", "", "The backend API token, default from QSM_API_TOKEN")
+	outputName := flags.String("o", string(TableOutput), "The output format: table, json or csv")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*outputName)
+	if err != nil {
+		return err
+	}
+
+	cmdArgs := flags.Args()
+	if len(cmdArgs) < 2 {
+		printUsage(flags)
+		return fmt.Errorf("need a group and a command")
+	}
+	cmd, ok := commands[cmdArgs[0]][cmdArgs[1]]
+	if !ok {
+		printUsage(flags)
+		return fmt.Errorf("unknown command %q %q", cmdArgs[0], cmdArgs[1])
+	}
+
+	// The client config reads them from the env
+	if *rootURL != "" {
+		m3util.ExitOnError(os.Setenv("BACKEND_ROOT_URL", *rootURL))
+	} else if os.Getenv("BACKEND_ROOT_URL") == "" {
+		m3util.ExitOnError(os.Setenv("BACKEND_ROOT_URL", defaultBackendRootURL))
+	}
+	if *token != "" {
+		m3util.ExitOnError(os.Setenv("QSM_API_TOKEN", *token))
+	}
+	ctx := &cmdContext{envId: m3util.QsmEnvID(*envNumber)}
+	if *envNumber < 0 {
+		ctx.envId = m3util.GetDefaultEnvId()
+	}
+
+	res, err := cmd.run(ctx, cmdArgs[2:])
+	if res != nil {
+		// Steps already done before a failure are still shown
+		writeErr := res.write(out, format)
+		if err == nil {
+			err = writeErr
+		}
+	}
+	return err
+}
+
+func printUsage(flags *flag.FlagSet) {
+	w := flags.Output()
+	_, _ = fmt.Fprintln(w, "Usage: qsmctl [-v] [options] <group> <command> [args]")
+	_, _ = fmt.Fprintln(w, "Options:")
+	flags.PrintDefaults()
+	_, _ = fmt.Fprintln(w, "Commands:")
+	groups := make([]string, 0, len(commands))
+	for group := range commands {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		names := make([]string, 0, len(commands[group]))
+		for name := range commands[group] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			_, _ = fmt.Fprintf(w, "  %s %s %s\n", group, name, commands[group][name].usage)
+		}
+	}
+}
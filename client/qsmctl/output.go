@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+type OutputFormat string
+
+const (
+	TableOutput OutputFormat = "table"
+	JsonOutput  OutputFormat = "json"
+	CsvOutput   OutputFormat = "csv"
+)
+
+var allOutputFormats = []OutputFormat{TableOutput, JsonOutput, CsvOutput}
+
+// The result of a command, one row per item with a value per column
+type table struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+func parseOutputFormat(name string) (OutputFormat, error) {
+	for _, f := range allOutputFormats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q, should be one of %v", name, allOutputFormats)
+}
+
+func makeTable(columns ...string) *table {
+	return &table{columns: columns, rows: make([][]interface{}, 0)}
+}
+
+func (t *table) addRow(values ...interface{}) {
+	if len(values) != len(t.columns) {
+		panic(fmt.Sprintf("row %v does not match the %d columns %v", values, len(t.columns), t.columns))
+	}
+	t.rows = append(t.rows, values)
+}
+
+func (t *table) write(out io.Writer, format OutputFormat) error {
+	switch format {
+	case JsonOutput:
+		return t.writeJson(out)
+	case CsvOutput:
+		return t.writeCsv(out)
+	}
+	return t.writeText(out)
+}
+
+func (t *table) writeText(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for i, c := range t.columns {
+		if i > 0 {
+			_, _ = fmt.Fprint(w, "\t")
+		}
+		_, _ = fmt.Fprint(w, c)
+	}
+	_, _ = fmt.Fprintln(w)
+	for _, row := range t.rows {
+		for i, v := range row {
+			if i > 0 {
+				_, _ = fmt.Fprint(w, "\t")
+			}
+			_, _ = fmt.Fprint(w, v)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+func (t *table) writeCsv(out io.Writer) error {
+	w := csv.NewWriter(out)
+	err := w.Write(t.columns)
+	if err != nil {
+		return err
+	}
+	record := make([]string, len(t.columns))
+	for _, row := range t.rows {
+		for i, v := range row {
+			record[i] = fmt.Sprint(v)
+		}
+		err = w.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// An array of objects keeping the keys in the column order
+func (t *table) writeJson(out io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for r, row := range t.rows {
+		if r > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  {")
+		for i, v := range row {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			key, _ := json.Marshal(t.columns[i])
+			value, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("could not write column %s of row %d as JSON due to %v", t.columns[i], r, err)
+			}
+			buf.Write(key)
+			buf.WriteString(": ")
+			buf.Write(value)
+		}
+		buf.WriteString("}")
+	}
+	if len(t.rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	_, err := out.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func makeOutputTestTable() *table {
+	res := makeTable("id", "name", "ratio", "root")
+	res.addRow(1, "first", 0.5, true)
+	res.addRow(12, "with, comma", float32(2), false)
+	return res
+}
+
+func TestOutputFormats(t *testing.T) {
+	format, err := parseOutputFormat("csv")
+	assert.NoError(t, err)
+	assert.Equal(t, CsvOutput, format)
+	_, err = parseOutputFormat("xml")
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, makeOutputTestTable().write(&buf, TableOutput))
+	assert.Equal(t, "id  name         ratio  root\n"+
+		"1   first        0.5    true\n"+
+		"12  with, comma  2      false\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, makeOutputTestTable().write(&buf, CsvOutput))
+	assert.Equal(t, "id,name,ratio,root\n1,first,0.5,true\n12,\"with, comma\",2,false\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, makeOutputTestTable().write(&buf, JsonOutput))
+	assert.Equal(t, "[\n"+
+		"  {\"id\": 1, \"name\": \"first\", \"ratio\": 0.5, \"root\": true},\n"+
+		"  {\"id\": 12, \"name\": \"with, comma\", \"ratio\": 2, \"root\": false}\n"+
+		"]\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, makeTable("id").write(&buf, JsonOutput))
+	assert.Equal(t, "[]\n", buf.String())
+}
+
+func TestParseArgs(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	threshold := flags.Int("threshold", 3, "")
+	at := flags.Int("at", 0, "")
+
+	positional, err := parseArgs(flags, []string{"-at", "-1", "space1", "-threshold", "5"}, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"space1"}, positional)
+	assert.Equal(t, 5, *threshold)
+	assert.Equal(t, -1, *at)
+
+	_, err = parseArgs(flags, []string{"a", "b"}, "name")
+	assert.Error(t, err)
+	_, err = parseArgs(flags, []string{"-unknown", "a"}, "name")
+	assert.Error(t, err)
+
+	p, err := parsePoint("3, -6,9")
+	assert.NoError(t, err)
+	assert.Equal(t, m3point.Point{3, -6, 9}, p)
+	_, err = parsePoint("3,6")
+	assert.Error(t, err)
+	_, err = parsePoint("3,6,z")
+	assert.Error(t, err)
+
+	color, err := parseColor("Blue")
+	assert.NoError(t, err)
+	assert.Equal(t, m3space.BlueEvent, color)
+	_, err = parseColor("pink")
+	assert.Error(t, err)
+
+	mask, err := parseColorMask("red,yellow")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(9), mask)
+	assert.Equal(t, "red+yellow", colorMaskNames(mask))
+	mask, err = parseColorMask("0x6")
+	assert.NoError(t, err)
+	assert.Equal(t, "green+blue", colorMaskNames(mask))
+	_, err = parseColorMask("300")
+	assert.Error(t, err)
+}
+
+func TestRunBadCommands(t *testing.T) {
+	m3util.SetLogLevelForAll(m3util.WARN)
+	var buf bytes.Buffer
+	for _, args := range [][]string{
+		{"env"},
+		{"env", "unknown"},
+		{"-o", "xml", "env", "list"},
+		{"-env", "1", "env", "drop", "-confirm", "qsm2"},
+		{"-env", "1", "path", "extend", "3"},
+	} {
+		assert.Error(t, run(&buf, args), "args %v", args)
+	}
+	assert.Equal(t, 0, buf.Len())
+}
@@ -134,6 +134,45 @@ func GetEnvironmentWithCreator(envId QsmEnvID, createEnvFunc func(envId QsmEnvID
 	return env
 }
 
+// Like GetEnvironmentWithCreator but the creation can fail, and then nothing is kept for the env id
+func GetOrCreateEnvironment(envId QsmEnvID, createEnvFunc func(envId QsmEnvID) (QsmEnvironment, error)) (QsmEnvironment, error) {
+	createEnvMutex.Lock()
+	defer createEnvMutex.Unlock()
+	env, ok := environments[envId]
+	if ok && env != nil {
+		return env, nil
+	}
+	env, err := createEnvFunc(envId)
+	if err != nil {
+		return nil, err
+	}
+	environments[envId] = env
+	return env, nil
+}
+
+// The environment if it was already created, never creating it
+func GetExistingEnvironment(envId QsmEnvID) (QsmEnvironment, bool) {
+	createEnvMutex.Lock()
+	defer createEnvMutex.Unlock()
+	env, ok := environments[envId]
+	return env, ok && env != nil
+}
+
+// Visit a snapshot of all the environments created
+func RangeEnvironments(visit func(env QsmEnvironment)) {
+	createEnvMutex.Lock()
+	toVisit := make([]QsmEnvironment, 0, len(environments))
+	for _, env := range environments {
+		if env != nil {
+			toVisit = append(toVisit, env)
+		}
+	}
+	createEnvMutex.Unlock()
+	for _, env := range toVisit {
+		visit(env)
+	}
+}
+
 func RemoveEnvFromMap(envId QsmEnvID) {
 	createEnvMutex.Lock()
 	defer createEnvMutex.Unlock()
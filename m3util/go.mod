@@ -1,5 +1,8 @@
 module github.com/freddy33/qsm-go/m3util
 
-require golang.org/x/text v0.3.3
+require (
+	github.com/stretchr/testify v1.3.0
+	golang.org/x/text v0.3.3
+)
 
 go 1.14
@@ -1,11 +1,19 @@
 package m3util
 
 import (
+	"encoding/json"
 	"fmt"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type LogLevel int
@@ -19,34 +27,66 @@ const (
 	FATAL
 )
 
+// Extra key values added to each log line of a logger
+type LogFields map[string]interface{}
+
+// Field name used for the env id of the JSON log lines
+const LogEnvIdField = "env_id"
+
+// Env var selecting the output of the registered loggers at startup, "json" or "text"
+const LogFormatKey = "QSM_LOG_FORMAT"
+
 type BaseLogger struct {
-	log             *log.Logger
-	level           LogLevel
+	log    *log.Logger
+	prefix string
+	// Shared with the loggers created by WithFields
+	level           *LogLevel
 	evaluateAssert  bool
 	ignoreNextError bool
+	// Only registered loggers switch to JSON, data and stat loggers stay raw text
+	structured bool
+	fields     LogFields
+}
+
+// One line of the JSON output
+type jsonLogLine struct {
+	Time    string    `json:"time"`
+	Level   string    `json:"level"`
+	Logger  string    `json:"logger"`
+	EnvId   QsmEnvID  `json:"env_id"`
+	Message string    `json:"msg"`
+	Caller  string    `json:"caller,omitempty"`
+	Fields  LogFields `json:"fields,omitempty"`
 }
 
 var allLogLevels = []LogLevel{TRACE, DEBUG, INFO, WARN, ERROR, FATAL}
 var allLogs = make(map[string]Logger)
 var p = message.NewPrinter(language.English)
 
+var jsonOutput = strings.EqualFold(os.Getenv(LogFormatKey), "json")
+var jsonOutputMutex sync.Mutex
+
+func newBaseLogger(l *log.Logger, prefix string, level LogLevel, evaluateAssert bool, structured bool) *BaseLogger {
+	return &BaseLogger{log: l, prefix: prefix, level: &level, evaluateAssert: evaluateAssert, structured: structured}
+}
+
 func NewLogger(prefix string, level LogLevel) Logger {
-	current, present := allLogs[prefix]
+	_, present := allLogs[prefix]
 	if present {
-		panic("Initialized the same log prefix '"+prefix+"' twice")
-		return current
+		panic("Initialized the same log prefix '" + prefix + "' twice")
 	}
-	l := &BaseLogger{log.New(os.Stdout, prefix+" ", log.LstdFlags|log.Lshortfile), level, level <= DEBUG, false}
+	l := newBaseLogger(log.New(os.Stdout, prefix+" ", log.LstdFlags|log.Lshortfile), prefix, level, level <= DEBUG, true)
 	allLogs[prefix] = l
 	return l
 }
 
+// Data and stat loggers write to their own rotated files in build/log, see RotatingFileWriter
 func NewDataLogger(prefix string, level LogLevel) Logger {
-	return &BaseLogger{log.New(os.Stdout, prefix+" ", 0), level, false, false}
+	return newBaseLogger(log.New(NewRotatingFileWriter(prefix), prefix+" ", 0), prefix, level, false, false)
 }
 
 func NewStatLogger(prefix string, level LogLevel) Logger {
-	return &BaseLogger{log.New(os.Stdout, prefix+" ", log.Ltime|log.Lmicroseconds), level, false, false}
+	return newBaseLogger(log.New(NewRotatingFileWriter(prefix), prefix+" ", log.Ltime|log.Lmicroseconds), prefix, level, false, false)
 }
 
 /***************************************************************/
@@ -59,23 +99,66 @@ func GetLogger(prefix string) Logger {
 
 func SetLogLevelForAll(level LogLevel) {
 	for _, l := range allLogs {
-		l.(*BaseLogger).level = level
+		*l.(*BaseLogger).level = level
 	}
 }
 
 func SetLoggerLevel(prefix string, level LogLevel) {
-	allLogs[prefix].(*BaseLogger).level = level
+	*allLogs[prefix].(*BaseLogger).level = level
+}
+
+// The level of all the registered loggers by prefix
+func GetAllLoggerLevels() map[string]LogLevel {
+	res := make(map[string]LogLevel, len(allLogs))
+	for prefix, l := range allLogs {
+		res[prefix] = *l.(*BaseLogger).level
+	}
+	return res
+}
+
+// Switch all registered loggers between JSON lines and text output
+func SetJsonLogOutput(enable bool) {
+	jsonOutputMutex.Lock()
+	defer jsonOutputMutex.Unlock()
+	jsonOutput = enable
+}
+
+func IsJsonLogOutput() bool {
+	jsonOutputMutex.Lock()
+	defer jsonOutputMutex.Unlock()
+	return jsonOutput
+}
+
+// The level matching the name or number, false if unknown
+func ParseLogLevel(name string) (LogLevel, bool) {
+	upperName := strings.ToUpper(name)
+	for _, lv := range allLogLevels {
+		if GetLevelName(lv) == upperName {
+			return lv, true
+		}
+	}
+	intVal, err := strconv.Atoi(name)
+	if err == nil {
+		for _, lv := range allLogLevels {
+			if intVal == int(lv) {
+				return lv, true
+			}
+		}
+	}
+	return LogLevel(-1), false
 }
 
 func ReadVerbose() []string {
 	others := make([]string, 0)
 	if len(os.Args) > 1 {
-		for i := 1; i<len(os.Args); i++ {
+		for i := 1; i < len(os.Args); i++ {
 			if os.Args[i] == "-v" {
 				// Make all logger debug level
 				for _, l := range allLogs {
 					l.SetDebug()
 				}
+			} else if os.Args[i] == "-jsonlog" {
+				SetJsonLogOutput(true)
 			} else {
 				others = append(others, os.Args[i])
 			}
@@ -106,27 +189,110 @@ func GetLevelName(level LogLevel) string {
 	return fmt.Sprintf("UNK%d", level)
 }
 
-func makeMsg(level LogLevel, a ...interface{}) string {
-	return p.Sprintln(append([]interface{}{GetLevelName(level)}, a...))
+func makeMsg(a ...interface{}) string {
+	return strings.TrimSuffix(p.Sprintln(a...), "\n")
 }
 
-func makeMsgFormat(level LogLevel, format string, v ...interface{}) string {
-	return p.Sprintln(append([]interface{}{GetLevelName(level)}, p.Sprintf(format, v...)))
+func makeMsgFormat(format string, v ...interface{}) string {
+	return p.Sprintf(format, v...)
 }
 
 /***************************************************************/
 // Logger Functions
 /***************************************************************/
 
-func (l *BaseLogger) print(msg string) {
-	err := l.log.Output(3, msg)
+// Write the message at the level and return the text line, the caller of the public log method is 2 frames up
+func (l *BaseLogger) print(level LogLevel, msg string) string {
+	text := p.Sprintln(GetLevelName(level), msg+l.fieldsText())
+	if l.structured && IsJsonLogOutput() {
+		l.printJson(level, msg)
+		return text
+	}
+	err := l.log.Output(3, text)
 	if err != nil {
 		log.Println(err)
 	}
+	return text
+}
+
+func (l *BaseLogger) printJson(level LogLevel, msg string) {
+	line := jsonLogLine{
+		Time:    time.Now().Format(time.RFC3339Nano),
+		Level:   GetLevelName(level),
+		Logger:  l.prefix,
+		EnvId:   getLogEnvId(),
+		Message: msg,
+		Fields:  l.fields,
+	}
+	if envId, ok := l.fields[LogEnvIdField].(QsmEnvID); ok {
+		line.EnvId = envId
+	}
+	_, file, lineNb, ok := runtime.Caller(3)
+	if ok {
+		line.Caller = filepath.Base(file) + ":" + strconv.Itoa(lineNb)
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		// Fields not serializable are sent as strings
+		line.Fields = make(LogFields, len(l.fields))
+		for key, value := range l.fields {
+			line.Fields[key] = fmt.Sprint(value)
+		}
+		b, err = json.Marshal(line)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	jsonOutputMutex.Lock()
+	defer jsonOutputMutex.Unlock()
+	_, err = os.Stdout.Write(append(b, '\n'))
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Not using GetDefaultEnvId since it logs
+func getLogEnvId() QsmEnvID {
+	envId, err := strconv.Atoi(os.Getenv(QsmEnvNumberKey))
+	if err != nil {
+		return MainEnv
+	}
+	return QsmEnvID(envId)
+}
+
+func (l *BaseLogger) fieldsText() string {
+	if len(l.fields) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l.fields))
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(fmt.Sprintf(" %s=%v", key, l.fields[key]))
+	}
+	return sb.String()
+}
+
+// A logger writing the fields on each line, sharing the level of this logger
+func (l *BaseLogger) WithFields(fields LogFields) Logger {
+	res := *l
+	res.ignoreNextError = false
+	res.fields = make(LogFields, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		res.fields[key] = value
+	}
+	for key, value := range fields {
+		res.fields[key] = value
+	}
+	return &res
 }
 
 func (l *BaseLogger) GetLevelName() string {
-	return GetLevelName(l.level)
+	return GetLevelName(*l.level)
 }
 
 func (l *BaseLogger) DoAssert() bool {
@@ -140,95 +306,95 @@ func (l *BaseLogger) SetAssert(enable bool) {
 // Trace Level
 
 func (l *BaseLogger) SetTrace() {
-	l.level = TRACE
+	*l.level = TRACE
 }
 
 func (l *BaseLogger) IsTrace() bool {
-	return l.level <= TRACE
+	return *l.level <= TRACE
 }
 
 func (l *BaseLogger) Trace(a ...interface{}) {
 	if l.IsTrace() {
-		l.print(makeMsg(TRACE, a...))
+		l.print(TRACE, makeMsg(a...))
 	}
 }
 
 func (l *BaseLogger) Tracef(format string, v ...interface{}) {
 	if l.IsTrace() {
-		l.print(makeMsgFormat(TRACE, format, v...))
+		l.print(TRACE, makeMsgFormat(format, v...))
 	}
 }
 
 // Debug Level
 
 func (l *BaseLogger) SetDebug() {
-	l.level = DEBUG
+	*l.level = DEBUG
 }
 
 func (l *BaseLogger) IsDebug() bool {
-	return l.level <= DEBUG
+	return *l.level <= DEBUG
 }
 
 func (l *BaseLogger) Debug(a ...interface{}) {
 	if l.IsDebug() {
-		l.print(makeMsg(DEBUG, a...))
+		l.print(DEBUG, makeMsg(a...))
 	}
 }
 
 func (l *BaseLogger) Debugf(format string, v ...interface{}) {
 	if l.IsDebug() {
-		l.print(makeMsgFormat(DEBUG, format, v...))
+		l.print(DEBUG, makeMsgFormat(format, v...))
 	}
 }
 
 // Info Level
 
 func (l *BaseLogger) SetInfo() {
-	l.level = INFO
+	*l.level = INFO
 }
 
 func (l *BaseLogger) IsInfo() bool {
-	return l.level <= INFO
+	return *l.level <= INFO
 }
 
 func (l *BaseLogger) Info(a ...interface{}) {
 	if l.IsInfo() {
-		l.print(makeMsg(INFO, a...))
+		l.print(INFO, makeMsg(a...))
 	}
 }
 
 func (l *BaseLogger) Infof(format string, v ...interface{}) {
 	if l.IsInfo() {
-		l.print(makeMsgFormat(INFO, format, v...))
+		l.print(INFO, makeMsgFormat(format, v...))
 	}
 }
 
 // Warn Level
 
 func (l *BaseLogger) SetWarn() {
-	l.level = WARN
+	*l.level = WARN
 }
 
 func (l *BaseLogger) IsWarn() bool {
-	return l.level <= WARN
+	return *l.level <= WARN
 }
 
 func (l *BaseLogger) Warn(a ...interface{}) {
 	if l.IsWarn() {
-		l.print(makeMsg(WARN, a...))
+		l.print(WARN, makeMsg(a...))
 	}
 }
 
 func (l *BaseLogger) Warnf(format string, v ...interface{}) {
 	if l.IsWarn() {
-		l.print(makeMsgFormat(WARN, format, v...))
+		l.print(WARN, makeMsgFormat(format, v...))
 	}
 }
 
 // Error Level
 
 func (l *BaseLogger) SetError() {
-	l.level = ERROR
+	*l.level = ERROR
 }
 
 func (l *BaseLogger) IsError() bool {
@@ -241,8 +407,7 @@ func (l *BaseLogger) Error(a ...interface{}) {
 		l.ignoreNextError = false
 		return
 	}
-	msg := makeMsg(ERROR, a...)
-	l.print(msg)
+	msg := l.print(ERROR, makeMsg(a...))
 	log.Print(msg)
 }
 
@@ -251,21 +416,18 @@ func (l *BaseLogger) Errorf(format string, v ...interface{}) {
 		l.ignoreNextError = false
 		return
 	}
-	msg := makeMsgFormat(ERROR, format, v...)
-	l.print(msg)
+	msg := l.print(ERROR, makeMsgFormat(format, v...))
 	log.Print(msg)
 }
 
 // Fatal panic out
 func (l *BaseLogger) Fatal(a ...interface{}) {
-	msg := makeMsg(FATAL, a...)
-	l.print(msg)
+	msg := l.print(FATAL, makeMsg(a...))
 	panic(msg)
 }
 
 func (l *BaseLogger) Fatalf(format string, v ...interface{}) {
-	msg := makeMsgFormat(FATAL, format, v...)
-	l.print(msg)
+	msg := l.print(FATAL, makeMsgFormat(format, v...))
 	panic(msg)
 }
 
@@ -308,4 +470,6 @@ type Logger interface {
 	Fatalf(format string, v ...interface{})
 
 	IgnoreNextError()
+
+	WithFields(fields LogFields) Logger
 }
@@ -0,0 +1,214 @@
+package m3util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// "true" or "false" to force data and stat loggers to files or stdout, default is files out of test mode
+	LogFilesKey = "QSM_LOG_FILES"
+	// Common prefix of the files of this run, default is the start time and pid
+	LogRunPrefixKey = "QSM_LOG_RUN_PREFIX"
+	// Max size in MB of one file before rotation
+	LogFileMaxSizeKey = "QSM_LOG_FILE_MAX_SIZE_MB"
+	// Max age as a Go duration of one file before rotation
+	LogFileMaxAgeKey = "QSM_LOG_FILE_MAX_AGE"
+)
+
+const (
+	defaultLogFileMaxSize = 100 * 1024 * 1024
+	defaultLogFileMaxAge  = 24 * time.Hour
+)
+
+var logRunPrefix = readLogRunPrefix()
+
+var allLogFileWriters = make([]*RotatingFileWriter, 0, 4)
+var logFileWritersMutex sync.Mutex
+
+/*
+Writer of data and stat loggers into build/log files named <run prefix>-<logger>-<seq>.log.
+A new file is opened when the current one is bigger than maxSize or older than maxAge.
+Nothing is created until the first write, and if the files are disabled or cannot be opened it writes to stdout.
+*/
+type RotatingFileWriter struct {
+	mutex    sync.Mutex
+	name     string
+	maxSize  int64
+	maxAge   time.Duration
+	started  bool
+	toStdout bool
+	file     *os.File
+	filePath string
+	size     int64
+	openedAt time.Time
+	seq      int
+}
+
+func readLogRunPrefix() string {
+	prefix := os.Getenv(LogRunPrefixKey)
+	if prefix != "" {
+		return prefix
+	}
+	return time.Now().Format("20060102-150405") + "-" + strconv.Itoa(os.Getpid())
+}
+
+func GetLogRunPrefix() string {
+	return logRunPrefix
+}
+
+func GetLogDir() string {
+	return getOrCreateBuildSubDir("log")
+}
+
+func NewRotatingFileWriter(name string) *RotatingFileWriter {
+	res := &RotatingFileWriter{name: name, maxSize: defaultLogFileMaxSize, maxAge: defaultLogFileMaxAge}
+	sizeMb, err := strconv.Atoi(os.Getenv(LogFileMaxSizeKey))
+	if err == nil && sizeMb > 0 {
+		res.maxSize = int64(sizeMb) * 1024 * 1024
+	}
+	maxAge, err := time.ParseDuration(os.Getenv(LogFileMaxAgeKey))
+	if err == nil && maxAge > 0 {
+		res.maxAge = maxAge
+	}
+	logFileWritersMutex.Lock()
+	defer logFileWritersMutex.Unlock()
+	allLogFileWriters = append(allLogFileWriters, res)
+	return res
+}
+
+// Close the files of all data and stat loggers, a new write reopens a file
+func CloseLogFiles() {
+	logFileWritersMutex.Lock()
+	defer logFileWritersMutex.Unlock()
+	for _, w := range allLogFileWriters {
+		err := w.Close()
+		if err != nil {
+			Log.Errorf("could not close log file of %s due to %v", w.name, err)
+		}
+	}
+}
+
+func (w *RotatingFileWriter) SetRotation(maxSize int64, maxAge time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.maxSize = maxSize
+	w.maxAge = maxAge
+}
+
+func useLogFiles() bool {
+	fromEnv, err := strconv.ParseBool(os.Getenv(LogFilesKey))
+	if err == nil {
+		return fromEnv
+	}
+	return !TestMode
+}
+
+// The file currently written, empty if writing to stdout or nothing written yet
+func (w *RotatingFileWriter) GetFilePath() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.filePath
+}
+
+func (w *RotatingFileWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.started {
+		w.started = true
+		w.toStdout = !useLogFiles()
+	}
+	if w.toStdout {
+		return os.Stdout.Write(b)
+	}
+	if w.file == nil || w.size+int64(len(b)) > w.maxSize || time.Since(w.openedAt) > w.maxAge {
+		err := w.rotate(int64(len(b)))
+		if err != nil {
+			Log.Errorf("data logger %s writes to stdout since %v", w.name, err)
+			w.toStdout = true
+			return os.Stdout.Write(b)
+		}
+	}
+	n, err := w.file.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingFileWriter) rotate(nextWriteSize int64) error {
+	if w.file != nil {
+		err := w.file.Close()
+		if err != nil {
+			Log.Errorf("could not close log file %s due to %v", w.filePath, err)
+		}
+		w.file = nil
+	}
+	if w.seq == 0 {
+		w.seq = w.resumeSeq(nextWriteSize)
+	}
+	w.seq++
+	p := w.getSeqFilePath(w.seq)
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return MakeWrapQsmErrorf(err, "could not open log file %s due to %v", p, err)
+	}
+	w.file = f
+	w.filePath = p
+	w.size = 0
+	// Same run prefix given after a restart appends to the existing file
+	fi, err := f.Stat()
+	if err == nil {
+		w.size = fi.Size()
+	}
+	w.openedAt = time.Now()
+	Log.Infof("data logger %s writes to %s", w.name, p)
+	return nil
+}
+
+func (w *RotatingFileWriter) getSeqFilePath(seq int) string {
+	return filepath.Join(GetLogDir(), fmt.Sprintf("%s-%s-%03d.log", logRunPrefix, strings.ToLower(w.name), seq))
+}
+
+// The sequence number before the first file to open. After a restart with the same run prefix,
+// the last file on disk is continued if the next write fits, otherwise the next one is created.
+func (w *RotatingFileWriter) resumeSeq(nextWriteSize int64) int {
+	lastSeq := 0
+	var lastSize int64
+	filePrefix := fmt.Sprintf("%s-%s-", logRunPrefix, strings.ToLower(w.name))
+	matches, err := filepath.Glob(filepath.Join(GetLogDir(), filePrefix+"*.log"))
+	if err != nil {
+		return 0
+	}
+	for _, match := range matches {
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), filePrefix), ".log"))
+		if err != nil || seq <= lastSeq {
+			continue
+		}
+		fi, err := os.Stat(match)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		lastSeq = seq
+		lastSize = fi.Size()
+	}
+	if lastSeq > 0 && lastSize+nextWriteSize <= w.maxSize {
+		return lastSeq - 1
+	}
+	return lastSeq
+}
+
+func (w *RotatingFileWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	w.filePath = ""
+	return err
+}
@@ -0,0 +1,155 @@
+package m3util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Run the test with the build dir, and so the log dir, in a new temp dir
+func inTempBuildDir(t *testing.T, runPrefix string, test func(logDir string)) {
+	tempDir, err := ioutil.TempDir("", "qsm-logfile-")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+	currentDir, err := os.Getwd()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, os.Chdir(tempDir))
+	defer os.Chdir(currentDir)
+	savedPrefix := logRunPrefix
+	logRunPrefix = runPrefix
+	defer func() { logRunPrefix = savedPrefix }()
+	savedLogFiles := os.Getenv(LogFilesKey)
+	assert.NoError(t, os.Setenv(LogFilesKey, "true"))
+	defer os.Setenv(LogFilesKey, savedLogFiles)
+
+	test(GetLogDir())
+}
+
+func readLogFile(t *testing.T, logDir, fileName string) string {
+	data, err := ioutil.ReadFile(filepath.Join(logDir, fileName))
+	assert.NoError(t, err)
+	return string(data)
+}
+
+// Everything written to stdout while running f
+func captureStdout(t *testing.T, f func()) string {
+	r, pw, err := os.Pipe()
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	savedStdout := os.Stdout
+	os.Stdout = pw
+	f()
+	os.Stdout = savedStdout
+	assert.NoError(t, pw.Close())
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	inTempBuildDir(t, "run1", func(logDir string) {
+		w := &RotatingFileWriter{name: "Data", maxSize: 10, maxAge: time.Hour}
+		_, err := w.Write([]byte("12345678\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(logDir, "run1-data-001.log"), w.GetFilePath())
+		// Over 10 bytes goes to the next file
+		_, err = w.Write([]byte("abc\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(logDir, "run1-data-002.log"), w.GetFilePath())
+		assert.NoError(t, w.Close())
+		assert.Equal(t, "", w.GetFilePath())
+
+		assert.Equal(t, "12345678\n", readLogFile(t, logDir, "run1-data-001.log"))
+		assert.Equal(t, "abc\n", readLogFile(t, logDir, "run1-data-002.log"))
+	})
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	inTempBuildDir(t, "run2", func(logDir string) {
+		w := &RotatingFileWriter{name: "Stat", maxSize: 1024, maxAge: 20 * time.Millisecond}
+		_, err := w.Write([]byte("first\n"))
+		assert.NoError(t, err)
+		_, err = w.Write([]byte("same\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(logDir, "run2-stat-001.log"), w.GetFilePath())
+		time.Sleep(30 * time.Millisecond)
+		_, err = w.Write([]byte("second\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(logDir, "run2-stat-002.log"), w.GetFilePath())
+		assert.NoError(t, w.Close())
+
+		assert.Equal(t, "first\nsame\n", readLogFile(t, logDir, "run2-stat-001.log"))
+		assert.Equal(t, "second\n", readLogFile(t, logDir, "run2-stat-002.log"))
+	})
+}
+
+func TestRotatingFileResumeSeq(t *testing.T) {
+	inTempBuildDir(t, "run3", func(logDir string) {
+		w := &RotatingFileWriter{name: "Data", maxSize: 10, maxAge: time.Hour}
+		for _, line := range []string{"12345678\n", "abcdefgh\n", "xy\n"} {
+			_, err := w.Write([]byte(line))
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, filepath.Join(logDir, "run3-data-003.log"), w.GetFilePath())
+		assert.NoError(t, w.Close())
+
+		// A restart with the same run prefix continues the last file when the write fits
+		w = &RotatingFileWriter{name: "Data", maxSize: 10, maxAge: time.Hour}
+		_, err := w.Write([]byte("z\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(logDir, "run3-data-003.log"), w.GetFilePath())
+		assert.Equal(t, "xy\nz\n", readLogFile(t, logDir, "run3-data-003.log"))
+		_, err = w.Write([]byte("1234567\n"))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		// The next write does not fit in the last file so the next one is created
+		w = &RotatingFileWriter{name: "Data", maxSize: 10, maxAge: time.Hour}
+		_, err = w.Write([]byte("next\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(logDir, "run3-data-005.log"), w.GetFilePath())
+		assert.NoError(t, w.Close())
+
+		// Other loggers and run prefixes have their own sequence
+		w = &RotatingFileWriter{name: "Stat", maxSize: 10, maxAge: time.Hour}
+		_, err = w.Write([]byte("stat\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(logDir, "run3-stat-001.log"), w.GetFilePath())
+		assert.NoError(t, w.Close())
+	})
+}
+
+func TestRotatingFileStdoutFallback(t *testing.T) {
+	inTempBuildDir(t, "run4", func(logDir string) {
+		// The first file path is taken by a directory so it cannot be opened
+		assert.NoError(t, os.MkdirAll(filepath.Join(logDir, "run4-data-001.log"), 0755))
+		w := &RotatingFileWriter{name: "Data", maxSize: 1024, maxAge: time.Hour}
+		out := captureStdout(t, func() {
+			_, err := w.Write([]byte("to stdout\n"))
+			assert.NoError(t, err)
+		})
+		assert.True(t, strings.Contains(out, "to stdout\n"), out)
+		assert.Equal(t, "", w.GetFilePath())
+
+		// Files disabled
+		assert.NoError(t, os.Setenv(LogFilesKey, "false"))
+		w = &RotatingFileWriter{name: "Stat", maxSize: 1024, maxAge: time.Hour}
+		out = captureStdout(t, func() {
+			_, err := w.Write([]byte("no file\n"))
+			assert.NoError(t, err)
+		})
+		assert.Equal(t, "no file\n", out)
+		assert.Equal(t, "", w.GetFilePath())
+		_, err := os.Stat(filepath.Join(logDir, "run4-stat-001.log"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,308 @@
+package m3util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Minimal local metrics registry written in the Prometheus text exposition format.
+No external services needed, the backend exposes it at /metrics.
+*/
+type MetricsRegistry struct {
+	mutex    sync.RWMutex
+	families map[string]metricFamily
+}
+
+type metricFamily interface {
+	getName() string
+	writeText(w *bufio.Writer)
+}
+
+// One value of a gauge function for a set of label values
+type GaugeValue struct {
+	LabelValues []string
+	Value       float64
+}
+
+// Default buckets in seconds covering DB queries up to long path computations
+var DefaultDurationBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 60}
+
+var QsmMetrics = NewMetricsRegistry()
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{families: make(map[string]metricFamily)}
+}
+
+// Register the family or return the already registered one with the same name
+func (reg *MetricsRegistry) register(family metricFamily) metricFamily {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	existing, ok := reg.families[family.getName()]
+	if ok {
+		return existing
+	}
+	reg.families[family.getName()] = family
+	return family
+}
+
+func (reg *MetricsRegistry) WriteText(w io.Writer) error {
+	reg.mutex.RLock()
+	names := make([]string, 0, len(reg.families))
+	for name := range reg.families {
+		names = append(names, name)
+	}
+	families := make([]metricFamily, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		families[i] = reg.families[name]
+	}
+	reg.mutex.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		family.writeText(bw)
+	}
+	return bw.Flush()
+}
+
+/***************************************************************/
+// Common metric functions
+/***************************************************************/
+
+type metricBase struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+}
+
+func (m *metricBase) getName() string {
+	return m.name
+}
+
+func (m *metricBase) writeHeader(w *bufio.Writer) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.metricType)
+}
+
+func (m *metricBase) checkLabels(labelValues []string) {
+	if len(labelValues) != len(m.labelNames) {
+		Log.Fatalf("metric %s needs %d label values %v and got %v", m.name, len(m.labelNames), m.labelNames, labelValues)
+	}
+}
+
+// Label values joined as map key
+func labelsKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func formatLabels(labelNames []string, labelValues []string, extraName string, extraValue string) string {
+	if len(labelNames) == 0 && extraName == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, name := range labelNames {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(name)
+		sb.WriteString("=")
+		sb.WriteString(strconv.Quote(labelValues[i]))
+	}
+	if extraName != "" {
+		if len(labelNames) > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(extraName)
+		sb.WriteString("=")
+		sb.WriteString(strconv.Quote(extraValue))
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string][]string) []string {
+	res := make([]string, 0, len(m))
+	for key := range m {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+/***************************************************************/
+// Counter Functions
+/***************************************************************/
+
+type CounterVec struct {
+	metricBase
+	mutex       sync.Mutex
+	labelValues map[string][]string
+	values      map[string]float64
+}
+
+func (reg *MetricsRegistry) Counter(name string, help string, labelNames ...string) *CounterVec {
+	res := &CounterVec{
+		metricBase:  metricBase{name: name, help: help, metricType: "counter", labelNames: labelNames},
+		labelValues: make(map[string][]string),
+		values:      make(map[string]float64),
+	}
+	return reg.register(res).(*CounterVec)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.checkLabels(labelValues)
+	key := labelsKey(labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.labelValues[key]; !ok {
+		c.labelValues[key] = labelValues
+	}
+	c.values[key] += v
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1.0, labelValues...)
+}
+
+func (c *CounterVec) Get(labelValues ...string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[labelsKey(labelValues)]
+}
+
+func (c *CounterVec) writeText(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writeHeader(w)
+	for _, key := range sortedKeys(c.labelValues) {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labelNames, c.labelValues[key], "", ""), formatValue(c.values[key]))
+	}
+}
+
+/***************************************************************/
+// Histogram Functions
+/***************************************************************/
+
+type histogramValue struct {
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+type HistogramVec struct {
+	metricBase
+	buckets     []float64
+	mutex       sync.Mutex
+	labelValues map[string][]string
+	values      map[string]*histogramValue
+}
+
+func (reg *MetricsRegistry) Histogram(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	res := &HistogramVec{
+		metricBase:  metricBase{name: name, help: help, metricType: "histogram", labelNames: labelNames},
+		buckets:     buckets,
+		labelValues: make(map[string][]string),
+		values:      make(map[string]*histogramValue),
+	}
+	return reg.register(res).(*HistogramVec)
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+	key := labelsKey(labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{bucketCounts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+		h.labelValues[key] = labelValues
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hv.bucketCounts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) GetCount(labelValues ...string) uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hv, ok := h.values[labelsKey(labelValues)]
+	if !ok {
+		return 0
+	}
+	return hv.count
+}
+
+func (h *HistogramVec) writeText(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.labelValues) {
+		labelValues := h.labelValues[key]
+		hv := h.values[key]
+		for i, upper := range h.buckets {
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, labelValues, "le", formatValue(upper)), hv.bucketCounts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, labelValues, "le", "+Inf"), hv.count)
+		labels := formatLabels(h.labelNames, labelValues, "", "")
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatValue(hv.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, hv.count)
+	}
+}
+
+/***************************************************************/
+// Gauge Functions
+/***************************************************************/
+
+// Gauge sampled at each write of the registry
+type GaugeFunc struct {
+	metricBase
+	collect func() []GaugeValue
+}
+
+func (reg *MetricsRegistry) GaugeFunc(name string, help string, collect func() []GaugeValue, labelNames ...string) *GaugeFunc {
+	res := &GaugeFunc{
+		metricBase: metricBase{name: name, help: help, metricType: "gauge", labelNames: labelNames},
+		collect:    collect,
+	}
+	return reg.register(res).(*GaugeFunc)
+}
+
+func (g *GaugeFunc) writeText(w *bufio.Writer) {
+	g.writeHeader(w)
+	values := g.collect()
+	sort.Slice(values, func(i, j int) bool {
+		return labelsKey(values[i].LabelValues) < labelsKey(values[j].LabelValues)
+	})
+	for _, gv := range values {
+		if len(gv.LabelValues) != len(g.labelNames) {
+			Log.Errorf("gauge %s needs %d label values %v and got %v", g.name, len(g.labelNames), g.labelNames, gv.LabelValues)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labelNames, gv.LabelValues, "", ""), formatValue(gv.Value))
+	}
+}
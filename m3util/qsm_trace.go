@@ -0,0 +1,150 @@
+package m3util
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Set to debug to log each span end, and the summary of each request
+var TraceLog = NewLogger("m3trace", INFO)
+
+type traceContextKey struct{}
+
+/*
+The timing spans of one request identified by its request id.
+Spans with the same name are summed, so one span per DB table can count all its queries.
+*/
+type RequestTrace struct {
+	id      string
+	start   time.Time
+	mutex   sync.Mutex
+	spans   []*TraceSpan
+	indexes map[string]int
+}
+
+type TraceSpan struct {
+	Name     string
+	Count    int
+	Duration time.Duration
+}
+
+// A random 16 hex chars id
+func NewRequestId() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func NewRequestTrace(id string) *RequestTrace {
+	return &RequestTrace{id: id, start: time.Now(), spans: make([]*TraceSpan, 0, 8), indexes: make(map[string]int, 8)}
+}
+
+func WithTrace(ctx context.Context, trace *RequestTrace) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// The trace of the context, nil if none. All the RequestTrace methods accept nil.
+func GetTrace(ctx context.Context) *RequestTrace {
+	if ctx == nil {
+		return nil
+	}
+	trace, _ := ctx.Value(traceContextKey{}).(*RequestTrace)
+	return trace
+}
+
+// Empty if no trace in the context
+func GetRequestId(ctx context.Context) string {
+	return GetTrace(ctx).GetId()
+}
+
+/*
+Start a span on the trace of the context and return the function ending it.
+Usage: defer m3util.StartSpan(ctx, "populate")()
+*/
+func StartSpan(ctx context.Context, name string) func() {
+	trace := GetTrace(ctx)
+	if trace == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		trace.AddSpan(name, time.Since(start))
+	}
+}
+
+func (trace *RequestTrace) GetId() string {
+	if trace == nil {
+		return ""
+	}
+	return trace.id
+}
+
+func (trace *RequestTrace) GetElapsed() time.Duration {
+	if trace == nil {
+		return 0
+	}
+	return time.Since(trace.start)
+}
+
+func (trace *RequestTrace) AddSpan(name string, duration time.Duration) {
+	if trace == nil {
+		return
+	}
+	if TraceLog.IsDebug() {
+		TraceLog.Debugf("request %s span %s took %v", trace.id, name, duration)
+	}
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+	idx, ok := trace.indexes[name]
+	if !ok {
+		idx = len(trace.spans)
+		trace.indexes[name] = idx
+		trace.spans = append(trace.spans, &TraceSpan{Name: name})
+	}
+	trace.spans[idx].Count++
+	trace.spans[idx].Duration += duration
+}
+
+// Copy of the spans in order of first end
+func (trace *RequestTrace) GetSpans() []TraceSpan {
+	if trace == nil {
+		return nil
+	}
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+	res := make([]TraceSpan, len(trace.spans))
+	for i, span := range trace.spans {
+		res[i] = *span
+	}
+	return res
+}
+
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d.Microseconds())/1000.0)
+}
+
+// Value of the Server-Timing header with all the spans and the total so far
+func (trace *RequestTrace) ServerTiming() string {
+	if trace == nil {
+		return ""
+	}
+	spans := trace.GetSpans()
+	parts := make([]string, 0, len(spans)+1)
+	for _, span := range spans {
+		part := span.Name + ";dur=" + formatMillis(span.Duration)
+		if span.Count > 1 {
+			part += fmt.Sprintf(";desc=\"%d calls\"", span.Count)
+		}
+		parts = append(parts, part)
+	}
+	parts = append(parts, "total;dur="+formatMillis(trace.GetElapsed()))
+	return strings.Join(parts, ", ")
+}
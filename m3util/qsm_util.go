@@ -2,6 +2,7 @@ package m3util
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,10 +13,35 @@ import (
 var Log = NewLogger("m3util", INFO)
 
 type QsmError struct {
-	msg string
+	code  QsmErrorCode
+	msg   string
 	cause error
 }
 
+// Error codes shared by the backend and its clients
+type QsmErrorCode int32
+
+const (
+	// No specific code, usually an internal error
+	ErrUnknown QsmErrorCode = iota
+	ErrBadRequest
+	ErrNotFound
+	ErrInvalidState
+	ErrTooLarge
+	ErrInternal
+	ErrUnavailable
+	ErrUnauthenticated
+	ErrForbidden
+)
+
+var qsmErrorCodeNames = [...]string{"UNKNOWN", "BAD_REQUEST", "NOT_FOUND", "INVALID_STATE", "TOO_LARGE", "INTERNAL", "UNAVAILABLE", "UNAUTHENTICATED", "FORBIDDEN"}
+
+// Errors carrying a QsmErrorCode
+type QsmCodedError interface {
+	error
+	GetCode() QsmErrorCode
+}
+
 func DirExists(dir string, subPath string) (bool, string) {
 	p := filepath.Join(dir, subPath)
 	fi, err := os.Stat(p)
@@ -71,7 +97,7 @@ func GetBuildDir() string {
 }
 
 func GetConfDir() string {
-	b, p := DirExists(GetGitRootDir(), "backend/conf")
+	p, b := FindConfDir()
 	if !b {
 		Log.Fatalf("conf dir %s does not exists!", p)
 		return ""
@@ -79,6 +105,24 @@ func GetConfDir() string {
 	return p
 }
 
+/*
+The backend/conf dir from the root dir, or the conf dir when running from the backend dir like the scripts do.
+Returns false with the path searched if none exists.
+*/
+func FindConfDir() (string, bool) {
+	rootDir := GetGitRootDir()
+	b, p := DirExists(rootDir, "backend/conf")
+	if b {
+		return p, true
+	}
+	if filepath.Base(rootDir) == "backend" {
+		if b, backendConf := DirExists(rootDir, "conf"); b {
+			return backendConf, true
+		}
+	}
+	return p, false
+}
+
 func CreateFile(dir, fileName string) *os.File {
 	p := filepath.Join(dir, fileName)
 	f, err := os.Create(p)
@@ -146,8 +190,16 @@ func PosMod8(i uint64) uint64 {
 // QsmError Functions
 /***************************************************************/
 
+func (code QsmErrorCode) String() string {
+	if code < 0 || int(code) >= len(qsmErrorCodeNames) {
+		return fmt.Sprintf("CODE_%d", int32(code))
+	}
+	return qsmErrorCodeNames[code]
+}
+
 func MakeQsmErrorf(format string, args ...interface{}) error {
 	return &QsmError{
+		code:  ErrUnknown,
 		msg:   fmt.Sprintf(format, args...),
 		cause: nil,
 	}
@@ -155,15 +207,48 @@ func MakeQsmErrorf(format string, args ...interface{}) error {
 
 func MakeWrapQsmErrorf(err error, format string, args ...interface{}) error {
 	return &QsmError{
+		code:  ErrUnknown,
 		msg:   fmt.Sprintf(format, args...),
 		cause: err,
 	}
 }
 
+func MakeQsmCodeErrorf(code QsmErrorCode, format string, args ...interface{}) error {
+	return &QsmError{
+		code:  code,
+		msg:   fmt.Sprintf(format, args...),
+		cause: nil,
+	}
+}
+
+func MakeWrapQsmCodeErrorf(code QsmErrorCode, err error, format string, args ...interface{}) error {
+	return &QsmError{
+		code:  code,
+		msg:   fmt.Sprintf(format, args...),
+		cause: err,
+	}
+}
+
+// Returns the first code different than ErrUnknown found in the chain of wrapped errors
+func GetQsmErrorCode(err error) QsmErrorCode {
+	for err != nil {
+		codedErr, ok := err.(QsmCodedError)
+		if ok && codedErr.GetCode() != ErrUnknown {
+			return codedErr.GetCode()
+		}
+		err = errors.Unwrap(err)
+	}
+	return ErrUnknown
+}
+
 func (qsmError *QsmError) Error() string {
 	return qsmError.msg
 }
 
+func (qsmError *QsmError) GetCode() QsmErrorCode {
+	return qsmError.code
+}
+
 func (qsmError *QsmError) Unwrap() error {
 	return qsmError.cause
 }
@@ -2,8 +2,11 @@ module github.com/freddy33/qsm-go/model
 
 require (
 	github.com/freddy33/qsm-go/m3util v0.0.0-latest
+	github.com/go-gl/mathgl v0.0.0-20190713194549-592312d8590a
 	github.com/golang/protobuf v1.4.2
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/image v0.0.0-20200119044424-58c23975cae1
+	google.golang.org/protobuf v1.23.0
 )
 
 replace github.com/freddy33/qsm-go/m3util => ../m3util
@@ -1,14 +1,27 @@
 package m3api
 
 import (
+	"errors"
+	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3path"
 	"github.com/freddy33/qsm-go/model/m3point"
+	"net/http"
 )
 
 const (
-	HttpEnvIdKey = "QsmEnvId"
+	HttpEnvIdKey         = "QsmEnvId"
+	HttpRequestIdKey     = "X-Request-Id"
+	HttpAuthorizationKey = "Authorization"
+	HttpServerTimingKey  = "Server-Timing"
+	HttpBearerPrefix     = "Bearer "
 )
 
+/*
+Version of the DB schema and of the data sent, to increase when they change so clients drop their caches.
+The backend TestSchemaVersion fails when the table definitions change without a new version.
+*/
+const SchemaVersion = 2
+
 type PointPathMsg interface {
 	GetPointId() int64
 	GetPoint() *PointMsg
@@ -27,4 +40,102 @@ func MsgToPathPoint(ppm PointPathMsg) m3path.PathPoint {
 		Id: m3path.PointId(ppm.GetPointId()),
 		P:  PointMsgToPoint(ppm.GetPoint()),
 	}
-}
\ No newline at end of file
+}
+
+/***************************************************************/
+// Health and readiness
+/***************************************************************/
+
+const (
+	HealthStatusUp       = "UP"
+	HealthStatusReady    = "READY"
+	HealthStatusNotReady = "NOT_READY"
+)
+
+// JSON body of /healthz and /readyz
+type HealthStatusMsg struct {
+	Status        string           `json:"status"`
+	EnvId         int              `json:"env_id"`
+	SchemaVersion int              `json:"schema_version,omitempty"`
+	Uptime        string           `json:"uptime,omitempty"`
+	Checks        []HealthCheckMsg `json:"checks,omitempty"`
+}
+
+type HealthCheckMsg struct {
+	Name    string `json:"name"`
+	Ok      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// JSON body of GET and POST /log
+type LogLevelsMsg struct {
+	// "text" or "json"
+	Format  string           `json:"format"`
+	Loggers []LoggerLevelMsg `json:"loggers"`
+	// The logger names changed by a POST
+	Updated []string `json:"updated,omitempty"`
+}
+
+type LoggerLevelMsg struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+}
+
+/***************************************************************/
+// Error codes and HTTP status
+/***************************************************************/
+
+func ErrorCodeToHttpStatus(code m3util.QsmErrorCode) int {
+	switch code {
+	case m3util.ErrBadRequest:
+		return http.StatusBadRequest
+	case m3util.ErrNotFound:
+		return http.StatusNotFound
+	case m3util.ErrInvalidState:
+		return http.StatusUnprocessableEntity
+	case m3util.ErrTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case m3util.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case m3util.ErrUnauthenticated:
+		return http.StatusUnauthorized
+	case m3util.ErrForbidden:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func HttpStatusToErrorCode(status int) m3util.QsmErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return m3util.ErrBadRequest
+	case http.StatusNotFound:
+		return m3util.ErrNotFound
+	case http.StatusUnprocessableEntity, http.StatusConflict:
+		return m3util.ErrInvalidState
+	case http.StatusRequestEntityTooLarge:
+		return m3util.ErrTooLarge
+	case http.StatusServiceUnavailable:
+		return m3util.ErrUnavailable
+	case http.StatusUnauthorized:
+		return m3util.ErrUnauthenticated
+	case http.StatusForbidden:
+		return m3util.ErrForbidden
+	case http.StatusInternalServerError:
+		return m3util.ErrInternal
+	}
+	return m3util.ErrUnknown
+}
+
+// The message of the error followed by the messages of all the wrapped causes
+func MakeErrorMsg(code m3util.QsmErrorCode, err error, requestId string) *ErrorMsg {
+	res := &ErrorMsg{
+		Code:      int32(code),
+		Message:   err.Error(),
+		RequestId: requestId,
+	}
+	for cause := errors.Unwrap(err); cause != nil; cause = errors.Unwrap(cause) {
+		res.Details = append(res.Details, cause.Error())
+	}
+	return res
+}
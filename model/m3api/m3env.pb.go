@@ -122,9 +122,73 @@ func (m *EnvListMsg) GetEnvs() []*EnvMsg {
 	return nil
 }
 
+type ErrorMsg struct {
+	Code                 int32    `protobuf:"varint,1,opt,name=code,proto3" json:"code" query:"code"`
+	Message              string   `protobuf:"bytes,2,opt,name=message,proto3" json:"message" query:"message"`
+	Details              []string `protobuf:"bytes,3,rep,name=details,proto3" json:"details,omitempty" query:"details"`
+	RequestId            string   `protobuf:"bytes,4,opt,name=request_id,json=requestId,proto3" json:"request_id" query:"request_id"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-" query:"-"`
+	XXX_unrecognized     []byte   `json:"-" query:"-"`
+	XXX_sizecache        int32    `json:"-" query:"-"`
+}
+
+func (m *ErrorMsg) Reset()         { *m = ErrorMsg{} }
+func (m *ErrorMsg) String() string { return proto.CompactTextString(m) }
+func (*ErrorMsg) ProtoMessage()    {}
+func (*ErrorMsg) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1f1c3839dc76096c, []int{2}
+}
+
+func (m *ErrorMsg) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ErrorMsg.Unmarshal(m, b)
+}
+func (m *ErrorMsg) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ErrorMsg.Marshal(b, m, deterministic)
+}
+func (m *ErrorMsg) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ErrorMsg.Merge(m, src)
+}
+func (m *ErrorMsg) XXX_Size() int {
+	return xxx_messageInfo_ErrorMsg.Size(m)
+}
+func (m *ErrorMsg) XXX_DiscardUnknown() {
+	xxx_messageInfo_ErrorMsg.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ErrorMsg proto.InternalMessageInfo
+
+func (m *ErrorMsg) GetCode() int32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *ErrorMsg) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *ErrorMsg) GetDetails() []string {
+	if m != nil {
+		return m.Details
+	}
+	return nil
+}
+
+func (m *ErrorMsg) GetRequestId() string {
+	if m != nil {
+		return m.RequestId
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*EnvMsg)(nil), "m3api.EnvMsg")
 	proto.RegisterType((*EnvListMsg)(nil), "m3api.EnvListMsg")
+	proto.RegisterType((*ErrorMsg)(nil), "m3api.ErrorMsg")
 }
 
 func init() {
@@ -132,18 +196,21 @@ func init() {
 }
 
 var fileDescriptor_1f1c3839dc76096c = []byte{
-	// 194 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0xcf, 0xbf, 0x6a, 0xc3, 0x30,
-	0x10, 0xc7, 0x71, 0xe4, 0x7f, 0xd0, 0x33, 0x1d, 0xaa, 0x52, 0xd0, 0x56, 0xd5, 0x93, 0x26, 0x15,
-	0xea, 0x67, 0xf0, 0x50, 0x68, 0x4b, 0x51, 0x1e, 0xc0, 0x28, 0xf6, 0x91, 0x68, 0xd0, 0xd9, 0x58,
-	0x46, 0x83, 0xdf, 0x22, 0x6f, 0x1c, 0x62, 0x13, 0xe2, 0xf5, 0x7e, 0x9f, 0xe1, 0x7b, 0x50, 0xfa,
-	0x1a, 0x29, 0xea, 0x71, 0x1a, 0xe6, 0x81, 0xe7, 0xbe, 0xb6, 0xa3, 0xab, 0x2e, 0x0c, 0x8a, 0x86,
-	0xe2, 0x6f, 0x38, 0xf1, 0x37, 0x28, 0x90, 0x62, 0xeb, 0x7a, 0xc1, 0x24, 0x53, 0xb9, 0xc9, 0x91,
-	0xe2, 0x77, 0xcf, 0xdf, 0xa1, 0x0c, 0xdd, 0x19, 0xbd, 0x6d, 0xc9, 0x7a, 0x14, 0x89, 0x64, 0xea,
-	0xc9, 0xc0, 0x76, 0xfa, 0xb3, 0x1e, 0x77, 0x20, 0xb8, 0x05, 0x45, 0x2a, 0x99, 0x4a, 0xef, 0xe0,
-	0xe0, 0x16, 0xe4, 0x1a, 0x5e, 0x77, 0xa0, 0x1d, 0x71, 0xea, 0x90, 0x66, 0x91, 0x49, 0xa6, 0x12,
-	0xf3, 0xf2, 0x80, 0xff, 0xdb, 0x50, 0x7d, 0x02, 0x34, 0x14, 0x7f, 0x5c, 0x98, 0x6f, 0x59, 0x1f,
-	0x90, 0x21, 0xc5, 0x20, 0x98, 0x4c, 0x55, 0xf9, 0xf5, 0xac, 0xd7, 0x6e, 0xbd, 0x35, 0x9b, 0x75,
-	0x3a, 0x16, 0xeb, 0x4b, 0xf5, 0x35, 0x00, 0x00, 0xff, 0xff, 0x3f, 0x3f, 0xe4, 0x7f, 0xe1, 0x00,
-	0x00, 0x00,
+	// 251 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x90, 0xcd, 0x4a, 0xf4, 0x30,
+	0x14, 0x86, 0xc9, 0xf4, 0xe7, 0xfb, 0xe6, 0x14, 0x17, 0x46, 0x84, 0x6c, 0xc4, 0xd8, 0x55, 0x57,
+	0x15, 0xec, 0x35, 0x74, 0x31, 0xa0, 0x22, 0xf5, 0x02, 0x4a, 0x6c, 0x0f, 0x63, 0xc0, 0xa4, 0x9d,
+	0x24, 0x66, 0x31, 0x77, 0xe1, 0x1d, 0x4b, 0xd2, 0x16, 0x67, 0x97, 0x73, 0xde, 0x27, 0xf0, 0x9c,
+	0x17, 0x0a, 0xd5, 0xa0, 0xf6, 0xf5, 0x6c, 0x26, 0x37, 0xd1, 0x4c, 0x35, 0x62, 0x96, 0xe5, 0x0f,
+	0x81, 0xbc, 0xd5, 0xfe, 0xc5, 0x1e, 0xe9, 0x2d, 0xe4, 0xa8, 0x7d, 0x2f, 0x47, 0x46, 0x38, 0xa9,
+	0xb2, 0x2e, 0x43, 0xed, 0x0f, 0x23, 0xbd, 0x87, 0xc2, 0x0e, 0x9f, 0xa8, 0x44, 0xaf, 0x85, 0x42,
+	0xb6, 0xe3, 0xa4, 0xda, 0x77, 0xb0, 0xac, 0x5e, 0x85, 0xc2, 0x0b, 0xc0, 0xca, 0x33, 0xb2, 0x84,
+	0x93, 0x2a, 0xd9, 0x80, 0x77, 0x79, 0x46, 0x5a, 0xc3, 0xcd, 0x05, 0xd0, 0xcf, 0x68, 0x06, 0xd4,
+	0x8e, 0xa5, 0x9c, 0x54, 0xbb, 0xee, 0xfa, 0x0f, 0x7c, 0x5b, 0x82, 0xf2, 0x11, 0xa0, 0xd5, 0xfe,
+	0x59, 0x5a, 0x17, 0xb4, 0x1e, 0x20, 0x45, 0xed, 0x2d, 0x23, 0x3c, 0xa9, 0x8a, 0xa7, 0xab, 0x3a,
+	0x7a, 0xd7, 0x8b, 0x73, 0x17, 0xa3, 0xf2, 0x04, 0xff, 0x5b, 0x63, 0x26, 0x13, 0x70, 0x0a, 0xe9,
+	0x30, 0x8d, 0xb8, 0xde, 0x10, 0xdf, 0x94, 0xc1, 0x3f, 0x85, 0xd6, 0x8a, 0xe3, 0xa6, 0xbf, 0x8d,
+	0x21, 0x19, 0xd1, 0x09, 0xf9, 0x65, 0x59, 0xc2, 0x93, 0x90, 0xac, 0x23, 0xbd, 0x03, 0x30, 0x78,
+	0xfa, 0x46, 0xeb, 0x42, 0x23, 0x69, 0xfc, 0xb6, 0x5f, 0x37, 0x87, 0xf1, 0x23, 0x8f, 0x2d, 0x36,
+	0xbf, 0x03, 0x00, 0xd4, 0x3f, 0xdd, 0xc1, 0x54, 0x01, 0x00, 0x00,
 }
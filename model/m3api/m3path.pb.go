@@ -115,16 +115,17 @@ func (m *PathContextIdMsg) GetPathCtxId() int32 {
 }
 
 type PathContextMsg struct {
-	PathCtxId            int32        `protobuf:"varint,1,opt,name=path_ctx_id,json=pathCtxId,proto3" json:"path_ctx_id" query:"path_ctx_id"`
-	GrowthContextId      int32        `protobuf:"varint,2,opt,name=growth_context_id,json=growthContextId,proto3" json:"growth_context_id" query:"growth_context_id"`
-	GrowthOffset         int32        `protobuf:"varint,3,opt,name=growth_offset,json=growthOffset,proto3" json:"growth_offset" query:"growth_offset"`
-	RootPathNode         *PathNodeMsg `protobuf:"bytes,4,opt,name=root_path_node,json=rootPathNode,proto3" json:"root_path_node,omitempty" query:"-"`
-	MaxDist              int32        `protobuf:"varint,5,opt,name=max_dist,json=maxDist,proto3" json:"max_dist" query:"max_dist"`
-	GrowthType           int32        `protobuf:"varint,6,opt,name=growth_type,json=growthType,proto3" json:"growth_type" query:"growth_type"`
-	GrowthIndex          int32        `protobuf:"varint,7,opt,name=growth_index,json=growthIndex,proto3" json:"growth_index" query:"growth_index"`
-	XXX_NoUnkeyedLiteral struct{}     `json:"-" query:"-"`
-	XXX_unrecognized     []byte       `json:"-" query:"-"`
-	XXX_sizecache        int32        `json:"-" query:"-"`
+	PathCtxId            int32              `protobuf:"varint,1,opt,name=path_ctx_id,json=pathCtxId,proto3" json:"path_ctx_id" query:"path_ctx_id"`
+	GrowthContextId      int32              `protobuf:"varint,2,opt,name=growth_context_id,json=growthContextId,proto3" json:"growth_context_id" query:"growth_context_id"`
+	GrowthOffset         int32              `protobuf:"varint,3,opt,name=growth_offset,json=growthOffset,proto3" json:"growth_offset" query:"growth_offset"`
+	RootPathNode         *PathNodeMsg       `protobuf:"bytes,4,opt,name=root_path_node,json=rootPathNode,proto3" json:"root_path_node,omitempty" query:"-"`
+	MaxDist              int32              `protobuf:"varint,5,opt,name=max_dist,json=maxDist,proto3" json:"max_dist" query:"max_dist"`
+	GrowthType           int32              `protobuf:"varint,6,opt,name=growth_type,json=growthType,proto3" json:"growth_type" query:"growth_type"`
+	GrowthIndex          int32              `protobuf:"varint,7,opt,name=growth_index,json=growthIndex,proto3" json:"growth_index" query:"growth_index"`
+	DistStats            []*PathDistStatMsg `protobuf:"bytes,8,rep,name=dist_stats,json=distStats,proto3" json:"dist_stats,omitempty" query:"-"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-" query:"-"`
+	XXX_unrecognized     []byte             `json:"-" query:"-"`
+	XXX_sizecache        int32              `json:"-" query:"-"`
 }
 
 func (m *PathContextMsg) Reset()         { *m = PathContextMsg{} }
@@ -201,6 +202,13 @@ func (m *PathContextMsg) GetGrowthIndex() int32 {
 	return 0
 }
 
+func (m *PathContextMsg) GetDistStats() []*PathDistStatMsg {
+	if m != nil {
+		return m.DistStats
+	}
+	return nil
+}
+
 type PathContextListMsg struct {
 	PathContexts         []*PathContextMsg `protobuf:"bytes,1,rep,name=path_contexts,json=pathContexts,proto3" json:"path_contexts,omitempty" query:"-"`
 	XXX_NoUnkeyedLiteral struct{}          `json:"-" query:"-"`
@@ -453,6 +461,69 @@ func (m *PathNodesResponseMsg) GetPathNodes() []*PathNodeMsg {
 	return nil
 }
 
+type PathDistStatMsg struct {
+	D                    int32    `protobuf:"varint,1,opt,name=d,proto3" json:"d" query:"d"`
+	NbNodes              int32    `protobuf:"varint,2,opt,name=nb_nodes,json=nbNodes,proto3" json:"nb_nodes" query:"nb_nodes"`
+	NbDeadEnds           int32    `protobuf:"varint,3,opt,name=nb_dead_ends,json=nbDeadEnds,proto3" json:"nb_dead_ends" query:"nb_dead_ends"`
+	NbConflicts          int32    `protobuf:"varint,4,opt,name=nb_conflicts,json=nbConflicts,proto3" json:"nb_conflicts" query:"nb_conflicts"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-" query:"-"`
+	XXX_unrecognized     []byte   `json:"-" query:"-"`
+	XXX_sizecache        int32    `json:"-" query:"-"`
+}
+
+func (m *PathDistStatMsg) Reset()         { *m = PathDistStatMsg{} }
+func (m *PathDistStatMsg) String() string { return proto.CompactTextString(m) }
+func (*PathDistStatMsg) ProtoMessage()    {}
+func (*PathDistStatMsg) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e8f5151eb02dd926, []int{7}
+}
+
+func (m *PathDistStatMsg) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PathDistStatMsg.Unmarshal(m, b)
+}
+func (m *PathDistStatMsg) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PathDistStatMsg.Marshal(b, m, deterministic)
+}
+func (m *PathDistStatMsg) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PathDistStatMsg.Merge(m, src)
+}
+func (m *PathDistStatMsg) XXX_Size() int {
+	return xxx_messageInfo_PathDistStatMsg.Size(m)
+}
+func (m *PathDistStatMsg) XXX_DiscardUnknown() {
+	xxx_messageInfo_PathDistStatMsg.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PathDistStatMsg proto.InternalMessageInfo
+
+func (m *PathDistStatMsg) GetD() int32 {
+	if m != nil {
+		return m.D
+	}
+	return 0
+}
+
+func (m *PathDistStatMsg) GetNbNodes() int32 {
+	if m != nil {
+		return m.NbNodes
+	}
+	return 0
+}
+
+func (m *PathDistStatMsg) GetNbDeadEnds() int32 {
+	if m != nil {
+		return m.NbDeadEnds
+	}
+	return 0
+}
+
+func (m *PathDistStatMsg) GetNbConflicts() int32 {
+	if m != nil {
+		return m.NbConflicts
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*PathContextRequestMsg)(nil), "m3api.PathContextRequestMsg")
 	proto.RegisterType((*PathContextIdMsg)(nil), "m3api.PathContextIdMsg")
@@ -461,6 +532,7 @@ func init() {
 	proto.RegisterType((*PathNodeMsg)(nil), "m3api.PathNodeMsg")
 	proto.RegisterType((*PathNodesRequestMsg)(nil), "m3api.PathNodesRequestMsg")
 	proto.RegisterType((*PathNodesResponseMsg)(nil), "m3api.PathNodesResponseMsg")
+	proto.RegisterType((*PathDistStatMsg)(nil), "m3api.PathDistStatMsg")
 }
 
 func init() {
@@ -468,37 +540,42 @@ func init() {
 }
 
 var fileDescriptor_e8f5151eb02dd926 = []byte{
-	// 504 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x54, 0xcd, 0x8e, 0xd3, 0x30,
-	0x18, 0x54, 0x36, 0xfd, 0x61, 0xbf, 0x24, 0x2d, 0x6b, 0x76, 0x45, 0xe0, 0x00, 0x21, 0x08, 0x51,
-	0x71, 0x28, 0xa2, 0xbd, 0x20, 0xae, 0xcb, 0x25, 0x12, 0x85, 0x2a, 0xe2, 0x1e, 0xa5, 0xb5, 0xb7,
-	0xb5, 0x4a, 0x6c, 0x53, 0x1b, 0x91, 0xbd, 0xf1, 0x1c, 0xbc, 0x17, 0xe2, 0x75, 0x90, 0x7f, 0xd2,
-	0x5a, 0x80, 0xd8, 0x3d, 0x70, 0xab, 0xc7, 0xf3, 0x79, 0x26, 0xf3, 0x8d, 0x0a, 0x71, 0x33, 0x17,
-	0xb5, 0xda, 0x4e, 0xc5, 0x9e, 0x2b, 0x8e, 0xfa, 0xcd, 0xbc, 0x16, 0xf4, 0x61, 0xd2, 0xcc, 0x05,
-	0xa7, 0x4c, 0x59, 0x34, 0xff, 0x16, 0xc0, 0xc5, 0xb2, 0x56, 0xdb, 0x4b, 0xce, 0x14, 0x69, 0x55,
-	0x49, 0x3e, 0x7f, 0x21, 0x52, 0x2d, 0xe4, 0x06, 0x3d, 0x86, 0x68, 0xb3, 0xe7, 0x5f, 0xd5, 0xb6,
-	0x52, 0xd7, 0x82, 0xa4, 0x41, 0x16, 0x4c, 0xfa, 0x25, 0x58, 0xe8, 0xe3, 0xb5, 0x20, 0xe8, 0x09,
-	0xc4, 0x8e, 0x40, 0x19, 0x26, 0x6d, 0x7a, 0x62, 0x18, 0x6e, 0xa8, 0xd0, 0x10, 0x7a, 0x0a, 0x89,
-	0xa3, 0xf0, 0xab, 0x2b, 0x49, 0x54, 0x1a, 0x1a, 0x8e, 0x9b, 0xfb, 0x60, 0xb0, 0x7c, 0x06, 0x77,
-	0x3d, 0x07, 0x05, 0xd6, 0xe2, 0x8f, 0x20, 0xd2, 0xd6, 0xab, 0xb5, 0x6a, 0x2b, 0x8a, 0x9d, 0xf8,
-	0xa9, 0x86, 0x2e, 0x55, 0x5b, 0xe0, 0xfc, 0xfb, 0x09, 0x8c, 0xbc, 0xa1, 0x5b, 0x8c, 0xa0, 0x17,
-	0x70, 0xe6, 0xbc, 0xac, 0xed, 0x90, 0x66, 0x59, 0xcf, 0x63, 0x7b, 0x71, 0x70, 0x70, 0x2b, 0xdf,
-	0xe8, 0x35, 0x8c, 0xf6, 0x9c, 0xab, 0xca, 0xa8, 0x32, 0x8e, 0x49, 0xda, 0xcb, 0x82, 0x49, 0x34,
-	0x43, 0x53, 0x93, 0xf4, 0x54, 0xfb, 0x7b, 0xcf, 0x31, 0x59, 0xc8, 0x4d, 0x19, 0x6b, 0x66, 0x07,
-	0xa0, 0x07, 0x70, 0xa7, 0xa9, 0xdb, 0x0a, 0x53, 0xa9, 0xd2, 0xbe, 0x79, 0x79, 0xd8, 0xd4, 0xed,
-	0x5b, 0x2a, 0xd5, 0xef, 0xa9, 0x0f, 0x6e, 0x4c, 0x7d, 0xf8, 0x47, 0xea, 0xf9, 0x12, 0x90, 0x97,
-	0xcd, 0x3b, 0x6a, 0xf7, 0xf9, 0x06, 0x12, 0x9b, 0x8f, 0x85, 0x65, 0x1a, 0x64, 0xe1, 0x24, 0x9a,
-	0x5d, 0x78, 0x6e, 0x8f, 0x69, 0x96, 0xb1, 0x38, 0x9e, 0x65, 0xfe, 0x33, 0x80, 0xc8, 0xfb, 0x1c,
-	0x94, 0x41, 0x7c, 0xf8, 0xea, 0x2e, 0xec, 0xb0, 0x04, 0xe1, 0x28, 0x05, 0x46, 0xcf, 0xa0, 0x6f,
-	0x6a, 0x66, 0x12, 0x8e, 0x66, 0xe3, 0x4e, 0x45, 0x63, 0xfa, 0x7d, 0x7b, 0x8b, 0x62, 0x08, 0xb0,
-	0x0b, 0x37, 0xc0, 0xe8, 0x3e, 0x0c, 0xd5, 0x9e, 0x72, 0xfd, 0x62, 0xcf, 0x60, 0x03, 0x7d, 0x2c,
-	0x30, 0x7a, 0x0e, 0xe3, 0x35, 0x67, 0x8c, 0xac, 0x15, 0xe5, 0xac, 0x6a, 0x6a, 0xb9, 0x33, 0xb9,
-	0x25, 0xe5, 0xe8, 0x08, 0x2f, 0x6a, 0xb9, 0x43, 0x2f, 0xe1, 0xfc, 0x13, 0x65, 0x3b, 0x82, 0x2b,
-	0xdf, 0x9f, 0x4c, 0x07, 0x59, 0x38, 0x09, 0xcb, 0x33, 0x7b, 0xb7, 0x3c, 0xd8, 0x94, 0xf9, 0x0a,
-	0xee, 0x75, 0x47, 0xe9, 0x95, 0xff, 0xa6, 0x32, 0x21, 0xe8, 0x99, 0xed, 0xd9, 0xfe, 0x98, 0xdf,
-	0xc6, 0x3d, 0xb7, 0x4b, 0x0d, 0x9d, 0x7b, 0xae, 0x77, 0x9a, 0xff, 0x08, 0xe0, 0xdc, 0x13, 0x91,
-	0x82, 0x33, 0x49, 0xfe, 0x83, 0x4a, 0xcf, 0x57, 0xf9, 0x57, 0xa9, 0x72, 0x48, 0xd8, 0xea, 0x98,
-	0x88, 0x74, 0xb5, 0x8a, 0xd8, 0xea, 0x60, 0x0b, 0xbd, 0x02, 0xf0, 0x08, 0xa1, 0xe9, 0xc6, 0xdf,
-	0x9a, 0x7c, 0xda, 0x2d, 0x59, 0xae, 0x06, 0xe6, 0x2f, 0x64, 0xfe, 0x2b, 0x00, 0x00, 0xff, 0xff,
-	0xb5, 0xba, 0xe1, 0xb3, 0x68, 0x04, 0x00, 0x00,
+	// 587 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x54, 0x4b, 0x6f, 0xd3, 0x40,
+	0x18, 0x94, 0xeb, 0x3c, 0xda, 0xcf, 0x4e, 0x43, 0x97, 0x16, 0x5c, 0x0e, 0x60, 0x8c, 0x10, 0x11,
+	0x87, 0x20, 0x12, 0x21, 0x21, 0xae, 0x29, 0x87, 0x48, 0x04, 0x22, 0xc3, 0xdd, 0xb2, 0xb3, 0x9b,
+	0xc4, 0x4a, 0xbd, 0x6b, 0xf2, 0x2d, 0xc2, 0x3d, 0xc1, 0x5f, 0xe4, 0x80, 0xf8, 0x3b, 0x68, 0x1f,
+	0x4e, 0xac, 0x22, 0x68, 0x0f, 0xdc, 0xb2, 0xb3, 0xdf, 0x63, 0x3c, 0x33, 0x1b, 0xf0, 0x8b, 0x71,
+	0x99, 0xca, 0xf5, 0xb0, 0xdc, 0x0a, 0x29, 0x48, 0xbb, 0x18, 0xa7, 0x65, 0xfe, 0xa0, 0x57, 0x8c,
+	0x4b, 0x91, 0x73, 0x69, 0xd0, 0xe8, 0xbb, 0x03, 0x67, 0xf3, 0x54, 0xae, 0x27, 0x82, 0x4b, 0x56,
+	0xc9, 0x98, 0x7d, 0xfe, 0xc2, 0x50, 0xce, 0x70, 0x45, 0x1e, 0x81, 0xb7, 0xda, 0x8a, 0xaf, 0x72,
+	0x9d, 0xc8, 0xab, 0x92, 0x05, 0x4e, 0xe8, 0x0c, 0xda, 0x31, 0x18, 0xe8, 0xd3, 0x55, 0xc9, 0xc8,
+	0x63, 0xf0, 0x6d, 0x41, 0xce, 0x29, 0xab, 0x82, 0x03, 0x5d, 0x61, 0x9b, 0xa6, 0x0a, 0x22, 0x4f,
+	0xa0, 0x67, 0x4b, 0xc4, 0x72, 0x89, 0x4c, 0x06, 0xae, 0xae, 0xb1, 0x7d, 0x1f, 0x34, 0x16, 0x8d,
+	0xe0, 0x4e, 0x83, 0xc1, 0x94, 0xaa, 0xe5, 0x0f, 0xc1, 0x53, 0xd4, 0x93, 0x85, 0xac, 0x92, 0x9c,
+	0xda, 0xe5, 0x47, 0x0a, 0x9a, 0xc8, 0x6a, 0x4a, 0xa3, 0x1f, 0x07, 0x70, 0xdc, 0x68, 0xba, 0x45,
+	0x0b, 0x79, 0x0e, 0x27, 0x96, 0xcb, 0xc2, 0x34, 0xa9, 0x2a, 0xc3, 0xb9, 0x6f, 0x2e, 0x76, 0x0c,
+	0x6e, 0xc5, 0x9b, 0xbc, 0x86, 0xe3, 0xad, 0x10, 0x32, 0xd1, 0x5b, 0xb9, 0xa0, 0x2c, 0x68, 0x85,
+	0xce, 0xc0, 0x1b, 0x91, 0xa1, 0x56, 0x7a, 0xa8, 0xf8, 0xbd, 0x17, 0x94, 0xcd, 0x70, 0x15, 0xfb,
+	0xaa, 0xb2, 0x06, 0xc8, 0x39, 0x1c, 0x16, 0x69, 0x95, 0xd0, 0x1c, 0x65, 0xd0, 0xd6, 0x93, 0xbb,
+	0x45, 0x5a, 0x5d, 0xe4, 0x28, 0xaf, 0xab, 0xde, 0xb9, 0x51, 0xf5, 0xee, 0x9f, 0xaa, 0xbf, 0x02,
+	0x50, 0xa3, 0x13, 0x94, 0xa9, 0xc4, 0xe0, 0x30, 0x74, 0x07, 0xde, 0xe8, 0x5e, 0x83, 0x94, 0x5a,
+	0xf4, 0x51, 0xa6, 0x4a, 0xb5, 0xf8, 0x88, 0xda, 0x03, 0x46, 0x73, 0x20, 0x0d, 0x49, 0xdf, 0xe5,
+	0x26, 0x06, 0x6f, 0xa0, 0x67, 0x64, 0x35, 0x30, 0x06, 0x8e, 0x9e, 0x77, 0xd6, 0x98, 0xb7, 0x37,
+	0x21, 0xf6, 0xcb, 0xfd, 0x19, 0xa3, 0x5f, 0x0e, 0x78, 0x0d, 0x15, 0x48, 0x08, 0xfe, 0x4e, 0xac,
+	0xda, 0x23, 0x37, 0x86, 0xd2, 0x96, 0x4c, 0x29, 0x79, 0x0a, 0x6d, 0x9d, 0x4e, 0x6d, 0x8c, 0x37,
+	0xea, 0xd7, 0x5b, 0x14, 0xa6, 0xe6, 0x9b, 0x5b, 0xe2, 0x83, 0x43, 0xad, 0x27, 0x0e, 0x25, 0xf7,
+	0xa1, 0x2b, 0xb7, 0xb9, 0x50, 0x13, 0x5b, 0x1a, 0xeb, 0xa8, 0xe3, 0x94, 0x92, 0x67, 0xd0, 0x5f,
+	0x08, 0xce, 0xd9, 0x42, 0xe6, 0x82, 0x27, 0x45, 0x8a, 0x1b, 0x2d, 0x77, 0x2f, 0x3e, 0xde, 0xc3,
+	0xb3, 0x14, 0x37, 0xe4, 0x05, 0x9c, 0x5e, 0xe6, 0x7c, 0xc3, 0x68, 0xd2, 0xe4, 0x87, 0x41, 0x27,
+	0x74, 0x07, 0x6e, 0x7c, 0x62, 0xee, 0xe6, 0x3b, 0x9a, 0x18, 0x65, 0x70, 0xb7, 0x3e, 0x62, 0xe3,
+	0xcd, 0xdc, 0x94, 0x41, 0x02, 0x2d, 0x6d, 0xba, 0x89, 0x9d, 0xfe, 0xad, 0xd9, 0x0b, 0x93, 0x05,
+	0xd7, 0xb2, 0x17, 0xca, 0xa1, 0xe8, 0xa7, 0x03, 0xa7, 0x8d, 0x25, 0x58, 0x0a, 0x8e, 0xec, 0x3f,
+	0x6c, 0x69, 0x35, 0xb7, 0xfc, 0x2b, 0x8b, 0x11, 0xf4, 0x78, 0xb6, 0x57, 0x04, 0x6d, 0x1a, 0x3d,
+	0x9e, 0xed, 0x68, 0x91, 0x97, 0x00, 0x8d, 0x02, 0x37, 0x74, 0xff, 0xf2, 0x00, 0x8e, 0x6a, 0x93,
+	0x31, 0xfa, 0x06, 0xfd, 0x6b, 0x29, 0x34, 0x7e, 0x3a, 0xb5, 0x9f, 0xe7, 0x70, 0xc8, 0x33, 0x3b,
+	0xd1, 0x7c, 0x43, 0x97, 0x67, 0x66, 0x5d, 0x08, 0x3e, 0xcf, 0x12, 0xca, 0x52, 0x9a, 0x30, 0x4e,
+	0xd1, 0x2a, 0x06, 0x3c, 0xbb, 0x60, 0x29, 0x7d, 0xcb, 0x29, 0xaa, 0xf7, 0xc1, 0x33, 0x95, 0xd6,
+	0xe5, 0x65, 0xbe, 0x90, 0x68, 0xbf, 0xd6, 0xe3, 0xd9, 0xa4, 0x86, 0xb2, 0x8e, 0xfe, 0xeb, 0x1b,
+	0xff, 0x1e, 0x00, 0xd1, 0xc8, 0xb7, 0x98, 0x20, 0x05, 0x00, 0x00,
 }
@@ -181,6 +181,7 @@ type GrowthContextMsg struct {
 	GrowthContextId      int32    `protobuf:"varint,1,opt,name=growth_context_id,json=growthContextId,proto3" json:"growth_context_id" query:"growth_context_id"`
 	GrowthType           int32    `protobuf:"varint,2,opt,name=growth_type,json=growthType,proto3" json:"growth_type" query:"growth_type"`
 	GrowthIndex          int32    `protobuf:"varint,3,opt,name=growth_index,json=growthIndex,proto3" json:"growth_index" query:"growth_index"`
+	TrioSequence         []int32  `protobuf:"varint,4,rep,packed,name=trio_sequence,json=trioSequence,proto3" json:"trio_sequence,omitempty" query:"trio_sequence"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-" query:"-"`
 	XXX_unrecognized     []byte   `json:"-" query:"-"`
 	XXX_sizecache        int32    `json:"-" query:"-"`
@@ -232,16 +233,24 @@ func (m *GrowthContextMsg) GetGrowthIndex() int32 {
 	return 0
 }
 
+func (m *GrowthContextMsg) GetTrioSequence() []int32 {
+	if m != nil {
+		return m.TrioSequence
+	}
+	return nil
+}
+
 type PointPackDataMsg struct {
-	AllConnections          []*ConnectionMsg    `protobuf:"bytes,1,rep,name=all_connections,json=allConnections,proto3" json:"all_connections,omitempty" query:"-"`
-	AllTrios                []*TrioMsg          `protobuf:"bytes,2,rep,name=all_trios,json=allTrios,proto3" json:"all_trios,omitempty" query:"-"`
-	AllGrowthContexts       []*GrowthContextMsg `protobuf:"bytes,3,rep,name=all_growth_contexts,json=allGrowthContexts,proto3" json:"all_growth_contexts,omitempty" query:"-"`
-	ValidNextTrioIds        []int32             `protobuf:"varint,6,rep,packed,name=valid_next_trio_ids,json=validNextTrioIds,proto3" json:"valid_next_trio_ids,omitempty" query:"valid_next_trio_ids"`
-	Mod4PermutationsTrioIds []int32             `protobuf:"varint,7,rep,packed,name=mod4_permutations_trio_ids,json=mod4PermutationsTrioIds,proto3" json:"mod4_permutations_trio_ids,omitempty"`
-	Mod8PermutationsTrioIds []int32             `protobuf:"varint,8,rep,packed,name=mod8_permutations_trio_ids,json=mod8PermutationsTrioIds,proto3" json:"mod8_permutations_trio_ids,omitempty"`
-	XXX_NoUnkeyedLiteral    struct{}            `json:"-" query:"-"`
-	XXX_unrecognized        []byte              `json:"-" query:"-"`
-	XXX_sizecache           int32               `json:"-" query:"-"`
+	AllConnections          []*ConnectionMsg         `protobuf:"bytes,1,rep,name=all_connections,json=allConnections,proto3" json:"all_connections,omitempty" query:"-"`
+	AllTrios                []*TrioMsg               `protobuf:"bytes,2,rep,name=all_trios,json=allTrios,proto3" json:"all_trios,omitempty" query:"-"`
+	AllGrowthContexts       []*GrowthContextMsg      `protobuf:"bytes,3,rep,name=all_growth_contexts,json=allGrowthContexts,proto3" json:"all_growth_contexts,omitempty" query:"-"`
+	ValidNextTrioIds        []int32                  `protobuf:"varint,6,rep,packed,name=valid_next_trio_ids,json=validNextTrioIds,proto3" json:"valid_next_trio_ids,omitempty" query:"valid_next_trio_ids"`
+	Mod4PermutationsTrioIds []int32                  `protobuf:"varint,7,rep,packed,name=mod4_permutations_trio_ids,json=mod4PermutationsTrioIds,proto3" json:"mod4_permutations_trio_ids,omitempty"`
+	Mod8PermutationsTrioIds []int32                  `protobuf:"varint,8,rep,packed,name=mod8_permutations_trio_ids,json=mod8PermutationsTrioIds,proto3" json:"mod8_permutations_trio_ids,omitempty"`
+	PredictedSizeModels     []*PredictedSizeModelMsg `protobuf:"bytes,9,rep,name=predicted_size_models,json=predictedSizeModels,proto3" json:"predicted_size_models,omitempty" query:"-"`
+	XXX_NoUnkeyedLiteral    struct{}                 `json:"-" query:"-"`
+	XXX_unrecognized        []byte                   `json:"-" query:"-"`
+	XXX_sizecache           int32                    `json:"-" query:"-"`
 }
 
 func (m *PointPackDataMsg) Reset()         { *m = PointPackDataMsg{} }
@@ -311,12 +320,99 @@ func (m *PointPackDataMsg) GetMod8PermutationsTrioIds() []int32 {
 	return nil
 }
 
+func (m *PointPackDataMsg) GetPredictedSizeModels() []*PredictedSizeModelMsg {
+	if m != nil {
+		return m.PredictedSizeModels
+	}
+	return nil
+}
+
+type PredictedSizeModelMsg struct {
+	GrowthType           int32    `protobuf:"varint,1,opt,name=growth_type,json=growthType,proto3" json:"growth_type" query:"growth_type"`
+	GrowthIndex          int32    `protobuf:"varint,2,opt,name=growth_index,json=growthIndex,proto3" json:"growth_index" query:"growth_index"`
+	A                    float64  `protobuf:"fixed64,3,opt,name=a,proto3" json:"a" query:"a"`
+	B                    float64  `protobuf:"fixed64,4,opt,name=b,proto3" json:"b" query:"b"`
+	C                    float64  `protobuf:"fixed64,5,opt,name=c,proto3" json:"c" query:"c"`
+	MaxDist              int32    `protobuf:"varint,6,opt,name=max_dist,json=maxDist,proto3" json:"max_dist" query:"max_dist"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-" query:"-"`
+	XXX_unrecognized     []byte   `json:"-" query:"-"`
+	XXX_sizecache        int32    `json:"-" query:"-"`
+}
+
+func (m *PredictedSizeModelMsg) Reset()         { *m = PredictedSizeModelMsg{} }
+func (m *PredictedSizeModelMsg) String() string { return proto.CompactTextString(m) }
+func (*PredictedSizeModelMsg) ProtoMessage()    {}
+func (*PredictedSizeModelMsg) Descriptor() ([]byte, []int) {
+	return fileDescriptor_168a29c33716c6bb, []int{5}
+}
+
+func (m *PredictedSizeModelMsg) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PredictedSizeModelMsg.Unmarshal(m, b)
+}
+func (m *PredictedSizeModelMsg) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PredictedSizeModelMsg.Marshal(b, m, deterministic)
+}
+func (m *PredictedSizeModelMsg) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PredictedSizeModelMsg.Merge(m, src)
+}
+func (m *PredictedSizeModelMsg) XXX_Size() int {
+	return xxx_messageInfo_PredictedSizeModelMsg.Size(m)
+}
+func (m *PredictedSizeModelMsg) XXX_DiscardUnknown() {
+	xxx_messageInfo_PredictedSizeModelMsg.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PredictedSizeModelMsg proto.InternalMessageInfo
+
+func (m *PredictedSizeModelMsg) GetGrowthType() int32 {
+	if m != nil {
+		return m.GrowthType
+	}
+	return 0
+}
+
+func (m *PredictedSizeModelMsg) GetGrowthIndex() int32 {
+	if m != nil {
+		return m.GrowthIndex
+	}
+	return 0
+}
+
+func (m *PredictedSizeModelMsg) GetA() float64 {
+	if m != nil {
+		return m.A
+	}
+	return 0
+}
+
+func (m *PredictedSizeModelMsg) GetB() float64 {
+	if m != nil {
+		return m.B
+	}
+	return 0
+}
+
+func (m *PredictedSizeModelMsg) GetC() float64 {
+	if m != nil {
+		return m.C
+	}
+	return 0
+}
+
+func (m *PredictedSizeModelMsg) GetMaxDist() int32 {
+	if m != nil {
+		return m.MaxDist
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*PointMsg)(nil), "m3api.PointMsg")
 	proto.RegisterType((*ConnectionMsg)(nil), "m3api.ConnectionMsg")
 	proto.RegisterType((*TrioMsg)(nil), "m3api.TrioMsg")
 	proto.RegisterType((*GrowthContextMsg)(nil), "m3api.GrowthContextMsg")
 	proto.RegisterType((*PointPackDataMsg)(nil), "m3api.PointPackDataMsg")
+	proto.RegisterType((*PredictedSizeModelMsg)(nil), "m3api.PredictedSizeModelMsg")
 }
 
 func init() {
@@ -324,32 +420,39 @@ func init() {
 }
 
 var fileDescriptor_168a29c33716c6bb = []byte{
-	// 427 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x92, 0x4f, 0x6f, 0xd3, 0x30,
-	0x18, 0xc6, 0x95, 0x84, 0xa4, 0xe5, 0xed, 0xd6, 0x3f, 0x1e, 0x52, 0x03, 0x17, 0x4a, 0x2e, 0x54,
-	0x20, 0x7a, 0x68, 0x77, 0x98, 0x84, 0x76, 0x1a, 0xd2, 0xd4, 0x03, 0xa8, 0xb2, 0x7a, 0xaf, 0x4c,
-	0x6c, 0x15, 0x0b, 0xd7, 0x8e, 0x62, 0x33, 0xd2, 0x1d, 0xf9, 0xc4, 0x7c, 0x04, 0xf4, 0x3a, 0x1e,
-	0x5d, 0x10, 0xbb, 0xf5, 0x7d, 0xf2, 0xfc, 0xde, 0x3f, 0x8f, 0x0b, 0xe7, 0x87, 0x55, 0x65, 0xa4,
-	0x76, 0x8b, 0xaa, 0x36, 0xce, 0x90, 0xf4, 0xb0, 0x62, 0x95, 0x2c, 0x2e, 0xa1, 0xbf, 0x41, 0xf5,
-	0xb3, 0xdd, 0x93, 0x33, 0x88, 0x9a, 0x3c, 0x9e, 0x45, 0xf3, 0x09, 0x8d, 0x1a, 0xac, 0x8e, 0x79,
-	0xd2, 0x56, 0x47, 0xac, 0xee, 0xf3, 0x67, 0x6d, 0x75, 0x5f, 0x30, 0x38, 0xbf, 0x31, 0x5a, 0x8b,
-	0xd2, 0x49, 0xa3, 0x11, 0x9d, 0x42, 0xaf, 0x34, 0x5a, 0xef, 0x24, 0xcf, 0x23, 0x6f, 0xca, 0xb0,
-	0x5c, 0x73, 0xf2, 0x16, 0xb2, 0x3b, 0x51, 0x3a, 0x53, 0xfb, 0xc6, 0x83, 0xe5, 0x68, 0xe1, 0xe7,
-	0x2e, 0x1e, 0x86, 0xd2, 0xf0, 0x99, 0x0c, 0x21, 0xe6, 0xd6, 0xcf, 0x4b, 0x68, 0xcc, 0x6d, 0x71,
-	0x0d, 0xbd, 0x6d, 0x2d, 0x4d, 0x68, 0xee, 0x6a, 0x69, 0x1e, 0x9a, 0xa7, 0x34, 0xc3, 0x72, 0xcd,
-	0xc9, 0x4b, 0xe8, 0x87, 0xa9, 0x36, 0x8f, 0x67, 0xc9, 0x7c, 0x42, 0x7b, 0xed, 0x58, 0x5b, 0xfc,
-	0x8a, 0x60, 0x7c, 0x5b, 0x9b, 0x9f, 0xee, 0xdb, 0x8d, 0xd1, 0x4e, 0x34, 0xfe, 0xc0, 0x77, 0x30,
-	0xd9, 0x7b, 0x6d, 0x57, 0xb6, 0xe2, 0xa9, 0xe5, 0x68, 0xff, 0xd8, 0xbc, 0xe6, 0xe4, 0x35, 0x0c,
-	0x82, 0xd7, 0x1d, 0x2b, 0xe1, 0xb7, 0x4f, 0x29, 0xb4, 0xd2, 0xf6, 0x58, 0x09, 0xf2, 0x06, 0xce,
-	0x82, 0x41, 0x6a, 0x2e, 0x1a, 0xbf, 0x7a, 0x4a, 0x03, 0xb4, 0x46, 0xa9, 0xf8, 0x1d, 0xc3, 0xd8,
-	0x1f, 0xba, 0x61, 0xe5, 0xf7, 0x4f, 0xcc, 0x31, 0x5c, 0xe2, 0x1a, 0x46, 0x4c, 0x29, 0xdc, 0x20,
-	0xe4, 0x67, 0xf3, 0x68, 0x96, 0xcc, 0x07, 0xcb, 0x17, 0x21, 0x9a, 0x4e, 0xb2, 0x74, 0xc8, 0x94,
-	0x3a, 0x29, 0x96, 0xbc, 0x87, 0xe7, 0x88, 0x63, 0x02, 0xed, 0xd1, 0x83, 0xe5, 0x30, 0x80, 0x21,
-	0x2f, 0xda, 0x67, 0x4a, 0xe1, 0x6f, 0x4b, 0x6e, 0xe1, 0x02, 0xcd, 0xdd, 0xa3, 0x31, 0x65, 0xc4,
-	0xa6, 0x01, 0xfb, 0x37, 0x26, 0x3a, 0x61, 0x4a, 0x75, 0x44, 0x4b, 0x3e, 0xc0, 0xc5, 0x1d, 0x53,
-	0x92, 0xef, 0x34, 0xa6, 0x16, 0x5e, 0xc3, 0xe6, 0xd9, 0x2c, 0x99, 0xa7, 0x74, 0xec, 0x3f, 0x7d,
-	0x11, 0x8d, 0xdb, 0xfa, 0x77, 0xb1, 0xe4, 0x23, 0xbc, 0x3a, 0x18, 0x7e, 0xb9, 0xab, 0x44, 0x7d,
-	0xf8, 0xe1, 0x98, 0xdf, 0xfc, 0x44, 0xf5, 0x3c, 0x35, 0x45, 0xc7, 0xe6, 0x91, 0xa1, 0x0b, 0x5f,
-	0x3d, 0x01, 0xf7, 0xff, 0xc2, 0x57, 0xff, 0x81, 0xbf, 0x66, 0xfe, 0xdf, 0xbd, 0xfa, 0x13, 0x00,
-	0x00, 0xff, 0xff, 0x53, 0x9d, 0x8f, 0x5c, 0xee, 0x02, 0x00, 0x00,
+	// 542 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x53, 0x51, 0x6f, 0xda, 0x3c,
+	0x14, 0x95, 0x49, 0x13, 0xe8, 0x85, 0xb6, 0x60, 0xbe, 0x8a, 0x7c, 0xd3, 0xa4, 0xb1, 0xec, 0x61,
+	0x68, 0xd3, 0x78, 0x80, 0x3e, 0x54, 0x9a, 0xfa, 0xd4, 0x4a, 0x15, 0x0f, 0x9d, 0x90, 0xcb, 0x7b,
+	0x64, 0x62, 0x8b, 0x59, 0x4b, 0xe2, 0x2c, 0x76, 0xbb, 0xc0, 0xaf, 0xd9, 0xdb, 0xfe, 0xc7, 0x7e,
+	0xd9, 0x64, 0xc7, 0x94, 0xd2, 0x76, 0xda, 0x5b, 0xce, 0xc9, 0x39, 0xf7, 0x5e, 0x1f, 0x5f, 0xc3,
+	0x51, 0x36, 0x2d, 0xa4, 0xc8, 0xf5, 0xb8, 0x28, 0xa5, 0x96, 0xd8, 0xcf, 0xa6, 0xb4, 0x10, 0xd1,
+	0x19, 0xb4, 0xe6, 0x86, 0xbd, 0x51, 0x2b, 0xdc, 0x01, 0x54, 0x85, 0x8d, 0x21, 0x1a, 0xf5, 0x08,
+	0xaa, 0x0c, 0x5a, 0x87, 0x5e, 0x8d, 0xd6, 0x06, 0x6d, 0xc2, 0x83, 0x1a, 0x6d, 0x22, 0x0a, 0x47,
+	0x97, 0x32, 0xcf, 0x79, 0xa2, 0x85, 0xcc, 0x8d, 0x75, 0x00, 0xcd, 0x44, 0xe6, 0x79, 0x2c, 0x58,
+	0x88, 0xac, 0x28, 0x30, 0x70, 0xc6, 0xf0, 0x7b, 0x08, 0xee, 0x79, 0xa2, 0x65, 0x69, 0x0b, 0xb7,
+	0x27, 0x27, 0x63, 0xdb, 0x77, 0xbc, 0x6d, 0x4a, 0xdc, 0x6f, 0x7c, 0x0c, 0x0d, 0xa6, 0x6c, 0x3f,
+	0x8f, 0x34, 0x98, 0x8a, 0x2e, 0xa0, 0xb9, 0x28, 0x85, 0x74, 0xc5, 0x75, 0x29, 0xe4, 0xb6, 0xb8,
+	0x4f, 0x02, 0x03, 0x67, 0x0c, 0xff, 0x0f, 0x2d, 0xd7, 0x55, 0x85, 0x8d, 0xa1, 0x37, 0xea, 0x91,
+	0x66, 0xdd, 0x56, 0x45, 0xbf, 0x10, 0x74, 0xaf, 0x4b, 0xf9, 0x43, 0x7f, 0xbd, 0x94, 0xb9, 0xe6,
+	0x95, 0x3d, 0xe0, 0x07, 0xe8, 0xad, 0x2c, 0x17, 0x27, 0x35, 0xb9, 0x2b, 0x79, 0xb2, 0x7a, 0x2c,
+	0x9e, 0x31, 0xfc, 0x06, 0xda, 0x4e, 0xab, 0xd7, 0x05, 0xb7, 0xd3, 0xfb, 0x04, 0x6a, 0x6a, 0xb1,
+	0x2e, 0x38, 0x7e, 0x0b, 0x1d, 0x27, 0x10, 0x39, 0xe3, 0x95, 0x1d, 0xdd, 0x27, 0xce, 0x34, 0x33,
+	0x14, 0x7e, 0x07, 0x47, 0x76, 0x70, 0xc5, 0xbf, 0xdf, 0xf1, 0x3c, 0xe1, 0xe1, 0xc1, 0xd0, 0x1b,
+	0xf9, 0xa4, 0x63, 0xc8, 0x5b, 0xc7, 0x45, 0xbf, 0x3d, 0xe8, 0xda, 0x34, 0xe6, 0x34, 0xf9, 0x76,
+	0x45, 0x35, 0x35, 0x93, 0x5e, 0xc0, 0x09, 0x4d, 0xd3, 0x38, 0x79, 0x08, 0x59, 0x85, 0x68, 0xe8,
+	0x8d, 0xda, 0x93, 0xff, 0x5c, 0x7e, 0x7b, 0xf1, 0x93, 0x63, 0x9a, 0xa6, 0x3b, 0x46, 0xe1, 0x8f,
+	0x70, 0x68, 0xec, 0xa6, 0x4f, 0x9d, 0x4c, 0x7b, 0x72, 0xec, 0x8c, 0x2e, 0x54, 0xd2, 0xa2, 0x69,
+	0x6a, 0xbe, 0x15, 0xbe, 0x86, 0xbe, 0x11, 0xef, 0x27, 0x63, 0xae, 0xc2, 0xd8, 0x06, 0xce, 0xf6,
+	0x34, 0x4b, 0xd2, 0xa3, 0x69, 0xba, 0x47, 0x2a, 0xfc, 0x09, 0xfa, 0xf7, 0x34, 0x15, 0x2c, 0xce,
+	0x4d, 0xb4, 0xee, 0xca, 0x54, 0x18, 0xd8, 0x43, 0x77, 0xed, 0xaf, 0x2f, 0xbc, 0xd2, 0x0b, 0x7b,
+	0x79, 0x0a, 0x7f, 0x86, 0x57, 0x99, 0x64, 0x67, 0x71, 0xc1, 0xcb, 0xec, 0x4e, 0x53, 0x3b, 0xf9,
+	0xce, 0xd5, 0xb4, 0xae, 0x81, 0x51, 0xcc, 0x1f, 0x09, 0xf6, 0xcd, 0xe7, 0x7f, 0x31, 0xb7, 0x1e,
+	0xcc, 0xe7, 0x2f, 0x99, 0xe7, 0x70, 0x5a, 0x94, 0x9c, 0x89, 0x44, 0x73, 0x16, 0x2b, 0xb1, 0xe1,
+	0x71, 0x26, 0x19, 0x4f, 0x55, 0x78, 0x68, 0xcf, 0xfc, 0x7a, 0xbb, 0xa3, 0x5b, 0xcd, 0xad, 0xd8,
+	0xf0, 0x1b, 0xa3, 0x30, 0x07, 0xef, 0x17, 0xcf, 0x68, 0x15, 0xfd, 0x44, 0x70, 0xfa, 0xa2, 0xfc,
+	0xe9, 0x1e, 0xa1, 0x7f, 0xee, 0x51, 0xe3, 0xf9, 0x1e, 0x75, 0x00, 0x51, 0xbb, 0x5f, 0x88, 0x20,
+	0x6a, 0xd0, 0xd2, 0x3e, 0x45, 0x44, 0xd0, 0xd2, 0xa0, 0x24, 0xf4, 0x6b, 0x94, 0x98, 0x17, 0x91,
+	0xd1, 0x2a, 0x66, 0x42, 0xe9, 0x30, 0xb0, 0x85, 0x9a, 0x19, 0xad, 0xae, 0x84, 0xd2, 0xcb, 0xc0,
+	0xbe, 0xfb, 0xe9, 0x9f, 0x01, 0x00, 0x79, 0x1e, 0xf1, 0xa0, 0x08, 0x04, 0x00, 0x00,
 }
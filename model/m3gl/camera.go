@@ -0,0 +1,227 @@
+package m3gl
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"sort"
+)
+
+type CameraMode uint8
+
+const (
+	// The eye turns around the target
+	OrbitCamera CameraMode = iota
+	// The eye stays in place and looks around, moving with the fly keys
+	FlyCamera
+)
+
+const (
+	// The distance to the target is EyeDist on each axis of the default diagonal view
+	eyeDistRatio = 1.7320508075688772
+	// The closest the eye can zoom to the target
+	minZoomDist = 1.0
+	maxPitch    = math.Pi/2.0 - 0.01
+)
+
+// The default view looks at the origin from the X, Y, Z diagonal
+var (
+	defaultYaw   = math.Pi / 4.0
+	defaultPitch = math.Asin(1.0 / eyeDistRatio)
+)
+
+/*
+The position of the eye relative to the target, the zero value is the diagonal view used before
+any mouse or keyboard move.
+*/
+type ViewCamera struct {
+	Mode   CameraMode
+	Target mgl32.Vec3
+	// Rotations in radians added to the default diagonal view, yaw around Z and pitch above the XY plane
+	Yaw, Pitch float64
+	// The number of event centers focused on, to cycle through the active events
+	eventFocusCount int
+}
+
+func (mode CameraMode) String() string {
+	if mode == FlyCamera {
+		return "fly"
+	}
+	return "orbit"
+}
+
+// The unit vector from the target to the eye
+func (view *ViewCamera) eyeDirection() mgl32.Vec3 {
+	yaw := defaultYaw + view.Yaw
+	pitch := defaultPitch + view.Pitch
+	return mgl32.Vec3{
+		float32(math.Cos(pitch) * math.Cos(yaw)),
+		float32(math.Cos(pitch) * math.Sin(yaw)),
+		float32(math.Sin(pitch)),
+	}
+}
+
+func (world *DisplayWorld) eyeDistance() float32 {
+	return float32(world.EyeDist.Val * eyeDistRatio)
+}
+
+func (world *DisplayWorld) EyePosition() mgl32.Vec3 {
+	return world.View.Target.Add(world.View.eyeDirection().Mul(world.eyeDistance()))
+}
+
+// The unit vectors looking forward, to the right and up of the screen
+func (world *DisplayWorld) viewAxes() (mgl32.Vec3, mgl32.Vec3, mgl32.Vec3) {
+	forward := world.View.eyeDirection().Mul(-1.0)
+	right := forward.Cross(mgl32.Vec3{0, 0, 1}).Normalize()
+	up := right.Cross(forward)
+	return forward, right, up
+}
+
+func (world *DisplayWorld) ResetView() {
+	world.View = ViewCamera{Mode: world.View.Mode}
+	world.SetMatrices()
+}
+
+func (world *DisplayWorld) SwitchCameraMode() {
+	if world.View.Mode == OrbitCamera {
+		world.View.Mode = FlyCamera
+	} else {
+		world.View.Mode = OrbitCamera
+	}
+}
+
+/*
+Turn the view by the angles in radians. In orbit mode the eye turns around the target,
+in fly mode the target turns around the eye.
+*/
+func (world *DisplayWorld) Orbit(dYaw, dPitch float64) {
+	eye := world.EyePosition()
+	world.View.Yaw = math.Mod(world.View.Yaw+dYaw, 2.0*math.Pi)
+	pitch := defaultPitch + world.View.Pitch + dPitch
+	if pitch > maxPitch {
+		pitch = maxPitch
+	}
+	if pitch < -maxPitch {
+		pitch = -maxPitch
+	}
+	world.View.Pitch = pitch - defaultPitch
+	if world.View.Mode == FlyCamera {
+		world.View.Target = eye.Sub(world.View.eyeDirection().Mul(world.eyeDistance()))
+	}
+	world.SetMatrices()
+}
+
+// Move the eye and the target together, forward to where the eye looks, right and up of the screen
+func (world *DisplayWorld) Fly(forward, right, up float32) {
+	f, r, u := world.viewAxes()
+	world.View.Target = world.View.Target.Add(f.Mul(forward)).Add(r.Mul(right)).Add(u.Mul(up))
+	world.SetMatrices()
+}
+
+// Move by a number of framebuffer pixels, the target follows the mouse
+func (world *DisplayWorld) PanPixels(dx, dy float64) {
+	if world.Height == 0 {
+		return
+	}
+	fovY := float64(mgl32.DegToRad(float32(world.FovAngle.Val)))
+	unitsPerPixel := 2.0 * float64(world.eyeDistance()) * math.Tan(fovY/2.0) / float64(world.Height)
+	world.Fly(0.0, float32(-dx*unitsPerPixel), float32(dy*unitsPerPixel))
+}
+
+// Multiply the distance of the eye to the target, below one gets closer
+func (world *DisplayWorld) Zoom(factor float64) {
+	world.EyeDist.Val *= factor
+	if world.EyeDist.Val > world.EyeDist.Max {
+		world.EyeDist.Val = world.EyeDist.Max
+	}
+	if world.EyeDist.Val < minZoomDist {
+		world.EyeDist.Val = minZoomDist
+	}
+	world.SetMatrices()
+}
+
+// Look at the point of the elements, the auto rotation stops to keep it on screen
+func (world *DisplayWorld) FocusOn(p mgl32.Vec3) {
+	world.Angle.Enabled = false
+	rotation := mgl32.HomogRotate3D(float32(world.Angle.Value), mgl32.Vec3{0, 0, 1})
+	world.View.Target = mgl32.TransformCoordinate(p, rotation)
+	world.SetMatrices()
+}
+
+/*
+Focus on the center of the next active event, in event id order.
+Returns the event focused on, nil if no active events.
+*/
+func (world *DisplayWorld) FocusNextEventCenter() (m3space.EventIfc, error) {
+	events := world.GetSpaceTime().GetActiveEvents()
+	if len(events) == 0 {
+		return nil, nil
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].GetId() < events[j].GetId()
+	})
+	evt := events[world.View.eventFocusCount%len(events)]
+	p, err := evt.GetCenterNode().GetPoint()
+	if err != nil {
+		return nil, m3util.MakeWrapQsmErrorf(err, "could not get center of event %d due to %v", evt.GetId(), err)
+	}
+	world.View.eventFocusCount++
+	world.FocusOn(mgl32.Vec3{float32(p.X()), float32(p.Y()), float32(p.Z())})
+	return evt, nil
+}
+
+/*
+The middle of the displayed nodes reached by the most event colors, at least two.
+Returns the number of colors, or zero if no events met yet.
+*/
+func (world *DisplayWorld) MeetingPoint() (mgl32.Vec3, uint8) {
+	var sum mgl32.Vec3
+	count := 0
+	maxColors := uint8(2)
+	for _, obj := range world.Elements {
+		n, ok := obj.(*NodeDrawingElement)
+		if !ok || !n.Display(world.Filter) {
+			continue
+		}
+		nbColors := n.sdc.howManyColors()
+		if nbColors < maxColors {
+			continue
+		}
+		pos := n.Pos()
+		if pos == nil {
+			continue
+		}
+		if nbColors > maxColors {
+			maxColors = nbColors
+			sum = mgl32.Vec3{}
+			count = 0
+		}
+		sum = sum.Add(mgl32.Vec3{float32(pos.X()), float32(pos.Y()), float32(pos.Z())})
+		count++
+	}
+	if count == 0 {
+		return mgl32.Vec3{}, 0
+	}
+	return sum.Mul(1.0 / float32(count)), maxColors
+}
+
+// Focus on the meeting point of the events, false if they did not meet yet
+func (world *DisplayWorld) FocusMeetingPoint() bool {
+	p, nbColors := world.MeetingPoint()
+	if nbColors == 0 {
+		return false
+	}
+	world.FocusOn(p)
+	return true
+}
+
+func (world *DisplayWorld) DisplayCameraSettings() {
+	eye := world.EyePosition()
+	fmt.Println("========= Camera Settings =========")
+	fmt.Println("Mode [V]", world.View.Mode, ", Eye", eye, ", Target", world.View.Target)
+	fmt.Println("Mouse drag to turn, right drag to pan, scroll to zoom, [H] to reset")
+	fmt.Println("Fly [UP,DOWN,A,D,PAGE_UP,PAGE_DOWN], Focus on event center [E], on meeting point [M]")
+	world.Filter.Clip.DisplaySettings()
+}
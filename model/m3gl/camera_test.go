@@ -0,0 +1,175 @@
+package m3gl
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+type cameraTestCenter struct {
+	m3space.NodeEventIfc
+	p m3point.Point
+}
+
+func (c *cameraTestCenter) GetPoint() (*m3point.Point, error) {
+	return &c.p, nil
+}
+
+type cameraTestEvent struct {
+	m3space.EventIfc
+	id     m3space.EventId
+	center m3point.Point
+}
+
+func (evt *cameraTestEvent) GetId() m3space.EventId {
+	return evt.id
+}
+
+func (evt *cameraTestEvent) GetCenterNode() m3space.NodeEventIfc {
+	return &cameraTestCenter{p: evt.center}
+}
+
+type cameraTestSpaceTime struct {
+	m3space.SpaceTimeIfc
+	events []m3space.EventIfc
+}
+
+func (st *cameraTestSpaceTime) GetActiveEvents() []m3space.EventIfc {
+	return st.events
+}
+
+func assertVecEqual(t *testing.T, expected, actual mgl32.Vec3) {
+	for i := range expected {
+		assert.InDelta(t, expected[i], actual[i], 1e-4, "expected %v got %v", expected, actual)
+	}
+}
+
+func TestDefaultView(t *testing.T) {
+	world := makeAxesOnlyWorld(3, 320, 240)
+	d := float32(world.EyeDist.Val)
+	assertVecEqual(t, mgl32.Vec3{d, d, d}, world.EyePosition())
+	assert.True(t, mgl32.LookAtV(mgl32.Vec3{d, d, d}, mgl32.Vec3{}, mgl32.Vec3{0, 0, 1}).ApproxEqualThreshold(world.Camera, 1e-4))
+}
+
+func TestOrbitPanZoomFly(t *testing.T) {
+	world := makeAxesOnlyWorld(3, 320, 240)
+	dist := world.EyePosition().Len()
+
+	// Orbit keeps the distance to the target and the pitch stays under the pole
+	world.Orbit(math.Pi/2.0, 10.0)
+	eye := world.EyePosition()
+	assert.InDelta(t, dist, eye.Len(), 1e-4)
+	assert.InDelta(t, math.Sin(maxPitch), eye.Normalize().Z(), 1e-4)
+	world.ResetView()
+
+	// Panning moves the target and the eye sideways
+	world.PanPixels(100.0, 0.0)
+	assert.InDelta(t, 0.0, world.View.Target.Z(), 1e-4)
+	assert.True(t, world.View.Target.Len() > 0.1)
+	assert.InDelta(t, dist, world.EyePosition().Sub(world.View.Target).Len(), 1e-4)
+	world.ResetView()
+
+	// Zoom is bounded
+	world.Zoom(0.001)
+	assert.Equal(t, minZoomDist, world.EyeDist.Val)
+	world.Zoom(1000.0)
+	assert.Equal(t, world.EyeDist.Max, world.EyeDist.Val)
+	world.EyeDist.Val = 3.0
+	world.SetMatrices()
+
+	// In fly mode turning keeps the eye in place and moving forward goes where it looks
+	world.SwitchCameraMode()
+	assert.Equal(t, FlyCamera, world.View.Mode)
+	eye = world.EyePosition()
+	world.Orbit(0.3, -0.2)
+	assertVecEqual(t, eye, world.EyePosition())
+	forward, _, _ := world.viewAxes()
+	world.Fly(2.0, 0.0, 0.0)
+	assertVecEqual(t, eye.Add(forward.Mul(2.0)), world.EyePosition())
+}
+
+func TestFocus(t *testing.T) {
+	m3util.SetToTestMode()
+	world := makePickWorld()
+	world.Angle.Enabled = true
+	world.CurrentSpaceTime = &cameraTestSpaceTime{events: []m3space.EventIfc{
+		&cameraTestEvent{id: 4, center: m3point.Point{0, 0, -3}},
+		&cameraTestEvent{id: 2, center: m3point.Point{3, 0, 3}},
+	}}
+
+	evt, err := world.FocusNextEventCenter()
+	assert.NoError(t, err)
+	assert.Equal(t, m3space.EventId(2), evt.GetId())
+	assertVecEqual(t, mgl32.Vec3{3, 0, 3}, world.View.Target)
+	assert.False(t, world.Angle.Enabled)
+	evt, _ = world.FocusNextEventCenter()
+	assert.Equal(t, m3space.EventId(4), evt.GetId())
+	evt, _ = world.FocusNextEventCenter()
+	assert.Equal(t, m3space.EventId(2), evt.GetId())
+
+	// The focus follows the model rotation
+	world.Angle.Value = math.Pi / 2.0
+	world.View.eventFocusCount = 0
+	_, _ = world.FocusNextEventCenter()
+	assertVecEqual(t, mgl32.Vec3{0, 3, 3}, world.View.Target)
+
+	world.Angle.Value = 0.0
+	assert.False(t, world.FocusMeetingPoint())
+	for _, p := range []m3point.Point{{1, 1, 0}, {3, 3, 0}} {
+		world.Elements = append(world.Elements, MakeNodeDrawingElement(&pickTestNode{p: p, mask: uint8(m3space.RedEvent | m3space.GreenEvent)}))
+	}
+	p, nbColors := world.MeetingPoint()
+	assert.Equal(t, uint8(2), nbColors)
+	assertVecEqual(t, mgl32.Vec3{2, 2, 0}, p)
+	world.Elements = append(world.Elements, MakeNodeDrawingElement(&pickTestNode{p: m3point.Point{0, 0, 3}, mask: uint8(m3space.RedEvent | m3space.GreenEvent | m3space.BlueEvent)}))
+	assert.True(t, world.FocusMeetingPoint())
+	assertVecEqual(t, mgl32.Vec3{0, 0, 3}, world.View.Target)
+}
+
+func TestClipBox(t *testing.T) {
+	m3util.SetToTestMode()
+	world := makePickWorld()
+	nbDisplayed := func() int {
+		res := 0
+		for _, obj := range world.Elements {
+			if obj.Display(world.Filter) {
+				res++
+			}
+		}
+		return res
+	}
+	assert.Equal(t, 8, nbDisplayed())
+
+	clip := &world.Filter.Clip
+	clip.Switch(world.Max)
+	assert.Equal(t, m3point.Point{-3, -3, -3}, clip.Min)
+	assert.Equal(t, 8, nbDisplayed())
+
+	// Slice on X between 1 and 3 keeps the node at 3,0,0 and the axes
+	clip.MoveMin(4)
+	assert.Equal(t, 7, nbDisplayed())
+	assert.Nil(t, world.PickNode(nodePixel(t, world, 7)))
+	assert.NotNil(t, world.PickNode(nodePixel(t, world, 6)))
+
+	clip.MoveMax(-10)
+	assert.Equal(t, m3point.CInt(1), clip.Max[0])
+	assert.Equal(t, 6, nbDisplayed())
+	clip.Slide(2)
+	assert.Equal(t, m3point.Point{3, -3, -3}, clip.Min)
+	assert.Equal(t, m3point.Point{3, 3, 3}, clip.Max)
+	assert.Equal(t, 7, nbDisplayed())
+
+	clip.NextAxe()
+	clip.MoveMax(-4)
+	assert.Equal(t, 6, nbDisplayed())
+
+	// Disabled keeps the box for the next time
+	clip.Switch(world.Max)
+	assert.Equal(t, 8, nbDisplayed())
+	clip.Switch(world.Max)
+	assert.Equal(t, m3point.Point{3, -1, 3}, clip.Max)
+}
@@ -48,6 +48,16 @@ type SpaceDrawingFilter struct {
 	EventOutgrowthManyColorsThreshold uint8
 	// The space active Threshold
 	ActiveThreshold m3space.DistAndTime
+	// The nodes and connections outside are hidden when enabled
+	Clip ClipBox
+}
+
+// Slice planes on X, Y and Z, the elements between Min and Max included are displayed when enabled
+type ClipBox struct {
+	Enabled  bool
+	Min, Max m3point.Point
+	// The axe of the planes moved by the clip keys
+	Axe int
 }
 
 func (filter *SpaceDrawingFilter) DisplaySettings() {
@@ -75,6 +85,61 @@ func (filter *SpaceDrawingFilter) ColorMaskSwitch(color m3space.EventColor) {
 	filter.EventColorMask ^= uint8(color)
 }
 
+func (clip *ClipBox) DisplaySettings() {
+	fmt.Println("Clip Box [K]", clip.Enabled, "from", clip.Min, "to", clip.Max, ", Axe [J]", "XYZ"[clip.Axe:clip.Axe+1])
+	fmt.Println("Move lower plane [LEFT_BRACKET,RIGHT_BRACKET], upper plane [MINUS,EQUAL], both [COMMA,PERIOD]")
+}
+
+func (clip *ClipBox) Contains(p *m3point.Point) bool {
+	if !clip.Enabled {
+		return true
+	}
+	if p == nil {
+		return false
+	}
+	for axe := 0; axe < 3; axe++ {
+		if p[axe] < clip.Min[axe] || p[axe] > clip.Max[axe] {
+			return false
+		}
+	}
+	return true
+}
+
+// Enabling with an empty box opens it to the whole space up to max
+func (clip *ClipBox) Switch(max m3point.CInt) {
+	clip.Enabled = !clip.Enabled
+	if clip.Enabled && clip.Min == clip.Max {
+		clip.Min = m3point.Point{-max, -max, -max}
+		clip.Max = m3point.Point{max, max, max}
+	}
+}
+
+func (clip *ClipBox) NextAxe() {
+	clip.Axe = (clip.Axe + 1) % 3
+}
+
+// Move the lower plane of the current axe, it cannot pass the upper one
+func (clip *ClipBox) MoveMin(delta m3point.CInt) {
+	clip.Min[clip.Axe] += delta
+	if clip.Min[clip.Axe] > clip.Max[clip.Axe] {
+		clip.Min[clip.Axe] = clip.Max[clip.Axe]
+	}
+}
+
+// Move the upper plane of the current axe, it cannot pass the lower one
+func (clip *ClipBox) MoveMax(delta m3point.CInt) {
+	clip.Max[clip.Axe] += delta
+	if clip.Max[clip.Axe] < clip.Min[clip.Axe] {
+		clip.Max[clip.Axe] = clip.Min[clip.Axe]
+	}
+}
+
+// Slide both planes of the current axe to look at the space layer by layer
+func (clip *ClipBox) Slide(delta m3point.CInt) {
+	clip.Min[clip.Axe] += delta
+	clip.Max[clip.Axe] += delta
+}
+
 type SpaceDrawingColor struct {
 	// Bitwise flag of colors. Bits 0->red, 1->green, 2->blue, 3->yellow. If 0 then it means grey
 	objColors uint8
@@ -270,6 +335,9 @@ func (n NodeDrawingElement) Key() ObjectType {
 }
 
 func (n NodeDrawingElement) Display(filter SpaceDrawingFilter) bool {
+	if filter.Clip.Enabled && !filter.Clip.Contains(n.Pos()) {
+		return false
+	}
 	if n.objectType == NodeActive {
 		if n.node.HasRoot() {
 			return true
@@ -302,6 +370,9 @@ func (c ConnectionDrawingElement) Key() ObjectType {
 }
 
 func (c ConnectionDrawingElement) Display(filter SpaceDrawingFilter) bool {
+	if filter.Clip.Enabled && !filter.Clip.Contains(c.pos) {
+		return false
+	}
 	if c.sdc.objColors&filter.EventColorMask != uint8(0) && c.sdc.howManyColors() >= filter.EventOutgrowthManyColorsThreshold {
 		return true
 	}
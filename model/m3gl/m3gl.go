@@ -2,7 +2,6 @@ package m3gl
 
 import (
 	"fmt"
-	"github.com/freddy33/qsm-go/client"
 	"github.com/freddy33/qsm-go/m3util"
 	"github.com/freddy33/qsm-go/model/m3point"
 	"github.com/freddy33/qsm-go/model/m3space"
@@ -37,14 +36,15 @@ const (
 )
 
 type DisplayWorld struct {
-	env              *client.QsmApiEnvironment
-	pointData        *client.ClientPointPackData
+	pointData        m3point.PointPackDataIfc
 	Max              m3point.CInt
 	WorldSpace       m3space.SpaceIfc
 	CurrentTime      m3space.DistAndTime
 	CurrentSpaceTime m3space.SpaceTimeIfc
 	Filter           SpaceDrawingFilter
 	Elements         []SpaceDrawingElement
+	// The point of the node selected by picking, kept when time moves
+	Selected *m3point.Point
 
 	NbVertices         int
 	OpenGLBuffer       []float32
@@ -54,6 +54,7 @@ type DisplayWorld struct {
 	Width, Height  int
 	EyeDist        SizeVar
 	FovAngle       SizeVar
+	View           ViewCamera
 	LightDirection mgl32.Vec3
 	LightColor     mgl32.Vec3
 	Projection     mgl32.Mat4
@@ -78,30 +79,11 @@ type OpenGLDrawingElement struct {
 	NbVertices   int32
 }
 
-func MakeWorld(env *client.QsmApiEnvironment, spaceName string, Max m3point.CInt, glfwTime float64, activeThreshold m3space.DistAndTime) DisplayWorld {
-	if Max%m3point.THREE != 0 {
-		panic(fmt.Sprintf("cannot have a max %d not dividable by %d", Max, m3point.THREE))
-	}
+// World of an already loaded space, the client env or the DB env provides the point data
+func MakeWorldFromSpace(pointData m3point.PointPackDataIfc, space m3space.SpaceIfc, glfwTime float64) DisplayWorld {
 	verifyData()
-	spaceData := client.GetClientSpacePackData(env)
-	spaces := spaceData.GetAllSpaces()
-	var space m3space.SpaceIfc
-	var err error
-	for _, sp := range spaces {
-		if sp.GetName() == spaceName {
-			space = sp
-			break
-		}
-	}
-	if space == nil {
-		space, err = spaceData.CreateSpace(spaceName, activeThreshold, 2, 4)
-		if err != nil {
-			Log.Fatal(err)
-		}
-	}
 	world := DisplayWorld{}
-	world.env = env
-	world.pointData = client.GetClientPointPackData(env)
+	world.pointData = pointData
 	world.initialized(space, glfwTime)
 	world.CheckMax()
 
@@ -144,12 +126,14 @@ func (world *DisplayWorld) initialized(space m3space.SpaceIfc, glfwTime float64)
 		ActiveThreshold:                   space.GetActiveThreshold(),
 	}
 	world.Elements = make([]SpaceDrawingElement, 0, 500)
+	world.Selected = nil
 	world.NbVertices = 0
 	world.OpenGLBuffer = make([]float32, 0)
 	world.DrawingElementsMap = make(map[ObjectType]OpenGLDrawingElement)
 	world.Width = 800
 	world.Height = 600
 	world.FovAngle = SizeVar{10.0, 75.0, 30.0}
+	world.View = ViewCamera{}
 	world.LightDirection = mgl32.Vec3{-1.0, 1.0, 1.0}.Normalize()
 	world.LightColor = mgl32.Vec3{1.0, 1.0, 1.0}
 	world.Projection = mgl32.Ident4()
@@ -160,6 +144,21 @@ func (world *DisplayWorld) initialized(space m3space.SpaceIfc, glfwTime float64)
 	world.Blinker = TimeAutoVar{true, 0.5, 2.0, glfwTime, 0.0}
 }
 
+// Display another space from time zero, the OpenGL buffer is recreated
+func (world *DisplayWorld) SwitchSpace(space m3space.SpaceIfc) {
+	world.initialized(space, world.Blinker.previousTime)
+	world.CheckMax()
+	world.CreateDrawingElements()
+}
+
+// Reload the current time after events were added, true if the OpenGL buffer changed
+func (world *DisplayWorld) ReloadSpaceTime() bool {
+	world.SetTime(world.CurrentTime)
+	changed := world.CheckMax()
+	world.CreateDrawingElements()
+	return changed
+}
+
 func (world *DisplayWorld) CheckMax() bool {
 	if world.WorldSpace.GetMaxCoord() > world.Max {
 		max := world.WorldSpace.GetMaxCoord()
@@ -216,8 +215,10 @@ func (world DisplayWorld) DisplaySettings() {
 	fmt.Println("Sphere Radius [P,L]", SphereRadius.Val)
 	fmt.Println("FOV Angle [Z,X]", world.FovAngle.Val)
 	fmt.Println("Eye Dist [Q,W]", world.EyeDist.Val)
+	fmt.Println("Spaces and events panel [TAB]")
 	fmt.Println(world.CurrentSpaceTime.GetDisplayState())
 	world.Filter.DisplaySettings()
+	world.DisplayCameraSettings()
 }
 
 type DrawingElementsCreator struct {
@@ -253,15 +254,29 @@ func (creator *DrawingElementsCreator) VisitLink(node m3space.SpaceTimeNodeIfc,
 	creator.offset++
 }
 
+// Implemented by the client spaces that need to reload their max coordinate
+type maxUpdater interface {
+	UpdateMax()
+}
+
 func (world *DisplayWorld) GetSpaceTime() m3space.SpaceTimeIfc {
 	if world.CurrentSpaceTime == nil {
 		world.CurrentSpaceTime = world.WorldSpace.GetSpaceTimeAt(world.CurrentTime)
 		// Update space also.
-		world.WorldSpace.(*client.SpaceCl).UpdateMax()
+		spaceWithMax, ok := world.WorldSpace.(maxUpdater)
+		if ok {
+			spaceWithMax.UpdateMax()
+		}
 	}
 	return world.CurrentSpaceTime
 }
 
+func (world *DisplayWorld) SetTime(time m3space.DistAndTime) {
+	world.CurrentTime = time
+	world.CurrentSpaceTime = nil
+	world.GetSpaceTime()
+}
+
 func (world *DisplayWorld) ForwardTime() {
 	world.CurrentTime++
 	world.CurrentSpaceTime = nil
@@ -363,10 +378,10 @@ func (world *DisplayWorld) Tick(glfwTime float64) {
 }
 
 func (world *DisplayWorld) SetMatrices() {
-	Eye := mgl32.Vec3{float32(world.EyeDist.Val), float32(world.EyeDist.Val), float32(world.EyeDist.Val)}
+	Eye := world.EyePosition()
 	Far := Eye.Len() + float32(world.TopCornerDist)
 	world.Projection = mgl32.Perspective(mgl32.DegToRad(float32(world.FovAngle.Val)), float32(world.Width)/float32(world.Height), 1.0, Far)
-	world.Camera = mgl32.LookAtV(Eye, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 0, 1})
+	world.Camera = mgl32.LookAtV(Eye, world.View.Target, mgl32.Vec3{0, 0, 1})
 }
 
 type TriangleFiller struct {
@@ -0,0 +1,42 @@
+package m3gl
+
+import (
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const overlayMargin = 4
+
+var OverlayBackground = color.RGBA{A: 0xc0}
+var OverlayTextColor = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+/*
+Text lines drawn in an image with the fixed 7x13 font, on a semi transparent background.
+Used by playgl as a texture on top of the world, nil if no lines.
+*/
+func RenderOverlayText(lines []string) *image.RGBA {
+	if len(lines) == 0 {
+		return nil
+	}
+	face := basicfont.Face7x13
+	lineHeight := face.Metrics().Height.Ceil()
+	maxWidth := 0
+	for _, line := range lines {
+		w := font.MeasureString(face, line).Ceil()
+		if w > maxWidth {
+			maxWidth = w
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, maxWidth+2*overlayMargin, len(lines)*lineHeight+2*overlayMargin))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: OverlayBackground}, image.Point{}, draw.Src)
+	drawer := font.Drawer{Dst: img, Src: &image.Uniform{C: OverlayTextColor}, Face: face}
+	for i, line := range lines {
+		drawer.Dot = fixed.P(overlayMargin, overlayMargin+i*lineHeight+face.Metrics().Ascent.Ceil())
+		drawer.DrawString(line)
+	}
+	return img
+}
@@ -0,0 +1,402 @@
+package m3gl
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"sort"
+	"strings"
+)
+
+// The keys used by the space panel, mapped from the window keys by the viewer
+type PanelKey uint8
+
+const (
+	PanelUp PanelKey = iota
+	PanelDown
+	PanelLeft
+	PanelRight
+	PanelEnter
+	PanelCancel
+	PanelBackspace
+	PanelDelete
+)
+
+type PanelMode uint8
+
+const (
+	PanelHidden PanelMode = iota
+	PanelSpaces
+	PanelNewSpace
+	PanelNewEvent
+)
+
+const (
+	defaultActiveThreshold  = 3
+	defaultMaxTriosPerPoint = 2
+	defaultMaxNodesPerPoint = 4
+	maxCenterCoord          = 99
+	defaultPyramidSize      = 4
+)
+
+var colorNames = map[m3space.EventColor]string{
+	m3space.RedEvent:    "red",
+	m3space.GreenEvent:  "green",
+	m3space.BlueEvent:   "blue",
+	m3space.YellowEvent: "yellow",
+}
+
+// A line of the creation forms, a text or an integer between min and max included
+type panelField struct {
+	label    string
+	isText   bool
+	text     string
+	value    int
+	min, max int
+	// The displayed value, the integer itself if nil
+	format func(v int) string
+}
+
+/*
+Lists the spaces of the environment, creates and deletes spaces and adds events to the displayed one.
+It is drawn as overlay text and driven by the keys and characters typed, the viewer only maps the keys.
+*/
+type SpacePanel struct {
+	spaceData m3space.SpacePackDataIfc
+	Mode      PanelMode
+	spaces    []m3space.SpaceIfc
+	// The selected space of the list or field of the forms
+	cursor int
+	fields []*panelField
+	// The space id to delete on the second delete key, -1 if none
+	confirmDelete int
+	// The result of the last action
+	Message string
+}
+
+func MakeSpacePanel(spaceData m3space.SpacePackDataIfc) *SpacePanel {
+	return &SpacePanel{spaceData: spaceData, confirmDelete: -1}
+}
+
+func (panel *SpacePanel) Visible() bool {
+	return panel.Mode != PanelHidden
+}
+
+func (panel *SpacePanel) Switch(world *DisplayWorld) {
+	if panel.Visible() {
+		panel.Mode = PanelHidden
+		return
+	}
+	panel.showSpaces(world)
+}
+
+func (panel *SpacePanel) showSpaces(world *DisplayWorld) {
+	panel.Mode = PanelSpaces
+	panel.reloadSpaces()
+	panel.cursor = 0
+	for i, space := range panel.spaces {
+		if space.GetId() == world.WorldSpace.GetId() {
+			panel.cursor = i
+		}
+	}
+}
+
+func (panel *SpacePanel) reloadSpaces() {
+	panel.spaces = panel.spaceData.GetAllSpaces()
+	sort.Slice(panel.spaces, func(i, j int) bool {
+		return panel.spaces[i].GetName() < panel.spaces[j].GetName()
+	})
+	if panel.cursor >= len(panel.spaces) {
+		panel.cursor = len(panel.spaces) - 1
+	}
+	if panel.cursor < 0 {
+		panel.cursor = 0
+	}
+}
+
+func (panel *SpacePanel) selectedSpace() m3space.SpaceIfc {
+	if panel.cursor < len(panel.spaces) {
+		return panel.spaces[panel.cursor]
+	}
+	return nil
+}
+
+func (panel *SpacePanel) setError(err error) {
+	Log.Error(err)
+	panel.Message = err.Error()
+}
+
+/***************************************************************/
+// Forms
+/***************************************************************/
+
+func (panel *SpacePanel) showNewSpace() {
+	panel.Mode = PanelNewSpace
+	panel.cursor = 0
+	panel.fields = []*panelField{
+		{label: "Name", isText: true, text: fmt.Sprintf("space%d", len(panel.spaces)+1)},
+		{label: "Active threshold", value: defaultActiveThreshold, min: 0, max: 100},
+		{label: "Max trios per point", value: defaultMaxTriosPerPoint, min: 1, max: 8},
+		{label: "Max nodes per point", value: defaultMaxNodesPerPoint, min: 1, max: 16},
+	}
+}
+
+func (panel *SpacePanel) showNewEvent(world *DisplayWorld) {
+	panel.Mode = PanelNewEvent
+	panel.cursor = 0
+	growthTypes := m3point.GetAllGrowthTypes()
+	colorIdx := len(world.WorldSpace.GetActiveEventsAt(world.CurrentTime)) % len(m3space.AllColors)
+	panel.fields = []*panelField{
+		{label: "Growth type", value: 4, min: 0, max: len(growthTypes) - 1, format: func(v int) string {
+			return fmt.Sprintf("%d", growthTypes[v])
+		}},
+		{label: "Growth index"},
+		{label: "Growth offset"},
+		{label: "Center X", min: -maxCenterCoord, max: maxCenterCoord},
+		{label: "Center Y", min: -maxCenterCoord, max: maxCenterCoord},
+		{label: "Center Z", min: -maxCenterCoord, max: maxCenterCoord},
+		{label: "Color", value: colorIdx, min: 0, max: len(m3space.AllColors) - 1, format: func(v int) string {
+			return colorNames[m3space.AllColors[v]]
+		}},
+		{label: "Creation time", value: int(world.CurrentTime), min: 0, max: int(world.CurrentTime) + 100},
+	}
+	panel.adjustGrowthBounds()
+}
+
+// The index and offset bounds depend on the growth type
+func (panel *SpacePanel) adjustGrowthBounds() {
+	growthType := m3point.GetAllGrowthTypes()[panel.fields[0].value]
+	panel.fields[1].max = growthType.GetNbIndexes() - 1
+	panel.fields[2].max = growthType.GetMaxOffset() - 1
+	for _, f := range panel.fields[1:3] {
+		f.change(0)
+	}
+}
+
+func (f *panelField) change(delta int) {
+	f.value += delta
+	if f.value > f.max {
+		f.value = f.max
+	}
+	if f.value < f.min {
+		f.value = f.min
+	}
+}
+
+func (f *panelField) String() string {
+	if f.isText {
+		return fmt.Sprintf("%s: %s_", f.label, f.text)
+	}
+	if f.format != nil {
+		return fmt.Sprintf("%s: %s", f.label, f.format(f.value))
+	}
+	return fmt.Sprintf("%s: %d (%d,%d)", f.label, f.value, f.min, f.max)
+}
+
+/***************************************************************/
+// Actions
+/***************************************************************/
+
+func (panel *SpacePanel) openSelected(world *DisplayWorld) bool {
+	space := panel.selectedSpace()
+	if space == nil {
+		return false
+	}
+	world.SwitchSpace(space)
+	panel.Message = fmt.Sprintf("Displaying %s", space.GetName())
+	return true
+}
+
+func (panel *SpacePanel) deleteSelected(world *DisplayWorld) {
+	space := panel.selectedSpace()
+	if space == nil {
+		return
+	}
+	if space.GetId() == world.WorldSpace.GetId() {
+		panel.Message = fmt.Sprintf("Cannot delete the displayed space %s", space.GetName())
+		return
+	}
+	if panel.confirmDelete != space.GetId() {
+		panel.confirmDelete = space.GetId()
+		panel.Message = fmt.Sprintf("Press DELETE again to delete %s", space.GetName())
+		return
+	}
+	_, err := panel.spaceData.DeleteSpace(space.GetId(), space.GetName())
+	if err != nil {
+		panel.setError(err)
+		return
+	}
+	panel.Message = fmt.Sprintf("Deleted %s", space.GetName())
+	panel.reloadSpaces()
+}
+
+func (panel *SpacePanel) createSpace(world *DisplayWorld) bool {
+	name := strings.TrimSpace(panel.fields[0].text)
+	if name == "" {
+		panel.Message = "The space name cannot be empty"
+		return false
+	}
+	space, err := panel.spaceData.CreateSpace(name, m3space.DistAndTime(panel.fields[1].value), panel.fields[2].value, panel.fields[3].value)
+	if err != nil {
+		panel.setError(err)
+		return false
+	}
+	world.SwitchSpace(space)
+	panel.showSpaces(world)
+	panel.Message = fmt.Sprintf("Created %s, press A to add events", space.GetName())
+	return true
+}
+
+func (panel *SpacePanel) createEvent(world *DisplayWorld) bool {
+	growthType := m3point.GetAllGrowthTypes()[panel.fields[0].value]
+	center := m3point.Point{m3point.CInt(panel.fields[3].value), m3point.CInt(panel.fields[4].value), m3point.CInt(panel.fields[5].value)}
+	color := m3space.AllColors[panel.fields[6].value]
+	evt, err := world.WorldSpace.CreateEvent(growthType, panel.fields[1].value, panel.fields[2].value,
+		m3space.DistAndTime(panel.fields[7].value), center, color)
+	if err != nil {
+		panel.setError(err)
+		return false
+	}
+	panel.showSpaces(world)
+	panel.Message = fmt.Sprintf("Created %s", evt.String())
+	return world.ReloadSpaceTime()
+}
+
+func (panel *SpacePanel) createPyramid(world *DisplayWorld) bool {
+	CreatePyramidWithParams(world.WorldSpace, defaultPyramidSize, [4]m3point.GrowthType{8, 8, 8, 8}, [4]int{0, 0, 0, 0}, [4]int{0, 2, 4, 6})
+	panel.Message = fmt.Sprintf("Created pyramid of size %d in %s", defaultPyramidSize, world.WorldSpace.GetName())
+	return world.ReloadSpaceTime()
+}
+
+/*
+Apply the key to the panel, true if the world elements and OpenGL buffer changed.
+The cancel key goes back to the list, then hides the panel.
+*/
+func (panel *SpacePanel) Key(world *DisplayWorld, key PanelKey) bool {
+	if key != PanelDelete {
+		panel.confirmDelete = -1
+	}
+	switch panel.Mode {
+	case PanelSpaces:
+		switch key {
+		case PanelUp:
+			if panel.cursor > 0 {
+				panel.cursor--
+			}
+		case PanelDown:
+			if panel.cursor < len(panel.spaces)-1 {
+				panel.cursor++
+			}
+		case PanelEnter:
+			return panel.openSelected(world)
+		case PanelDelete:
+			panel.deleteSelected(world)
+		case PanelCancel:
+			panel.Mode = PanelHidden
+		}
+	case PanelNewSpace, PanelNewEvent:
+		f := panel.fields[panel.cursor]
+		switch key {
+		case PanelUp:
+			if panel.cursor > 0 {
+				panel.cursor--
+			}
+		case PanelDown:
+			if panel.cursor < len(panel.fields)-1 {
+				panel.cursor++
+			}
+		case PanelLeft:
+			f.change(-1)
+		case PanelRight:
+			f.change(1)
+		case PanelBackspace:
+			if f.isText && len(f.text) > 0 {
+				f.text = f.text[:len(f.text)-1]
+			}
+		case PanelEnter:
+			if panel.Mode == PanelNewSpace {
+				return panel.createSpace(world)
+			}
+			return panel.createEvent(world)
+		case PanelCancel:
+			panel.showSpaces(world)
+		}
+		if panel.Mode == PanelNewEvent && panel.cursor == 0 {
+			panel.adjustGrowthBounds()
+		}
+	}
+	return false
+}
+
+/*
+Apply a typed character, the letter shortcuts of the list or the text of the name.
+True if the world elements and OpenGL buffer changed.
+*/
+func (panel *SpacePanel) Char(world *DisplayWorld, char rune) bool {
+	panel.confirmDelete = -1
+	switch panel.Mode {
+	case PanelSpaces:
+		switch char {
+		case 'n', 'N':
+			panel.showNewSpace()
+		case 'a', 'A':
+			panel.showNewEvent(world)
+		case 'p', 'P':
+			return panel.createPyramid(world)
+		case 'r', 'R':
+			panel.reloadSpaces()
+			panel.Message = fmt.Sprintf("Reloaded %d spaces", len(panel.spaces))
+		}
+	case PanelNewSpace, PanelNewEvent:
+		f := panel.fields[panel.cursor]
+		if f.isText && char > ' ' && char < 0x7f {
+			f.text += string(char)
+		}
+	}
+	return false
+}
+
+// The overlay text of the panel, nil if hidden
+func (panel *SpacePanel) Lines(world *DisplayWorld) []string {
+	res := make([]string, 0, len(panel.spaces)+6)
+	switch panel.Mode {
+	case PanelHidden:
+		return nil
+	case PanelSpaces:
+		res = append(res, "Spaces [TAB] close [UP,DOWN] select [ENTER] display [DELETE] delete")
+		res = append(res, "[N] new space [A] add event [P] add pyramid [R] reload")
+		for i, space := range panel.spaces {
+			line := fmt.Sprintf("%s (%d) threshold=%d max time=%d max coord=%d",
+				space.GetName(), space.GetId(), space.GetActiveThreshold(), space.GetMaxTime(), space.GetMaxCoord())
+			res = append(res, panel.cursorPrefix(i)+line+displayedMark(world, space))
+		}
+	case PanelNewSpace, PanelNewEvent:
+		if panel.Mode == PanelNewSpace {
+			res = append(res, "New space [ENTER] create [ESC] back")
+		} else {
+			res = append(res, fmt.Sprintf("New event in %s [ENTER] create [ESC] back", world.WorldSpace.GetName()))
+		}
+		res = append(res, "[UP,DOWN] field [LEFT,RIGHT] change value")
+		for i, f := range panel.fields {
+			res = append(res, panel.cursorPrefix(i)+f.String())
+		}
+	}
+	if panel.Message != "" {
+		res = append(res, panel.Message)
+	}
+	return res
+}
+
+func (panel *SpacePanel) cursorPrefix(i int) string {
+	if i == panel.cursor {
+		return "> "
+	}
+	return "  "
+}
+
+func displayedMark(world *DisplayWorld, space m3space.SpaceIfc) string {
+	if space.GetId() == world.WorldSpace.GetId() {
+		return " *"
+	}
+	return ""
+}
@@ -0,0 +1,223 @@
+package m3gl
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type panelTestSpaceTime struct {
+	m3space.SpaceTimeIfc
+}
+
+func (st *panelTestSpaceTime) GetNbActiveNodes() int                           { return 0 }
+func (st *panelTestSpaceTime) GetNbActiveLinks() int                           { return 0 }
+func (st *panelTestSpaceTime) VisitNodes(visitor m3space.SpaceTimeNodeVisitor) {}
+func (st *panelTestSpaceTime) VisitLinks(visitor m3space.SpaceTimeLinkVisitor) {}
+
+type panelTestEvent struct {
+	m3space.EventIfc
+	desc string
+}
+
+func (evt *panelTestEvent) String() string {
+	return evt.desc
+}
+
+type panelTestSpace struct {
+	m3space.SpaceIfc
+	id        int
+	name      string
+	threshold m3space.DistAndTime
+	events    []string
+}
+
+func (space *panelTestSpace) GetId() int                              { return space.id }
+func (space *panelTestSpace) GetName() string                         { return space.name }
+func (space *panelTestSpace) GetActiveThreshold() m3space.DistAndTime { return space.threshold }
+func (space *panelTestSpace) GetMaxTime() m3space.DistAndTime         { return 0 }
+func (space *panelTestSpace) GetMaxCoord() m3point.CInt               { return 0 }
+
+func (space *panelTestSpace) GetActiveEventsAt(atTime m3space.DistAndTime) []m3space.EventIfc {
+	return make([]m3space.EventIfc, len(space.events))
+}
+
+func (space *panelTestSpace) GetSpaceTimeAt(atTime m3space.DistAndTime) m3space.SpaceTimeIfc {
+	return &panelTestSpaceTime{}
+}
+
+func (space *panelTestSpace) CreateEvent(growthType m3point.GrowthType, growthIndex int, growthOffset int,
+	creationTime m3space.DistAndTime, center m3point.Point, color m3space.EventColor) (m3space.EventIfc, error) {
+	desc := fmt.Sprintf("%d %d %d %d %d,%d,%d %d", growthType, growthIndex, growthOffset, creationTime, center.X(), center.Y(), center.Z(), color)
+	space.events = append(space.events, desc)
+	return &panelTestEvent{desc: desc}, nil
+}
+
+type panelTestSpaceData struct {
+	m3space.SpacePackDataIfc
+	spaces map[int]*panelTestSpace
+	nextId int
+}
+
+func (spaceData *panelTestSpaceData) GetAllSpaces() []m3space.SpaceIfc {
+	res := make([]m3space.SpaceIfc, 0, len(spaceData.spaces))
+	for _, space := range spaceData.spaces {
+		res = append(res, space)
+	}
+	return res
+}
+
+func (spaceData *panelTestSpaceData) CreateSpace(name string, activePathNodeThreshold m3space.DistAndTime, maxTriosPerPoint int, maxPathNodesPerPoint int) (m3space.SpaceIfc, error) {
+	for _, space := range spaceData.spaces {
+		if space.name == name {
+			return nil, m3util.MakeQsmErrorf("space %s already exists", name)
+		}
+	}
+	spaceData.nextId++
+	space := &panelTestSpace{id: spaceData.nextId, name: name, threshold: activePathNodeThreshold}
+	spaceData.spaces[space.id] = space
+	return space, nil
+}
+
+func (spaceData *panelTestSpaceData) DeleteSpace(id int, name string) (int, error) {
+	delete(spaceData.spaces, id)
+	return 1, nil
+}
+
+func makePanelWorld() (*DisplayWorld, *panelTestSpaceData) {
+	spaceData := &panelTestSpaceData{spaces: make(map[int]*panelTestSpace)}
+	for _, name := range []string{"ui3", "big", "small"} {
+		_, _ = spaceData.CreateSpace(name, 3, 2, 4)
+	}
+	world := makeAxesOnlyWorld(3, 320, 240)
+	world.WorldSpace = spaceData.spaces[1]
+	return world, spaceData
+}
+
+func typeText(panel *SpacePanel, world *DisplayWorld, text string) {
+	for _, c := range text {
+		panel.Char(world, c)
+	}
+}
+
+func TestSpacePanelSpaces(t *testing.T) {
+	m3util.SetToTestMode()
+	world, spaceData := makePanelWorld()
+	panel := MakeSpacePanel(spaceData)
+	assert.Nil(t, panel.Lines(world))
+
+	panel.Switch(world)
+	assert.True(t, panel.Visible())
+	lines := panel.Lines(world)
+	assert.Equal(t, 5, len(lines))
+	assert.Equal(t, "  big (2) threshold=3 max time=0 max coord=0", lines[2])
+	assert.Equal(t, "> ui3 (1) threshold=3 max time=0 max coord=0 *", lines[4])
+
+	// The displayed space cannot be deleted and the others need a confirmation
+	panel.Key(world, PanelDelete)
+	assert.Equal(t, "Cannot delete the displayed space ui3", panel.Message)
+	panel.Key(world, PanelUp)
+	panel.Key(world, PanelDelete)
+	assert.Equal(t, "Press DELETE again to delete small", panel.Message)
+	panel.Key(world, PanelDown)
+	panel.Key(world, PanelUp)
+	panel.Key(world, PanelDelete)
+	assert.Equal(t, 3, len(spaceData.spaces))
+	panel.Key(world, PanelDelete)
+	assert.Equal(t, "Deleted small", panel.Message)
+	assert.Equal(t, 2, len(spaceData.spaces))
+
+	panel.Key(world, PanelUp)
+	assert.True(t, panel.Key(world, PanelEnter))
+	assert.Equal(t, "big", world.WorldSpace.GetName())
+	assert.Equal(t, "> big (2) threshold=3 max time=0 max coord=0 *", panel.Lines(world)[2])
+
+	// A new space is displayed once created, an error is shown
+	typeText(panel, world, "n")
+	assert.Equal(t, PanelNewSpace, panel.Mode)
+	assert.Equal(t, "> Name: space3_", panel.Lines(world)[2])
+	for i := 0; i < 10; i++ {
+		panel.Key(world, PanelBackspace)
+	}
+	assert.False(t, panel.Key(world, PanelEnter))
+	assert.Equal(t, "The space name cannot be empty", panel.Message)
+	typeText(panel, world, "ui3")
+	panel.Key(world, PanelDown)
+	panel.Key(world, PanelRight)
+	assert.Equal(t, "> Active threshold: 4 (0,100)", panel.Lines(world)[3])
+	assert.False(t, panel.Key(world, PanelEnter))
+	assert.Equal(t, "space ui3 already exists", panel.Message)
+	panel.Key(world, PanelUp)
+	typeText(panel, world, "b")
+	assert.Equal(t, "> Name: ui3b_", panel.Lines(world)[2])
+	assert.True(t, panel.Key(world, PanelEnter))
+	assert.Equal(t, PanelSpaces, panel.Mode)
+	assert.Equal(t, "ui3b", world.WorldSpace.GetName())
+	assert.Equal(t, m3space.DistAndTime(4), world.WorldSpace.GetActiveThreshold())
+
+	panel.Key(world, PanelCancel)
+	assert.False(t, panel.Visible())
+}
+
+func TestSpacePanelEvents(t *testing.T) {
+	m3util.SetToTestMode()
+	world, spaceData := makePanelWorld()
+	panel := MakeSpacePanel(spaceData)
+	panel.Switch(world)
+
+	typeText(panel, world, "a")
+	assert.Equal(t, PanelNewEvent, panel.Mode)
+	lines := panel.Lines(world)
+	assert.Equal(t, "New event in ui3 [ENTER] create [ESC] back", lines[0])
+	assert.Equal(t, "> Growth type: 8", lines[2])
+	assert.Equal(t, "  Growth index: 0 (0,11)", lines[3])
+	assert.Equal(t, "  Growth offset: 0 (0,7)", lines[4])
+	assert.Equal(t, "  Color: red", lines[8])
+
+	// Going to type 4 keeps the index and offset in bounds
+	for i := 0; i < 20; i++ {
+		panel.Key(world, PanelDown)
+		if panel.cursor == 1 || panel.cursor == 2 {
+			panel.Key(world, PanelRight)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		panel.Key(world, PanelUp)
+	}
+	panel.Key(world, PanelLeft)
+	lines = panel.Lines(world)
+	assert.Equal(t, "> Growth type: 4", lines[2])
+	assert.Equal(t, "  Growth index: 1 (0,11)", lines[3])
+	panel.Key(world, PanelLeft)
+	panel.Key(world, PanelLeft)
+	lines = panel.Lines(world)
+	assert.Equal(t, "> Growth type: 2", lines[2])
+	assert.Equal(t, "  Growth offset: 1 (0,1)", lines[4])
+
+	for i := 0; i < 3; i++ {
+		panel.Key(world, PanelDown)
+	}
+	panel.Key(world, PanelLeft)
+	panel.Key(world, PanelDown)
+	panel.Key(world, PanelDown)
+	panel.Key(world, PanelRight)
+	panel.Key(world, PanelDown)
+	panel.Key(world, PanelRight)
+	panel.Key(world, PanelEnter)
+	assert.Equal(t, PanelSpaces, panel.Mode)
+	assert.Equal(t, []string{"2 1 1 0 -1,0,1 2"}, spaceData.spaces[1].events)
+	assert.Equal(t, "Created 2 1 1 0 -1,0,1 2", panel.Message)
+
+	// The color follows the number of active events
+	typeText(panel, world, "a")
+	assert.Equal(t, "  Color: green", panel.Lines(world)[8])
+	panel.Key(world, PanelCancel)
+	assert.Equal(t, PanelSpaces, panel.Mode)
+
+	typeText(panel, world, "p")
+	assert.Equal(t, 5, len(spaceData.spaces[1].events))
+	assert.Equal(t, "8 0 6 0 0,0,-12 8", spaceData.spaces[1].events[4])
+}
@@ -0,0 +1,190 @@
+package m3gl
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/model/m3path"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"strings"
+)
+
+const (
+	// Drawn with the many colors white of the shaders
+	highlightColor = int32(0xff)
+	highlightScale = 1.6
+)
+
+func (n NodeDrawingElement) Node() m3space.SpaceTimeNodeIfc {
+	return n.node
+}
+
+/*
+The ray from the eye through the pixel x,y of the framebuffer, top left origin.
+The ray is in the coordinates of the elements, before the model rotation around Z.
+*/
+func (world *DisplayWorld) ScreenRay(x, y float64) (mgl32.Vec3, mgl32.Vec3) {
+	nx := float32(2.0*x/float64(world.Width) - 1.0)
+	ny := float32(1.0 - 2.0*y/float64(world.Height))
+	inv := world.Projection.Mul4(world.Camera).Inv()
+	near := mgl32.TransformCoordinate(mgl32.Vec3{nx, ny, -1.0}, inv)
+	far := mgl32.TransformCoordinate(mgl32.Vec3{nx, ny, 1.0}, inv)
+	unRotate := mgl32.HomogRotate3D(-float32(world.Angle.Value), mgl32.Vec3{0, 0, 1})
+	origin := mgl32.TransformCoordinate(near, unRotate)
+	dir := mgl32.TransformNormal(far.Sub(near), unRotate).Normalize()
+	return origin, dir
+}
+
+// Distance along the ray to the sphere, negative if missed
+func raySphere(origin, dir, center mgl32.Vec3, radius float32) float32 {
+	oc := origin.Sub(center)
+	b := oc.Dot(dir)
+	c := oc.Dot(oc) - radius*radius
+	disc := b*b - c
+	if disc < 0.0 {
+		return -1.0
+	}
+	sq := float32(math.Sqrt(float64(disc)))
+	t := -b - sq
+	if t < 0.0 {
+		t = -b + sq
+	}
+	return t
+}
+
+func nodeRadius(key ObjectType) float32 {
+	if key == NodeEmpty {
+		return float32(SphereRadius.Val / 2.0)
+	}
+	return float32(SphereRadius.Val)
+}
+
+// The nearest displayed node under the pixel x,y of the framebuffer, nil if none
+func (world *DisplayWorld) PickNode(x, y float64) *NodeDrawingElement {
+	origin, dir := world.ScreenRay(x, y)
+	var res *NodeDrawingElement
+	minT := float32(math.MaxFloat32)
+	for _, obj := range world.Elements {
+		n, ok := obj.(*NodeDrawingElement)
+		if !ok || !n.Display(world.Filter) {
+			continue
+		}
+		pos := n.Pos()
+		if pos == nil {
+			continue
+		}
+		center := mgl32.Vec3{float32(pos.X()), float32(pos.Y()), float32(pos.Z())}
+		t := raySphere(origin, dir, center, nodeRadius(n.Key())*highlightScale)
+		if t >= 0.0 && t < minT {
+			minT = t
+			res = n
+		}
+	}
+	return res
+}
+
+// Select the node under the pixel, or clear the selection if none. Returns the node selected.
+func (world *DisplayWorld) SelectNodeAt(x, y float64) *NodeDrawingElement {
+	n := world.PickNode(x, y)
+	if n == nil {
+		world.Selected = nil
+		return nil
+	}
+	p := *n.Pos()
+	world.Selected = &p
+	return n
+}
+
+// The node of the current elements at the selected point, it changes with time
+func (world *DisplayWorld) SelectedNode() *NodeDrawingElement {
+	if world.Selected == nil {
+		return nil
+	}
+	for _, obj := range world.Elements {
+		n, ok := obj.(*NodeDrawingElement)
+		if ok {
+			pos := n.Pos()
+			if pos != nil && *pos == *world.Selected {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+func (world *DisplayWorld) isSelected(obj SpaceDrawingElement) bool {
+	if world.Selected == nil || !obj.Key().IsNode() {
+		return false
+	}
+	pos := obj.Pos()
+	return pos != nil && *pos == *world.Selected
+}
+
+// The obj_color and obj_dimmer of the element, the selected node is highlighted
+func (world *DisplayWorld) ElementColor(obj SpaceDrawingElement) (int32, float32) {
+	if world.isSelected(obj) {
+		return highlightColor, noDimmer
+	}
+	return obj.Color(world.Blinker.Value), obj.Dimmer(world.Blinker.Value)
+}
+
+// The model matrix of the element with the current rotation, the selected node is bigger
+func (world *DisplayWorld) ElementModel(obj SpaceDrawingElement) mgl32.Mat4 {
+	rotation := mgl32.HomogRotate3D(float32(world.Angle.Value), mgl32.Vec3{0, 0, 1})
+	model := elementModel(rotation, obj.Pos())
+	if world.isSelected(obj) {
+		model = model.Mul4(mgl32.Scale3D(highlightScale, highlightScale, highlightScale))
+	}
+	return model
+}
+
+// Letters F for from, N for next, B for blocked and - for not set, per connection index
+func connectionMaskString(connectionMask uint16) string {
+	sb := strings.Builder{}
+	for connIdx := 0; connIdx < m3path.NbConnections; connIdx++ {
+		switch m3path.GetConnectionState(connectionMask, connIdx) {
+		case m3path.ConnectionFrom:
+			sb.WriteByte('F')
+		case m3path.ConnectionNext:
+			sb.WriteByte('N')
+		case m3path.ConnectionBlocked:
+			sb.WriteByte('B')
+		default:
+			sb.WriteByte('-')
+		}
+	}
+	return sb.String()
+}
+
+// The lines of the inspection overlay of a node
+func DescribeNode(node m3space.SpaceTimeNodeIfc) []string {
+	res := make([]string, 0, 6)
+	p, err := node.GetPoint()
+	if err != nil {
+		return append(res, err.Error())
+	}
+	res = append(res, fmt.Sprintf("Point %d %d %d id=%d", p.X(), p.Y(), p.Z(), node.GetPointId()))
+	res = append(res, fmt.Sprintf("Colors=%d root=%v last=%d", node.GetColorMask(), node.HasRoot(), node.GetLastAccessed()))
+	details, ok := node.(m3space.SpaceTimeNodeDetailsIfc)
+	if !ok {
+		return append(res, fmt.Sprintf("Events %v", node.GetEventIds()))
+	}
+	for _, ned := range details.GetNodeEventDetails() {
+		res = append(res, fmt.Sprintf("Event %d d=%d trio=%d conns=%s created=%d",
+			ned.EventId, ned.D, ned.TrioIndex, connectionMaskString(ned.ConnectionMask), ned.CreationTime))
+	}
+	return res
+}
+
+// The overlay lines of the selected node, nil if none
+func (world *DisplayWorld) DescribeSelection() []string {
+	n := world.SelectedNode()
+	if n == nil {
+		if world.Selected != nil {
+			p := *world.Selected
+			return []string{fmt.Sprintf("Point %d %d %d", p.X(), p.Y(), p.Z()), fmt.Sprintf("No node at time %d", world.CurrentTime)}
+		}
+		return nil
+	}
+	return DescribeNode(n.Node())
+}
@@ -0,0 +1,110 @@
+package m3gl
+
+import (
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3path"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+// Only the methods used by picking and the overlay are implemented, the embedded interface is nil
+type pickTestNode struct {
+	m3space.SpaceTimeNodeIfc
+	p    m3point.Point
+	mask uint8
+}
+
+func (node *pickTestNode) GetPoint() (*m3point.Point, error) {
+	return &node.p, nil
+}
+
+func (node *pickTestNode) GetPointId() m3path.PointId {
+	return 12
+}
+
+func (node *pickTestNode) GetColorMask() uint8 {
+	return node.mask
+}
+
+func (node *pickTestNode) HasRoot() bool {
+	return false
+}
+
+func (node *pickTestNode) GetLastAccessed() m3space.DistAndTime {
+	return 2
+}
+
+func (node *pickTestNode) GetNodeEventDetails() []m3space.NodeEventDetails {
+	mask := m3path.SetConnectionState(0, 0, m3path.ConnectionFrom) |
+		m3path.SetConnectionState(0, 2, m3path.ConnectionNext)<<(2*m3path.ConnectionMaskBits)
+	return []m3space.NodeEventDetails{{EventId: 1, CreationTime: 0, D: 2, TrioIndex: 5, ConnectionMask: mask}}
+}
+
+func makePickWorld() *DisplayWorld {
+	world := makeAxesOnlyWorld(3, 320, 240)
+	for _, p := range []m3point.Point{{3, 0, 0}, {0, 3, 0}} {
+		world.Elements = append(world.Elements, MakeNodeDrawingElement(&pickTestNode{p: p, mask: uint8(m3space.RedEvent)}))
+	}
+	return world
+}
+
+// The framebuffer pixel of the center of the node element
+func nodePixel(t *testing.T, world *DisplayWorld, idx int) (float64, float64) {
+	obj := world.Elements[idx]
+	screen, _, ok := projectToScreen(world, world.Projection.Mul4(world.Camera).Mul4(world.ElementModel(obj)), [3]float32{})
+	assert.True(t, ok)
+	return float64(screen[0]), float64(screen[1])
+}
+
+func TestPickNode(t *testing.T) {
+	m3util.SetToTestMode()
+	world := makePickWorld()
+	first, second := len(world.Elements)-2, len(world.Elements)-1
+
+	x, y := nodePixel(t, world, first)
+	assert.Equal(t, world.Elements[first], world.PickNode(x, y))
+	assert.Nil(t, world.PickNode(0, 0))
+
+	// Follows the model rotation
+	world.Angle.Value = math.Pi / 2.0
+	x, y = nodePixel(t, world, second)
+	n := world.SelectNodeAt(x, y)
+	assert.Equal(t, world.Elements[second], n)
+	assert.Equal(t, m3point.Point{0, 3, 0}, *world.Selected)
+	assert.True(t, world.isSelected(n))
+	c, d := world.ElementColor(n)
+	assert.Equal(t, highlightColor, c)
+	assert.Equal(t, float32(noDimmer), d)
+
+	assert.Equal(t, []string{
+		"Point 0 3 0 id=12",
+		"Colors=1 root=false last=2",
+		"Event 1 d=2 trio=5 conns=F-N created=0",
+	}, world.DescribeSelection())
+
+	// The selection stays on the point when the elements change
+	world.Elements = world.Elements[:first]
+	assert.Nil(t, world.SelectedNode())
+	assert.Equal(t, "No node at time 0", world.DescribeSelection()[1])
+	world.SelectNodeAt(0, 0)
+	assert.Nil(t, world.Selected)
+	assert.Nil(t, world.DescribeSelection())
+}
+
+func TestRenderOverlayText(t *testing.T) {
+	assert.Nil(t, RenderOverlayText(nil))
+	img := RenderOverlayText([]string{"Point 0 3 0", "Event 1"})
+	assert.Equal(t, 11*7+2*overlayMargin, img.Rect.Dx())
+	assert.Equal(t, 2*13+2*overlayMargin, img.Rect.Dy())
+	assert.Equal(t, OverlayBackground, img.RGBAAt(0, 0))
+	found := false
+	for y := 0; y < img.Rect.Dy() && !found; y++ {
+		for x := 0; x < img.Rect.Dx() && !found; x++ {
+			found = img.RGBAAt(x, y) == OverlayTextColor
+		}
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,266 @@
+package m3gl
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Same value as the fragment shader of playgl
+const ambientStrength = 0.15
+
+var BackgroundColor = color.RGBA{A: 0xff}
+
+/*
+Software rasterizer of the DisplayWorld, used where OpenGL is not available.
+It draws the same triangles of the OpenGL buffer with the same projection, camera and model matrices,
+and the same colors and light as the playgl shaders, with a depth buffer per pixel.
+*/
+type Rasterizer struct {
+	img   *image.RGBA
+	depth []float32
+}
+
+func NewRasterizer(width, height int) *Rasterizer {
+	rast := &Rasterizer{}
+	rast.resize(width, height)
+	return rast
+}
+
+func (rast *Rasterizer) resize(width, height int) {
+	if rast.img != nil && rast.img.Rect.Dx() == width && rast.img.Rect.Dy() == height {
+		return
+	}
+	rast.img = image.NewRGBA(image.Rect(0, 0, width, height))
+	rast.depth = make([]float32, width*height)
+}
+
+func (rast *Rasterizer) clear() {
+	pix := rast.img.Pix
+	for i := 0; i < len(pix); i += 4 {
+		pix[i] = BackgroundColor.R
+		pix[i+1] = BackgroundColor.G
+		pix[i+2] = BackgroundColor.B
+		pix[i+3] = BackgroundColor.A
+	}
+	for i := range rast.depth {
+		rast.depth[i] = math.MaxFloat32
+	}
+}
+
+// The obj_color and obj_dimmer values to RGB like the vertex shader of playgl
+func objectColor(objColor int32, dimmer float32) mgl32.Vec3 {
+	var c mgl32.Vec3
+	switch m3space.EventColor(objColor) {
+	case 0:
+		c = mgl32.Vec3{0.25, 0.25, 0.25}
+	case m3space.RedEvent:
+		c = mgl32.Vec3{1.0, 0.0, 0.0}
+	case m3space.GreenEvent:
+		c = mgl32.Vec3{0.0, 1.0, 0.0}
+	case m3space.BlueEvent:
+		c = mgl32.Vec3{0.0, 0.0, 1.0}
+	case m3space.YellowEvent:
+		c = mgl32.Vec3{1.0, 1.0, 0.0}
+	default:
+		c = mgl32.Vec3{1.0, 1.0, 1.0}
+	}
+	return c.Mul(dimmer)
+}
+
+func toColorByte(f float32) uint8 {
+	if f <= 0.0 {
+		return 0
+	}
+	if f >= 1.0 {
+		return 0xff
+	}
+	return uint8(f*255.0 + 0.5)
+}
+
+// Draw all the displayed elements of the world like the playgl loop, the image is reused by the next render
+func (rast *Rasterizer) Render(world *DisplayWorld) *image.RGBA {
+	rast.resize(world.Width, world.Height)
+	rast.clear()
+	projCamera := world.Projection.Mul4(world.Camera)
+	for _, obj := range world.Elements {
+		if obj == nil || !obj.Display(world.Filter) {
+			continue
+		}
+		pos := obj.Pos()
+		if pos == nil {
+			continue
+		}
+		toDraw, ok := world.DrawingElementsMap[obj.Key()]
+		if !ok {
+			continue
+		}
+		model := world.ElementModel(obj)
+		objColor := objectColor(world.ElementColor(obj))
+		rast.drawTriangles(world, projCamera.Mul4(model), model, objColor, toDraw)
+	}
+	return rast.img
+}
+
+// The model matrix of playgl for an element drawn at the origin and moved to pos
+func elementModel(rotation mgl32.Mat4, pos *m3point.Point) mgl32.Mat4 {
+	return rotation.Mul4(mgl32.Translate3D(float32(pos.X()), float32(pos.Y()), float32(pos.Z())))
+}
+
+func (rast *Rasterizer) drawTriangles(world *DisplayWorld, mvp mgl32.Mat4, model mgl32.Mat4, objColor mgl32.Vec3, toDraw OpenGLDrawingElement) {
+	buffer := world.OpenGLBuffer
+	width := float32(world.Width)
+	height := float32(world.Height)
+	var screen [pointsPerTriangle]mgl32.Vec3
+	for v := toDraw.OpenGLOffset; v+pointsPerTriangle <= toDraw.OpenGLOffset+toDraw.NbVertices; v += pointsPerTriangle {
+		visible := true
+		for i := 0; i < pointsPerTriangle; i++ {
+			offset := int(v+int32(i)) * FloatPerVertices
+			clip := mvp.Mul4x1(mgl32.Vec4{buffer[offset], buffer[offset+1], buffer[offset+2], 1.0})
+			// No clipping of triangles crossing the near or far planes, they are skipped
+			if clip[3] <= 0.0 || clip[2] < -clip[3] || clip[2] > clip[3] {
+				visible = false
+				break
+			}
+			screen[i] = mgl32.Vec3{
+				(clip[0]/clip[3] + 1.0) * 0.5 * width,
+				(1.0 - clip[1]/clip[3]) * 0.5 * height,
+				(clip[2]/clip[3] + 1.0) * 0.5,
+			}
+		}
+		if !visible {
+			continue
+		}
+		// All vertices of a triangle have the same normal in the buffer
+		offset := int(v)*FloatPerVertices + coordinates
+		normal := model.Mul4x1(mgl32.Vec4{buffer[offset], buffer[offset+1], buffer[offset+2], 0.0}).Vec3()
+		diff := normal.Dot(world.LightDirection)
+		if diff < 0.0 {
+			diff = 0.0
+		}
+		light := world.LightColor.Mul(ambientStrength + diff)
+		c := color.RGBA{
+			R: toColorByte(light[0] * objColor[0]),
+			G: toColorByte(light[1] * objColor[1]),
+			B: toColorByte(light[2] * objColor[2]),
+			A: 0xff,
+		}
+		rast.fillTriangle(screen, c)
+	}
+}
+
+func edge(a, b mgl32.Vec3, x, y float32) float32 {
+	return (b[0]-a[0])*(y-a[1]) - (b[1]-a[1])*(x-a[0])
+}
+
+// The pixels covering the 3 coordinates, max excluded
+func pixelRange(a, b, c float32) (int, int) {
+	min := math.Min(float64(a), math.Min(float64(b), float64(c)))
+	max := math.Max(float64(a), math.Max(float64(b), float64(c)))
+	return int(math.Floor(min)), int(math.Ceil(max))
+}
+
+func (rast *Rasterizer) fillTriangle(v [pointsPerTriangle]mgl32.Vec3, c color.RGBA) {
+	area := edge(v[0], v[1], v[2][0], v[2][1])
+	if area == 0.0 {
+		return
+	}
+	bounds := rast.img.Rect
+	minX, maxX := pixelRange(v[0][0], v[1][0], v[2][0])
+	minY, maxY := pixelRange(v[0][1], v[1][1], v[2][1])
+	if minX < bounds.Min.X {
+		minX = bounds.Min.X
+	}
+	if minY < bounds.Min.Y {
+		minY = bounds.Min.Y
+	}
+	if maxX > bounds.Max.X {
+		maxX = bounds.Max.X
+	}
+	if maxY > bounds.Max.Y {
+		maxY = bounds.Max.Y
+	}
+	for y := minY; y < maxY; y++ {
+		py := float32(y) + 0.5
+		for x := minX; x < maxX; x++ {
+			px := float32(x) + 0.5
+			// Barycentric coordinates positive inside whatever the winding
+			b0 := edge(v[1], v[2], px, py) / area
+			b1 := edge(v[2], v[0], px, py) / area
+			b2 := edge(v[0], v[1], px, py) / area
+			if b0 < 0.0 || b1 < 0.0 || b2 < 0.0 {
+				continue
+			}
+			z := b0*v[0][2] + b1*v[1][2] + b2*v[2][2]
+			idx := y*bounds.Dx() + x
+			if z >= rast.depth[idx] {
+				continue
+			}
+			rast.depth[idx] = z
+			rast.img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func (world *DisplayWorld) WritePNG(rast *Rasterizer, w io.Writer) error {
+	err := png.Encode(w, rast.Render(world))
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not encode PNG of %s at %d due to %v", world.WorldSpace.GetName(), world.CurrentTime, err)
+	}
+	return nil
+}
+
+func (world *DisplayWorld) SavePNG(rast *Rasterizer, filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not create PNG file %s due to %v", filePath, err)
+	}
+	err = world.WritePNG(rast, f)
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return m3util.MakeWrapQsmErrorf(closeErr, "could not close PNG file %s due to %v", filePath, closeErr)
+	}
+	return nil
+}
+
+/*
+Write one frame-<time>.png file in dir per time from from to to included, growing the space time if needed.
+Returns the files written.
+*/
+func (world *DisplayWorld) RenderFrames(from, to m3space.DistAndTime, dir string) ([]string, error) {
+	if to < from {
+		return nil, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "cannot render frames from %d to %d", from, to)
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, m3util.MakeWrapQsmErrorf(err, "could not create frames dir %s due to %v", dir, err)
+	}
+	world.SetMatrices()
+	rast := NewRasterizer(world.Width, world.Height)
+	files := make([]string, 0, int(to-from)+1)
+	for time := from; time <= to; time++ {
+		world.SetTime(time)
+		world.CheckMax()
+		world.CreateDrawingElements()
+		filePath := filepath.Join(dir, fmt.Sprintf("frame-%04d.png", time))
+		err = world.SavePNG(rast, filePath)
+		if err != nil {
+			return files, err
+		}
+		Log.Infof("Rendered %d elements of %s at %d in %s", len(world.Elements), world.WorldSpace.GetName(), time, filePath)
+		files = append(files, filePath)
+	}
+	return files, nil
+}
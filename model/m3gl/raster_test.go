@@ -0,0 +1,81 @@
+package m3gl
+
+import (
+	"bytes"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// A world with only the axes and no space, drawn at the origin
+func makeAxesOnlyWorld(max m3point.CInt, width, height int) *DisplayWorld {
+	verifyData()
+	world := &DisplayWorld{}
+	world.Width = width
+	world.Height = height
+	world.Max = max
+	world.TopCornerDist = math.Sqrt(float64(3.0*max*max)) + 1.1
+	world.EyeDist = SizeVar{float64(max), world.TopCornerDist * 2.0, world.TopCornerDist * 1.5}
+	world.FovAngle = SizeVar{10.0, 75.0, 30.0}
+	world.LightDirection = mgl32.Vec3{-1.0, 1.0, 1.0}.Normalize()
+	world.LightColor = mgl32.Vec3{1.0, 1.0, 1.0}
+	world.Filter = SpaceDrawingFilter{EventColorMask: uint8(0xff)}
+	world.NbVertices = axes * trianglesPerLine * pointsPerTriangle
+	world.OpenGLBuffer = make([]float32, world.NbVertices*FloatPerVertices)
+	triangleFiller := TriangleFiller{nil, make(map[ObjectType]OpenGLDrawingElement), 0, 0, &(world.OpenGLBuffer)}
+	triangleFiller.drawAxes(world.Max)
+	world.DrawingElementsMap = triangleFiller.objMap
+	dec := DrawingElementsCreator{nbElements: 6, elements: make([]SpaceDrawingElement, 6)}
+	dec.createAxes(world.Max)
+	world.Elements = dec.elements
+	world.SetMatrices()
+	return world
+}
+
+func TestObjectColor(t *testing.T) {
+	assert.Equal(t, mgl32.Vec3{1.0, 0.0, 0.0}, objectColor(int32(m3space.RedEvent), 1.0))
+	assert.Equal(t, mgl32.Vec3{0.5, 0.5, 0.0}, objectColor(int32(m3space.YellowEvent), 0.5))
+	assert.Equal(t, mgl32.Vec3{0.25, 0.25, 0.25}, objectColor(0, 1.0))
+	assert.Equal(t, mgl32.Vec3{1.0, 1.0, 1.0}, objectColor(3, 1.0))
+}
+
+func TestRasterizeAxes(t *testing.T) {
+	m3util.SetToTestMode()
+	world := makeAxesOnlyWorld(3, 160, 120)
+	rast := NewRasterizer(world.Width, world.Height)
+	img := rast.Render(world)
+	assert.Equal(t, 160, img.Rect.Dx())
+	assert.Equal(t, 120, img.Rect.Dy())
+
+	// Corners are background, and each axe color is drawn
+	assert.Equal(t, BackgroundColor, img.RGBAAt(0, 0))
+	assert.Equal(t, BackgroundColor, img.RGBAAt(159, 119))
+	var reds, greens, blues int
+	for y := 0; y < world.Height; y++ {
+		for x := 0; x < world.Width; x++ {
+			c := img.RGBAAt(x, y)
+			switch {
+			case c.R > 0 && c.G == 0 && c.B == 0:
+				reds++
+			case c.G > 0 && c.R == 0 && c.B == 0:
+				greens++
+			case c.B > 0 && c.R == 0 && c.G == 0:
+				blues++
+			}
+		}
+	}
+	assert.True(t, reds > 0 && greens > 0 && blues > 0, "missing axes colors %d %d %d", reds, greens, blues)
+
+	// Rotated drawing encoded in PNG
+	world.Angle.Value = math.Pi / 4.0
+	buf := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(buf, rast.Render(world)))
+	decoded, err := png.Decode(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, img.Rect, decoded.Bounds())
+}
@@ -0,0 +1,277 @@
+package m3gl
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+	"github.com/go-gl/mathgl/mgl32"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+/*
+Options of the SVG frames export, one frame per time from From to To included.
+The world Filter is replaced by Filter if not nil.
+*/
+type SvgExportOptions struct {
+	From, To m3space.DistAndTime
+	// Rotation around the Z axis added at each time step, in radians
+	AnglePerStep float64
+	Filter       *SpaceDrawingFilter
+	// Milliseconds per frame of the HTML index animation
+	FrameDelay int
+}
+
+func DefaultSvgExportOptions() SvgExportOptions {
+	return SvgExportOptions{From: 0, To: 10, AnglePerStep: 0.0, FrameDelay: 500}
+}
+
+// A line or circle of the SVG with its distance to the eye, drawn from the farthest
+type svgShape struct {
+	depth float32
+	svg   string
+}
+
+// The screen position and distance to the eye of a point of the element, false if outside of the near and far planes
+func projectToScreen(world *DisplayWorld, mvp mgl32.Mat4, v mgl32.Vec3) (mgl32.Vec2, float32, bool) {
+	clip := mvp.Mul4x1(v.Vec4(1.0))
+	if clip[3] <= 0.0 || clip[2] < -clip[3] || clip[2] > clip[3] {
+		return mgl32.Vec2{}, 0.0, false
+	}
+	return mgl32.Vec2{
+		(clip[0]/clip[3] + 1.0) * 0.5 * float32(world.Width),
+		(1.0 - clip[1]/clip[3]) * 0.5 * float32(world.Height),
+	}, clip[3], true
+}
+
+// The inverse of getConnectionObjectType
+func getObjectTypeConnectionId(ot ObjectType) m3point.ConnectionId {
+	k := m3point.ConnectionId(ot) - m3point.ConnectionId(Connection00)
+	if k%2 == 0 {
+		return k / 2
+	}
+	return -(k - 1) / 2
+}
+
+// The segment drawn by TriangleFiller for an axe or connection element, from the origin
+func (world *DisplayWorld) segmentEnd(key ObjectType) (mgl32.Vec3, float64, bool) {
+	if key.IsAxe() {
+		end := mgl32.Vec3{}
+		end[key] = float32(world.Max + AxeExtraLength)
+		return end, LineWidth.Val, true
+	}
+	if world.pointData == nil {
+		return mgl32.Vec3{}, 0.0, false
+	}
+	cd := world.pointData.GetConnDetailsById(getObjectTypeConnectionId(key))
+	if cd == nil {
+		return mgl32.Vec3{}, 0.0, false
+	}
+	return mgl32.Vec3{float32(cd.Vector.X()), float32(cd.Vector.Y()), float32(cd.Vector.Z())}, LineWidth.Val / 2.0, true
+}
+
+func svgColor(c mgl32.Vec3) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", toColorByte(c[0]), toColorByte(c[1]), toColorByte(c[2]))
+}
+
+/*
+Project the displayed elements with the same matrices as playgl.
+Nodes are circles and axes and connections are lines, with a width following the perspective.
+*/
+func (world *DisplayWorld) svgShapes() []svgShape {
+	projCamera := world.Projection.Mul4(world.Camera)
+	// Pixels for one unit at distance one of the eye
+	focal := world.Projection.At(1, 1) * 0.5 * float32(world.Height)
+	shapes := make([]svgShape, 0, len(world.Elements))
+	for _, obj := range world.Elements {
+		if obj == nil || !obj.Display(world.Filter) {
+			continue
+		}
+		pos := obj.Pos()
+		if pos == nil {
+			continue
+		}
+		mvp := projCamera.Mul4(world.ElementModel(obj))
+		c := svgColor(objectColor(world.ElementColor(obj)))
+		key := obj.Key()
+		if key.IsNode() {
+			center, depth, ok := projectToScreen(world, mvp, mgl32.Vec3{})
+			if !ok {
+				continue
+			}
+			radius := nodeRadius(key)
+			if world.isSelected(obj) {
+				radius *= highlightScale
+			}
+			shapes = append(shapes, svgShape{depth, fmt.Sprintf(`<circle cx="%.2f" cy="%.2f" r="%.2f" fill="%s"/>`,
+				center[0], center[1], radius*focal/depth, c)})
+			continue
+		}
+		end, lineWidth, ok := world.segmentEnd(key)
+		if !ok {
+			continue
+		}
+		a, depthA, okA := projectToScreen(world, mvp, mgl32.Vec3{})
+		b, depthB, okB := projectToScreen(world, mvp, end)
+		if !okA || !okB {
+			continue
+		}
+		depth := (depthA + depthB) / 2.0
+		shapes = append(shapes, svgShape{depth, fmt.Sprintf(`<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f" stroke-linecap="round"/>`,
+			a[0], a[1], b[0], b[1], c, 2.0*float32(lineWidth)*focal/depth)})
+	}
+	sort.SliceStable(shapes, func(i, j int) bool {
+		return shapes[i].depth > shapes[j].depth
+	})
+	return shapes
+}
+
+func (world *DisplayWorld) WriteSVG(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		world.Width, world.Height, world.Width, world.Height)
+	fmt.Fprintf(bw, "<rect width=\"100%%\" height=\"100%%\" fill=\"rgb(%d,%d,%d)\"/>\n", BackgroundColor.R, BackgroundColor.G, BackgroundColor.B)
+	for _, shape := range world.svgShapes() {
+		fmt.Fprintln(bw, shape.svg)
+	}
+	fmt.Fprintln(bw, "</svg>")
+	err := bw.Flush()
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not write SVG of %s at %d due to %v", world.WorldSpace.GetName(), world.CurrentTime, err)
+	}
+	return nil
+}
+
+func (world *DisplayWorld) SaveSVG(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not create SVG file %s due to %v", filePath, err)
+	}
+	err = world.WriteSVG(f)
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return m3util.MakeWrapQsmErrorf(closeErr, "could not close SVG file %s due to %v", filePath, closeErr)
+	}
+	return nil
+}
+
+/*
+Write one frame-<time>.svg file in dir per time step going forward from opts.From to opts.To,
+rotating by opts.AnglePerStep at each step, and an index.html playing them.
+Returns the files written, the index last.
+*/
+func (world *DisplayWorld) ExportSVGFrames(opts SvgExportOptions, dir string) ([]string, error) {
+	if opts.To < opts.From {
+		return nil, m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "cannot export SVG frames from %d to %d", opts.From, opts.To)
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, m3util.MakeWrapQsmErrorf(err, "could not create frames dir %s due to %v", dir, err)
+	}
+	if opts.Filter != nil {
+		world.Filter = *opts.Filter
+	}
+	world.SetMatrices()
+	files := make([]string, 0, int(opts.To-opts.From)+2)
+	frames := make([]string, 0, int(opts.To-opts.From)+1)
+	world.SetTime(opts.From)
+	for {
+		world.CheckMax()
+		world.CreateDrawingElements()
+		fileName := fmt.Sprintf("frame-%04d.svg", world.CurrentTime)
+		filePath := filepath.Join(dir, fileName)
+		err = world.SaveSVG(filePath)
+		if err != nil {
+			return files, err
+		}
+		Log.Infof("Exported %d elements of %s at %d in %s", len(world.Elements), world.WorldSpace.GetName(), world.CurrentTime, filePath)
+		files = append(files, filePath)
+		frames = append(frames, fileName)
+		if world.CurrentTime >= opts.To {
+			break
+		}
+		world.ForwardTime()
+		world.Angle.Value = math.Mod(world.Angle.Value+opts.AnglePerStep, 2.0*math.Pi)
+	}
+	indexPath := filepath.Join(dir, "index.html")
+	err = writeSvgIndex(indexPath, world.WorldSpace.GetName(), frames, opts)
+	if err != nil {
+		return files, err
+	}
+	return append(files, indexPath), nil
+}
+
+var svgIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} from {{.From}} to {{.To}}</title>
+</head>
+<body style="background: black; color: white; font-family: sans-serif">
+<div>
+<button id="play">Pause</button>
+<input id="slider" type="range" min="0" max="{{.Last}}" value="0">
+<span id="time">{{.From}}</span>
+</div>
+<img id="frame" src="{{index .Frames 0}}" alt="{{.Name}}">
+<script>
+const frames = [{{range $i, $f := .Frames}}{{if $i}}, {{end}}{{$f}}{{end}}];
+const from = {{.From}};
+let current = 0;
+let playing = true;
+const img = document.getElementById("frame");
+const slider = document.getElementById("slider");
+const time = document.getElementById("time");
+const play = document.getElementById("play");
+function show(i) {
+    current = i;
+    img.src = frames[i];
+    slider.value = i;
+    time.textContent = from + i;
+}
+slider.oninput = () => show(Number(slider.value));
+play.onclick = () => {
+    playing = !playing;
+    play.textContent = playing ? "Pause" : "Play";
+};
+setInterval(() => { if (playing) show((current + 1) % frames.length); }, {{.FrameDelay}});
+</script>
+</body>
+</html>
+`))
+
+func writeSvgIndex(filePath string, name string, frames []string, opts SvgExportOptions) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not create SVG index %s due to %v", filePath, err)
+	}
+	delay := opts.FrameDelay
+	if delay <= 0 {
+		delay = DefaultSvgExportOptions().FrameDelay
+	}
+	err = svgIndexTemplate.Execute(f, map[string]interface{}{
+		"Name":       name,
+		"From":       int(opts.From),
+		"To":         int(opts.To),
+		"Last":       len(frames) - 1,
+		"Frames":     frames,
+		"FrameDelay": delay,
+	})
+	closeErr := f.Close()
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not write SVG index %s due to %v", filePath, err)
+	}
+	if closeErr != nil {
+		return m3util.MakeWrapQsmErrorf(closeErr, "could not close SVG index %s due to %v", filePath, closeErr)
+	}
+	return nil
+}
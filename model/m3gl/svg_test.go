@@ -0,0 +1,60 @@
+package m3gl
+
+import (
+	"bytes"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConnectionObjectType(t *testing.T) {
+	for _, connId := range []m3point.ConnectionId{1, -1, 2, -2, 25, -25} {
+		assert.Equal(t, connId, getObjectTypeConnectionId(getConnectionObjectType(connId)))
+	}
+}
+
+func TestWriteAxesSVG(t *testing.T) {
+	m3util.SetToTestMode()
+	world := makeAxesOnlyWorld(3, 160, 120)
+	buf := &bytes.Buffer{}
+	assert.NoError(t, world.WriteSVG(buf))
+	svg := buf.String()
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="160" height="120" viewBox="0 0 160 120">`))
+	assert.True(t, strings.HasSuffix(svg, "</svg>\n"))
+	// Positive and negative part of each axe
+	assert.Equal(t, 6, strings.Count(svg, "<line "))
+	assert.Equal(t, 0, strings.Count(svg, "<circle "))
+	assert.Contains(t, svg, `stroke="rgb(255,0,0)"`)
+
+	// Same shapes rotated
+	world.Angle.Value = math.Pi / 4.0
+	rotated := world.svgShapes()
+	assert.Equal(t, 6, len(rotated))
+	assert.NotContains(t, svg, rotated[0].svg)
+	for i := 1; i < len(rotated); i++ {
+		assert.True(t, rotated[i-1].depth >= rotated[i].depth)
+	}
+}
+
+func TestWriteSvgIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "svgindex")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	indexPath := filepath.Join(dir, "index.html")
+	opts := SvgExportOptions{From: 2, To: 3, FrameDelay: 0}
+	assert.NoError(t, writeSvgIndex(indexPath, "test<space>", []string{"frame-0002.svg", "frame-0003.svg"}, opts))
+	data, err := ioutil.ReadFile(indexPath)
+	assert.NoError(t, err)
+	index := string(data)
+	assert.Contains(t, index, "<title>test&lt;space&gt; from 2 to 3</title>")
+	assert.Contains(t, index, `const frames = ["frame-0002.svg", "frame-0003.svg"];`)
+	assert.Contains(t, index, `max="1"`)
+	assert.Contains(t, index, "500 );")
+}
@@ -17,7 +17,13 @@ type PathPackDataIfc interface {
 	GetPathCtxFromAttributes(growthType m3point.GrowthType, growthIndex int, growthOffset int) (PathContext, error)
 }
 
-func CalculatePredictedSize(growthType m3point.GrowthType, d int) int {
+// Default trend lines used when no fitted model was stored in the point pack data
+var defaultPredictedSizeModels = map[m3point.GrowthType]*m3point.PredictedSizeModel{
+	m3point.GrowthType(8): {GrowthType: 8, GrowthIndex: m3point.AllGrowthIndexes, A: 1.775, B: -2.497, C: 5.039},
+	m3point.GrowthType(2): {GrowthType: 2, GrowthIndex: m3point.AllGrowthIndexes, A: 1.445, B: -0.065, C: -0.377},
+}
+
+func CalculatePredictedSize(growthCtx m3point.GrowthContext, d int) int {
 	if d == 0 {
 		return 3
 	}
@@ -25,15 +31,23 @@ func CalculatePredictedSize(growthType m3point.GrowthType, d int) int {
 		return 6
 	}
 
-	buffer := float32(1.02)
-	df := float32(d)
-	if growthType == m3point.GrowthType(8) {
-		return int((1.775*df*df - 2.497*df + 5.039) * buffer)
-	} else if growthType == m3point.GrowthType(2) {
-		return int((1.445*df*df - 0.065*df - 0.377) * buffer)
+	growthType := growthCtx.GetGrowthType()
+	env := growthCtx.GetEnv()
+	if env != nil {
+		ppd, ok := env.GetData(m3util.PointIdx).(m3point.PointPackDataIfc)
+		if ok {
+			model := ppd.GetPredictedSizeModel(growthType, growthCtx.GetGrowthIndex())
+			if model != nil {
+				return model.Predict(d)
+			}
+		}
+	}
+	model, ok := defaultPredictedSizeModels[growthType]
+	if !ok {
+		// Run the size model analysis to get trend lines for other context types
+		model = defaultPredictedSizeModels[m3point.GrowthType(8)]
 	}
-	// TODO: Find trend lines for other context types
-	return int((1.775*df*df - 2.497*df + 5.039) * buffer)
+	return model.Predict(d)
 }
 
 func MakePathPointMap(initSize int) *PathPointMap {
@@ -44,6 +58,10 @@ func MakePathPointMap(initSize int) *PathPointMap {
 	return &res
 }
 
+func (ppm *PathPointMap) Size() int {
+	return ppm.idMap.Size()
+}
+
 func (ppm *PathPointMap) GetById(pointId PointId) (*PathPoint, bool) {
 	pp, ok := ppm.idMap.Load(pointId)
 	if ok {
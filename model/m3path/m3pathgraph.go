@@ -0,0 +1,284 @@
+package m3path
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type GraphFormat string
+
+const (
+	GraphMLFormat GraphFormat = "graphml"
+	DotFormat     GraphFormat = "dot"
+	// The nodes and links JSON read by the d3 pages of the visual folder
+	D3JsonFormat GraphFormat = "d3"
+)
+
+var AllGraphFormats = []GraphFormat{GraphMLFormat, DotFormat, D3JsonFormat}
+
+func ParseGraphFormat(name string) (GraphFormat, error) {
+	for _, f := range AllGraphFormats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return "", m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "graph format %q unknown, should be one of %v", name, AllGraphFormats)
+}
+
+func (f GraphFormat) ContentType() string {
+	switch f {
+	case GraphMLFormat:
+		return "application/graphml+xml"
+	case DotFormat:
+		return "text/vnd.graphviz"
+	case D3JsonFormat:
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
+
+func (f GraphFormat) Extension() string {
+	if f == D3JsonFormat {
+		return "json"
+	}
+	return string(f)
+}
+
+/*
+A directed graph of points linked by connections, from a path context or a space time.
+The node id is its index in Nodes, so the d3 links work with or without an id accessor.
+*/
+type Graph struct {
+	Name  string
+	Nodes []*GraphNode
+	Links []GraphLink
+
+	indexes map[m3point.Point]int
+}
+
+type GraphNode struct {
+	Id    int
+	Point m3point.Point
+	// The distance for path nodes, the color mask for space time nodes
+	Group   int
+	TrioIds []m3point.TrioIndex
+}
+
+type GraphLink struct {
+	Source, Target int
+	ConnId         m3point.ConnectionId
+}
+
+func MakeGraph(name string, initSize int) *Graph {
+	return &Graph{
+		Name:    name,
+		Nodes:   make([]*GraphNode, 0, initSize),
+		Links:   make([]GraphLink, 0, initSize),
+		indexes: make(map[m3point.Point]int, initSize),
+	}
+}
+
+// Returns the node at this point, creating it in group -1 if needed
+func (g *Graph) GetOrAddNode(p m3point.Point) *GraphNode {
+	idx, ok := g.indexes[p]
+	if ok {
+		return g.Nodes[idx]
+	}
+	node := &GraphNode{Id: len(g.Nodes), Point: p, Group: -1}
+	g.indexes[p] = node.Id
+	g.Nodes = append(g.Nodes, node)
+	return node
+}
+
+func (g *Graph) FindNode(p m3point.Point) *GraphNode {
+	idx, ok := g.indexes[p]
+	if !ok {
+		return nil
+	}
+	return g.Nodes[idx]
+}
+
+func (g *Graph) AddLink(source, target *GraphNode, connId m3point.ConnectionId) {
+	g.Links = append(g.Links, GraphLink{Source: source.Id, Target: target.Id, ConnId: connId})
+}
+
+func (node *GraphNode) AddTrioId(trioId m3point.TrioIndex) {
+	for _, id := range node.TrioIds {
+		if id == trioId {
+			return
+		}
+	}
+	node.TrioIds = append(node.TrioIds, trioId)
+}
+
+func (node *GraphNode) Name() string {
+	return fmt.Sprintf("%d,%d,%d", node.Point[0], node.Point[1], node.Point[2])
+}
+
+func (node *GraphNode) trioIdsString() string {
+	res := make([]string, len(node.TrioIds))
+	for i, id := range node.TrioIds {
+		res[i] = strconv.Itoa(int(id))
+	}
+	return strings.Join(res, ",")
+}
+
+/*
+The graph of the path nodes given, linked by their next connections.
+Next connections going to a point outside the path nodes given are dropped.
+*/
+func MakePathGraph(pathCtx PathContext, pathNodes []PathNode, pointData m3point.PointPackDataIfc) (*Graph, error) {
+	maxD := 0
+	for _, pn := range pathNodes {
+		if pn.D() > maxD {
+			maxD = pn.D()
+		}
+	}
+	g := MakeGraph(fmt.Sprintf("path-%d-%d", pathCtx.GetId(), maxD), len(pathNodes))
+	for _, pn := range pathNodes {
+		node := g.GetOrAddNode(pn.P())
+		node.Group = pn.D()
+		node.AddTrioId(pn.GetTrioIndex())
+	}
+	for _, pn := range pathNodes {
+		td := pn.GetTrioDetails(pointData)
+		if td == nil {
+			return nil, m3util.MakeQsmErrorf("path node %s has unknown trio index %d", pn.String(), pn.GetTrioIndex())
+		}
+		source := g.FindNode(pn.P())
+		for connIdx, cd := range td.Conns {
+			if !pn.IsNext(connIdx) {
+				continue
+			}
+			target := g.FindNode(pn.P().Add(cd.Vector))
+			if target != nil {
+				g.AddLink(source, target, cd.Id)
+			}
+		}
+	}
+	return g, nil
+}
+
+func (g *Graph) Write(w io.Writer, format GraphFormat) error {
+	switch format {
+	case GraphMLFormat:
+		return g.WriteGraphML(w)
+	case DotFormat:
+		return g.WriteDot(w)
+	case D3JsonFormat:
+		return g.WriteD3Json(w)
+	}
+	return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "graph format %q unknown", format)
+}
+
+/***************************************************************/
+// Writers
+/***************************************************************/
+
+func xmlEscape(s string) string {
+	b := &bytes.Buffer{}
+	// Only fails if the writer fails
+	_ = xml.EscapeText(b, []byte(s))
+	return b.String()
+}
+
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(bw, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	for _, coord := range []string{"x", "y", "z"} {
+		fmt.Fprintf(bw, "  <key id=\"%s\" for=\"node\" attr.name=\"%s\" attr.type=\"int\"/>\n", coord, coord)
+	}
+	fmt.Fprintln(bw, `  <key id="group" for="node" attr.name="group" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <key id="trio_ids" for="node" attr.name="trio_ids" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="conn_id" for="edge" attr.name="conn_id" attr.type="int"/>`)
+	fmt.Fprintf(bw, "  <graph id=\"%s\" edgedefault=\"directed\">\n", xmlEscape(g.Name))
+	for _, node := range g.Nodes {
+		fmt.Fprintf(bw, "    <node id=\"n%d\"><data key=\"x\">%d</data><data key=\"y\">%d</data><data key=\"z\">%d</data>"+
+			"<data key=\"group\">%d</data><data key=\"trio_ids\">%s</data></node>\n",
+			node.Id, node.Point[0], node.Point[1], node.Point[2], node.Group, node.trioIdsString())
+	}
+	for i, link := range g.Links {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"n%d\" target=\"n%d\"><data key=\"conn_id\">%d</data></edge>\n",
+			i, link.Source, link.Target, link.ConnId)
+	}
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	err := bw.Flush()
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not write %s graph %s due to %v", GraphMLFormat, g.Name, err)
+	}
+	return nil
+}
+
+func (g *Graph) WriteDot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", strconv.Quote(g.Name))
+	for _, node := range g.Nodes {
+		fmt.Fprintf(bw, "  n%d [label=%q group=%d trio_ids=%q];\n", node.Id, node.Name(), node.Group, node.trioIdsString())
+	}
+	for _, link := range g.Links {
+		fmt.Fprintf(bw, "  n%d -> n%d [label=\"%d\" conn_id=%d];\n", link.Source, link.Target, link.ConnId, link.ConnId)
+	}
+	fmt.Fprintln(bw, "}")
+	err := bw.Flush()
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not write %s graph %s due to %v", DotFormat, g.Name, err)
+	}
+	return nil
+}
+
+type d3Graph struct {
+	Nodes []d3Node `json:"nodes"`
+	Links []d3Link `json:"links"`
+}
+
+type d3Node struct {
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Group   int    `json:"group"`
+	Point   [3]int `json:"point"`
+	TrioIds []int  `json:"trio_ids"`
+}
+
+type d3Link struct {
+	Source int `json:"source"`
+	Target int `json:"target"`
+	Value  int `json:"value"`
+	ConnId int `json:"conn_id"`
+}
+
+func (g *Graph) WriteD3Json(w io.Writer) error {
+	doc := d3Graph{Nodes: make([]d3Node, len(g.Nodes)), Links: make([]d3Link, len(g.Links))}
+	for i, node := range g.Nodes {
+		trioIds := make([]int, len(node.TrioIds))
+		for j, id := range node.TrioIds {
+			trioIds[j] = int(id)
+		}
+		doc.Nodes[i] = d3Node{
+			Id:      node.Id,
+			Name:    node.Name(),
+			Group:   node.Group,
+			Point:   [3]int{int(node.Point[0]), int(node.Point[1]), int(node.Point[2])},
+			TrioIds: trioIds,
+		}
+	}
+	for i, link := range g.Links {
+		doc.Links[i] = d3Link{Source: link.Source, Target: link.Target, Value: 1, ConnId: int(link.ConnId)}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	err := enc.Encode(&doc)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not write d3 graph %s due to %v", g.Name, err)
+	}
+	return nil
+}
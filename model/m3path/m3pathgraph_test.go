@@ -0,0 +1,120 @@
+package m3path
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// Only the methods used by the graph are implemented, the embedded interfaces are nil
+
+type graphTestPathCtx struct {
+	PathContext
+}
+
+func (pathCtx *graphTestPathCtx) GetId() PathContextId {
+	return 7
+}
+
+var graphTestTrio = &m3point.TrioDetails{Id: 4, Conns: [3]*m3point.ConnectionDetails{
+	{Id: 1, Vector: m3point.Point{1, 1, 0}, ConnDS: 2},
+	{Id: 2, Vector: m3point.Point{-1, 1, 0}, ConnDS: 2},
+	{Id: -1, Vector: m3point.Point{-1, -1, 0}, ConnDS: 2},
+}}
+
+type graphTestPathNode struct {
+	PathNode
+	p    m3point.Point
+	d    int
+	next [3]bool
+}
+
+func (pn *graphTestPathNode) P() m3point.Point {
+	return pn.p
+}
+
+func (pn *graphTestPathNode) D() int {
+	return pn.d
+}
+
+func (pn *graphTestPathNode) GetTrioIndex() m3point.TrioIndex {
+	return graphTestTrio.Id
+}
+
+func (pn *graphTestPathNode) GetTrioDetails(pointData m3point.PointPackDataIfc) *m3point.TrioDetails {
+	return graphTestTrio
+}
+
+func (pn *graphTestPathNode) IsNext(connIdx int) bool {
+	return pn.next[connIdx]
+}
+
+func makeTestPathGraph(t *testing.T) *Graph {
+	pathNodes := []PathNode{
+		&graphTestPathNode{p: m3point.Origin, d: 0, next: [3]bool{true, true, false}},
+		&graphTestPathNode{p: m3point.Point{1, 1, 0}, d: 1, next: [3]bool{true, false, false}},
+		&graphTestPathNode{p: m3point.Point{-1, 1, 0}, d: 1},
+	}
+	g, err := MakePathGraph(&graphTestPathCtx{}, pathNodes, nil)
+	assert.NoError(t, err)
+	return g
+}
+
+func TestMakePathGraph(t *testing.T) {
+	g := makeTestPathGraph(t)
+	assert.Equal(t, "path-7-1", g.Name)
+	assert.Equal(t, 3, len(g.Nodes))
+	// The next connection of dist 1 goes outside of the nodes given
+	assert.Equal(t, []GraphLink{{0, 1, 1}, {0, 2, 2}}, g.Links)
+	assert.Equal(t, 1, g.Nodes[2].Group)
+	assert.Equal(t, []m3point.TrioIndex{4}, g.Nodes[2].TrioIds)
+	assert.Nil(t, g.FindNode(m3point.Point{2, 2, 0}))
+
+	f, err := ParseGraphFormat("DOT")
+	assert.NoError(t, err)
+	assert.Equal(t, DotFormat, f)
+	_, err = ParseGraphFormat("gexf")
+	assert.Error(t, err)
+}
+
+func TestWriteGraph(t *testing.T) {
+	g := makeTestPathGraph(t)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, g.Write(buf, D3JsonFormat))
+	doc := &d3Graph{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), doc))
+	assert.Equal(t, d3Node{Id: 1, Name: "1,1,0", Group: 1, Point: [3]int{1, 1, 0}, TrioIds: []int{4}}, doc.Nodes[1])
+	assert.Equal(t, d3Link{Source: 0, Target: 2, Value: 1, ConnId: 2}, doc.Links[1])
+
+	buf.Reset()
+	assert.NoError(t, g.Write(buf, DotFormat))
+	dot := buf.String()
+	assert.True(t, strings.HasPrefix(dot, "digraph \"path-7-1\" {\n"))
+	assert.Contains(t, dot, "  n0 [label=\"0,0,0\" group=0 trio_ids=\"4\"];\n")
+	assert.Contains(t, dot, "  n0 -> n1 [label=\"1\" conn_id=1];\n")
+
+	buf.Reset()
+	assert.NoError(t, g.Write(buf, GraphMLFormat))
+	graphML := buf.String()
+	assert.Contains(t, graphML, "<graph id=\"path-7-1\" edgedefault=\"directed\">")
+	assert.Contains(t, graphML, "<edge id=\"e1\" source=\"n0\" target=\"n2\"><data key=\"conn_id\">2</data></edge>")
+	assert.Equal(t, 3, strings.Count(graphML, "<node "))
+
+	for _, format := range AllGraphFormats {
+		err := g.Write(failingWriter{}, format)
+		if assert.Error(t, err, "format %s", format) {
+			assert.Contains(t, err.Error(), "could not write "+string(format)+" graph path-7-1", "format %s", format)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
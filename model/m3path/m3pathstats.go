@@ -0,0 +1,89 @@
+package m3path
+
+import (
+	"sync"
+)
+
+// The counts of path nodes created at one distance of a path context
+type PathDistStat struct {
+	D       int
+	NbNodes int
+	// Number of connections of the nodes at D-1 that became dead ends while growing to D
+	NbDeadEnds int
+	// Number of new nodes at D rejected because the point was already used in the path context
+	NbConflicts int
+}
+
+/*
+The stats for all the distances of a path context from 0 to the max dist.
+Keeps the cumulative number of nodes so counts between distances are direct.
+*/
+type PathDistStats struct {
+	mutex      sync.RWMutex
+	stats      []PathDistStat
+	cumulNodes []int
+}
+
+func MakePathDistStats(initSize int) *PathDistStats {
+	res := new(PathDistStats)
+	res.stats = make([]PathDistStat, 0, initSize)
+	res.cumulNodes = make([]int, 0, initSize)
+	return res
+}
+
+// The stats have to be added in order of distance starting at 0, returns false if D is not the next one
+func (s *PathDistStats) Add(stat PathDistStat) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stat.D != len(s.stats) {
+		return false
+	}
+	previous := 0
+	if stat.D > 0 {
+		previous = s.cumulNodes[stat.D-1]
+	}
+	s.stats = append(s.stats, stat)
+	s.cumulNodes = append(s.cumulNodes, previous+stat.NbNodes)
+	return true
+}
+
+// The max distance with stats, -1 if empty
+func (s *PathDistStats) GetMaxDist() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.stats) - 1
+}
+
+func (s *PathDistStats) GetStat(dist int) (PathDistStat, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if dist < 0 || dist >= len(s.stats) {
+		return PathDistStat{}, false
+	}
+	return s.stats[dist], true
+}
+
+func (s *PathDistStats) GetAll() []PathDistStat {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := make([]PathDistStat, len(s.stats))
+	copy(res, s.stats)
+	return res
+}
+
+func (s *PathDistStats) GetNumberOfNodesAt(dist int) (int, bool) {
+	stat, ok := s.GetStat(dist)
+	return stat.NbNodes, ok
+}
+
+func (s *PathDistStats) GetNumberOfNodesBetween(fromDist int, toDist int) (int, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if fromDist < 0 || toDist < fromDist || toDist >= len(s.cumulNodes) {
+		return 0, false
+	}
+	if fromDist == 0 {
+		return s.cumulNodes[toDist], true
+	}
+	return s.cumulNodes[toDist] - s.cumulNodes[fromDist-1], true
+}
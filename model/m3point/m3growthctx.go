@@ -6,21 +6,35 @@ import (
 )
 
 /*
-Define how outgrowth and path evolve from the center. There are 6 types of growth depending of the value of growthType:
+Define how outgrowth and path evolve from the center. There are 7 types of growth depending of the value of growthType:
 TODO: Create trio index for non nextMainPoint points base on growth type
-1. type = 0 : Type not yet existing TODO: Main points will not be covered. In here trio index switch from trio to next that has neg conn
+1. type = 0 : Trio index switch from trio to the next base trio (not the prime) that has the neg of one of its connections.
+              The connection used rotates between the 3 connections, starting at the offset. The cycle is of size 3,
+              after 3 switches the walk is back on the initial trio.
 2. type = 1 : All nextMainPoint points have the same base trio index
 3. type = 3 : Rotate between valid trios depending on starting index in modulo 3
 4. type = 2 : Use the modulo 2 permutation => Specific index valid next trio back and forth
 5. type = 4 : Use the modulo 4 permutation => Specific index line in AllMod4Permutations cycling through the 4 values
 6. type = 8 : Use the modulo 8 permutation => Specific index line in AllMod8Permutations cycling through the 8 values
+7. type = 255 : User defined cyclic sequence of trio indexes registered at runtime, see ValidateTrioSequence
 */
 type GrowthType uint8
 
-var allGrowthTypes = [5]GrowthType{1, 2, 3, 4, 8}
-var TotalNbContexts = 8 + 12 + 8 + 12 + 12
+// Type 0 is last to keep the ids of the growth contexts created before it existed
+var allGrowthTypes = [6]GrowthType{1, 2, 3, 4, 8, 0}
+var TotalNbContexts = 8 + 12 + 8 + 12 + 12 + 8
+
+// The number of trio switches before type 0 goes back to the initial trio
+const GrowthType0CycleSize = 3
+
+// The growth type of all the custom growth contexts, the index is the registration order
+const CustomGrowthType = GrowthType(255)
+
+// Custom trio sequences cannot cycle on more trios than the biggest permutation
+const MaxCustomSequenceSize = 8
 
 var maxOffsetPerType = map[GrowthType]int{
+	GrowthType(0): 3,
 	GrowthType(1): 1,
 	GrowthType(3): 3,
 	GrowthType(2): 2,
@@ -37,16 +51,27 @@ type BaseGrowthContext struct {
 	// Index in the permutations to choose from. For type 1 and 3 [0,7] for the other in the 12 list [0,11]
 	// Max number of indexes returned by GrowthType.GetNbIndexes()
 	GrowthIndex int
+	// Only for CustomGrowthType the cyclic sequence of base trio indexes
+	TrioSequence []TrioIndex
 }
 
 /***************************************************************/
 // GrowthType Functions
 /***************************************************************/
 
-func GetAllGrowthTypes() [5]GrowthType {
+func GetAllGrowthTypes() [6]GrowthType {
 	return allGrowthTypes
 }
 
+// The number of path contexts centered at origin, one per growth context and offset
+func GetTotalNbPathContexts() int {
+	res := 0
+	for _, t := range allGrowthTypes {
+		res += t.GetNbIndexes() * t.GetMaxOffset()
+	}
+	return res
+}
+
 func (t GrowthType) String() string {
 	return fmt.Sprintf("CtxType%d", t)
 }
@@ -59,7 +84,11 @@ func (t GrowthType) GetModulo() int {
 	return int(t)
 }
 
+// Custom growth contexts are registered at runtime, so the custom type has no fixed number of indexes
 func (t GrowthType) GetNbIndexes() int {
+	if t.IsCustom() {
+		return 0
+	}
 	if t.IsPermutation() {
 		return 12
 	}
@@ -70,6 +99,63 @@ func (t GrowthType) GetMaxOffset() int {
 	return maxOffsetPerType[t]
 }
 
+func (t GrowthType) IsCustom() bool {
+	return t == CustomGrowthType
+}
+
+// The custom type is valid, but its number of indexes and offsets depend on the registered growth contexts
+func (t GrowthType) IsValid() bool {
+	_, ok := maxOffsetPerType[t]
+	return ok || t.IsCustom()
+}
+
+// Verify that the cyclic sequence of trio indexes can be used for a custom growth context.
+// Like the permutations, two consecutive trios (including the last and the first) should be a valid next trio pair.
+func ValidateTrioSequence(ppd PointPackDataIfc, trioSequence []TrioIndex) error {
+	seqSize := len(trioSequence)
+	if seqSize < 2 || seqSize > MaxCustomSequenceSize {
+		return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "trio sequence %v should have between 2 and %d trios", trioSequence, MaxCustomSequenceSize)
+	}
+	for idx, trIdx := range trioSequence {
+		if trIdx >= 8 {
+			return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "trio index %d at %d in sequence %v is not a base trio", trIdx, idx, trioSequence)
+		}
+		nextTrIdx := trioSequence[(idx+1)%seqSize]
+		validPair := false
+		for _, validTrio := range ppd.GetValidNextTrio() {
+			if (validTrio[0] == trIdx && validTrio[1] == nextTrIdx) || (validTrio[0] == nextTrIdx && validTrio[1] == trIdx) {
+				validPair = true
+				break
+			}
+		}
+		if !validPair {
+			return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "trio index %d at %d in sequence %v cannot be followed by %d", trIdx, idx, trioSequence, nextTrIdx)
+		}
+	}
+	return nil
+}
+
+// Two trio sequences are the same if one is a rotation of the other
+func SameTrioSequence(s1, s2 []TrioIndex) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	seqSize := len(s1)
+	for shift := 0; shift < seqSize; shift++ {
+		same := true
+		for idx := 0; idx < seqSize; idx++ {
+			if s1[idx] != s2[(idx+shift)%seqSize] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
 /***************************************************************/
 // BaseGrowthContext Functions
 /***************************************************************/
@@ -94,6 +180,18 @@ func (gowthCtx *BaseGrowthContext) GetGrowthIndex() int {
 	return gowthCtx.GrowthIndex
 }
 
+func (gowthCtx *BaseGrowthContext) GetTrioSequence() []TrioIndex {
+	return gowthCtx.TrioSequence
+}
+
+// For custom growth contexts each position in the trio sequence is a possible offset
+func (gowthCtx *BaseGrowthContext) GetMaxOffset() int {
+	if gowthCtx.GrowthType.IsCustom() {
+		return len(gowthCtx.TrioSequence)
+	}
+	return gowthCtx.GrowthType.GetMaxOffset()
+}
+
 func (gowthCtx *BaseGrowthContext) GetBaseDivByThree(mainPoint Point) uint64 {
 	if !mainPoint.IsMainPoint() {
 		Log.Fatalf("cannot ask for trio index on non nextMainPoint Pos %v in context %v!", mainPoint, gowthCtx.String())
@@ -129,8 +227,23 @@ func (gowthCtx *BaseGrowthContext) GetBaseTrioIndex(ppd PointPackDataIfc, divByT
 		Log.Fatalf("did not find valid trio for div by three value %d in context %s-%d!", divByThree, gowthCtx.String(), offset)
 	}
 
+	if gowthCtx.GrowthType == 0 {
+		// Walk from trio to trio using the neg connections, starting at the offset connection
+		trIdx := ctxTrIdx
+		nbSteps := int(divByThree % GrowthType0CycleSize)
+		for step := 0; step < nbSteps; step++ {
+			trIdx = getNextTrioWithNegConn(ppd, trIdx, (step+offset)%3)
+			if trIdx == NilTrioIndex {
+				Log.Fatalf("did not find next trio for div by three value %d in context %s-%d!", divByThree, gowthCtx.String(), offset)
+			}
+		}
+		return trIdx
+	}
+
 	divByThreeWithOffset := uint64(offset) + divByThree
 	switch gowthCtx.GrowthType {
+	case CustomGrowthType:
+		return gowthCtx.TrioSequence[int(divByThreeWithOffset%uint64(len(gowthCtx.TrioSequence)))]
 	case 2:
 		permutationMap := ppd.GetValidNextTrio()[gowthCtx.GrowthIndex]
 		idx := int(m3util.PosMod2(divByThreeWithOffset))
@@ -147,3 +260,18 @@ func (gowthCtx *BaseGrowthContext) GetBaseTrioIndex(ppd PointPackDataIfc, divByT
 	Log.Fatalf("event permutation type %d in context %s-%d is invalid!", gowthCtx.GrowthIndex, gowthCtx.String(), offset)
 	return NilTrioIndex
 }
+
+// Find the base trio, not the prime one, having the neg connection of the connection at connIdx of trIdx
+func getNextTrioWithNegConn(ppd PointPackDataIfc, trIdx TrioIndex, connIdx int) TrioIndex {
+	negConnId := ppd.GetTrioDetails(trIdx).Conns[connIdx].GetNegId()
+	for nextTrIdx := TrioIndex(0); nextTrIdx < 8; nextTrIdx++ {
+		// The prime trio has all the neg connections, and it is in the other half of the base trios
+		if nextTrIdx == trIdx || (nextTrIdx < 4) != (trIdx < 4) {
+			continue
+		}
+		if ppd.GetTrioDetails(nextTrIdx).HasConnection(negConnId) {
+			return nextTrIdx
+		}
+	}
+	return NilTrioIndex
+}
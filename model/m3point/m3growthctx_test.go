@@ -0,0 +1,63 @@
+package m3point
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Only the base trios are needed for the type 0 walk
+type baseTriosPackData struct {
+	PointPackDataIfc
+	trios []*TrioDetails
+}
+
+func (ppd *baseTriosPackData) GetTrioDetails(trIdx TrioIndex) *TrioDetails {
+	return ppd.trios[trIdx]
+}
+
+func newBaseTriosPackData() *baseTriosPackData {
+	baseConnIds := [8][3]ConnectionId{
+		{4, -6, -9}, {-5, 6, -8}, {5, -7, 9}, {-4, 7, 8},
+		{-4, 6, 9}, {5, -6, 8}, {-5, 7, -9}, {4, -7, -8},
+	}
+	res := &baseTriosPackData{trios: make([]*TrioDetails, len(baseConnIds))}
+	for i, connIds := range baseConnIds {
+		td := TrioDetails{Id: TrioIndex(i)}
+		for j, connId := range connIds {
+			td.Conns[j] = &ConnectionDetails{Id: connId}
+		}
+		res.trios[i] = &td
+	}
+	return res
+}
+
+func TestGrowthType0BaseTrioIndex(t *testing.T) {
+	ppd := newBaseTriosPackData()
+	ctx := &BaseGrowthContext{Id: 52, GrowthType: GrowthType(0), GrowthIndex: 0}
+	expected := map[int][GrowthType0CycleSize]TrioIndex{
+		0: {0, 3, 2},
+		1: {0, 1, 3},
+		2: {0, 2, 1},
+	}
+	for offset, sequence := range expected {
+		for divByThree := uint64(0); divByThree < 2*GrowthType0CycleSize; divByThree++ {
+			assert.Equal(t, sequence[divByThree%GrowthType0CycleSize], ctx.GetBaseTrioIndex(ppd, divByThree, offset),
+				"wrong trio for offset %d at div by three %d", offset, divByThree)
+		}
+	}
+
+	// All the walks stay in the half of the base trios of their growth index
+	for growthIndex := 0; growthIndex < 8; growthIndex++ {
+		ctx = &BaseGrowthContext{GrowthType: GrowthType(0), GrowthIndex: growthIndex}
+		for offset := 0; offset < ctx.GetGrowthType().GetMaxOffset(); offset++ {
+			for divByThree := uint64(0); divByThree < GrowthType0CycleSize; divByThree++ {
+				trIdx := ctx.GetBaseTrioIndex(ppd, divByThree, offset)
+				assert.Equal(t, growthIndex < 4, trIdx < 4, "trio %d for %d-%d", trIdx, growthIndex, offset)
+			}
+		}
+	}
+	ctx = &BaseGrowthContext{GrowthType: GrowthType(0), GrowthIndex: 4}
+	assert.Equal(t, TrioIndex(7), ctx.GetBaseTrioIndex(ppd, 1, 0))
+	assert.Equal(t, TrioIndex(6), ctx.GetBaseTrioIndex(ppd, 2, 0))
+	assert.Equal(t, TrioIndex(4), ctx.GetBaseTrioIndex(ppd, 3, 0))
+}
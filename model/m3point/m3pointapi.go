@@ -11,6 +11,8 @@ type GrowthContext interface {
 	GetId() int
 	GetGrowthType() GrowthType
 	GetGrowthIndex() int
+	GetTrioSequence() []TrioIndex
+	GetMaxOffset() int
 	GetBaseDivByThree(mainPoint Point) uint64
 	GetBaseTrioIndex(ppd PointPackDataIfc, divByThree uint64, offset int) TrioIndex
 }
@@ -23,6 +23,9 @@ type PointPackDataIfc interface {
 	// Follow should be used by UI
 	GetAllGrowthContexts() []GrowthContext
 
+	// Used by m3path to predict the number of path nodes at a distance
+	GetPredictedSizeModel(growthType GrowthType, growthIndex int) *PredictedSizeModel
+
 	// Used by space tests
 	GetAllMod8Permutations() [12][8]TrioIndex
 	GetValidNextTrio() [12][2]TrioIndex
@@ -44,6 +47,9 @@ type BasePointPackData struct {
 	// Collection of all growth context ordered
 	AllGrowthContexts    []GrowthContext
 	GrowthContextsLoaded bool
+
+	// Fitted trend lines of the number of path nodes per distance, can be empty
+	PredictedSizeModels []*PredictedSizeModel
 }
 
 func (ppd *BasePointPackData) GetEnvId() m3util.QsmEnvID {
@@ -136,3 +142,19 @@ func (ppd *BasePointPackData) GetTrioDetails(trIdx TrioIndex) *TrioDetails {
 	return ppd.AllTrioDetails[trIdx]
 }
 
+
+// Return the model fitted for the growth index if it exists, then the one for the growth type, or nil
+func (ppd *BasePointPackData) GetPredictedSizeModel(growthType GrowthType, growthIndex int) *PredictedSizeModel {
+	var typeModel *PredictedSizeModel
+	for _, model := range ppd.PredictedSizeModels {
+		if model.GrowthType == growthType {
+			if model.GrowthIndex == growthIndex {
+				return model
+			}
+			if model.GrowthIndex == AllGrowthIndexes {
+				typeModel = model
+			}
+		}
+	}
+	return typeModel
+}
@@ -0,0 +1,126 @@
+package m3point
+
+import (
+	"fmt"
+	"math"
+)
+
+// The growth index used by the predicted size model valid for all the indexes of a growth type
+const AllGrowthIndexes = -1
+
+// Quadratic trend line of the number of path nodes at a given distance for a growth type (and optionally index)
+type PredictedSizeModel struct {
+	GrowthType  GrowthType
+	GrowthIndex int
+	A, B, C     float64
+	// The biggest distance of the samples used to fit the model
+	MaxDist int
+}
+
+// Keep a small buffer above the trend line to avoid slices growth
+const predictedSizeBuffer = 1.02
+
+func (m *PredictedSizeModel) String() string {
+	return fmt.Sprintf("SizeModel-T%d-Idx%02d %.3f*d^2 %+.3f*d %+.3f", m.GrowthType, m.GrowthIndex, m.A, m.B, m.C)
+}
+
+func (m *PredictedSizeModel) Predict(d int) int {
+	df := float64(d)
+	res := int((m.A*df*df + m.B*df + m.C) * predictedSizeBuffer)
+	if res < 1 {
+		return 1
+	}
+	return res
+}
+
+// The mean of |predicted - actual| / actual over all the samples with a non zero actual size
+func (m *PredictedSizeModel) MeanRelativeError(samples [][2]int) float64 {
+	total := 0.0
+	nbUsed := 0
+	for _, sample := range samples {
+		if sample[1] == 0 {
+			continue
+		}
+		df := float64(sample[0])
+		predicted := m.A*df*df + m.B*df + m.C
+		total += math.Abs(predicted-float64(sample[1])) / float64(sample[1])
+		nbUsed++
+	}
+	if nbUsed == 0 {
+		return 0.0
+	}
+	return total / float64(nbUsed)
+}
+
+/*
+Least square fit of a quadratic on the samples of (distance, number of nodes).
+Returns nil if there are not enough samples with different distances.
+*/
+func FitPredictedSizeModel(growthType GrowthType, growthIndex int, samples [][2]int) *PredictedSizeModel {
+	// Sums of d^k for k in [0,4] and sums of n*d^k for k in [0,2]
+	var sd [5]float64
+	var snd [3]float64
+	maxDist := 0
+	distances := make(map[int]bool)
+	for _, sample := range samples {
+		d := float64(sample[0])
+		n := float64(sample[1])
+		dk := 1.0
+		for k := 0; k < 5; k++ {
+			sd[k] += dk
+			if k < 3 {
+				snd[k] += n * dk
+			}
+			dk *= d
+		}
+		if sample[0] > maxDist {
+			maxDist = sample[0]
+		}
+		distances[sample[0]] = true
+	}
+	if len(distances) < 3 {
+		return nil
+	}
+	// Normal equations with x = (C, B, A)
+	m := [3][4]float64{
+		{sd[0], sd[1], sd[2], snd[0]},
+		{sd[1], sd[2], sd[3], snd[1]},
+		{sd[2], sd[3], sd[4], snd[2]},
+	}
+	x, ok := solveLinear3(m)
+	if !ok {
+		return nil
+	}
+	return &PredictedSizeModel{GrowthType: growthType, GrowthIndex: growthIndex, A: x[2], B: x[1], C: x[0], MaxDist: maxDist}
+}
+
+// Gaussian elimination with partial pivoting on the augmented matrix
+func solveLinear3(m [3][4]float64) ([3]float64, bool) {
+	var res [3]float64
+	for col := 0; col < 3; col++ {
+		pivot := col
+		for row := col + 1; row < 3; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return res, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := col + 1; row < 3; row++ {
+			f := m[row][col] / m[col][col]
+			for k := col; k < 4; k++ {
+				m[row][k] -= f * m[col][k]
+			}
+		}
+	}
+	for row := 2; row >= 0; row-- {
+		v := m[row][3]
+		for k := row + 1; k < 3; k++ {
+			v -= m[row][k] * res[k]
+		}
+		res[row] = v / m[row][row]
+	}
+	return res, true
+}
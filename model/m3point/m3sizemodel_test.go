@@ -0,0 +1,61 @@
+package m3point
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFitPredictedSizeModel(t *testing.T) {
+	Log.SetDebug()
+	// Not enough distinct distances
+	assert.Nil(t, FitPredictedSizeModel(GrowthType(8), AllGrowthIndexes, [][2]int{{2, 10}, {3, 20}, {3, 21}}))
+
+	// Exact quadratic is found back
+	samples := make([][2]int, 0, 20)
+	for d := 2; d < 22; d++ {
+		samples = append(samples, [2]int{d, 2*d*d - 3*d + 7})
+	}
+	model := FitPredictedSizeModel(GrowthType(4), 3, samples)
+	if !assert.NotNil(t, model) {
+		return
+	}
+	assert.Equal(t, GrowthType(4), model.GrowthType)
+	assert.Equal(t, 3, model.GrowthIndex)
+	assert.Equal(t, 21, model.MaxDist)
+	assert.InDelta(t, 2.0, model.A, 1e-6)
+	assert.InDelta(t, -3.0, model.B, 1e-6)
+	assert.InDelta(t, 7.0, model.C, 1e-6)
+	assert.InDelta(t, 0.0, model.MeanRelativeError(samples), 1e-6)
+	// The buffer keeps the prediction a little above
+	expected := float64(2*30*30-3*30+7) * predictedSizeBuffer
+	assert.Equal(t, int(expected), model.Predict(30))
+
+	// Noisy samples on two contexts still fit well
+	noisy := make([][2]int, 0, 40)
+	for d := 2; d < 22; d++ {
+		noisy = append(noisy, [2]int{d, 2*d*d - 3*d + 7 + 3}, [2]int{d, 2*d*d - 3*d + 7 - 3})
+	}
+	model = FitPredictedSizeModel(GrowthType(4), AllGrowthIndexes, noisy)
+	if !assert.NotNil(t, model) {
+		return
+	}
+	assert.InDelta(t, 2.0, model.A, 1e-6)
+	meanError := model.MeanRelativeError(noisy)
+	assert.True(t, meanError < 0.05)
+	// The empty samples are not counted
+	assert.InDelta(t, meanError, model.MeanRelativeError(append(noisy, [2]int{1, 0}, [2]int{0, 0})), 1e-9)
+	assert.Equal(t, 0.0, model.MeanRelativeError([][2]int{{1, 0}}))
+}
+
+func TestGetPredictedSizeModel(t *testing.T) {
+	Log.SetDebug()
+	ppd := BasePointPackData{}
+	assert.Nil(t, ppd.GetPredictedSizeModel(GrowthType(2), 0))
+
+	typeModel := &PredictedSizeModel{GrowthType: GrowthType(2), GrowthIndex: AllGrowthIndexes, A: 1.0}
+	indexModel := &PredictedSizeModel{GrowthType: GrowthType(2), GrowthIndex: 5, A: 2.0}
+	ppd.PredictedSizeModels = []*PredictedSizeModel{typeModel, indexModel}
+	assert.Equal(t, indexModel, ppd.GetPredictedSizeModel(GrowthType(2), 5))
+	assert.Equal(t, typeModel, ppd.GetPredictedSizeModel(GrowthType(2), 3))
+	assert.Nil(t, ppd.GetPredictedSizeModel(GrowthType(4), 5))
+}
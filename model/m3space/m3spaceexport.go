@@ -0,0 +1,516 @@
+package m3space
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"io"
+	"math"
+	"strings"
+)
+
+type ExportFormat string
+
+const (
+	GltfFormat ExportFormat = "gltf"
+	PlyFormat  ExportFormat = "ply"
+	ObjFormat  ExportFormat = "obj"
+)
+
+var AllExportFormats = []ExportFormat{GltfFormat, PlyFormat, ObjFormat}
+
+func ParseExportFormat(name string) (ExportFormat, error) {
+	for _, f := range AllExportFormats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return "", m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "export format %q unknown, should be one of %v", name, AllExportFormats)
+}
+
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case GltfFormat:
+		return "model/gltf+json"
+	case PlyFormat:
+		return "application/x-ply"
+	case ObjFormat:
+		return "model/obj"
+	}
+	return "application/octet-stream"
+}
+
+func (f ExportFormat) Extension() string {
+	return string(f)
+}
+
+/*
+The nodes and links of a space time ready to be exported.
+Vertices are the node points plus the end points of links not on an active node.
+*/
+type SpaceTimeScene struct {
+	SpaceName string
+	Time      DistAndTime
+	Vertices  []SceneVertex
+	Edges     []SceneEdge
+
+	pointData m3point.PointPackDataIfc
+	indexes   map[m3point.Point]int
+	err       error
+}
+
+type SceneVertex struct {
+	Point     m3point.Point
+	ColorMask uint8
+	IsNode    bool
+	HasRoot   bool
+}
+
+type SceneEdge struct {
+	From, To  int
+	ColorMask uint8
+}
+
+// Visit all the nodes and links of the space time, the point data is used for the link vectors
+func CollectScene(spaceTime SpaceTimeIfc, pointData m3point.PointPackDataIfc) (*SpaceTimeScene, error) {
+	nbNodes := spaceTime.GetNbActiveNodes()
+	if nbNodes < 0 {
+		nbNodes = 0
+	}
+	scene := &SpaceTimeScene{
+		SpaceName: spaceTime.GetSpace().GetName(),
+		Time:      spaceTime.GetCurrentTime(),
+		Vertices:  make([]SceneVertex, 0, nbNodes),
+		Edges:     make([]SceneEdge, 0, nbNodes),
+		pointData: pointData,
+		indexes:   make(map[m3point.Point]int, nbNodes),
+	}
+	spaceTime.VisitNodes(scene)
+	if scene.err == nil {
+		spaceTime.VisitLinks(scene)
+	}
+	if scene.err != nil {
+		return nil, scene.err
+	}
+	return scene, nil
+}
+
+func (scene *SpaceTimeScene) vertexIndex(p m3point.Point) int {
+	idx, ok := scene.indexes[p]
+	if !ok {
+		idx = len(scene.Vertices)
+		scene.indexes[p] = idx
+		scene.Vertices = append(scene.Vertices, SceneVertex{Point: p})
+	}
+	return idx
+}
+
+func (scene *SpaceTimeScene) VisitNode(node SpaceTimeNodeIfc) {
+	if scene.err != nil {
+		return
+	}
+	p, err := node.GetPoint()
+	if err != nil {
+		scene.err = err
+		return
+	}
+	v := &scene.Vertices[scene.vertexIndex(*p)]
+	v.IsNode = true
+	v.ColorMask = node.GetColorMask()
+	v.HasRoot = node.HasRoot()
+}
+
+func (scene *SpaceTimeScene) VisitLink(node SpaceTimeNodeIfc, srcPoint m3point.Point, connId m3point.ConnectionId) {
+	if scene.err != nil {
+		return
+	}
+	cd := scene.pointData.GetConnDetailsById(connId)
+	if cd == nil || cd.Id == m3point.NilConnectionId {
+		scene.err = m3util.MakeQsmErrorf("link from %v has unknown connection id %d", srcPoint, connId)
+		return
+	}
+	from := scene.vertexIndex(srcPoint)
+	to := scene.vertexIndex(srcPoint.Add(cd.Vector))
+	scene.Edges = append(scene.Edges, SceneEdge{From: from, To: to, ColorMask: node.GetColorMask()})
+}
+
+func (scene *SpaceTimeScene) Name() string {
+	return fmt.Sprintf("%s-%d", scene.SpaceName, scene.Time)
+}
+
+func (scene *SpaceTimeScene) Write(w io.Writer, format ExportFormat) error {
+	switch format {
+	case GltfFormat:
+		return scene.WriteGltf(w)
+	case PlyFormat:
+		return scene.WritePly(w)
+	case ObjFormat:
+		return scene.WriteObj(w)
+	}
+	return m3util.MakeQsmCodeErrorf(m3util.ErrBadRequest, "export format %q unknown", format)
+}
+
+/***************************************************************/
+// Colors and materials
+/***************************************************************/
+
+// One material per single event color, plus grey for empty and white for many colors, like the UI
+const (
+	emptyMaterial = iota
+	redMaterial
+	greenMaterial
+	blueMaterial
+	yellowMaterial
+	manyMaterial
+	nbMaterials
+)
+
+var materialNames = [nbMaterials]string{"empty", "red", "green", "blue", "yellow", "many"}
+var materialColors = [nbMaterials][3]uint8{
+	{64, 64, 64},
+	{255, 0, 0},
+	{0, 255, 0},
+	{0, 0, 255},
+	{255, 255, 0},
+	{255, 255, 255},
+}
+
+func colorMaterial(colorMask uint8) int {
+	switch EventColor(colorMask) {
+	case 0:
+		return emptyMaterial
+	case RedEvent:
+		return redMaterial
+	case GreenEvent:
+		return greenMaterial
+	case BlueEvent:
+		return blueMaterial
+	case YellowEvent:
+		return yellowMaterial
+	}
+	return manyMaterial
+}
+
+// The RGB color of the nodes and links with this color mask
+func ColorMaskToRGB(colorMask uint8) [3]uint8 {
+	return materialColors[colorMaterial(colorMask)]
+}
+
+/***************************************************************/
+// PLY and OBJ
+/***************************************************************/
+
+func (scene *SpaceTimeScene) WritePly(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "ply")
+	fmt.Fprintln(bw, "format ascii 1.0")
+	fmt.Fprintf(bw, "comment space %s at time %d\n", scene.SpaceName, scene.Time)
+	fmt.Fprintf(bw, "element vertex %d\n", len(scene.Vertices))
+	fmt.Fprintln(bw, "property int x\nproperty int y\nproperty int z")
+	fmt.Fprintln(bw, "property uchar red\nproperty uchar green\nproperty uchar blue")
+	fmt.Fprintf(bw, "element edge %d\n", len(scene.Edges))
+	fmt.Fprintln(bw, "property int vertex1\nproperty int vertex2")
+	fmt.Fprintln(bw, "property uchar red\nproperty uchar green\nproperty uchar blue")
+	fmt.Fprintln(bw, "end_header")
+	for _, v := range scene.Vertices {
+		c := ColorMaskToRGB(v.ColorMask)
+		fmt.Fprintf(bw, "%d %d %d %d %d %d\n", v.Point.X(), v.Point.Y(), v.Point.Z(), c[0], c[1], c[2])
+	}
+	for _, e := range scene.Edges {
+		c := ColorMaskToRGB(e.ColorMask)
+		fmt.Fprintf(bw, "%d %d %d %d %d\n", e.From, e.To, c[0], c[1], c[2])
+	}
+	return flushExport(bw, "ply")
+}
+
+// Vertices with the common r g b extension, nodes as points and links as lines
+func (scene *SpaceTimeScene) WriteObj(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# space %s at time %d\n", scene.SpaceName, scene.Time)
+	fmt.Fprintf(bw, "o %s\n", scene.Name())
+	for _, v := range scene.Vertices {
+		c := ColorMaskToRGB(v.ColorMask)
+		fmt.Fprintf(bw, "v %d %d %d %.3f %.3f %.3f\n", v.Point.X(), v.Point.Y(), v.Point.Z(),
+			float64(c[0])/255.0, float64(c[1])/255.0, float64(c[2])/255.0)
+	}
+	// OBJ indexes start at 1
+	for i, v := range scene.Vertices {
+		if v.IsNode {
+			fmt.Fprintf(bw, "p %d\n", i+1)
+		}
+	}
+	for _, e := range scene.Edges {
+		fmt.Fprintf(bw, "l %d %d\n", e.From+1, e.To+1)
+	}
+	return flushExport(bw, "obj")
+}
+
+func flushExport(bw *bufio.Writer, format string) error {
+	err := bw.Flush()
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not write %s export due to %v", format, err)
+	}
+	return nil
+}
+
+/***************************************************************/
+// glTF 2.0
+/***************************************************************/
+
+const (
+	gltfFloat         = 5126
+	gltfUnsignedShort = 5123
+	gltfArrayBuffer   = 34962
+	gltfElementBuffer = 34963
+	gltfLines         = 1
+	gltfTriangles     = 4
+
+	sphereRings    = 8
+	sphereSegments = 12
+	// Same sizes as the UI spheres
+	nodeRadius      = 0.4
+	emptyNodeRadius = 0.2
+)
+
+type gltfDoc struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Name  string `json:"name"`
+	Nodes []int  `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name        string     `json:"name"`
+	Mesh        *int       `json:"mesh,omitempty"`
+	Children    []int      `json:"children,omitempty"`
+	Translation []float32  `json:"translation,omitempty"`
+	Scale       []float32  `json:"scale,omitempty"`
+	Extras      gltfExtras `json:"extras,omitempty"`
+}
+
+type gltfExtras map[string]interface{}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices,omitempty"`
+	Material   int            `json:"material"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfMaterial struct {
+	Name string  `json:"name"`
+	Pbr  gltfPbr `json:"pbrMetallicRoughness"`
+}
+
+type gltfPbr struct {
+	BaseColorFactor [4]float32 `json:"baseColorFactor"`
+	MetallicFactor  float32    `json:"metallicFactor"`
+	RoughnessFactor float32    `json:"roughnessFactor"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	Uri        string `json:"uri"`
+}
+
+// Accumulate the binary buffer and its views and accessors
+type gltfBuilder struct {
+	doc *gltfDoc
+	buf bytes.Buffer
+}
+
+func (b *gltfBuilder) addView(data interface{}, target int) int {
+	// Views are aligned on 4 bytes
+	for b.buf.Len()%4 != 0 {
+		b.buf.WriteByte(0)
+	}
+	offset := b.buf.Len()
+	// Writing to a bytes.Buffer does not fail
+	_ = binary.Write(&b.buf, binary.LittleEndian, data)
+	b.doc.BufferViews = append(b.doc.BufferViews, gltfBufferView{ByteOffset: offset, ByteLength: b.buf.Len() - offset, Target: target})
+	return len(b.doc.BufferViews) - 1
+}
+
+func (b *gltfBuilder) addVec3Accessor(vertices []float32) int {
+	min := []float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	max := []float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for i, f := range vertices {
+		if f < min[i%3] {
+			min[i%3] = f
+		}
+		if f > max[i%3] {
+			max[i%3] = f
+		}
+	}
+	view := b.addView(vertices, gltfArrayBuffer)
+	b.doc.Accessors = append(b.doc.Accessors, gltfAccessor{BufferView: view, ComponentType: gltfFloat, Count: len(vertices) / 3, Type: "VEC3", Min: min, Max: max})
+	return len(b.doc.Accessors) - 1
+}
+
+func (b *gltfBuilder) addIndicesAccessor(indices []uint16) int {
+	view := b.addView(indices, gltfElementBuffer)
+	b.doc.Accessors = append(b.doc.Accessors, gltfAccessor{BufferView: view, ComponentType: gltfUnsignedShort, Count: len(indices), Type: "SCALAR"})
+	return len(b.doc.Accessors) - 1
+}
+
+func (b *gltfBuilder) addMesh(name string, primitive gltfPrimitive) int {
+	b.doc.Meshes = append(b.doc.Meshes, gltfMesh{Name: name, Primitives: []gltfPrimitive{primitive}})
+	return len(b.doc.Meshes) - 1
+}
+
+func (b *gltfBuilder) addNode(node gltfNode) int {
+	b.doc.Nodes = append(b.doc.Nodes, node)
+	return len(b.doc.Nodes) - 1
+}
+
+// UV sphere of radius 1, the positions are also the normals
+func makeUnitSphere() ([]float32, []uint16) {
+	positions := make([]float32, 0, (sphereRings+1)*(sphereSegments+1)*3)
+	for ring := 0; ring <= sphereRings; ring++ {
+		theta := math.Pi * float64(ring) / sphereRings
+		for seg := 0; seg <= sphereSegments; seg++ {
+			phi := 2.0 * math.Pi * float64(seg) / sphereSegments
+			positions = append(positions,
+				float32(math.Sin(theta)*math.Cos(phi)), float32(math.Sin(theta)*math.Sin(phi)), float32(math.Cos(theta)))
+		}
+	}
+	indices := make([]uint16, 0, sphereRings*sphereSegments*6)
+	for ring := 0; ring < sphereRings; ring++ {
+		for seg := 0; seg < sphereSegments; seg++ {
+			a := uint16(ring*(sphereSegments+1) + seg)
+			b := a + sphereSegments + 1
+			indices = append(indices, a, b, a+1, a+1, b, b+1)
+		}
+	}
+	return positions, indices
+}
+
+/*
+One glTF JSON file with an embedded buffer.
+All nodes share one sphere per event color material, placed by translation and scale.
+Links are one lines mesh per material.
+*/
+func (scene *SpaceTimeScene) WriteGltf(w io.Writer) error {
+	doc := &gltfDoc{
+		Asset:     gltfAsset{Version: "2.0", Generator: "qsm-go"},
+		Materials: make([]gltfMaterial, nbMaterials),
+	}
+	for i := 0; i < nbMaterials; i++ {
+		c := materialColors[i]
+		doc.Materials[i] = gltfMaterial{Name: materialNames[i], Pbr: gltfPbr{
+			BaseColorFactor: [4]float32{float32(c[0]) / 255.0, float32(c[1]) / 255.0, float32(c[2]) / 255.0, 1.0},
+			MetallicFactor:  0.0,
+			RoughnessFactor: 1.0,
+		}}
+	}
+	b := &gltfBuilder{doc: doc}
+	rootIdx := b.addNode(gltfNode{Name: scene.Name(), Extras: gltfExtras{"space": scene.SpaceName, "time": int(scene.Time)}})
+	children := make([]int, 0, len(scene.Vertices)+nbMaterials)
+
+	positions, indices := makeUnitSphere()
+	spherePositions := b.addVec3Accessor(positions)
+	sphereNormals := b.addVec3Accessor(positions)
+	sphereIndices := b.addIndicesAccessor(indices)
+	sphereMeshes := make(map[int]int, nbMaterials)
+	for _, v := range scene.Vertices {
+		if !v.IsNode {
+			continue
+		}
+		material := colorMaterial(v.ColorMask)
+		mesh, ok := sphereMeshes[material]
+		if !ok {
+			mesh = b.addMesh("node-"+materialNames[material], gltfPrimitive{
+				Attributes: map[string]int{"POSITION": spherePositions, "NORMAL": sphereNormals},
+				Indices:    &sphereIndices,
+				Material:   material,
+				Mode:       gltfTriangles,
+			})
+			sphereMeshes[material] = mesh
+		}
+		radius := float32(nodeRadius)
+		if material == emptyMaterial {
+			radius = emptyNodeRadius
+		}
+		meshIdx := mesh
+		children = append(children, b.addNode(gltfNode{
+			Name:        v.Point.String(),
+			Mesh:        &meshIdx,
+			Translation: []float32{float32(v.Point.X()), float32(v.Point.Y()), float32(v.Point.Z())},
+			Scale:       []float32{radius, radius, radius},
+			Extras:      gltfExtras{"colorMask": v.ColorMask, "root": v.HasRoot},
+		}))
+	}
+
+	linesPerMaterial := make([][]float32, nbMaterials)
+	for _, e := range scene.Edges {
+		material := colorMaterial(e.ColorMask)
+		from := scene.Vertices[e.From].Point
+		to := scene.Vertices[e.To].Point
+		linesPerMaterial[material] = append(linesPerMaterial[material],
+			float32(from.X()), float32(from.Y()), float32(from.Z()), float32(to.X()), float32(to.Y()), float32(to.Z()))
+	}
+	for material, lines := range linesPerMaterial {
+		if len(lines) == 0 {
+			continue
+		}
+		mesh := b.addMesh("links-"+materialNames[material], gltfPrimitive{
+			Attributes: map[string]int{"POSITION": b.addVec3Accessor(lines)},
+			Material:   material,
+			Mode:       gltfLines,
+		})
+		children = append(children, b.addNode(gltfNode{Name: "links-" + materialNames[material], Mesh: &mesh}))
+	}
+
+	doc.Nodes[rootIdx].Children = children
+	doc.Scenes = []gltfScene{{Name: scene.Name(), Nodes: []int{rootIdx}}}
+	doc.Buffers = []gltfBuffer{{
+		ByteLength: b.buf.Len(),
+		Uri:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(b.buf.Bytes()),
+	}}
+	err := json.NewEncoder(w).Encode(doc)
+	if err != nil {
+		return m3util.MakeWrapQsmErrorf(err, "could not write gltf export due to %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package m3space
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/freddy33/qsm-go/model/m3path"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// Only the methods used by the export are implemented, the embedded interfaces are nil
+
+type exportTestSpace struct {
+	SpaceIfc
+}
+
+func (space *exportTestSpace) GetName() string {
+	return "export"
+}
+
+type exportTestNode struct {
+	SpaceTimeNodeIfc
+	p    m3point.Point
+	mask uint8
+}
+
+func (node *exportTestNode) GetPoint() (*m3point.Point, error) {
+	return &node.p, nil
+}
+
+func (node *exportTestNode) GetColorMask() uint8 {
+	return node.mask
+}
+
+func (node *exportTestNode) HasRoot() bool {
+	return node.p == m3point.Origin
+}
+
+type exportTestPointData struct {
+	m3point.PointPackDataIfc
+	base *m3point.BasePointPackData
+}
+
+func (ppd *exportTestPointData) GetConnDetailsById(id m3point.ConnectionId) *m3point.ConnectionDetails {
+	return ppd.base.GetConnDetailsById(id)
+}
+
+type exportTestSpaceTime struct {
+	SpaceTimeIfc
+	nodes []*exportTestNode
+}
+
+func (st *exportTestSpaceTime) GetSpace() SpaceIfc {
+	return &exportTestSpace{}
+}
+
+func (st *exportTestSpaceTime) GetCurrentTime() DistAndTime {
+	return DistAndTime(3)
+}
+
+func (st *exportTestSpaceTime) GetNbActiveNodes() int {
+	return len(st.nodes)
+}
+
+func (st *exportTestSpaceTime) VisitNodes(visitor SpaceTimeNodeVisitor) {
+	for _, node := range st.nodes {
+		visitor.VisitNode(node)
+	}
+}
+
+// Each node links with connection 1 to the next point
+func (st *exportTestSpaceTime) VisitLinks(visitor SpaceTimeLinkVisitor) {
+	for _, node := range st.nodes {
+		visitor.VisitLink(node, node.p, m3point.ConnectionId(1))
+	}
+}
+
+func makeExportTestScene(t *testing.T) *SpaceTimeScene {
+	pointData := &exportTestPointData{base: &m3point.BasePointPackData{
+		AllConnections: []*m3point.ConnectionDetails{
+			{Id: 1, Vector: m3point.Point{1, 1, 0}, ConnDS: 2},
+			{Id: -1, Vector: m3point.Point{-1, -1, 0}, ConnDS: 2},
+		},
+		ConnectionsLoaded: true,
+	}}
+	st := &exportTestSpaceTime{nodes: []*exportTestNode{
+		{p: m3point.Origin, mask: uint8(RedEvent)},
+		{p: m3point.Point{1, 1, 0}, mask: uint8(RedEvent | BlueEvent)},
+	}}
+	scene, err := CollectScene(st, pointData)
+	assert.NoError(t, err)
+	return scene
+}
+
+func TestCollectScene(t *testing.T) {
+	scene := makeExportTestScene(t)
+	assert.Equal(t, "export-3", scene.Name())
+	// The second link ends on a point without node
+	assert.Equal(t, 3, len(scene.Vertices))
+	assert.Equal(t, []SceneEdge{{0, 1, uint8(RedEvent)}, {1, 2, uint8(RedEvent | BlueEvent)}}, scene.Edges)
+	assert.True(t, scene.Vertices[0].HasRoot)
+	assert.True(t, scene.Vertices[1].IsNode)
+	assert.False(t, scene.Vertices[2].IsNode)
+	assert.Equal(t, m3point.Point{2, 2, 0}, scene.Vertices[2].Point)
+	assert.Equal(t, [3]uint8{255, 255, 255}, ColorMaskToRGB(scene.Vertices[1].ColorMask))
+
+	f, err := ParseExportFormat("GLTF")
+	assert.NoError(t, err)
+	assert.Equal(t, GltfFormat, f)
+	_, err = ParseExportFormat("stl")
+	assert.Error(t, err)
+}
+
+func TestExportPlyAndObj(t *testing.T) {
+	scene := makeExportTestScene(t)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, scene.Write(buf, PlyFormat))
+	ply := buf.String()
+	assert.True(t, strings.HasPrefix(ply, "ply\nformat ascii 1.0\n"))
+	assert.Contains(t, ply, "element vertex 3\n")
+	assert.Contains(t, ply, "element edge 2\n")
+	assert.True(t, strings.HasSuffix(ply, "end_header\n0 0 0 255 0 0\n1 1 0 255 255 255\n2 2 0 64 64 64\n0 1 255 0 0\n1 2 255 255 255\n"), ply)
+
+	buf.Reset()
+	assert.NoError(t, scene.Write(buf, ObjFormat))
+	obj := buf.String()
+	assert.Contains(t, obj, "o export-3\n")
+	assert.Contains(t, obj, "v 0 0 0 1.000 0.000 0.000\n")
+	assert.Contains(t, obj, "p 1\np 2\nl 1 2\nl 2 3\n")
+}
+
+func TestExportGltf(t *testing.T) {
+	scene := makeExportTestScene(t)
+	buf := &bytes.Buffer{}
+	assert.NoError(t, scene.Write(buf, GltfFormat))
+
+	doc := &gltfDoc{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), doc))
+	assert.Equal(t, "2.0", doc.Asset.Version)
+	assert.Equal(t, nbMaterials, len(doc.Materials))
+	// Root, 2 nodes and 2 lines for the red and many materials
+	assert.Equal(t, 5, len(doc.Nodes))
+	assert.Equal(t, []int{1, 2, 3, 4}, doc.Nodes[0].Children)
+	assert.Equal(t, []float32{1, 1, 0}, doc.Nodes[2].Translation)
+	assert.Equal(t, 4, len(doc.Meshes))
+	assert.Equal(t, gltfLines, doc.Meshes[2].Primitives[0].Mode)
+
+	assert.Equal(t, 1, len(doc.Buffers))
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(doc.Buffers[0].Uri, "data:application/octet-stream;base64,"))
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Buffers[0].ByteLength, len(data))
+	for _, view := range doc.BufferViews {
+		assert.True(t, view.ByteOffset+view.ByteLength <= len(data))
+		assert.Equal(t, 0, view.ByteOffset%4)
+	}
+	lines := doc.Accessors[doc.Meshes[2].Primitives[0].Attributes["POSITION"]]
+	assert.Equal(t, 2, lines.Count)
+	assert.Equal(t, []float32{0, 0, 0}, lines.Min)
+	assert.Equal(t, []float32{1, 1, 0}, lines.Max)
+}
+
+func TestCollectSpaceTimeGraph(t *testing.T) {
+	pointData := &exportTestPointData{base: &m3point.BasePointPackData{
+		AllConnections: []*m3point.ConnectionDetails{
+			{Id: 1, Vector: m3point.Point{1, 1, 0}, ConnDS: 2},
+			{Id: -1, Vector: m3point.Point{-1, -1, 0}, ConnDS: 2},
+		},
+		ConnectionsLoaded: true,
+	}}
+	st := &exportTestSpaceTime{nodes: []*exportTestNode{
+		{p: m3point.Origin, mask: uint8(RedEvent)},
+		{p: m3point.Point{1, 1, 0}, mask: uint8(RedEvent | BlueEvent)},
+	}}
+	g, err := CollectSpaceTimeGraph(st, pointData)
+	assert.NoError(t, err)
+	assert.Equal(t, "export-3", g.Name)
+	assert.Equal(t, 3, len(g.Nodes))
+	assert.Equal(t, int(RedEvent|BlueEvent), g.Nodes[1].Group)
+	// The end of the last link is not an active node
+	assert.Equal(t, -1, g.Nodes[2].Group)
+	assert.Equal(t, []m3path.GraphLink{{Source: 0, Target: 1, ConnId: 1}, {Source: 1, Target: 2, ConnId: 1}}, g.Links)
+}
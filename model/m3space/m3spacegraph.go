@@ -0,0 +1,75 @@
+package m3space
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3path"
+	"github.com/freddy33/qsm-go/model/m3point"
+)
+
+// Space time nodes giving access to their node events provide the trio ids of the graph nodes
+type eventNodesHolder interface {
+	GetEventNodes() []NodeEventIfc
+}
+
+type spaceTimeGraphCollector struct {
+	graph     *m3path.Graph
+	pointData m3point.PointPackDataIfc
+	err       error
+}
+
+/*
+The graph of the active nodes of the space time grouped by color mask, linked by their active connections.
+Links ending on a point without active node create a node in group -1.
+*/
+func CollectSpaceTimeGraph(spaceTime SpaceTimeIfc, pointData m3point.PointPackDataIfc) (*m3path.Graph, error) {
+	nbNodes := spaceTime.GetNbActiveNodes()
+	if nbNodes < 0 {
+		nbNodes = 0
+	}
+	collector := &spaceTimeGraphCollector{
+		graph:     m3path.MakeGraph(fmt.Sprintf("%s-%d", spaceTime.GetSpace().GetName(), spaceTime.GetCurrentTime()), nbNodes),
+		pointData: pointData,
+	}
+	spaceTime.VisitNodes(collector)
+	if collector.err == nil {
+		spaceTime.VisitLinks(collector)
+	}
+	if collector.err != nil {
+		return nil, collector.err
+	}
+	return collector.graph, nil
+}
+
+func (collector *spaceTimeGraphCollector) VisitNode(node SpaceTimeNodeIfc) {
+	if collector.err != nil {
+		return
+	}
+	p, err := node.GetPoint()
+	if err != nil {
+		collector.err = err
+		return
+	}
+	graphNode := collector.graph.GetOrAddNode(*p)
+	graphNode.Group = int(node.GetColorMask())
+	holder, ok := node.(eventNodesHolder)
+	if ok {
+		for _, evtNode := range holder.GetEventNodes() {
+			graphNode.AddTrioId(evtNode.GetTrioIndex())
+		}
+	}
+}
+
+func (collector *spaceTimeGraphCollector) VisitLink(node SpaceTimeNodeIfc, srcPoint m3point.Point, connId m3point.ConnectionId) {
+	if collector.err != nil {
+		return
+	}
+	cd := collector.pointData.GetConnDetailsById(connId)
+	if cd == nil || cd.Id == m3point.NilConnectionId {
+		collector.err = m3util.MakeQsmErrorf("link from %v has unknown connection id %d", srcPoint, connId)
+		return
+	}
+	source := collector.graph.GetOrAddNode(srcPoint)
+	target := collector.graph.GetOrAddNode(srcPoint.Add(cd.Vector))
+	collector.graph.AddLink(source, target, connId)
+}
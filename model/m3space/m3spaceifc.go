@@ -112,3 +112,17 @@ type NodeEventIfc interface {
 }
 
 
+
+// What is known of one event going through a space time node, for inspection in the UI
+type NodeEventDetails struct {
+	EventId        EventId
+	CreationTime   DistAndTime
+	D              DistAndTime
+	TrioIndex      m3point.TrioIndex
+	ConnectionMask uint16
+}
+
+// Space time nodes able to give the details of their node events
+type SpaceTimeNodeDetailsIfc interface {
+	GetNodeEventDetails() []NodeEventDetails
+}
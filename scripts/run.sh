@@ -1,7 +1,7 @@
 #!/usr/bin/env bash
 
 usage() {
-  echo "Usage qsm run [tidy, build, filldb, gentxt, play, perf]"
+  echo "Usage qsm run [tidy, build, filldb, gentxt, render, export, graph, play, perf]"
   exit 1
 }
 
@@ -47,6 +47,18 @@ build)
 play)
   launch_ui "$@"
   ;;
+render)
+  # Example: qsm run render -space ui3 -from 0 -to 20, add -format svg -angle-step 5 for animated SVG frames
+  cd ${rootDir}/backend && ${go_exe} build && ./backend render "$@"
+  ;;
+export)
+  # Example: qsm run export -space ui3 -time 20 -format ply
+  cd ${rootDir}/backend && ${go_exe} build && ./backend export "$@"
+  ;;
+graph)
+  # Example: qsm run graph -path-ctx 3 -to 6 -format graphml, or -space ui3 -time 20 for a space
+  cd ${rootDir}/backend && ${go_exe} build && ./backend graph "$@"
+  ;;
 gentxt | *filldb | perf)
   cd ${rootDir}/backend && ${go_exe} build && ./backend "$@"
   ;;
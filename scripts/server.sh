@@ -120,7 +120,8 @@ runServer() {
   backendPid="$!"
   echo "INFO: Backend QSM launched PID=$backendPid"
   echo "$backendPid" >"$pidFile"
-  sleep 1
+  port="$(getServerPort)"
+  BACKEND_ROOT_URL="http://localhost:${port}" BACKEND_CHECK_URI="healthz" "${rootDir}/scripts/wait-for-backend.sh" true
 }
 
 runBackend() {
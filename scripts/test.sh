@@ -18,7 +18,7 @@ curDir="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
 . "$curDir/functions.sh"
 
 test_model() {
-  cd ${rootDir}/model && go test ./m3point/
+  cd ${rootDir}/model && go test ./m3point/ ./m3gl/
 }
 
 test_client() {
@@ -30,7 +30,7 @@ test_backend() {
 }
 
 test_ui() {
-  cd ${rootDir}/ui && go test ./m3gl/
+  cd ${rootDir}/ui && go test ./clientgl/
 }
 
 test_perf() {
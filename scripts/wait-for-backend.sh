@@ -0,0 +1,18 @@
+#!/bin/sh
+# wait-for-backend.sh
+
+set -e
+
+cmd="$@"
+
+backendUrl="${BACKEND_ROOT_URL:-http://localhost:8063}"
+checkUri="${BACKEND_CHECK_URI:-readyz}"
+
+sleep 1
+until curl -s -f -o /dev/null "$backendUrl/$checkUri"; do
+  echo >&2 "Backend $backendUrl/$checkUri is unavailable - sleeping"
+  sleep 1
+done
+
+echo >&2 "Backend is up - executing command"
+exec $cmd
@@ -0,0 +1,36 @@
+package clientgl
+
+import (
+	"fmt"
+	"github.com/freddy33/qsm-go/client"
+	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3gl"
+	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3space"
+)
+
+var Log = m3util.NewLogger("clientgl", m3util.INFO)
+
+// World of the space named spaceName on the backend of env, creating the space if it does not exist
+func MakeWorld(env *client.QsmApiEnvironment, spaceName string, Max m3point.CInt, glfwTime float64, activeThreshold m3space.DistAndTime) m3gl.DisplayWorld {
+	if Max%m3point.THREE != 0 {
+		panic(fmt.Sprintf("cannot have a max %d not dividable by %d", Max, m3point.THREE))
+	}
+	spaceData := client.GetClientSpacePackData(env)
+	spaces := spaceData.GetAllSpaces()
+	var space m3space.SpaceIfc
+	var err error
+	for _, sp := range spaces {
+		if sp.GetName() == spaceName {
+			space = sp
+			break
+		}
+	}
+	if space == nil {
+		space, err = spaceData.CreateSpace(spaceName, activeThreshold, 2, 4)
+		if err != nil {
+			Log.Fatal(err)
+		}
+	}
+	return m3gl.MakeWorldFromSpace(client.GetClientPointPackData(env), space, glfwTime)
+}
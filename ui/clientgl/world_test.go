@@ -1,8 +1,9 @@
-package m3gl
+package clientgl
 
 import (
 	"github.com/freddy33/qsm-go/client"
 	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/model/m3gl"
 	"github.com/freddy33/qsm-go/model/m3point"
 	"github.com/freddy33/qsm-go/model/m3space"
 	"github.com/stretchr/testify/assert"
@@ -15,12 +16,17 @@ type ExpectedSpaceState struct {
 }
 
 func getGlTestEnv() *client.QsmApiEnvironment {
-	return client.GetOrCreateInitializedApiEnv(m3util.GlTestEnv, true, true)
+	env, err := client.GetOrCreateInitializedApiEnv(m3util.GlTestEnv, true, true)
+	if err != nil {
+		Log.Fatal(err)
+	}
+	return env
 }
 
 func TestSingleRedEvent(t *testing.T) {
 	Log.SetDebug()
 	m3space.Log.SetDebug()
+	m3gl.Log.SetDebug()
 	m3util.SetToTestMode()
 
 	max := m3space.MinMaxCoord
@@ -43,14 +49,14 @@ func TestSingleRedEvent(t *testing.T) {
 	assertSpaceStates(t, &world, expectedState, 5)
 }
 
-func assertEmptyWorld(t *testing.T, world *DisplayWorld, max m3point.CInt) {
+func assertEmptyWorld(t *testing.T, world *m3gl.DisplayWorld, max m3point.CInt) {
 	assert.Equal(t, max, world.WorldSpace.GetMaxCoord())
 	spaceTime := world.GetSpaceTime()
 	assert.Nil(t, spaceTime)
 	assert.Equal(t, 0, len(world.Elements))
 }
 
-func assertSpaceStates(t *testing.T, world *DisplayWorld, expectMap map[m3space.DistAndTime]ExpectedSpaceState, finalTime m3space.DistAndTime) {
+func assertSpaceStates(t *testing.T, world *m3gl.DisplayWorld, expectMap map[m3space.DistAndTime]ExpectedSpaceState, finalTime m3space.DistAndTime) {
 	expectedTime := m3space.ZeroDistAndTime
 	expect, ok := expectMap[expectedTime]
 	assert.True(t, ok, "Should have the 0 tick time map entry in %v", expectMap)
@@ -84,7 +90,7 @@ func assertSpaceStates(t *testing.T, world *DisplayWorld, expectMap map[m3space.
 	}
 }
 
-func assertSpaceSingleEvent(t *testing.T, world *DisplayWorld, time m3space.DistAndTime, nbNodes, nbConnections int, nbActive int) {
+func assertSpaceSingleEvent(t *testing.T, world *m3gl.DisplayWorld, time m3space.DistAndTime, nbNodes, nbConnections int, nbActive int) {
 	spaceTime := world.GetSpaceTime()
 	assert.Equal(t, time, spaceTime.GetCurrentTime(), "failed at %d", time)
 	assert.Equal(t, nbActive, spaceTime.GetNbActiveNodes(), "failed at %d", time)
@@ -93,10 +99,10 @@ func assertSpaceSingleEvent(t *testing.T, world *DisplayWorld, time m3space.Dist
 	assert.Equal(t, 1, len(world.WorldSpace.GetActiveEventsAt(0)), "failed at %d", time)
 	assert.Equal(t, spaceTime.GetNbActiveNodes()+spaceTime.GetNbActiveLinks()+6, len(world.Elements), "failed at %d", time)
 	nbDisplay := 0
-	collectActiveElements := make([]*NodeDrawingElement, 0, 20)
+	collectActiveElements := make([]*m3gl.NodeDrawingElement, 0, 20)
 	for _, draw := range world.Elements {
-		if draw.Key() == NodeActive {
-			nodeDrawing, ok := draw.(*NodeDrawingElement)
+		if draw.Key() == m3gl.NodeActive {
+			nodeDrawing, ok := draw.(*m3gl.NodeDrawingElement)
 			assert.True(t, ok, "Node draw element should be of type NodeDrawingElement not %v", draw)
 			collectActiveElements = append(collectActiveElements, nodeDrawing)
 		}
@@ -110,6 +116,8 @@ func assertSpaceSingleEvent(t *testing.T, world *DisplayWorld, time m3space.Dist
 	assert.Equal(t, 6+nbActive, nbDisplay, "failed at %d", time)
 	assert.Equal(t, nbActive, len(collectActiveElements), "failed at %d", time)
 	for _, nodeDraw := range collectActiveElements {
-		assert.Equal(t, uint8(1), nodeDraw.sdc.howManyColors(), "failed at %d", time)
+		// Only the red event color whatever the blink value
+		assert.Equal(t, int32(m3space.RedEvent), nodeDraw.Color(0), "failed at %d", time)
+		assert.Equal(t, int32(m3space.RedEvent), nodeDraw.Color(1), "failed at %d", time)
 	}
 }
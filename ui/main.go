@@ -1,12 +1,16 @@
 package main
 
 import (
-	"github.com/freddy33/qsm-go/ui/playgl"
+	"flag"
 	"github.com/freddy33/qsm-go/m3util"
+	"github.com/freddy33/qsm-go/ui/playgl"
 )
 
 func main() {
-	m3util.ReadVerbose()
+	others := m3util.ReadVerbose()
 	defer m3util.CloseAll()
-	playgl.Play()
+	flags := flag.NewFlagSet("ui", flag.ExitOnError)
+	spaceName := flags.String("space", "ui3", "The space displayed first, created if missing. The others are in the panel opened with TAB")
+	_ = flags.Parse(others)
+	playgl.Play(*spaceName)
 }
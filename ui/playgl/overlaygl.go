@@ -0,0 +1,124 @@
+package playgl
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"image"
+)
+
+// Texture of text drawn on top of the world at the top left corner
+type overlayGl struct {
+	prog           uint32
+	vao            uint32
+	vbo            uint32
+	texture        uint32
+	textureUniform int32
+	width          int
+	height         int
+}
+
+var overlayVertexShader = `
+#version 330
+
+in vec2 pos;
+in vec2 uv;
+
+out vec2 s_uv;
+
+void main() {
+    s_uv = uv;
+    gl_Position = vec4(pos, 0, 1);
+}
+` + "\x00"
+
+var overlayFragmentShader = `
+#version 330
+
+uniform sampler2D text;
+
+in vec2 s_uv;
+
+out vec4 out_color;
+
+void main() {
+    out_color = texture(text, s_uv);
+}
+` + "\x00"
+
+func newOverlayGl() (*overlayGl, error) {
+	prog, err := newProgram(overlayVertexShader, overlayFragmentShader)
+	if err != nil {
+		return nil, err
+	}
+	o := &overlayGl{prog: prog}
+	o.textureUniform = gl.GetUniformLocation(prog, gl.Str("text\x00"))
+	gl.BindFragDataLocation(prog, 0, gl.Str("out_color\x00"))
+
+	var previousVbo int32
+	gl.GetIntegerv(gl.ARRAY_BUFFER_BINDING, &previousVbo)
+	gl.GenVertexArrays(1, &o.vao)
+	gl.BindVertexArray(o.vao)
+	gl.GenBuffers(1, &o.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, o.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, 4*4*4, nil, gl.DYNAMIC_DRAW)
+	posAttrib := uint32(gl.GetAttribLocation(prog, gl.Str("pos\x00")))
+	gl.EnableVertexAttribArray(posAttrib)
+	gl.VertexAttribPointer(posAttrib, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(0))
+	uvAttrib := uint32(gl.GetAttribLocation(prog, gl.Str("uv\x00")))
+	gl.EnableVertexAttribArray(uvAttrib)
+	gl.VertexAttribPointer(uvAttrib, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(2*4))
+	// The world buffer is refilled on key press with the bound array buffer
+	gl.BindBuffer(gl.ARRAY_BUFFER, uint32(previousVbo))
+
+	gl.GenTextures(1, &o.texture)
+	gl.BindTexture(gl.TEXTURE_2D, o.texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	return o, nil
+}
+
+// Replace the texture by the image, nil hides the overlay
+func (o *overlayGl) update(img *image.RGBA) {
+	if img == nil {
+		o.width, o.height = 0, 0
+		return
+	}
+	o.width = img.Rect.Dx()
+	o.height = img.Rect.Dy()
+	gl.BindTexture(gl.TEXTURE_2D, o.texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(o.width), int32(o.height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+}
+
+// Draw the texture pixel per pixel at the top left corner of the framebuffer
+func (o *overlayGl) draw(fbWidth, fbHeight int) {
+	if o.width == 0 || o.height == 0 {
+		return
+	}
+	x1 := -1.0 + 2.0*float32(o.width)/float32(fbWidth)
+	y1 := 1.0 - 2.0*float32(o.height)/float32(fbHeight)
+	vertices := []float32{
+		-1.0, 1.0, 0.0, 0.0,
+		-1.0, y1, 0.0, 1.0,
+		x1, 1.0, 1.0, 0.0,
+		x1, y1, 1.0, 1.0,
+	}
+
+	var previousVbo int32
+	gl.GetIntegerv(gl.ARRAY_BUFFER_BINDING, &previousVbo)
+	gl.UseProgram(o.prog)
+	gl.BindVertexArray(o.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, o.vbo)
+	gl.BufferSubData(gl.ARRAY_BUFFER, 0, len(vertices)*4, gl.Ptr(vertices))
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, o.texture)
+	gl.Uniform1i(o.textureUniform, 0)
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
+	gl.Disable(gl.BLEND)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.BindBuffer(gl.ARRAY_BUFFER, uint32(previousVbo))
+}
@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"github.com/freddy33/qsm-go/client"
 	"github.com/freddy33/qsm-go/m3util"
-	"github.com/freddy33/qsm-go/model/m3point"
+	"github.com/freddy33/qsm-go/model/m3gl"
 	"github.com/freddy33/qsm-go/model/m3space"
-	"github.com/freddy33/qsm-go/ui/m3gl"
+	"github.com/freddy33/qsm-go/ui/clientgl"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
-	"github.com/go-gl/mathgl/mgl32"
+	"math"
 	"runtime"
 	"strings"
 )
@@ -19,10 +19,37 @@ var Log = m3util.NewLogger("playgl", m3util.INFO)
 const windowWidth = 800
 const windowHeight = 600
 
+const (
+	// Radians turned per pixel of mouse drag
+	dragAngle = 0.005
+	// Under this number of pixels between press and release the left button selects
+	clickSlop = 3.0
+	// Zoom ratio per scroll step
+	scrollZoom = 0.9
+	// Units moved per fly key press
+	flyStep = 0.5
+)
+
 // TODO: Is there another way than global?
 var world m3gl.DisplayWorld
+var overlay *overlayGl
+var panel *m3gl.SpacePanel
+
+// The mouse button pressed and the cursor positions in framebuffer pixels
+type mouseDrag struct {
+	pressed        bool
+	button         glfw.MouseButton
+	startX, startY float64
+	lastX, lastY   float64
+}
 
-func Play() {
+var drag mouseDrag
+
+/*
+Display the space of the name, created if it does not exist.
+The space panel opened with TAB manages the spaces and events.
+*/
+func Play(spaceName string) {
 	runtime.LockOSThread()
 	if err := glfw.Init(); err != nil {
 		Log.Fatalf("could not initialize glfw: %v", err)
@@ -47,26 +74,16 @@ func Play() {
 	Log.Info("Renderer:", gl.GoStr(gl.GetString(gl.RENDERER)))
 	Log.Info("OpenGL version supported:", gl.GoStr(gl.GetString(gl.VERSION)))
 
-	// ******************************************************************
-	//    HERE CHANGE THE SIZE
-	// ******************************************************************
-	max := m3space.MinMaxCoord
-	env := client.GetInitializedApiEnv(m3util.GetDefaultEnvId())
-	world = m3gl.MakeWorld(env, "ui3", max, glfw.GetTime(), 3)
-	events := world.WorldSpace.GetActiveEventsAt(m3space.ZeroDistAndTime)
-	if len(events) == 0 {
-		m3gl.CreatePyramidWithParams(world.WorldSpace, 4, [4]m3point.GrowthType{8, 8, 8, 8}, [4]int{0, 0, 0, 0}, [4]int{0, 2, 4, 6})
-		/*
-			_, err = world.WorldSpace.CreateEvent(m3point.GrowthType(8), 0, 0, m3space.ZeroDistAndTime, m3point.Origin, m3space.RedEvent)
-			if err != nil {
-				Log.Fatal(err)
-				return
-			}
-		*/
+	env, err := client.GetInitializedApiEnv(m3util.GetDefaultEnvId())
+	if err != nil {
+		Log.Fatal(err)
 	}
+	world = clientgl.MakeWorld(env, spaceName, m3space.MinMaxCoord, glfw.GetTime(), 3)
 	world.CurrentTime = m3space.ZeroDistAndTime
 	world.CurrentSpaceTime = nil
 	world.CreateDrawingElements()
+	win.SetTitle(windowTitle())
+	panel = m3gl.MakeSpacePanel(client.GetClientSpacePackData(env))
 
 	// Configure the vertex and fragment shaders
 	prog, err := newProgram(vertexShaderFull, fragmentShader)
@@ -75,6 +92,10 @@ func Play() {
 	}
 
 	win.SetKeyCallback(onKey)
+	win.SetCharCallback(onChar)
+	win.SetMouseButtonCallback(onMouseButton)
+	win.SetCursorPosCallback(onCursorPos)
+	win.SetScrollCallback(onScroll)
 
 	projectionUniform := gl.GetUniformLocation(prog, gl.Str("projection\x00"))
 	cameraUniform := gl.GetUniformLocation(prog, gl.Str("camera\x00"))
@@ -94,7 +115,7 @@ func Play() {
 	gl.GenBuffers(1, &vbo)
 	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
 	Log.Info("Nb vertices", world.NbVertices, ", total size", len(world.OpenGLBuffer))
-	gl.BufferData(gl.ARRAY_BUFFER, world.NbVertices*m3gl.FloatPerVertices*m3gl.FloatSize, gl.Ptr(world.OpenGLBuffer), gl.STATIC_DRAW)
+	uploadBuffer()
 
 	vertAttrib := uint32(gl.GetAttribLocation(prog, gl.Str("vert\x00")))
 	gl.EnableVertexAttribArray(vertAttrib)
@@ -104,6 +125,16 @@ func Play() {
 	gl.EnableVertexAttribArray(normAttrib)
 	gl.VertexAttribPointer(normAttrib, 3, gl.FLOAT, true, m3gl.FloatPerVertices*m3gl.FloatSize, gl.PtrOffset(3*m3gl.FloatSize))
 
+	overlay, err = newOverlayGl()
+	if err != nil {
+		Log.Fatal(err)
+	}
+	if len(world.WorldSpace.GetActiveEventsAt(m3space.ZeroDistAndTime)) == 0 {
+		panel.Switch(&world)
+		panel.Message = fmt.Sprintf("No events in %s, press A to add one or P for a pyramid", world.WorldSpace.GetName())
+		refreshOverlay()
+	}
+
 	// Configure global settings
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
@@ -130,17 +161,19 @@ func Play() {
 		for _, obj := range world.Elements {
 			if obj != nil && obj.Display(world.Filter) {
 				toDraw := world.DrawingElementsMap[obj.Key()]
-				world.Model = mgl32.HomogRotate3D(float32(world.Angle.Value), mgl32.Vec3{0, 0, 1})
-				world.Model = world.Model.Mul4(mgl32.Translate3D(float32(obj.Pos().X()), float32(obj.Pos().Y()), float32(obj.Pos().Z())))
+				world.Model = world.ElementModel(obj)
 				gl.UniformMatrix4fv(modelUniform, 1, false, &(world.Model[0]))
 
-				gl.Uniform1i(colorUniform, obj.Color(world.Blinker.Value))
-				gl.Uniform1f(colorDimmerUniform, obj.Dimmer(world.Blinker.Value))
+				objColor, objDimmer := world.ElementColor(obj)
+				gl.Uniform1i(colorUniform, objColor)
+				gl.Uniform1f(colorDimmerUniform, objDimmer)
 
 				gl.DrawArrays(gl.TRIANGLES, toDraw.OpenGLOffset, toDraw.NbVertices)
 			}
 		}
 
+		overlay.draw(world.Width, world.Height)
+
 		win.SwapBuffers()
 		glfw.PollEvents()
 	}
@@ -148,6 +181,20 @@ func Play() {
 }
 
 func onKey(win *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action == glfw.Press && key == glfw.KeyTab {
+		panel.Switch(&world)
+		refreshOverlay()
+		return
+	}
+	if panel.Visible() {
+		if action == glfw.Press || action == glfw.Repeat {
+			onPanelKey(win, key)
+		}
+		return
+	}
+	if (action == glfw.Press || action == glfw.Repeat) && onFlyKey(key) {
+		return
+	}
 	if action == glfw.Press {
 		displaySettings := true
 		reCalc := false
@@ -165,9 +212,10 @@ func onKey(win *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mod
 		case glfw.KeyRight:
 			world.ForwardTime()
 			if world.CheckMax() {
-				gl.BufferData(gl.ARRAY_BUFFER, world.NbVertices*m3gl.FloatPerVertices*4, gl.Ptr(world.OpenGLBuffer), gl.STATIC_DRAW)
+				uploadBuffer()
 			}
 			world.CreateDrawingElements()
+			refreshOverlay()
 			displaySettings = false
 
 		case glfw.KeyN:
@@ -202,6 +250,44 @@ func onKey(win *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mod
 			world.EyeDist.Decrease()
 			reCalc = true
 
+		case glfw.KeyV:
+			world.SwitchCameraMode()
+			world.DisplayCameraSettings()
+			displaySettings = false
+		case glfw.KeyH:
+			world.ResetView()
+			displaySettings = false
+		case glfw.KeyE:
+			evt, err := world.FocusNextEventCenter()
+			if err != nil {
+				Log.Error(err)
+			} else if evt != nil {
+				Log.Infof("Focus on center of %s", evt.String())
+			}
+			displaySettings = false
+		case glfw.KeyM:
+			if !world.FocusMeetingPoint() {
+				Log.Infof("Events did not meet at time %d", world.CurrentTime)
+			}
+			displaySettings = false
+
+		case glfw.KeyK:
+			world.Filter.Clip.Switch(world.Max)
+		case glfw.KeyJ:
+			world.Filter.Clip.NextAxe()
+		case glfw.KeyLeftBracket:
+			world.Filter.Clip.MoveMin(-1)
+		case glfw.KeyRightBracket:
+			world.Filter.Clip.MoveMin(1)
+		case glfw.KeyMinus:
+			world.Filter.Clip.MoveMax(-1)
+		case glfw.KeyEqual:
+			world.Filter.Clip.MoveMax(1)
+		case glfw.KeyComma:
+			world.Filter.Clip.Slide(-1)
+		case glfw.KeyPeriod:
+			world.Filter.Clip.Slide(1)
+
 		case glfw.KeyB:
 			m3gl.LineWidth.Increase()
 			reCalc = true
@@ -230,12 +316,157 @@ func onKey(win *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mod
 	}
 }
 
+// While the panel is visible it gets all the keys, the letters come from the char callback
+func onPanelKey(win *glfw.Window, key glfw.Key) {
+	var panelKey m3gl.PanelKey
+	switch key {
+	case glfw.KeyUp:
+		panelKey = m3gl.PanelUp
+	case glfw.KeyDown:
+		panelKey = m3gl.PanelDown
+	case glfw.KeyLeft:
+		panelKey = m3gl.PanelLeft
+	case glfw.KeyRight:
+		panelKey = m3gl.PanelRight
+	case glfw.KeyEnter, glfw.KeyKPEnter:
+		panelKey = m3gl.PanelEnter
+	case glfw.KeyEscape:
+		panelKey = m3gl.PanelCancel
+	case glfw.KeyBackspace:
+		panelKey = m3gl.PanelBackspace
+	case glfw.KeyDelete:
+		panelKey = m3gl.PanelDelete
+	default:
+		return
+	}
+	if panel.Key(&world, panelKey) {
+		worldChanged(win)
+	}
+	refreshOverlay()
+}
+
+func onChar(win *glfw.Window, char rune) {
+	if !panel.Visible() {
+		return
+	}
+	if panel.Char(&world, char) {
+		worldChanged(win)
+	}
+	refreshOverlay()
+}
+
+// After the panel displayed another space or added events
+func worldChanged(win *glfw.Window) {
+	uploadBuffer()
+	win.SetTitle(windowTitle())
+}
+
+func windowTitle() string {
+	return fmt.Sprintf("QSM %s", world.WorldSpace.GetName())
+}
+
+// A space without events has no buffer until its first event
+func uploadBuffer() {
+	if world.NbVertices == 0 {
+		return
+	}
+	gl.BufferData(gl.ARRAY_BUFFER, world.NbVertices*m3gl.FloatPerVertices*m3gl.FloatSize, gl.Ptr(world.OpenGLBuffer), gl.STATIC_DRAW)
+}
+
+// In fly mode the arrows, A, D and the page keys move the eye, true if the key was used
+func onFlyKey(key glfw.Key) bool {
+	if world.View.Mode != m3gl.FlyCamera {
+		return false
+	}
+	switch key {
+	case glfw.KeyUp:
+		world.Fly(flyStep, 0.0, 0.0)
+	case glfw.KeyDown:
+		world.Fly(-flyStep, 0.0, 0.0)
+	case glfw.KeyA:
+		world.Fly(0.0, -flyStep, 0.0)
+	case glfw.KeyD:
+		world.Fly(0.0, flyStep, 0.0)
+	case glfw.KeyPageUp:
+		world.Fly(0.0, 0.0, flyStep)
+	case glfw.KeyPageDown:
+		world.Fly(0.0, 0.0, -flyStep)
+	default:
+		return false
+	}
+	return true
+}
+
+/*
+Dragging with the left button turns the view and with the other buttons pans it.
+A left click without moving selects the node under the cursor and shows its details, or clears the selection.
+*/
+func onMouseButton(win *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+	x, y := framebufferCursorPos(win)
+	if action == glfw.Press {
+		drag = mouseDrag{true, button, x, y, x, y}
+		return
+	}
+	if action != glfw.Release || !drag.pressed || drag.button != button {
+		return
+	}
+	drag.pressed = false
+	if button != glfw.MouseButtonLeft || math.Hypot(x-drag.startX, y-drag.startY) > clickSlop {
+		return
+	}
+	n := world.SelectNodeAt(x, y)
+	if n != nil {
+		Log.Infof("Selected %s", n.Node().GetStateString())
+	}
+	refreshOverlay()
+}
+
+func onCursorPos(win *glfw.Window, xpos float64, ypos float64) {
+	if !drag.pressed {
+		return
+	}
+	x, y := framebufferCursorPos(win)
+	dx, dy := x-drag.lastX, y-drag.lastY
+	drag.lastX, drag.lastY = x, y
+	if drag.button == glfw.MouseButtonLeft {
+		world.Orbit(-dx*dragAngle, dy*dragAngle)
+	} else {
+		world.PanPixels(dx, dy)
+	}
+}
+
+func onScroll(win *glfw.Window, xoff float64, yoff float64) {
+	world.Zoom(math.Pow(scrollZoom, yoff))
+}
+
+// The cursor position in framebuffer pixels, different from the window ones on Retina displays
+func framebufferCursorPos(win *glfw.Window) (float64, float64) {
+	x, y := win.GetCursorPos()
+	winWidth, winHeight := win.GetSize()
+	fbWidth, fbHeight := win.GetFramebufferSize()
+	if winWidth > 0 && winHeight > 0 {
+		x *= float64(fbWidth) / float64(winWidth)
+		y *= float64(fbHeight) / float64(winHeight)
+	}
+	return x, y
+}
+
+// The panel on top of the selected node details
+func refreshOverlay() {
+	lines := panel.Lines(&world)
+	selection := world.DescribeSelection()
+	if len(lines) > 0 && len(selection) > 0 {
+		lines = append(lines, "")
+	}
+	overlay.update(m3gl.RenderOverlayText(append(lines, selection...)))
+}
+
 func recalc(fill bool) {
 	world.DisplaySettings()
 	world.SetMatrices()
 	if fill {
 		world.CreateDrawingElementsMap()
-		gl.BufferData(gl.ARRAY_BUFFER, world.NbVertices*m3gl.FloatPerVertices*4, gl.Ptr(world.OpenGLBuffer), gl.STATIC_DRAW)
+		uploadBuffer()
 	}
 }
 